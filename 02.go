@@ -2,28 +2,42 @@ package main
 
 import (
 	"flag"
-	"fmt"
 	"log"
+	"os"
 	"sync"
 
+	"github.com/brnstz/routine/term"
 	"github.com/brnstz/routine/wikimg"
 )
 
 var (
-	// Print a blank line with the given 256 ANSI color
-	fmtSpec = "\x1b[30;48;5;%dm%-80s\x1b[0m\n"
+	// Print blank lines with 256 ANSI colors (or 16 on consoles that only
+	// have those, or plain hex values when not printing colors)
+	renderer *term.Renderer
 )
 
 func main() {
-	var max, workers, buffer int
+	var max, workers, buffer, stride int
+	var colorMode string
 
 	flag.IntVar(&max, "max", 100, "maximum number of images to retrieve")
 	flag.IntVar(&workers, "workers", 25, "number of background workers")
 	flag.IntVar(&buffer, "buffer", 10000, "size of buffered channels")
+	flag.IntVar(&stride, "stride", 1, "scan every Nth pixel of each image")
+	flag.StringVar(&colorMode, "color", "auto", "print colors: always, never or auto")
 	flag.Parse()
 
+	mode, err := term.ParseMode(colorMode)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Create a renderer for printing colors to stdout
+	renderer = term.NewRenderer(os.Stdout, mode)
+
 	// Create a new image puller with our max
 	p := wikimg.NewPuller(max)
+	p.Options.Stride = stride
 
 	// Create a buffered channel for communicating between image
 	// puller loop and workers
@@ -41,14 +55,14 @@ func main() {
 			for imgURL := range imgURLs {
 
 				// Get the first color in this image
-				color, _, err := p.FirstColor(imgURL)
+				info, err := p.FirstColor(imgURL)
 				if err != nil {
 					log.Println(err)
 					continue
 				}
 
 				// Print color to the terminal
-				fmt.Printf(fmtSpec, color, "")
+				renderer.Bar(info)
 			}
 
 			// Once there is nothing else in imgURLs, this goroutine
@@ -9,14 +9,10 @@ import (
 
 	"golang.org/x/net/context"
 
+	"github.com/brnstz/routine/server"
 	"github.com/brnstz/routine/wikimg"
 )
 
-var (
-	// Print an HTML div with the hex background
-	fmtSpec = `<div style="background: %s; width=100%%">&nbsp;</div>`
-)
-
 // imgRequest is a request to get the first color from a URL
 type imgRequest struct {
 	p         *wikimg.Puller
@@ -35,11 +31,11 @@ type imgResponse struct {
 func worker(in chan *imgRequest) {
 	for req := range in {
 		// Get the first color in this image
-		_, hex, err := req.p.FirstColor(req.url)
+		info, err := req.p.FirstColor(req.url)
 
 		// Create a response object
 		resp := imgResponse{
-			hex: hex,
+			hex: info.Hex,
 			err: err,
 		}
 
@@ -49,12 +45,13 @@ func worker(in chan *imgRequest) {
 }
 
 func main() {
-	var max, workers, buffer, port int
+	var max, workers, buffer, port, stride int
 
-	flag.IntVar(&max, "max", 100, "maximum number of images per request")
+	flag.IntVar(&max, "max", 100, "most images a request can ask for with ?max=")
 	flag.IntVar(&workers, "workers", 25, "number of background workers")
 	flag.IntVar(&buffer, "buffer", 10000, "size of buffered channels")
 	flag.IntVar(&port, "port", 8000, "HTTP port to listen on")
+	flag.IntVar(&stride, "stride", 1, "scan every Nth pixel of each image")
 	flag.Parse()
 
 	// Create a buffered channel for communicating between image
@@ -67,11 +64,21 @@ func main() {
 	}
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// Create a new image puller with our max
-		p := wikimg.NewPuller(max)
+		// Clients can ask for fewer images, a shorter timeout and another
+		// format, e.g., /?max=10&timeout=5s&format=json, but never more
+		// than our max
+		params, err := server.ParseParams(r, server.Params{Max: max, Timeout: 20 * time.Second})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		// Create a context with a 20 second timeout
-		ctx, _ := context.WithTimeout(context.Background(), time.Second*20)
+		// Create a new image puller with the request's max
+		p := wikimg.NewPuller(params.Max)
+		p.Options.Stride = stride
+
+		// Create a context with the request's timeout, 20 seconds at most
+		ctx, _ := context.WithTimeout(context.Background(), params.Timeout)
 
 		// Set puller's Cancel channel, so it will be closed when the
 		// context times out
@@ -79,14 +86,11 @@ func main() {
 
 		// Create a channel for receiving responses specific
 		// to this HTTP request
-		responses := make(chan imgResponse, max)
+		responses := make(chan imgResponse, params.Max)
 
-		// Assert our writer to a flusher, so we can stream line by line
-		f, ok := w.(http.Flusher)
-		if !ok {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+		// Stream colors line by line in the client's format
+		cw := server.NewColorWriter(w, params.Format)
+		defer cw.Close()
 
 		// Loop to retrieve more images
 		for {
@@ -110,7 +114,7 @@ func main() {
 			}
 		}
 
-		for i := 0; i < max; i++ {
+		for i := 0; i < params.Max; i++ {
 			// Read a response from the channel
 			resp := <-responses
 
@@ -121,9 +125,7 @@ func main() {
 			}
 
 			// Write a line of color
-			fmt.Fprintf(w, fmtSpec, resp.hex)
-			fmt.Fprintln(w)
-			f.Flush()
+			cw.Write(resp.hex)
 		}
 	})
 
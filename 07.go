@@ -1,206 +1,109 @@
 package main
 
 import (
-	"container/list"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
-	"sync"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"golang.org/x/net/context"
 
+	"github.com/brnstz/routine/config"
+	"github.com/brnstz/routine/server"
 	"github.com/brnstz/routine/wikimg"
 )
 
-var (
-	// Print an HTML div with the hex background
-	fmtSpec = `<div style="background: %s; width=100%%">&nbsp;</div>`
-
-	// cache is our global cache of urls to imgResponse values
-	cache = newColorCache(50000)
-)
-
-// colorCache is a cache of recent URLs to imgResponse values. It expires older
-// URLs once it contains the maximum number of values.
-type colorCache struct {
-	hmap  map[string]imgResponse
-	count int
-	max   int
-	mutex sync.RWMutex
-	exp   *list.List
-}
-
-// newColorCache creates colorCache that holds max items.
-func newColorCache(max int) *colorCache {
-	return &colorCache{
-		hmap:  map[string]imgResponse{},
-		count: 0,
-		max:   max,
-		mutex: sync.RWMutex{},
-		exp:   list.New(),
-	}
-}
-
-// Add saves a url and its response to our cache
-func (cc *colorCache) Add(url string, resp imgResponse) {
-	// Lock the cache while we're adding
-	cc.mutex.Lock()
-
-	if cc.count >= cc.max {
-		// If we've exceeded the max size, we must remove the oldest
-		// element
-
-		// Find the last element
-		back := cc.exp.Back()
-
-		// Remove it from the cache
-		delete(cc.hmap, back.Value.(string))
-
-		// Also remove it from the exp list
-		cc.exp.Remove(back)
-	} else {
-
-		// Otherwise, we didn't remove anything so increment count
-		cc.count++
-	}
-
-	// Add new url to be last to expire
-	cc.exp.PushFront(url)
-
-	// Save its value
-	cc.hmap[url] = resp
-
-	// Done locking
-	cc.mutex.Unlock()
-}
-
-// Get retrieves an imgResponse by its url, returning whether it was found or
-// not as the second value
-func (cc *colorCache) Get(url string) (imgResponse, bool) {
-	cc.mutex.RLock()
-
-	// Get it within read lock
-	resp, ok := cc.hmap[url]
-
-	cc.mutex.RUnlock()
-
-	return resp, ok
-}
-
-// imgRequest is a request to get the first color from a URL
-type imgRequest struct {
-	p         *wikimg.Puller
-	url       string
-	responses chan imgResponse
-}
-
-// imgResponse contains the result of processing an imgRequest
-type imgResponse struct {
-	hex string
-	err error
-}
-
-// worker takes imgRequests on the in channel, processes them and sends
-// an imgResponse back on the request's channel
-func worker(in chan *imgRequest) {
-	for req := range in {
-		var resp imgResponse
-
-		// Check cache first
-		resp, ok := cache.Get(req.url)
-
-		if !ok {
-
-			// It wasn't in the cache, so actually get it and add it
-			_, resp.hex, resp.err = req.p.FirstColor(req.url)
-			cache.Add(req.url, resp)
-		}
-
-		// Send it back on our response channel
-		req.responses <- resp
-	}
-}
-
 func main() {
-	var max, workers, buffer, port int
+	var max, workers, port, stride, burst, concurrent int
+	var rate float64
+	var timeout time.Duration
+	var configFile string
 
-	flag.IntVar(&max, "max", 100, "maximum number of images per request")
+	flag.IntVar(&max, "max", 100, "most images a request can ask for with ?max=")
 	flag.IntVar(&workers, "workers", 25, "number of background workers")
-	flag.IntVar(&buffer, "buffer", 10000, "size of buffered channels")
 	flag.IntVar(&port, "port", 8000, "HTTP port to listen on")
+	flag.IntVar(&stride, "stride", 1, "scan every Nth pixel of each image")
+	flag.DurationVar(&timeout, "timeout", 20*time.Second, "how long each HTTP request may take")
+	flag.Float64Var(&rate, "rate", 0.2, "requests per second allowed from each IP address on average")
+	flag.IntVar(&burst, "burst", 5, "requests allowed from each IP address at once")
+	flag.IntVar(&concurrent, "concurrent", 20, "maximum number of requests handled at once")
+	flag.StringVar(&configFile, "config", "", "YAML, TOML or JSON file of flag settings (see the config package)")
 	flag.Parse()
 
-	// Create a buffered channel for communicating between image
-	// puller loop and workers
-	imgReqs := make(chan *imgRequest, buffer)
-
-	// Create workers
-	for i := 0; i < workers; i++ {
-		go worker(imgReqs)
+	// Settings not given as flags can come from the config file or
+	// WIKIMG_ environment variables, e.g., WIKIMG_PORT=8080
+	err := config.Load(flag.CommandLine, configFile, "WIKIMG_")
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// Create a new image puller with our max
-		p := wikimg.NewPuller(max)
+	// The server pulls and analyzes the latest images in the background
+	// with its pool of workers, keeping their colors in its cache
+	s := server.New(50000, 0)
+	s.Max = max
+	s.Workers = workers
+	s.NewPuller = func(n int) *wikimg.Puller {
+		p := wikimg.NewPuller(n)
+		p.Options.Stride = stride
 
-		// Create a context with a 20 second timeout
-		ctx, _ := context.WithTimeout(context.Background(), time.Second*20)
+		return p
+	}
 
-		// Set puller's Cancel channel, so it will be closed when the
-		// context times out
-		p.Cancel = ctx.Done()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cycles := make(chan struct{})
+	go func() {
+		defer close(cycles)
+		s.Run(ctx)
+	}()
+
+	// Clients can ask for fewer colors and another format, e.g.,
+	// /?max=10&format=json, but never more than our max. Requests are
+	// answered from the cache, but limit how often each client can make
+	// one and how many run at once anyway.
+	srv := &http.Server{
+		Addr: fmt.Sprintf(":%d", port),
+		Handler: server.Chain(s.Handler(),
+			server.RateLimit(rate, burst),
+			server.MaxConcurrent(concurrent),
+			server.Timeout(timeout),
+		),
+	}
 
-		// Create a channel for receiving responses specific
-		// to this HTTP request
-		responses := make(chan imgResponse, max)
+	// On SIGINT or SIGTERM, stop accepting requests and let the ones in
+	// progress finish, which takes at most our timeout. Then cancel the
+	// background cycle and wait for it to stop.
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
 
-		// Assert our writer to a flusher, so we can stream line by line
-		f, ok := w.(http.Flusher)
-		if !ok {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		<-ctx.Done()
+		stop()
+		log.Println("shutting down")
 
-		// Loop to retrieve more images
-		for {
-			imgURL, err := p.Next()
-
-			if err == wikimg.EndOfResults {
-				// Break from loop when end of results is reached
-				break
-
-			} else if err != nil {
-				// Send error on the response channel and continue
-				responses <- imgResponse{err: err}
-				continue
-			}
-
-			// Create request and send on the global channel
-			imgReqs <- &imgRequest{
-				p:         p,
-				url:       imgURL,
-				responses: responses,
-			}
-		}
+		ctx, cancelShutdown := context.WithTimeout(context.Background(), timeout+5*time.Second)
+		defer cancelShutdown()
 
-		for i := 0; i < max; i++ {
-			// Read a response from the channel
-			resp := <-responses
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			log.Println(err)
+		}
 
-			// If there's an error, just log it on the server
-			if resp.err != nil {
-				log.Println(resp.err)
-				continue
-			}
+		cancel()
+		<-cycles
+	}()
 
-			// Write a line of color
-			fmt.Fprintf(w, fmtSpec, resp.hex)
-			fmt.Fprintln(w)
-			f.Flush()
-		}
-	})
+	err = srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
 
-	http.ListenAndServe(fmt.Sprintf(":%d", port), nil)
+	<-stopped
 }
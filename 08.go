@@ -1,253 +1,243 @@
 package main
 
 import (
-	"container/list"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
-	"sync"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"golang.org/x/net/context"
 
+	"github.com/brnstz/routine/config"
+	"github.com/brnstz/routine/lifecycle"
+	"github.com/brnstz/routine/schedule"
+	"github.com/brnstz/routine/server"
 	"github.com/brnstz/routine/wikimg"
+	"github.com/brnstz/routine/wikimg/boltcache"
+	"github.com/brnstz/routine/wikimg/metrics"
+	"github.com/brnstz/routine/wikimg/rediscache"
 )
 
-var (
-	// Print an HTML div with the hex background, also make it link
-	// to the image itself
-	fmtSpec = `<a style="text-decoration: none" href="%s"><div style="background: %s; width=100%%">&nbsp;</div></a>`
-
-	// cache is our global cache of urls to imgResponse values
-	cache *colorCache
-)
-
-// colorCache is a cache of recent URLs to imgResponse values. It expires older
-// URLs once it contains the maximum number of values.
-type colorCache struct {
-	hmap  map[string]imgResponse
-	count int
-	max   int
-	mutex sync.RWMutex
-	exp   *list.List
-}
-
-// newColorCache creates colorCache that holds max items.
-func newColorCache(max int) *colorCache {
-	return &colorCache{
-		hmap:  map[string]imgResponse{},
-		count: 0,
-		max:   max,
-		mutex: sync.RWMutex{},
-		exp:   list.New(),
-	}
-}
-
-// Add saves a url and its response to our cache
-func (cc *colorCache) Add(url string, resp imgResponse) {
-	// Lock the cache while we're adding
-	cc.mutex.Lock()
-
-	if cc.count >= cc.max {
-		// If we've exceeded the max size, we must remove the oldest
-		// element
-
-		// Find the last element
-		back := cc.exp.Back()
-
-		// Remove it from the cache
-		delete(cc.hmap, back.Value.(string))
-
-		// Also remove it from the exp list
-		cc.exp.Remove(back)
-	} else {
-
-		// Otherwise, we didn't remove anything so increment count
-		cc.count++
-	}
-
-	// Add new url to be last to expire
-	cc.exp.PushFront(url)
-
-	// Save its value
-	cc.hmap[url] = resp
-
-	// Done locking
-	cc.mutex.Unlock()
-}
-
-// Get retrieves an imgResponse by its url, returning whether it was found or
-// not as the second value
-func (cc *colorCache) Get(url string) (imgResponse, bool) {
-	cc.mutex.RLock()
-
-	// Get it within read lock
-	resp, ok := cc.hmap[url]
-
-	cc.mutex.RUnlock()
-
-	return resp, ok
-}
-
-// GetMulti feeds at most max values into the out channel, closing it when all
-// possible entries have been exhausted (may be less than max)
-func (cc *colorCache) GetMulti(max int, out chan imgResponse) {
-	cc.mutex.RLock()
-
-	i := 0
-	for _, v := range cc.hmap {
-		// Break if we've reached max
-		if i > max {
-			break
-		}
-
-		// Skip results that were errors
-		if v.err != nil {
-			continue
-		}
-
-		i++
-		out <- v
-	}
-
-	close(out)
-
-	cc.mutex.RUnlock()
+// about describes this deployment to the people who run the servers it
+// calls
+type about struct {
+	UserAgent string    `json:"user_agent"`
+	Operator  string    `json:"operator"`
+	Started   time.Time `json:"started"`
 }
 
-// imgRequest is a request to get the first color from a URL
-type imgRequest struct {
-	p         *wikimg.Puller
-	url       string
-	responses chan imgResponse
-}
-
-// imgResponse contains the result of processing an imgRequest
-type imgResponse struct {
-	url string
-	hex string
-	err error
-}
-
-// worker takes imgRequests on the in channel, processes them and sends
-// an imgResponse back on the request's channel
-func worker(in chan *imgRequest) {
-	for req := range in {
-		var resp imgResponse
-
-		// Check cache first
-		resp, ok := cache.Get(req.url)
-
-		if !ok {
-
-			// It wasn't in the cache, so actually get it and add it
-			_, resp.hex, resp.err = req.p.FirstColor(req.url)
-			resp.url = req.url
-
-			cache.Add(req.url, resp)
-		}
-
-		// Send it back on our response channel
-		req.responses <- resp
-	}
+// ServeHTTP writes the description as JSON
+func (a about) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a)
 }
 
 func main() {
-	var max, bgmax, workers, buffer, port, cacheSize int
-
-	flag.IntVar(&max, "max", 300, "max number of images per HTTP request")
+	var max, bgmax, workers, port, cacheSize, stride, thumbs, keepCycles int
+	var licenses, iotdStrategy, cacheFile, redisAddr, titlesFile, operator, templateFile string
+	var decodeCPU float64
+	var debug bool
+	var cacheTTL, refreshEvery, interval, jitter, bgTimeout, grace time.Duration
+	var configFile string
+
+	flag.IntVar(&max, "max", 300, "most images a request can ask for with ?max=")
 	flag.IntVar(&bgmax, "bgmax", 1000, "max images to pull on each background request")
+	flag.DurationVar(&interval, "interval", 30*time.Minute, "how often to pull images in the background")
+	flag.DurationVar(&jitter, "jitter", time.Minute, "most random time to add to each interval, so many servers don't pull at once")
+	flag.DurationVar(&bgTimeout, "bgtimeout", 10*time.Minute, "how long each background pull may take")
 	flag.IntVar(&workers, "workers", 25, "number of background workers")
-	flag.IntVar(&buffer, "buffer", 10000, "size of buffered channels")
 	flag.IntVar(&port, "port", 8000, "HTTP port to listen on")
 	flag.IntVar(&cacheSize, "cache", 50000, "size of our background cache")
+	flag.DurationVar(&cacheTTL, "cachettl", 24*time.Hour, "how long to keep images in the background cache (0 for no limit)")
+	flag.IntVar(&stride, "stride", 1, "scan every Nth pixel of each image")
+	flag.IntVar(&thumbs, "thumbs", 0, "analyze thumbnails of this width instead of originals")
+	flag.StringVar(&licenses, "licenses", "", "comma separated licenses to allow (e.g., cc0,cc-by), default all")
+	flag.IntVar(&keepCycles, "cycles", server.DefaultKeepCycles, "number of background cycles to keep stats for")
+	flag.Float64Var(&decodeCPU, "decodecpu", 0.5, "fraction of CPU to use for decoding images, leaving the rest for serving (0 for no limit)")
+	flag.StringVar(&cacheFile, "cachefile", "", "keep image colors in this file, so they survive restarts")
+	flag.StringVar(&redisAddr, "redis", "", "share image colors with other servers through Redis at this address (host:port)")
+	flag.StringVar(&titlesFile, "titles", "", "keep the page titles of images in this file, so swatches link to their pages after restarts")
+	flag.StringVar(&operator, "operator", "", "how to contact you (e.g., an email address), included in the User-Agent of every request")
+	flag.StringVar(&iotdStrategy, "iotd", server.IOTDColorful, "image of the day strategy: colorful, saturation or random")
+	flag.BoolVar(&debug, "debug", false, "log every page and image the background pullers process")
+	flag.StringVar(&templateFile, "template", "", "html/template file to show the wall with instead of the default (see server.ParseTemplate)")
+	flag.DurationVar(&refreshEvery, "refresh", time.Minute, "how often browsers reload the wall (0 for never)")
+	flag.DurationVar(&grace, "grace", 30*time.Second, "how long to wait for work in progress when shutting down")
+	flag.StringVar(&configFile, "config", "", "YAML, TOML or JSON file of flag settings (see the config package)")
 	flag.Parse()
 
-	// Initialize the cache
-	cache = newColorCache(cacheSize)
-
-	// Create a buffered channel for communicating between image
-	// puller loop and workers
-	imgReqs := make(chan *imgRequest, buffer)
-
-	// Create workers
-	for i := 0; i < workers; i++ {
-		go worker(imgReqs)
+	// Settings not given as flags can come from the config file or
+	// WIKIMG_ environment variables, e.g., WIKIMG_REDIS=cache:6379
+	err := config.Load(flag.CommandLine, configFile, "WIKIMG_")
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	// Create background pull task
-	go func() {
+	// Log what the pullers are doing, in detail if asked
+	level := slog.LevelInfo
+	if debug {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	wikimg.SetLogger(slog.Default())
+
+	// Remember colors across restarts, so the first cycle doesn't have
+	// to download every image again. With Redis, they're also shared
+	// with other instances of this server.
+	var colors wikimg.Cache
+	switch {
+	case len(redisAddr) > 0:
+		rc, err := rediscache.Open(redisAddr, cacheTTL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		lifecycle.Add("redis", func(ctx context.Context) error { return rc.Close() })
 
-		// Loop forever
-		for {
+		colors = rc
 
-			// Create a new image puller with our bgmax
-			p := wikimg.NewPuller(bgmax)
+	case len(cacheFile) > 0:
+		bc, err := boltcache.Open(cacheFile, cacheTTL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		lifecycle.Add("cachefile", func(ctx context.Context) error { return bc.Close() })
 
-			// Since this is running in the background, we can have a much
-			// longer timeout
-			ctx, _ := context.WithTimeout(context.Background(), time.Minute*10)
+		colors = bc
+	}
 
-			// Set puller's Cancel channel, so it will be closed when the
-			// context times out
-			p.Cancel = ctx.Done()
+	titles := wikimg.NewTitleIndex()
+	if len(titlesFile) > 0 {
+		titles, err = wikimg.OpenTitleIndex(titlesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		lifecycle.Add("titles", func(ctx context.Context) error { return titles.Close() })
+	}
 
-			// Create a channel for receiving responses in this background
-			// process
-			responses := make(chan imgResponse, max)
+	// Share a decoder between cycles, so decoding a large batch leaves
+	// CPU for serving requests
+	var decoder *wikimg.Executor
+	if decodeCPU > 0 {
+		decoder = wikimg.NewExecutor(wikimg.CPUFraction(decodeCPU))
+	}
 
-			// Loop to retrieve more images
-			for {
-				imgURL, err := p.Next()
+	// Identify ourselves, so Wikimedia's admins can reach us instead of
+	// blocking us
+	if len(operator) < 1 {
+		slog.Warn("no -operator set, please set one so Wikimedia can contact you")
+	}
+	agent := wikimg.NewPuller(0)
+	agent.Operator = operator
+	agent.RequestID = ""
+	http.Handle("/about", about{
+		UserAgent: agent.UserAgent(),
+		Operator:  operator,
+		Started:   time.Now(),
+	})
 
-				if err == wikimg.EndOfResults {
-					// Break from loop when end of results is reached
-					break
+	// Export what the pullers are doing for Prometheus to scrape
+	m := metrics.New()
+	http.Handle("/metrics", m)
+
+	// The server analyzes images in the background and serves their
+	// colors from its cache, so requests never wait on downloads
+	s := server.New(cacheSize, cacheTTL)
+	s.Max = max
+	s.Batch = bgmax
+	s.Workers = workers
+	s.CycleTimeout = bgTimeout
+	s.KeepCycles = keepCycles
+	s.Refresh = refreshEvery
+	s.Logger = slog.Default()
+	s.NewPuller = func(n int) *wikimg.Puller {
+		p := wikimg.NewPuller(n)
+		p.Options.Stride = stride
+		p.Decoder = decoder
+		p.Cache = colors
+		p.Titles = titles
+		p.Operator = operator
+		p.Observer = m
+		p.UseThumbnails(thumbs)
+
+		// Only show images with the licenses we want
+		if len(licenses) > 0 {
+			p.Licenses = strings.Split(licenses, ",")
+		}
 
-				} else if err != nil {
-					// Send error on the response channel and continue
-					responses <- imgResponse{err: err}
-					continue
-				}
+		return p
+	}
 
-				// Create request and send on the global channel
-				imgReqs <- &imgRequest{
-					p:         p,
-					url:       imgURL,
-					responses: responses,
-				}
-			}
+	// Show the wall with the template we're given, if any
+	if len(templateFile) > 0 {
+		s.Template, err = server.ParseTemplate(templateFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
-			for i := 0; i < bgmax; i++ {
-				// Read a response from the channel
-				resp := <-responses
+	// Pick an image of the day from the cache after each cycle. Previous
+	// days come from the store, if the cache has one.
+	s.IOTD = &server.IOTD{Strategy: iotdStrategy}
+	if store, ok := colors.(server.ValueStore); ok {
+		s.IOTD.Store = store
+	}
 
-				// If there's an error, just log it on the server
-				if resp.err != nil {
-					log.Println(resp.err)
-					continue
-				}
+	// Run a cycle right away, then every interval, until we shut down.
+	// Cycles never overlap, even if one takes longer than the interval.
+	sched := &schedule.Scheduler{Interval: interval, Jitter: jitter, Immediate: true}
+	lifecycle.Go(func(ctx context.Context) {
+		sched.Run(ctx, func(ctx context.Context) {
+			err := s.Cycle(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.Warn("background cycle failed", "err", err)
 			}
+		})
+	})
 
-			// Sleep for a bit until next iteration
-			time.Sleep(30 * time.Minute)
-		}
-
-	}()
+	// The wall, its colors and everything else the server serves, e.g.,
+	// /?max=50&format=json, /?offset=300&limit=100 or /iotd. Cycle stats
+	// and the mood of the cache used to be under /api.
+	http.HandleFunc("/api/cycles", s.ServeCycles)
+	http.HandleFunc("/api/mood", s.ServeMood)
+	http.Handle("/", s.Handler())
+
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", port)}
+	lifecycle.Add("http", srv.Shutdown)
+
+	// On SIGINT or SIGTERM, shut down in order: cancel the background
+	// cycle and wait for it to stop, stop accepting requests and let the
+	// ones in progress finish, then close the caches so everything
+	// they've written is flushed. A second signal kills us as usual.
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// Create a channel
-		responses := make(chan imgResponse, max)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		<-ctx.Done()
+		stop()
+		slog.Info("shutting down")
 
-		// Everybody gets a goroutine!
-		go cache.GetMulti(max, responses)
+		ctx, cancel := context.WithTimeout(context.Background(), grace)
+		defer cancel()
 
-		for resp := range responses {
-			fmt.Fprintf(w, fmtSpec, resp.url, resp.hex)
-			fmt.Fprintln(w)
+		err := lifecycle.Shutdown(ctx)
+		if err != nil {
+			slog.Warn("couldn't shut down cleanly", "err", err)
 		}
-	})
+	}()
+
+	err = srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
 
-	http.ListenAndServe(fmt.Sprintf(":%d", port), nil)
+	<-stopped
 }
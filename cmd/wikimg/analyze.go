@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// analyze reads records, adds the first color of each image and writes them
+// back out. Records are written as soon as they're analyzed, so the output
+// order may differ from the input.
+func analyze(args []string) error {
+	var wf workerFlags
+	var s3f s3Flags
+	var stride, thumbs int
+	var report string
+	var percentile float64
+	var truecolor, local bool
+
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	wf.register(fs)
+	s3f.register(fs)
+	fs.IntVar(&stride, "stride", 1, "scan every Nth pixel of each image")
+	fs.IntVar(&thumbs, "thumbs", 0, "analyze thumbnails of this width instead of originals")
+	fs.Float64Var(&percentile, "percentile", 0, "pick the color at this saturation percentile (e.g., 0.9) instead of the first non-gray pixel")
+	fs.StringVar(&report, "report", "palette", "report the palette color or the pixel color")
+	fs.BoolVar(&truecolor, "truecolor", false, "report exact 24-bit colors instead of mapping them to xterm256")
+	fs.BoolVar(&local, "local", false, "read file URLs from disk, e.g., from pull -source local")
+	fs.Parse(args)
+
+	source, err := wikimg.ParseColorSource(report)
+	if err != nil {
+		return err
+	}
+
+	// We only use the puller for analyzing, not pulling, so it doesn't
+	// need a max
+	p := newPuller(0)
+	p.Options.Stride = stride
+	p.Options.Report = source
+	p.Options.Unquantized = truecolor
+	p.LocalFiles = local
+	s3f.configure(p)
+	if percentile > 0 {
+		p.Options.Method = wikimg.SaturationPercentile
+		p.Options.Percentile = percentile
+	}
+	p.UseThumbnails(thumbs)
+
+	in := make(chan wikimg.Record)
+	out := make(chan wikimg.Record)
+
+	err = wf.run(in, out, func(ctx context.Context, rec wikimg.Record) wikimg.Record {
+		return analyzeRecord(ctx, p, rec)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Read records in the background, so we can write them as they come
+	// out of the workers
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- readRecords(os.Stdin, in)
+	}()
+
+	w := wikimg.NewRecordWriter(os.Stdout)
+	for rec := range out {
+		err := w.Write(rec)
+		if err != nil {
+			return err
+		}
+	}
+
+	return <-readErr
+}
+
+// analyzeRecord adds the color of an image to rec, unless it already has
+// one or a previous stage failed. The analysis stops when ctx is done.
+func analyzeRecord(ctx context.Context, p *wikimg.Puller, rec wikimg.Record) wikimg.Record {
+	if len(rec.RequestID) < 1 {
+		rec.RequestID = p.RequestID
+	}
+
+	if rec.Color != nil || len(rec.Error) > 0 {
+		return rec
+	}
+
+	info, err := p.FirstColorContext(ctx, rec.URL)
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		rec.Error = err.Error()
+		return rec
+	}
+
+	// The puller didn't pull this image, so it doesn't know when it was
+	// uploaded
+	info.Uploaded = rec.Uploaded
+
+	rec.Color = &info
+
+	return rec
+}
+
+// recordError returns the error recorded on rec, if any
+func recordError(rec wikimg.Record) error {
+	if len(rec.Error) > 0 {
+		return errors.New(rec.Error)
+	}
+
+	return nil
+}
+
+// readRecords sends each record read from r on the out channel, closing it
+// when there are no more
+func readRecords(r io.Reader, out chan wikimg.Record) error {
+	defer close(out)
+
+	rr := wikimg.NewRecordReader(r)
+	for {
+		rec, err := rr.Read()
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		out <- rec
+	}
+}
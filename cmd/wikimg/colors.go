@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// colors pulls the latest images, analyzes them and prints their colors as
+// they're found, all in one process. It's the same as
+//
+//	wikimg pull | wikimg analyze | wikimg render
+//
+// without the flags for tuning each stage.
+func colors(args []string) error {
+	var pf pullFlags
+	var wf workerFlags
+	var sf sourceFlags
+	var rf renderFlags
+
+	fs := flag.NewFlagSet("colors", flag.ExitOnError)
+	pf.register(fs, 100)
+	wf.register(fs)
+	sf.register(fs)
+	rf.register(fs)
+	fs.Parse(args)
+
+	r, err := rf.renderer(os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	p := pf.puller()
+	src, err := sf.source(p, pf.max)
+	if err != nil {
+		return err
+	}
+
+	pulled := make(chan wikimg.Record)
+	analyzed := make(chan wikimg.Record)
+
+	err = wf.run(pulled, analyzed, func(ctx context.Context, rec wikimg.Record) wikimg.Record {
+		return analyzeRecord(ctx, p, rec)
+	})
+	if err != nil {
+		return err
+	}
+
+	pullErr := make(chan error, 1)
+	go func() {
+		pullErr <- pullRecords(src, pulled)
+	}()
+
+	for rec := range analyzed {
+		err = r.render(rec)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = r.flush()
+	if err != nil {
+		return err
+	}
+
+	return <-pullErr
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/brnstz/routine/wikimg"
+	"github.com/brnstz/routine/wikimg/download"
+)
+
+// downloadImages reads records and saves each image to a directory, named
+// after its page title if the record has one, adding where it was saved to
+// the record. Images that are already in the
+// directory's manifest aren't downloaded again, so an interrupted download
+// can simply be run again.
+func downloadImages(args []string) error {
+	var wf workerFlags
+	var dir string
+
+	fs := flag.NewFlagSet("download", flag.ExitOnError)
+	wf.register(fs)
+	fs.StringVar(&dir, "dir", "images", "directory to save images to")
+	fs.Parse(args)
+
+	d, err := download.NewDownloader(dir)
+	if err != nil {
+		return err
+	}
+
+	in := make(chan wikimg.Record)
+	out := make(chan wikimg.Record)
+
+	err = wf.run(in, out, func(ctx context.Context, rec wikimg.Record) wikimg.Record {
+		if len(rec.Error) > 0 {
+			return rec
+		}
+
+		e, err := d.DownloadImage(ctx, wikimg.ImageInfo{URL: rec.URL, Title: rec.Title})
+		if err != nil {
+			rec.Error = err.Error()
+			return rec
+		}
+		rec.Path = filepath.Join(dir, e.Path)
+
+		return rec
+	})
+	if err != nil {
+		return err
+	}
+
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- readRecords(os.Stdin, in)
+	}()
+
+	w := wikimg.NewRecordWriter(os.Stdout)
+	for rec := range out {
+		err := w.Write(rec)
+		if err != nil {
+			return err
+		}
+	}
+
+	return <-readErr
+}
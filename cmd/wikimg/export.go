@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// export reads analyzed records and writes the URL, xterm index, hex and
+// upload time of each color as CSV, a JSON array or NDJSON, for
+// spreadsheets and data pipelines. Records without a color are skipped.
+func export(args []string) error {
+	var format string
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.StringVar(&format, "format", "csv", fmt.Sprintf("output format: %s", strings.Join(wikimg.ExportFormats, ", ")))
+	fs.Parse(args)
+
+	w, err := wikimg.NewExportWriter(format, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	in := make(chan wikimg.Record)
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- readRecords(os.Stdin, in)
+	}()
+
+	for rec := range in {
+		row, ok := wikimg.NewExportRow(rec)
+		if !ok {
+			continue
+		}
+
+		err = w.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = <-readErr
+	if err != nil {
+		return err
+	}
+
+	return w.Close()
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brnstz/routine/pool"
+	"github.com/brnstz/routine/tune"
+	"github.com/brnstz/routine/wikimg"
+	"github.com/brnstz/routine/wikimg/featured"
+	"github.com/brnstz/routine/wikimg/flickr"
+	"github.com/brnstz/routine/wikimg/s3"
+	"github.com/brnstz/routine/wikimg/unsplash"
+)
+
+// pullFlags are the flags of subcommands that pull images from Commons
+type pullFlags struct {
+	max        int
+	licenses   string
+	structured bool
+}
+
+// register adds the flags to fs, with max images by default
+func (f *pullFlags) register(fs *flag.FlagSet, max int) {
+	fs.IntVar(&f.max, "max", max, "maximum number of images to retrieve")
+	fs.StringVar(&f.licenses, "licenses", "", "comma separated licenses to allow (e.g., cc0,cc-by), default all")
+	fs.BoolVar(&f.structured, "structured", false, "include what each image depicts and its captions, from its structured data")
+}
+
+// puller creates a Puller configured by the flags
+func (f *pullFlags) puller() *wikimg.Puller {
+	p := newPuller(f.max)
+	if len(f.licenses) > 0 {
+		p.Licenses = strings.Split(f.licenses, ",")
+	}
+	p.StructuredData = f.structured
+
+	return p
+}
+
+// sourceFlags are the flags of subcommands that can pull images from
+// somewhere other than Commons
+type sourceFlags struct {
+	name        string
+	flickrKey   string
+	unsplashKey string
+	path        string
+	category    string
+
+	s3       s3Flags
+	s3Bucket string
+	s3Prefix string
+}
+
+// register adds the flags to fs
+func (f *sourceFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.name, "source", "commons", "where to pull images from: commons, potd, featured, flickr, unsplash, local or s3")
+	fs.StringVar(&f.category, "category", featured.DefaultCategory, "category of curated images, for -source featured")
+	fs.StringVar(&f.flickrKey, "flickr-key", os.Getenv("FLICKR_API_KEY"), "Flickr API key, for -source flickr")
+	fs.StringVar(&f.unsplashKey, "unsplash-key", os.Getenv("UNSPLASH_ACCESS_KEY"), "Unsplash access key, for -source unsplash")
+	fs.StringVar(&f.path, "path", ".", "directory or glob of images, for -source local")
+	fs.StringVar(&f.s3Bucket, "s3-bucket", "", "bucket of images, for -source s3")
+	fs.StringVar(&f.s3Prefix, "s3-prefix", "", "prefix of the keys of images, for -source s3")
+	f.s3.register(fs)
+}
+
+// newSource returns a function creating a Source of at most max images
+// configured by the flags, or nil for Commons, which the Puller pulls
+// itself
+func (f *sourceFlags) newSource() (func(max int) wikimg.Source, error) {
+	switch f.name {
+	case "commons":
+		return nil, nil
+
+	case featured.PictureOfTheDay, featured.Pictures:
+		return func(max int) wikimg.Source {
+			src := featured.NewSource(f.name, max)
+			src.Category = f.category
+
+			return src
+		}, nil
+
+	case "flickr":
+		if len(f.flickrKey) < 1 {
+			return nil, fmt.Errorf("-source flickr needs an API key, set -flickr-key or $FLICKR_API_KEY")
+		}
+
+		return func(max int) wikimg.Source {
+			return flickr.NewSource(f.flickrKey, max)
+		}, nil
+
+	case "unsplash":
+		if len(f.unsplashKey) < 1 {
+			return nil, fmt.Errorf("-source unsplash needs an access key, set -unsplash-key or $UNSPLASH_ACCESS_KEY")
+		}
+
+		// Rather than fail when the hourly limit runs out, keep going
+		// once it resets
+		return func(max int) wikimg.Source {
+			src := unsplash.NewSource(f.unsplashKey, max)
+			src.MaxWait = time.Hour
+
+			return src
+		}, nil
+
+	case "local":
+		files, err := wikimg.FileSource(f.path)
+		if err != nil {
+			return nil, err
+		}
+
+		// Each Source continues where the last one stopped
+		return func(max int) wikimg.Source {
+			return wikimg.LimitSource(files, max)
+		}, nil
+
+	case "s3":
+		if len(f.s3Bucket) < 1 {
+			return nil, fmt.Errorf("-source s3 needs a -s3-bucket")
+		}
+
+		objects := s3.NewSource(f.s3Bucket, math.MaxInt)
+		objects.Config = f.s3.config()
+		objects.Prefix = f.s3Prefix
+
+		return func(max int) wikimg.Source {
+			return wikimg.LimitSource(objects, max)
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown source %q, expected commons, potd, featured, flickr, unsplash, local or s3", f.name)
+	}
+}
+
+// source creates a Source of at most max images configured by the flags,
+// which is p's own for Commons. p is configured to download its images.
+func (f *sourceFlags) source(p *wikimg.Puller, max int) (wikimg.Source, error) {
+	newSource, err := f.newSource()
+	if err != nil {
+		return nil, err
+	}
+	f.configure(p)
+
+	if newSource == nil {
+		return p.Source(), nil
+	}
+
+	return newSource(max), nil
+}
+
+// configure lets p download the images of the source: local files are
+// read from disk, and objects in S3 are signed with its credentials. Only
+// Pullers for the source should be configured, so others can't read them.
+func (f *sourceFlags) configure(p *wikimg.Puller) {
+	switch f.name {
+	case "local":
+		p.LocalFiles = true
+
+	case "s3":
+		f.s3.configure(p)
+	}
+}
+
+// s3Flags are the flags of subcommands that download images from S3
+type s3Flags struct {
+	endpoint string
+	region   string
+}
+
+// register adds the flags to fs
+func (f *s3Flags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.endpoint, "s3-endpoint", "", "URL of an S3 compatible API, for s3:// URLs, default AWS")
+	fs.StringVar(&f.region, "s3-region", os.Getenv("AWS_REGION"), "region of the bucket, for s3:// URLs, default us-east-1")
+}
+
+// config returns the Config of the bucket. Credentials come from the
+// environment, like the AWS CLI's, rather than flags that other users
+// could see.
+func (f *s3Flags) config() s3.Config {
+	return s3.Config{
+		Endpoint:        f.endpoint,
+		Region:          f.region,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// configure lets p download s3:// URLs, signed with the credentials
+func (f *s3Flags) configure(p *wikimg.Puller) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.RegisterProtocol(s3.Scheme, &s3.Transport{Config: f.config()})
+	p.Client = &http.Client{Transport: t}
+}
+
+// workerFlags are the flags of subcommands that process records with a
+// pool of workers
+type workerFlags struct {
+	workers string
+	timeout time.Duration
+}
+
+// register adds the flags to fs
+func (f *workerFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.workers, "workers", "medium", "number of background workers: low, medium, high, auto or a number")
+	fs.DurationVar(&f.timeout, "timeout", 0, "give up on each image after this long (0 for no limit)")
+}
+
+// run calls fn for each record read from in using the workers, sending
+// the results to out and closing it once in is closed. Each call gets a
+// context that's done after the timeout. Records are passed through even
+// after an interrupt (the puller fails them instead), so the pool runs
+// until the input ends.
+func (f *workerFlags) run(in <-chan wikimg.Record, out chan<- wikimg.Record, fn func(context.Context, wikimg.Record) wikimg.Record) error {
+	// In auto mode, we start as many workers as we'd ever want and let the
+	// tuner decide how many run at once
+	preset := f.workers
+	var tuner *tune.Tuner
+	if preset == "auto" {
+		max, _ := tune.Workers("high")
+		tuner = tune.NewTuner(1, max)
+		preset = "high"
+	}
+
+	workers, err := tune.Workers(preset)
+	if err != nil {
+		close(out)
+		return err
+	}
+
+	go func() {
+		pool.Run(context.Background(), workers, in, func(ctx context.Context, rec wikimg.Record) error {
+			if f.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, f.timeout)
+				defer cancel()
+			}
+
+			if tuner == nil {
+				out <- fn(ctx, rec)
+				return nil
+			}
+
+			// Let the tuner know how long it took and whether it
+			// worked
+			tuner.Acquire()
+			start := time.Now()
+			rec = fn(ctx, rec)
+			tuner.Release(time.Since(start), recordError(rec))
+
+			out <- rec
+			return nil
+		})
+		close(out)
+	}()
+
+	return nil
+}
@@ -0,0 +1,148 @@
+// Command wikimg pulls the latest images from Wikimedia Commons and analyzes
+// their colors. Each subcommand reads and writes newline-delimited JSON
+// records (see wikimg.Record), so they compose with pipes and standard tools
+// can be inserted between stages:
+//
+//	wikimg pull -max 100 | wikimg analyze | wikimg render -html > wall.html
+//	wikimg pull | wikimg analyze | jq -c 'select(.color.s > 0.5)' | wikimg render
+//	wikimg pull | wikimg analyze | wikimg overlay -hold 10s
+//	wikimg pull | wikimg analyze | wikimg summary
+//	wikimg pull | wikimg analyze | wikimg mosaic -o wall.png
+//	wikimg pull | wikimg analyze | wikimg render -svg > colors.svg
+//	wikimg pull | wikimg analyze | wikimg export -format csv > colors.csv
+//	wikimg pull -max 20 | wikimg download -dir images
+//
+// Some subcommands do the whole job in one process: colors pulls, analyzes
+// and prints colors, view shows them in an interactive viewer, and serve
+// keeps analyzing the latest images in the background to serve their colors
+// over HTTP.
+// Subcommands that pull share the -max and -licenses flags, and those that
+// process images share -workers and -timeout.
+//
+// Every request to Wikimedia carries a request ID in its X-Request-Id header
+// and User-Agent, which is also added to records and log lines. Each stage
+// picks a random one, unless WIKIMG_REQUEST_ID is set, so exporting it
+// gives every stage of a pipeline the same ID:
+//
+//	export WIKIMG_REQUEST_ID=$(date +%s)
+//	wikimg pull | wikimg analyze | wikimg render
+//
+// Please set WIKIMG_OPERATOR to how to contact you (e.g., an email
+// address). It's included in the User-Agent, as Wikimedia's bot policy asks.
+// Set WIKIMG_DEBUG to log every page and image a stage processes.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/brnstz/routine/lifecycle"
+	"github.com/brnstz/routine/wikimg"
+)
+
+// shutdownTimeout is how long components get to stop after an interrupt
+const shutdownTimeout = 5 * time.Second
+
+var (
+	// requestID identifies this run in requests, records and logs
+	requestID = os.Getenv("WIKIMG_REQUEST_ID")
+
+	// operator is how to contact whoever runs us, included in the
+	// User-Agent of every request
+	operator = os.Getenv("WIKIMG_OPERATOR")
+
+	// debug logs every page and image we process
+	debug = len(os.Getenv("WIKIMG_DEBUG")) > 0
+)
+
+// command is a wikimg subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// commands are all of the available subcommands
+var commands = []command{
+	{"pull", "print the URLs of the latest images as records", pull},
+	{"analyze", "add the color of each image to records", analyze},
+	{"render", "print the colors of records to the terminal, as HTML or as SVG", render},
+	{"overlay", "show the colors of records on a live page for OBS", overlay},
+	{"summary", "print aggregate measures of the colors of records", summary},
+	{"export", "write the colors of records as CSV, JSON or NDJSON", export},
+	{"colors", "pull, analyze and print the colors of the latest images", colors},
+	{"download", "save the image of each record to a directory", downloadImages},
+	{"mosaic", "write a PNG with a square of each record's color", mosaicImages},
+	{"serve", "serve the colors of the latest images over HTTP", serve},
+	{"today", "print the top colors a server has seen today", today},
+	{"view", "browse the colors of the latest images as they're found", view},
+}
+
+// newPuller creates a Puller that is canceled on shutdown and identifies
+// itself with requestID
+func newPuller(max int) *wikimg.Puller {
+	p := wikimg.NewPullerContext(lifecycle.Context(), max)
+	p.RequestID = requestID
+	p.Operator = operator
+
+	return p
+}
+
+// usage prints the available subcommands
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: wikimg <command> [flags]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun \"wikimg <command> -h\" for the flags of a command\n")
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(requestID) < 1 {
+		requestID = wikimg.NewRequestID()
+	}
+	log.SetPrefix(fmt.Sprintf("wikimg [%s]: ", requestID))
+	if debug {
+		wikimg.SetLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	}
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	// Shut everything down in order on the first interrupt. A second one
+	// kills us as usual.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		signal.Stop(interrupt)
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		lifecycle.Shutdown(ctx)
+	}()
+
+	for _, c := range commands {
+		if c.name != os.Args[1] {
+			continue
+		}
+
+		err := c.run(os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	usage()
+	os.Exit(2)
+}
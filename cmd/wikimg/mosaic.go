@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/brnstz/routine/lifecycle"
+	"github.com/brnstz/routine/wikimg"
+	"github.com/brnstz/routine/wikimg/mosaic"
+)
+
+// mosaicImages reads analyzed records and writes a PNG with a square of each
+// image's color, in rows from the top left. With -thumbs, each square is a
+// thumbnail of the image instead.
+func mosaicImages(args []string) error {
+	var m mosaic.Mosaic
+	var thumbs bool
+	var output string
+
+	fs := flag.NewFlagSet("mosaic", flag.ExitOnError)
+	fs.IntVar(&m.Cols, "cols", mosaic.DefaultCols, "number of squares in each row")
+	fs.IntVar(&m.Cell, "cell", mosaic.DefaultCell, "width and height of each square in pixels")
+	fs.BoolVar(&thumbs, "thumbs", false, "fill each square with a thumbnail of the image instead of its color")
+	fs.IntVar(&m.Workers, "workers", mosaic.DefaultWorkers, "number of thumbnails to download at once")
+	fs.StringVar(&output, "o", "-", "file to write the PNG to, or - for standard output")
+	fs.Parse(args)
+
+	if m.Cols < 1 || m.Cell < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if thumbs {
+		m.Thumbnails = newPuller(0)
+	}
+
+	in := make(chan wikimg.Record)
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- readRecords(os.Stdin, in)
+	}()
+
+	// Only records with a color get a square
+	var cells []mosaic.Cell
+	for rec := range in {
+		if rec.Color != nil {
+			cells = append(cells, mosaic.NewCell(rec.URL, *rec.Color))
+		}
+	}
+
+	err := <-readErr
+	if err != nil {
+		return err
+	}
+
+	ctx := lifecycle.Context()
+	if output == "-" {
+		return m.WritePNG(ctx, os.Stdout, cells)
+	}
+
+	return m.WriteFile(ctx, output, cells)
+}
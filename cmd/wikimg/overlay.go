@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/brnstz/routine/lifecycle"
+	"github.com/brnstz/routine/wikimg"
+)
+
+// overlayPage is a transparent page showing the latest color, meant to be
+// added to OBS as a browser source. It follows the server over a WebSocket
+// and reconnects if the connection drops.
+const overlayPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<style>
+	html, body { background: transparent; margin: 0; }
+	#ticker { display: flex; align-items: center; font: bold 24px monospace; color: #fff; text-shadow: 0 0 4px #000; }
+	#swatch { width: 64px; height: 64px; margin: 8px; border-radius: 8px; transition: background 1s; }
+	#url { font-size: 14px; font-weight: normal; }
+</style>
+</head>
+<body>
+<div id="ticker">
+	<div id="swatch"></div>
+	<div><div id="hex"></div><div id="url"></div></div>
+</div>
+<script>
+function connect() {
+	var ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/ws");
+	ws.onmessage = function(e) {
+		var m = JSON.parse(e.data);
+		document.getElementById("swatch").style.background = m.hex;
+		document.getElementById("hex").textContent = m.hex + " " + m.name;
+		document.getElementById("url").textContent = decodeURIComponent(m.url.split("/").pop());
+	};
+	ws.onclose = function() { setTimeout(connect, 2000); };
+}
+connect();
+</script>
+</body>
+</html>
+`
+
+// overlayMessage is sent to overlay pages for each color
+type overlayMessage struct {
+	URL  string `json:"url"`
+	Hex  string `json:"hex"`
+	Name string `json:"name"`
+}
+
+// overlayHub sends the latest color to every connected overlay page
+type overlayHub struct {
+	latest *overlayMessage
+	subs   map[chan overlayMessage]bool
+	mutex  sync.Mutex
+}
+
+// publish sends msg to every page, and to pages that connect later
+func (h *overlayHub) publish(msg overlayMessage) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.latest = &msg
+	for sub := range h.subs {
+		// Slow pages only need the latest color, so drop the one they
+		// haven't read yet
+		select {
+		case <-sub:
+		default:
+		}
+		sub <- msg
+	}
+}
+
+// subscribe returns a channel receiving each published color, starting with
+// the latest one
+func (h *overlayHub) subscribe() chan overlayMessage {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	sub := make(chan overlayMessage, 1)
+	if h.latest != nil {
+		sub <- *h.latest
+	}
+	h.subs[sub] = true
+
+	return sub
+}
+
+// unsubscribe stops sending colors to sub
+func (h *overlayHub) unsubscribe(sub chan overlayMessage) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.subs, sub)
+}
+
+// serve sends colors to one connected page until it goes away or we shut
+// down
+func (h *overlayHub) serve(ws *websocket.Conn) {
+	defer ws.Close()
+
+	sub := h.subscribe()
+	defer h.unsubscribe(sub)
+
+	for {
+		select {
+		case msg := <-sub:
+			err := websocket.JSON.Send(ws, msg)
+			if err != nil {
+				return
+			}
+		case <-lifecycle.Done():
+			return
+		}
+	}
+}
+
+// overlay serves a transparent page for OBS browser sources that shows the
+// color of each analyzed record as it's read, holding each one on screen
+// for a while. Once the input ends, the last color stays up until we're
+// interrupted.
+func overlay(args []string) error {
+	var port int
+	var hold time.Duration
+
+	fs := flag.NewFlagSet("overlay", flag.ExitOnError)
+	fs.IntVar(&port, "port", 8080, "HTTP port to listen on")
+	fs.DurationVar(&hold, "hold", 5*time.Second, "how long to show each color")
+	fs.Parse(args)
+
+	hub := &overlayHub{subs: map[chan overlayMessage]bool{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, overlayPage)
+	})
+	mux.Handle("/ws", websocket.Handler(hub.serve))
+
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux}
+	lifecycle.Add("overlay server", srv.Shutdown)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	log.Printf("overlay at http://localhost:%d/", port)
+
+	in := make(chan wikimg.Record)
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- readRecords(os.Stdin, in)
+	}()
+
+	for rec := range in {
+		if rec.Color == nil {
+			continue
+		}
+
+		hub.publish(overlayMessage{URL: rec.URL, Hex: rec.Color.Hex, Name: rec.Color.Name()})
+
+		select {
+		case <-time.After(hold):
+		case <-lifecycle.Done():
+			return nil
+		case err := <-serveErr:
+			return err
+		}
+	}
+
+	err := <-readErr
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-lifecycle.Done():
+		return nil
+	case err := <-serveErr:
+		return err
+	}
+}
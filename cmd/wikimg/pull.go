@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/brnstz/routine/lifecycle"
+	"github.com/brnstz/routine/wikimg"
+)
+
+// pull writes a record with the URL and upload time of each of the latest
+// images, on Commons or the -source
+func pull(args []string) error {
+	var pf pullFlags
+	var sf sourceFlags
+
+	fs := flag.NewFlagSet("pull", flag.ExitOnError)
+	pf.register(fs, 100)
+	sf.register(fs)
+	fs.Parse(args)
+
+	src, err := sf.source(pf.puller(), pf.max)
+	if err != nil {
+		return err
+	}
+
+	out := make(chan wikimg.Record)
+	pullErr := make(chan error, 1)
+	go func() {
+		pullErr <- pullRecords(src, out)
+	}()
+
+	w := wikimg.NewRecordWriter(os.Stdout)
+	for rec := range out {
+		err = w.Write(rec)
+		if err != nil {
+			return err
+		}
+	}
+
+	return <-pullErr
+}
+
+// pullRecords sends a record for each image from src on the out channel,
+// closing it when there are no more
+func pullRecords(src wikimg.Source, out chan wikimg.Record) error {
+	defer close(out)
+
+	for {
+		img, err := src.Next(lifecycle.Context())
+
+		if err == wikimg.EndOfResults {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		out <- wikimg.Record{
+			URL:       img.URL,
+			Title:     img.Title,
+			Page:      img.Page,
+			Uploaded:  img.Uploaded,
+			Depicts:   img.Depicts,
+			Captions:  img.Captions,
+			RequestID: requestID,
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/brnstz/routine/term"
+	"github.com/brnstz/routine/wikimg"
+	"github.com/brnstz/routine/wikimg/sheet"
+)
+
+// htmlSpec prints an HTML div with the hex background that links to the
+// image itself. The hex value is printed on top in a contrasting color.
+const htmlSpec = `<a style="text-decoration: none" href="%s"><div style="background: %s; color: %s; font-family: monospace; width: 100%%">%s</div></a>` + "\n"
+
+// renderFlags are the flags of subcommands that print colors
+type renderFlags struct {
+	html      bool
+	preview   bool
+	colorMode string
+	cvdName   string
+	sortName  string
+	collapse  string
+}
+
+// register adds the flags to fs
+func (f *renderFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.html, "html", false, "print HTML instead of terminal colors")
+	fs.StringVar(&f.colorMode, "color", "auto", "print terminal colors: always, never or auto")
+	fs.BoolVar(&f.preview, "preview", false, "print a low resolution preview of each image below its color in the terminal")
+	fs.StringVar(&f.cvdName, "cvd", "none", "preview colors with a color vision deficiency: none, protanopia, deuteranopia or tritanopia")
+	fs.StringVar(&f.collapse, "collapse", "none", "print repeated colors once with a count: none, runs (in a row) or all")
+	fs.StringVar(&f.sortName, "sort", "none", "print colors once they're all found, in order: none, hue, luminance or buckets (grouped by hue)")
+}
+
+// recordRenderer prints the colors of records
+type recordRenderer struct {
+	w        *os.File
+	html     bool
+	preview  bool
+	cvd      wikimg.CVD
+	renderer *term.Renderer
+
+	// order is the order records are printed in by flush. Until then,
+	// they're held, unless it's wikimg.Unsorted.
+	order wikimg.Order
+	held  []wikimg.Record
+
+	// collapse is how repeated colors are merged. Runs are held until
+	// they end.
+	collapse wikimg.Collapse
+
+	// p downloads previews again, at most one at a time
+	p *wikimg.Puller
+}
+
+// renderer creates a recordRenderer configured by the flags that prints
+// to w
+func (f *renderFlags) renderer(w *os.File) (*recordRenderer, error) {
+	mode, err := term.ParseMode(f.colorMode)
+	if err != nil {
+		return nil, err
+	}
+
+	cvd, err := wikimg.ParseCVD(f.cvdName)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := wikimg.ParseOrder(f.sortName)
+	if err != nil {
+		return nil, err
+	}
+
+	collapse, err := wikimg.ParseCollapse(f.collapse)
+	if err != nil {
+		return nil, err
+	}
+
+	return &recordRenderer{
+		w:        w,
+		html:     f.html,
+		preview:  f.preview && !f.html,
+		cvd:      cvd,
+		order:    order,
+		collapse: collapse,
+		renderer: term.NewRenderer(w, mode),
+		p:        newPuller(0),
+	}, nil
+}
+
+// render prints the color of rec, or holds it until flush if the records
+// are sorted or collapsed. Records with errors are logged and records that
+// haven't been analyzed are skipped.
+func (r *recordRenderer) render(rec wikimg.Record) error {
+	if len(rec.Error) > 0 {
+		log.Printf("%s: %s", rec.URL, rec.Error)
+		return nil
+	}
+
+	// Records that haven't been analyzed have nothing to show
+	if rec.Color == nil {
+		return nil
+	}
+
+	switch {
+	case r.order != wikimg.Unsorted || r.collapse == wikimg.CollapseAll:
+		r.held = append(r.held, rec)
+		return nil
+
+	case r.collapse == wikimg.CollapseRuns:
+		// Only the run so far needs to be held
+		if len(r.held) > 0 && r.held[0].Color.Hex != rec.Color.Hex {
+			err := r.flush()
+			if err != nil {
+				return err
+			}
+		}
+		r.held = append(r.held, rec)
+		return nil
+	}
+
+	return r.print(rec, 1)
+}
+
+// flush prints the held records in order, collapsed. It must be called
+// once every record has been rendered.
+func (r *recordRenderer) flush() error {
+	held := r.held
+	r.held = nil
+
+	wikimg.SortColors(held, r.order, func(rec wikimg.Record) wikimg.ColorInfo {
+		return rec.Color.Simulate(r.cvd)
+	})
+
+	for _, run := range wikimg.CollapseColors(held, r.collapse, func(rec wikimg.Record) wikimg.ColorInfo { return *rec.Color }) {
+		err := r.print(run.Item, run.Count)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// print prints the color of rec, which has been analyzed, with the count
+// of records it stands for if there's more than one
+func (r *recordRenderer) print(rec wikimg.Record, count int) error {
+	var label string
+	if count > 1 {
+		label = fmt.Sprintf("×%d", count)
+	}
+
+	var err error
+	info := rec.Color.Simulate(r.cvd)
+	if r.html {
+		_, err = fmt.Fprintf(r.w, htmlSpec, rec.URL, info.Hex, info.Contrast(), strings.TrimSpace(info.Hex+" "+label))
+	} else {
+		err = r.renderer.LabeledBar(info, label)
+	}
+	if err != nil {
+		return err
+	}
+
+	if r.preview {
+		err = r.renderer.Preview(r.p, rec.URL)
+		if err != nil {
+			log.Printf("%s: %v", rec.URL, err)
+		}
+	}
+
+	return nil
+}
+
+// render reads analyzed records and prints their colors, either as bars in
+// the terminal, as HTML or as an SVG sheet. Records with errors are logged.
+// With -sort, colors are printed once every record is read, sorted by hue
+// or luminance or grouped by hue, for a gradient rather than noise. With
+// -collapse, repeated colors are printed once with a count.
+func render(args []string) error {
+	var rf renderFlags
+	var svg bool
+
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	rf.register(fs)
+	fs.BoolVar(&svg, "svg", false, "print an SVG sheet of every color once all records are read")
+	fs.Parse(args)
+
+	if svg {
+		return renderSheet(rf.cvdName, rf.sortName)
+	}
+
+	r, err := rf.renderer(os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	in := make(chan wikimg.Record)
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- readRecords(os.Stdin, in)
+	}()
+
+	for rec := range in {
+		err = r.render(rec)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = r.flush()
+	if err != nil {
+		return err
+	}
+
+	return <-readErr
+}
+
+// renderSheet reads analyzed records and prints an SVG sheet of their
+// colors, as seen with the named color vision deficiency, in the named
+// order. Colors link to the pages of their images if records have pages or
+// titles.
+func renderSheet(cvdName, sortName string) error {
+	cvd, err := wikimg.ParseCVD(cvdName)
+	if err != nil {
+		return err
+	}
+
+	order, err := wikimg.ParseOrder(sortName)
+	if err != nil {
+		return err
+	}
+
+	in := make(chan wikimg.Record)
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- readRecords(os.Stdin, in)
+	}()
+
+	// Link to the pages of images we know
+	var results []wikimg.ColorResult
+	pages := map[string]string{}
+	for rec := range in {
+		if len(rec.Error) > 0 {
+			log.Printf("%s: %s", rec.URL, rec.Error)
+			continue
+		}
+
+		if rec.Color != nil {
+			results = append(results, wikimg.ColorResult{URL: rec.URL, Info: rec.Color.Simulate(cvd)})
+		}
+		if len(rec.Page) > 0 {
+			pages[rec.URL] = rec.Page
+		} else if len(rec.Title) > 0 {
+			pages[rec.URL] = wikimg.PageURL(rec.Title)
+		}
+	}
+
+	err = <-readErr
+	if err != nil {
+		return err
+	}
+
+	wikimg.SortColors(results, order, func(res wikimg.ColorResult) wikimg.ColorInfo { return res.Info })
+
+	s := &sheet.Sheet{
+		Title: "Latest colors on Wikimedia Commons",
+		Link: func(imgURL string) string {
+			if page, ok := pages[imgURL]; ok {
+				return page
+			}
+
+			return imgURL
+		},
+	}
+
+	return s.Encode(os.Stdout, results)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/brnstz/routine/lifecycle"
+	"github.com/brnstz/routine/notify"
+	"github.com/brnstz/routine/server"
+	"github.com/brnstz/routine/tune"
+	"github.com/brnstz/routine/wikimg"
+	"github.com/brnstz/routine/wikimg/rpc"
+)
+
+// serve pulls and analyzes the latest images in the background and serves
+// their colors (see the server package): as JSON at /colors, as a wall of
+// HTML swatches at / and as an Atom feed at /feed.xml. All take a max query
+// parameter to get fewer colors than -max. With -today, the top colors of
+// everything analyzed over the last day are served at /today (see the today
+// command). With -grpc, the gRPC service of the rpc package is served too,
+// so backend services can pull and analyze images themselves. With -source,
+// the background cycles pull images from elsewhere: the Picture of the Day
+// or featured pictures on Commons, the latest uploads to Flickr or
+// Unsplash, a local photo library or an S3 bucket. With -rules, images that
+// match rules are posted to webhooks, and summary rules get a summary of
+// each cycle. With -thumbnails, the wall shows the images too, from
+// thumbnails cached at /img. With -template, the wall is rendered with an
+// html/template file instead of the default.
+func serve(args []string) error {
+	var pf pullFlags
+	var wf workerFlags
+	var sf sourceFlags
+	var port, grpcPort, cacheSize, batch int
+	var interval time.Duration
+	var today, thumbnails bool
+	var rules, templateFile string
+
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	pf.register(fs, server.DefaultMax)
+	wf.register(fs)
+	sf.register(fs)
+	fs.IntVar(&port, "port", 8000, "HTTP port to listen on")
+	fs.IntVar(&grpcPort, "grpc", 0, "gRPC port to listen on, or 0 to not serve gRPC")
+	fs.IntVar(&cacheSize, "cache", 50000, "number of image colors to keep")
+	fs.IntVar(&batch, "batch", server.DefaultBatch, "number of images to pull in each background cycle")
+	fs.DurationVar(&interval, "interval", server.DefaultInterval, "how long to wait between background cycles")
+	fs.BoolVar(&today, "today", false, "count the colors of every pixel for the color of the day at /today (slower)")
+	fs.BoolVar(&thumbnails, "thumbnails", false, "show a thumbnail of each image on the wall, proxied and cached at /img")
+	fs.StringVar(&templateFile, "template", "", "html/template file to show the wall with instead of the default (see server.ParseTemplate)")
+	fs.StringVar(&rules, "rules", "", "JSON file of rules for posting matching images to webhooks (see the notify package)")
+	fs.Parse(args)
+
+	// Nothing waits on the background workers, so there's no tuner
+	preset := wf.workers
+	if preset == "auto" {
+		preset = "medium"
+	}
+
+	workers, err := tune.Workers(preset)
+	if err != nil {
+		return err
+	}
+
+	newSource, err := sf.newSource()
+	if err != nil {
+		return err
+	}
+
+	s := server.New(cacheSize, 0)
+	s.Max = pf.max
+	s.Batch = batch
+	s.Workers = workers
+	s.Interval = interval
+	s.Timeout = wf.timeout
+	newPuller := func(max int) *wikimg.Puller {
+		cycle := pf
+		cycle.max = max
+
+		return cycle.puller()
+	}
+
+	// Only the cycles download the source's images. gRPC clients can't.
+	s.NewPuller = func(max int) *wikimg.Puller {
+		p := newPuller(max)
+		sf.configure(p)
+
+		return p
+	}
+	s.NewSource = newSource
+	s.Thumbnails = thumbnails
+	if len(templateFile) > 0 {
+		s.Template, err = server.ParseTemplate(templateFile)
+		if err != nil {
+			return err
+		}
+	}
+	if today {
+		s.Aggregator = &wikimg.Aggregator{}
+	}
+	if len(rules) > 0 {
+		s.Notifier, err = newNotifier(rules)
+		if err != nil {
+			return err
+		}
+		go s.Notifier.Run(lifecycle.Context())
+	}
+	go s.Run(lifecycle.Context())
+
+	if grpcPort > 0 {
+		err = serveGRPC(grpcPort, &rpc.Server{
+			Max:       pf.max,
+			Workers:   workers,
+			NewPuller: newPuller,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: s.Handler()}
+	lifecycle.Add("server", srv.Shutdown)
+
+	log.Printf("serving at http://localhost:%d/", port)
+	err = srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// serveGRPC serves svc on port in the background until shutdown
+func serveGRPC(port int, svc *rpc.Server) error {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+
+	gs := grpc.NewServer()
+	rpc.RegisterWikimgServer(gs, svc)
+	lifecycle.Add("grpc", func(ctx context.Context) error {
+		// Let calls finish unless we're out of time
+		stopped := make(chan struct{})
+		go func() {
+			gs.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			gs.Stop()
+		}
+
+		return nil
+	})
+
+	go func() {
+		err := gs.Serve(l)
+		if err != nil {
+			log.Printf("grpc: %v", err)
+		}
+	}()
+	log.Printf("serving gRPC on port %d", port)
+
+	return nil
+}
+
+// newNotifier creates a Notifier for the rules in the file at path
+func newNotifier(path string) (*notify.Notifier, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rules, err := notify.ParseRules(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	n, err := notify.New(rules)
+	if err != nil {
+		return nil, err
+	}
+	n.Logger = slog.Default()
+
+	return n, nil
+}
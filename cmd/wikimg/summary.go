@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// summary reads analyzed records and prints aggregate measures of their
+// colors, computed by wikimg.Summarize
+func summary(args []string) error {
+	var asJSON bool
+
+	fs := flag.NewFlagSet("summary", flag.ExitOnError)
+	fs.BoolVar(&asJSON, "json", false, "print the summary as JSON")
+	fs.Parse(args)
+
+	in := make(chan wikimg.Record)
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- readRecords(os.Stdin, in)
+	}()
+
+	var results []wikimg.ColorResult
+	for rec := range in {
+		res := wikimg.ColorResult{Index: len(results), URL: rec.URL}
+
+		switch {
+		case len(rec.Error) > 0:
+			res.Err = errors.New(rec.Error)
+		case rec.Color != nil:
+			res.Info = *rec.Color
+		default:
+			// Records that haven't been analyzed have no color
+			continue
+		}
+
+		results = append(results, res)
+	}
+	if err := <-readErr; err != nil {
+		return err
+	}
+
+	s := wikimg.Summarize(results)
+	if asJSON {
+		return json.NewEncoder(os.Stdout).Encode(s)
+	}
+
+	fmt.Printf("images:          %d (%d errors)\n", s.Images, s.Errors)
+	fmt.Printf("mean saturation: %.2f\n", s.MeanSaturation)
+	fmt.Printf("gray:            %.0f%%\n", s.GrayFraction*100)
+	fmt.Printf("entropy:         %.2f bits\n", s.Entropy)
+	fmt.Printf("mood:            %s (%+.2f; %d warm, %d cool, %d neutral)\n", s.Temperature, s.Warmth, s.Warm, s.Cool, s.Neutral)
+	fmt.Printf("hues:\n")
+
+	most := 0
+	for _, n := range s.Hues {
+		most = max(most, n)
+	}
+	for i, n := range s.Hues {
+		bar := 0
+		if most > 0 {
+			bar = n * 40 / most
+		}
+		fmt.Printf("  %-10s %5d %s\n", wikimg.HueNames[i], n, strings.Repeat("#", bar))
+	}
+
+	return nil
+}
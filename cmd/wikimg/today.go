@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brnstz/routine/term"
+	"github.com/brnstz/routine/wikimg"
+)
+
+// today prints the color of the day: the top colors of everything a
+// server started with serve -today has analyzed recently, each with a bar
+// as long as its share of the pixels
+func today(args []string) error {
+	var addr, colorMode string
+	var over time.Duration
+	var k int
+
+	fs := flag.NewFlagSet("today", flag.ExitOnError)
+	fs.StringVar(&addr, "server", "http://localhost:8000", "URL of the server")
+	fs.DurationVar(&over, "over", 24*time.Hour, "how far back to look, e.g., 1h")
+	fs.IntVar(&k, "k", 10, "number of colors to print")
+	fs.StringVar(&colorMode, "color", "auto", "print terminal colors: always, never or auto")
+	fs.Parse(args)
+
+	mode, err := term.ParseMode(colorMode)
+	if err != nil {
+		return err
+	}
+
+	q := url.Values{}
+	q.Set("over", over.String())
+	q.Set("k", strconv.Itoa(k))
+
+	resp, err := http.Get(strings.TrimSuffix(addr, "/") + "/today?" + q.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("today: %s isn't counting colors, start it with serve -today", addr)
+	} else if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("today: %s returned %s", addr, resp.Status)
+	}
+
+	var top []wikimg.ColorCount
+	err = json.NewDecoder(resp.Body).Decode(&top)
+	if err != nil {
+		return err
+	}
+
+	if len(top) < 1 {
+		fmt.Printf("no colors in the last %v yet\n", over)
+		return nil
+	}
+
+	r := term.NewRenderer(os.Stdout, mode)
+	for _, cc := range top {
+		fmt.Printf("%s %5.1f%%\n", cc.Hex, cc.Share*100)
+
+		r.Width = max(1, int(cc.Share*80))
+		err = r.Bar(cc.Info())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/brnstz/routine/term"
+	"github.com/brnstz/routine/wikimg"
+)
+
+// view pulls and analyzes the latest images and shows their colors in a
+// live, full screen viewer (see term.Viewer) that can be paused, filtered
+// by hue, and open the selected image's page in the browser
+func view(args []string) error {
+	var pf pullFlags
+	var wf workerFlags
+	var sf sourceFlags
+	var keep int
+
+	fs := flag.NewFlagSet("view", flag.ExitOnError)
+	pf.register(fs, 1000)
+	wf.register(fs)
+	sf.register(fs)
+	fs.IntVar(&keep, "keep", 5000, "number of colors to keep for scrolling back through")
+	fs.Parse(args)
+
+	p := pf.puller()
+	src, err := sf.source(p, pf.max)
+	if err != nil {
+		return err
+	}
+
+	pulled := make(chan wikimg.Record)
+	analyzed := make(chan wikimg.Record)
+
+	err = wf.run(pulled, analyzed, func(ctx context.Context, rec wikimg.Record) wikimg.Record {
+		return analyzeRecord(ctx, p, rec)
+	})
+	if err != nil {
+		return err
+	}
+
+	go pullRecords(src, pulled)
+
+	// Failed images aren't shown
+	entries := make(chan term.Entry)
+	go func() {
+		defer close(entries)
+
+		for rec := range analyzed {
+			if rec.Color == nil {
+				continue
+			}
+
+			e := term.Entry{Info: *rec.Color, URL: rec.URL, Title: rec.Title}
+			if len(rec.Page) > 0 {
+				e.Page = rec.Page
+			} else if len(rec.Title) > 0 {
+				e.Page = wikimg.PageURL(rec.Title)
+			}
+			entries <- e
+		}
+	}()
+
+	v := term.NewViewer(os.Stdin, os.Stdout)
+	v.Keep = keep
+
+	return v.Run(entries)
+}
@@ -0,0 +1,176 @@
+// Package config lets the servers in this repository be configured with a
+// file and environment variables as well as flags, which is easier to
+// deploy and keep in version control.
+//
+// Every setting is a flag. A config file sets flags by name, so the flags
+// (and their -h output) are the documentation of the file. YAML, TOML and
+// JSON files are supported, picked by extension:
+//
+//	# server.yaml
+//	port: 8080
+//	workers: 50
+//	cache: 100000
+//	cachettl: 12h
+//	licenses: [cc0, cc-by]
+//
+// Each flag can also be set with an environment variable named after it
+// with a prefix, in upper case, with dashes replaced by underscores, e.g.,
+// WIKIMG_CACHETTL=12h for -cachettl. Flags given on the command line win
+// over the environment, which wins over the file, which wins over the
+// defaults:
+//
+//	var file string
+//	flag.StringVar(&file, "config", "", "config file")
+//	flag.Parse()
+//
+//	err := config.Load(flag.CommandLine, file, "WIKIMG_")
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Load sets the flags of fs that weren't given on the command line, first
+// from the config file at path, if it isn't empty, then from environment
+// variables starting with prefix, if it isn't empty. fs must already be
+// parsed. Settings in the file that aren't flags are an error, so typos
+// don't go unnoticed.
+func Load(fs *flag.FlagSet, path, prefix string) error {
+	// Flags given on the command line always win
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	if len(path) > 0 {
+		settings, err := ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		// Set them in order, so errors are deterministic
+		names := make([]string, 0, len(settings))
+		for name := range settings {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if fs.Lookup(name) == nil {
+				return fmt.Errorf("config: %s: unknown setting %q", path, name)
+			}
+			if given[name] {
+				continue
+			}
+
+			err = fs.Set(name, settings[name])
+			if err != nil {
+				return fmt.Errorf("config: %s: %s: %v", path, name, err)
+			}
+		}
+	}
+
+	if len(prefix) < 1 {
+		return nil
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || given[f.Name] {
+			return
+		}
+
+		key := EnvName(prefix, f.Name)
+		v, ok := os.LookupEnv(key)
+		if !ok {
+			return
+		}
+
+		if serr := fs.Set(f.Name, v); serr != nil {
+			err = fmt.Errorf("config: %s: %v", key, serr)
+		}
+	})
+
+	return err
+}
+
+// EnvName returns the environment variable that sets the flag called name
+func EnvName(prefix, name string) string {
+	return prefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// ReadFile reads the settings in the config file at path, as the strings
+// flags are set with. Lists are joined with commas.
+func ReadFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]any{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &values)
+	case ".toml":
+		err = toml.Unmarshal(b, &values)
+	case ".json":
+		err = json.Unmarshal(b, &values)
+	default:
+		return nil, fmt.Errorf("config: %s: unknown format %q, use .yaml, .toml or .json", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: %v", path, err)
+	}
+
+	settings := make(map[string]string, len(values))
+	for name, v := range values {
+		s, err := flagValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("config: %s: %s: %v", path, name, err)
+		}
+		settings[name] = s
+	}
+
+	return settings, nil
+}
+
+// flagValue returns v as a string flags can be set with
+func flagValue(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+
+	case []any:
+		elems := make([]string, len(v))
+		for i, e := range v {
+			s, err := flagValue(e)
+			if err != nil {
+				return "", err
+			}
+			elems[i] = s
+		}
+
+		return strings.Join(elems, ","), nil
+
+	case float64:
+		// JSON numbers are all float64, so large ones mustn't be
+		// printed with an exponent
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+
+	case map[string]any, nil:
+		return "", fmt.Errorf("expected a value or list but got %v", v)
+	}
+
+	// Other numbers and booleans
+	return fmt.Sprint(v), nil
+}
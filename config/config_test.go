@@ -0,0 +1,136 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// settings are the flags of a test server
+type settings struct {
+	port, workers, cache int
+	ttl                  time.Duration
+	licenses, operator   string
+	debug                bool
+}
+
+// newFlagSet returns a FlagSet for s parsed from args
+func newFlagSet(t *testing.T, s *settings, args ...string) *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.IntVar(&s.port, "port", 8000, "")
+	fs.IntVar(&s.workers, "workers", 25, "")
+	fs.IntVar(&s.cache, "cache", 50000, "")
+	fs.DurationVar(&s.ttl, "cache-ttl", 24*time.Hour, "")
+	fs.StringVar(&s.licenses, "licenses", "", "")
+	fs.StringVar(&s.operator, "operator", "", "")
+	fs.BoolVar(&s.debug, "debug", false, "")
+
+	err := fs.Parse(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fs
+}
+
+// writeFile writes contents to name in a temporary directory and returns
+// its path
+func writeFile(t *testing.T, name, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+
+	err := os.WriteFile(path, []byte(contents), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadFormats(t *testing.T) {
+	files := map[string]string{
+		"server.yaml": "port: 8080\nworkers: 50\ncache: 1000000\ncache-ttl: 12h\nlicenses: [cc0, cc-by]\ndebug: true\n",
+		"server.toml": "port = 8080\nworkers = 50\ncache = 1000000\ncache-ttl = \"12h\"\nlicenses = [\"cc0\", \"cc-by\"]\ndebug = true\n",
+		"server.json": `{"port": 8080, "workers": 50, "cache": 1000000, "cache-ttl": "12h", "licenses": ["cc0", "cc-by"], "debug": true}`,
+	}
+
+	expected := settings{port: 8080, workers: 50, cache: 1000000, ttl: 12 * time.Hour, licenses: "cc0,cc-by", debug: true}
+	for name, contents := range files {
+		var s settings
+		fs := newFlagSet(t, &s)
+
+		err := Load(fs, writeFile(t, name, contents), "")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		if s != expected {
+			t.Errorf("%s: expected %+v but got %+v", name, expected, s)
+		}
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "server.yaml", "port: 8080\nworkers: 50\ncache: 10\n")
+	t.Setenv("TEST_WORKERS", "60")
+	t.Setenv("TEST_CACHE", "20")
+	t.Setenv("TEST_CACHE_TTL", "1h")
+
+	// The command line beats the environment, which beats the file, which
+	// beats the defaults
+	var s settings
+	fs := newFlagSet(t, &s, "-cache", "30")
+	err := Load(fs, path, "TEST_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := settings{port: 8080, workers: 60, cache: 30, ttl: time.Hour}
+	if s != expected {
+		t.Errorf("expected %+v but got %+v", expected, s)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := map[string]string{
+		// Typos are caught
+		"typo.yaml": "prot: 8080\n",
+
+		// Values must parse as their flag
+		"bad.json": `{"port": "eighty"}`,
+
+		// Nested tables aren't flags
+		"nested.json": `{"port": {"http": 80}}`,
+
+		// Unknown formats
+		"server.ini": "port=80\n",
+	}
+
+	for name, contents := range tests {
+		var s settings
+		err := Load(newFlagSet(t, &s), writeFile(t, name, contents), "")
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	var s settings
+	t.Setenv("TEST_PORT", "eighty")
+	err := Load(newFlagSet(t, &s), "", "TEST_")
+	if err == nil {
+		t.Error("expected an error for an invalid environment variable")
+	}
+
+	err = Load(newFlagSet(t, &s), filepath.Join(t.TempDir(), "missing.yaml"), "")
+	if err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if name := EnvName("WIKIMG_", "cache-ttl"); name != "WIKIMG_CACHE_TTL" {
+		t.Errorf("expected WIKIMG_CACHE_TTL but got %s", name)
+	}
+}
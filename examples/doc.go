@@ -0,0 +1,6 @@
+// Package examples contains small runnable programs, one per directory, that
+// each demonstrate a single capability of the wikimg package using only its
+// public API. Run them with go run, e.g.:
+//
+//	go run ./examples/palettes
+package examples
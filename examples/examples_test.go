@@ -0,0 +1,24 @@
+package examples
+
+import (
+	"os/exec"
+	"testing"
+)
+
+// TestExamples vets and builds every example so they can't fall out of date
+// with the packages they use
+func TestExamples(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+
+	for _, args := range [][]string{
+		{"vet", "./..."},
+		{"build", "-o", t.TempDir(), "./..."},
+	} {
+		out, err := exec.Command("go", args...).CombinedOutput()
+		if err != nil {
+			t.Errorf("go %v: %v\n%s", args, err, out)
+		}
+	}
+}
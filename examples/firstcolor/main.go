@@ -0,0 +1,39 @@
+// Command firstcolor prints the first color of the most recent Commons
+// uploads as bars in the terminal.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/brnstz/routine/term"
+	"github.com/brnstz/routine/wikimg"
+)
+
+func main() {
+	var max int
+
+	flag.IntVar(&max, "max", 10, "maximum number of images to retrieve")
+	flag.Parse()
+
+	r := term.NewRenderer(os.Stdout, term.Auto)
+	p := wikimg.NewPuller(max)
+
+	for {
+		imgURL, err := p.Next()
+		if err == wikimg.EndOfResults {
+			break
+		} else if err != nil {
+			log.Fatal(err)
+		}
+
+		info, err := p.FirstColor(imgURL)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		r.Bar(info)
+	}
+}
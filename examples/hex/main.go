@@ -0,0 +1,26 @@
+// Command hex parses hex colors given as arguments and prints their nearest
+// xterm256 color.
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"os"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+func main() {
+	pal := color.Palette(wikimg.XTerm256)
+
+	for _, arg := range os.Args[1:] {
+		c, err := wikimg.ParseHex(arg)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		i := pal.Index(c)
+		fmt.Printf("%s => xterm %d (%s)\n", wikimg.Hex(c), i, wikimg.Hex(pal[i]))
+	}
+}
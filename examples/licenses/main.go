@@ -0,0 +1,35 @@
+// Command licenses lists recent uploads that are available under specific
+// licenses.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+func main() {
+	var max int
+	var licenses string
+
+	flag.IntVar(&max, "max", 10, "maximum number of images to retrieve")
+	flag.StringVar(&licenses, "licenses", "cc0,cc-by", "comma separated licenses to allow")
+	flag.Parse()
+
+	p := wikimg.NewPuller(max)
+	p.Licenses = strings.Split(licenses, ",")
+
+	for {
+		imgURL, err := p.Next()
+		if err == wikimg.EndOfResults {
+			break
+		} else if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Println(imgURL)
+	}
+}
@@ -0,0 +1,57 @@
+// Command palettes shows how the same image maps to different palettes,
+// including no palette at all.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"log"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+func main() {
+	var imgURL string
+
+	flag.StringVar(&imgURL, "url", "", "image URL (default is the latest upload)")
+	flag.Parse()
+
+	p := wikimg.NewPuller(1)
+
+	// Use the latest upload if we weren't given one
+	if len(imgURL) < 1 {
+		var err error
+		imgURL, err = p.Next()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	palettes := []struct {
+		name string
+		opts wikimg.ColorOptions
+	}{
+		{"xterm256", wikimg.ColorOptions{Palette: wikimg.XTerm256}},
+		{"xterm16", wikimg.ColorOptions{Palette: wikimg.XTerm16}},
+		{"websafe", wikimg.ColorOptions{Palette: wikimg.WebSafe}},
+		{"custom", wikimg.ColorOptions{Palette: color.Palette{
+			color.RGBA{0xe6, 0x39, 0x46, 0xff},
+			color.RGBA{0xf1, 0xfa, 0xee, 0xff},
+			color.RGBA{0x1d, 0x35, 0x57, 0xff},
+		}}},
+		{"none", wikimg.ColorOptions{Unquantized: true}},
+	}
+
+	fmt.Println(imgURL)
+	for _, pal := range palettes {
+		p.Options = pal.opts
+
+		info, err := p.FirstColor(imgURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("%-10s index=%-4d %s\n", pal.name, info.Index, info.Hex)
+	}
+}
@@ -0,0 +1,57 @@
+// Command thumbnails compares how long it takes to find the first color of
+// recent uploads using originals versus thumbnails with a sampling stride.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+func main() {
+	var max, width, stride int
+
+	flag.IntVar(&max, "max", 10, "maximum number of images to retrieve")
+	flag.IntVar(&width, "width", 320, "width of thumbnails")
+	flag.IntVar(&stride, "stride", 4, "scan every Nth pixel of thumbnails")
+	flag.Parse()
+
+	p := wikimg.NewPuller(max)
+
+	// A second puller for analyzing thumbnails
+	thumbs := wikimg.NewPuller(max)
+	thumbs.UseThumbnails(width)
+	thumbs.Options.Stride = stride
+
+	for {
+		imgURL, err := p.Next()
+		if err == wikimg.EndOfResults {
+			break
+		} else if err != nil {
+			log.Fatal(err)
+		}
+
+		start := time.Now()
+		orig, err := p.FirstColor(imgURL)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		origTime := time.Since(start)
+
+		start = time.Now()
+		thumb, err := thumbs.FirstColor(imgURL)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		thumbTime := time.Since(start)
+
+		fmt.Printf("original %s %-8v thumbnail %s %-8v\n",
+			orig.Hex, origTime.Round(time.Millisecond),
+			thumb.Hex, thumbTime.Round(time.Millisecond))
+	}
+}
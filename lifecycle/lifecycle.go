@@ -0,0 +1,159 @@
+// Package lifecycle ties the lifetimes of long running components (pullers,
+// worker pools, feeds, cache janitors, servers) to one cancellation, so a
+// program can shut everything down in order from a single place instead of
+// owning a channel per component.
+//
+// Components get their context from Context() and register a stop function
+// with Add(). Shutdown() cancels the context, waits for goroutines started
+// with Go(), then stops components in the reverse order they were added, so
+// a server added after the pool it uses is stopped first.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// StopFunc stops a component, giving up when ctx is done
+type StopFunc func(ctx context.Context) error
+
+// component is a registered component
+type component struct {
+	name string
+	stop StopFunc
+}
+
+// Group is a set of components that are shut down together. The zero value
+// isn't usable, use New().
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// wg counts goroutines started with Go()
+	wg sync.WaitGroup
+
+	components []component
+	stopped    bool
+	mutex      sync.Mutex
+}
+
+// Default is the process wide group used by the package level functions
+var Default = New(context.Background())
+
+// New creates a Group whose context is canceled when parent is, or when the
+// group is shut down
+func New(parent context.Context) *Group {
+	ctx, cancel := context.WithCancel(parent)
+
+	return &Group{ctx: ctx, cancel: cancel}
+}
+
+// Context returns the context components should stop work on when it's done
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+// Done returns a channel that's closed when the group starts shutting down.
+// It can be used directly as wikimg.Puller.Cancel.
+func (g *Group) Done() <-chan struct{} {
+	return g.ctx.Done()
+}
+
+// Add registers a component to be stopped on shutdown. If the group is
+// already shut down, stop is called right away.
+func (g *Group) Add(name string, stop StopFunc) {
+	g.mutex.Lock()
+	if !g.stopped {
+		g.components = append(g.components, component{name, stop})
+		g.mutex.Unlock()
+
+		return
+	}
+	g.mutex.Unlock()
+
+	stop(g.ctx)
+}
+
+// Go runs fn in a goroutine with the group's context. Shutdown waits for it
+// to return before stopping components.
+func (g *Group) Go(fn func(ctx context.Context)) {
+	g.wg.Add(1)
+
+	go func() {
+		defer g.wg.Done()
+		fn(g.ctx)
+	}()
+}
+
+// Shutdown cancels the group's context, waits for goroutines started with
+// Go(), then stops components in the reverse order they were added. If ctx
+// is done first, it returns ctx.Err() along with the errors so far, without
+// waiting for the rest. Calling it again does nothing.
+func (g *Group) Shutdown(ctx context.Context) error {
+	g.mutex.Lock()
+	if g.stopped {
+		g.mutex.Unlock()
+		return nil
+	}
+	g.stopped = true
+	components := g.components
+	g.components = nil
+	g.mutex.Unlock()
+
+	g.cancel()
+
+	// Wait for goroutines, unless we run out of time
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+
+		err := components[i].stop(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("lifecycle: stopping %s: %w", components[i].name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Context returns the context of the Default group
+func Context() context.Context {
+	return Default.Context()
+}
+
+// Done returns the Done channel of the Default group
+func Done() <-chan struct{} {
+	return Default.Done()
+}
+
+// Add registers a component with the Default group
+func Add(name string, stop StopFunc) {
+	Default.Add(name, stop)
+}
+
+// Go runs fn in a goroutine of the Default group
+func Go(fn func(ctx context.Context)) {
+	Default.Go(fn)
+}
+
+// Shutdown shuts down the Default group
+func Shutdown(ctx context.Context) error {
+	return Default.Shutdown(ctx)
+}
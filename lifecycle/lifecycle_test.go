@@ -0,0 +1,88 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	g := New(context.Background())
+
+	var order []string
+	stop := func(name string) StopFunc {
+		return func(ctx context.Context) error {
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	g.Add("pool", stop("pool"))
+	g.Add("server", stop("server"))
+
+	// Goroutines finish before components are stopped
+	g.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		order = append(order, "worker")
+	})
+
+	err := g.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"worker", "server", "pool"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected %v but got %v", expected, order)
+	}
+
+	select {
+	case <-g.Done():
+	default:
+		t.Errorf("expected Done to be closed")
+	}
+
+	// Components added late are stopped right away
+	g.Add("late", stop("late"))
+	if order[len(order)-1] != "late" {
+		t.Errorf("expected late component to be stopped")
+	}
+}
+
+func TestShutdownErrors(t *testing.T) {
+	g := New(context.Background())
+
+	failed := errors.New("failed")
+	g.Add("cache", func(ctx context.Context) error {
+		return failed
+	})
+
+	if err := g.Shutdown(context.Background()); !errors.Is(err, failed) {
+		t.Errorf("expected error wrapping %v but got %v", failed, err)
+	}
+
+	if err := g.Shutdown(context.Background()); err != nil {
+		t.Errorf("expected second shutdown to do nothing but got %v", err)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	g := New(context.Background())
+
+	// This goroutine ignores cancellation
+	block := make(chan struct{})
+	defer close(block)
+	g.Go(func(ctx context.Context) {
+		<-block
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := g.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded but got %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+// Package lru is a bounded, expiring cache that drops the least recently
+// used entry when it's full. It's safe for concurrent use.
+package lru
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// entry is a cached value
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// Cache maps keys to values, holding at most a fixed number of entries, each
+// for at most a fixed time. The zero value isn't usable, use New().
+type Cache[K comparable, V any] struct {
+	size int
+	ttl  time.Duration
+
+	// items maps keys to their elements in order, which runs from most to
+	// least recently used
+	items map[K]*list.Element
+	order *list.List
+	mutex sync.Mutex
+
+	// onEvict is called with each entry that's removed
+	onEvict func(key K, value V)
+}
+
+// New creates a cache that holds up to size entries, each for up to ttl. A
+// ttl of zero keeps entries until they're dropped to make room. A cache with
+// a size less than 1 holds nothing.
+func New[K comparable, V any](size int, ttl time.Duration) *Cache[K, V] {
+	return &Cache[K, V]{
+		size:  size,
+		ttl:   ttl,
+		items: map[K]*list.Element{},
+		order: list.New(),
+	}
+}
+
+// OnEvict sets fn to be called with every entry that's removed from the
+// cache, whether it's dropped to make room, found to have expired or
+// removed with Remove, e.g., to keep an index of the cache up to date.
+// Entries replaced by Add aren't removed. fn is called while the cache is
+// locked, so it must not call methods of the cache. Call OnEvict before
+// using the cache.
+func (c *Cache[K, V]) OnEvict(fn func(key K, value V)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.onEvict = fn
+}
+
+// Len returns the number of entries, including any that have expired but
+// haven't been removed yet
+func (c *Cache[K, V]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.order.Len()
+}
+
+// Get returns the value of key, if it's cached and hasn't expired, and
+// marks it as the most recently used
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var zero V
+
+	el, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+
+	e := el.Value.(*entry[K, V])
+	if c.expired(e, time.Now()) {
+		c.remove(el)
+		return zero, false
+	}
+
+	c.order.MoveToFront(el)
+
+	return e.value, true
+}
+
+// Peek is like Get, but doesn't mark the entry as used
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var zero V
+
+	el, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+
+	e := el.Value.(*entry[K, V])
+	if c.expired(e, time.Now()) {
+		c.remove(el)
+		return zero, false
+	}
+
+	return e.value, true
+}
+
+// Add saves value for key as the most recently used entry, dropping the
+// least recently used entry if the cache is full
+func (c *Cache[K, V]) Add(key K, value V) {
+	c.AddTTL(key, value, c.ttl)
+}
+
+// AddTTL is like Add, but the entry expires after ttl instead of the
+// cache's TTL. A ttl of zero keeps it until it's dropped to make room.
+func (c *Cache[K, V]) AddTTL(key K, value V, ttl time.Duration) {
+	if c.size < 1 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e := &entry[K, V]{key: key, value: value}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+
+	if el, ok := c.items[key]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+
+		return
+	}
+
+	if c.order.Len() >= c.size {
+		c.remove(c.order.Back())
+	}
+
+	c.items[key] = c.order.PushFront(e)
+}
+
+// Remove drops key from the cache, if it's there
+func (c *Cache[K, V]) Remove(key K) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Each calls fn with every entry that hasn't expired, from most to least
+// recently used, until fn returns false. Entries aren't marked as used. fn
+// must not call other methods of the cache.
+func (c *Cache[K, V]) Each(fn func(key K, value V) bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*entry[K, V])
+		if c.expired(e, now) {
+			continue
+		}
+
+		if !fn(e.key, e.value) {
+			return
+		}
+	}
+}
+
+// expired returns true if e has expired at now
+func (c *Cache[K, V]) expired(e *entry[K, V], now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+// remove drops el from the cache. The caller must hold the lock.
+func (c *Cache[K, V]) remove(el *list.Element) {
+	e := el.Value.(*entry[K, V])
+
+	c.order.Remove(el)
+	delete(c.items, e.key)
+
+	if c.onEvict != nil {
+		c.onEvict(e.key, e.value)
+	}
+}
@@ -0,0 +1,104 @@
+package lru
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c := New[string, int](2, 0)
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+
+	// Using a makes b the least recently used
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("expected a to be 1 but got %d, %v", v, ok)
+	}
+
+	c.Add("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("expected b to be dropped")
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries but got %d", c.Len())
+	}
+
+	// Adding an existing key replaces it without dropping anything
+	c.Add("a", 10)
+	if v, _ := c.Get("a"); v != 10 || c.Len() != 2 {
+		t.Errorf("expected a to be replaced but got %d with %d entries", v, c.Len())
+	}
+
+	// Each goes from most to least recently used
+	var keys []string
+	c.Each(func(k string, v int) bool {
+		keys = append(keys, k)
+		return true
+	})
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "c" {
+		t.Errorf("expected [a c] but got %v", keys)
+	}
+
+	c.Remove("a")
+	if _, ok := c.Get("a"); ok || c.Len() != 1 {
+		t.Errorf("expected a to be removed")
+	}
+
+	// Nothing is held without a size
+	empty := New[string, int](0, 0)
+	empty.Add("a", 1)
+	if empty.Len() != 0 {
+		t.Errorf("expected an empty cache to stay empty")
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c := New[int, string](2, time.Millisecond)
+	c.Add(1, "a")
+	time.Sleep(5 * time.Millisecond)
+
+	c.Each(func(k int, v string) bool {
+		t.Errorf("expected %d to be skipped once expired", k)
+		return true
+	})
+
+	if _, ok := c.Get(1); ok {
+		t.Errorf("expected 1 to expire")
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected expired entry to be removed")
+	}
+
+	// Entries can have their own TTL
+	c.AddTTL(2, "b", time.Hour)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.Get(2); !ok {
+		t.Errorf("expected 2 to outlive the cache's TTL")
+	}
+}
+
+func TestCacheOnEvict(t *testing.T) {
+	c := New[string, int](2, 0)
+
+	evicted := map[string]int{}
+	c.OnEvict(func(key string, value int) {
+		evicted[key] = value
+	})
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+
+	// Peeking doesn't save a from being dropped
+	if v, ok := c.Peek("a"); !ok || v != 1 {
+		t.Errorf("expected a to be 1 but got %d, %v", v, ok)
+	}
+
+	c.Add("b", 20)
+	c.Add("c", 3)
+	c.Remove("b")
+
+	if len(evicted) != 2 || evicted["a"] != 1 || evicted["b"] != 20 {
+		t.Errorf("expected a and b to be evicted but got %v", evicted)
+	}
+}
@@ -0,0 +1,551 @@
+// Package notify posts to webhooks when analyzed images match rules, e.g.,
+// to tell a Slack channel whenever an image close to a brand's color is
+// uploaded, or whenever a given user uploads anything:
+//
+//	rules, err := notify.ParseRules(f)
+//	if err != nil {
+//		return err
+//	}
+//
+//	n, err := notify.New(rules)
+//	if err != nil {
+//		return err
+//	}
+//	go n.Run(ctx)
+//
+//	// For each analyzed image
+//	n.Notify(img, info)
+//
+//	// For each batch of results, e.g., a pull cycle
+//	n.Report(results)
+//
+// Each webhook has its own queue and is posted to in order, at most once
+// every Every, so a busy rule can't get the webhook blocked. Failed posts
+// are retried with exponential backoff. Notify never blocks the analysis:
+// matches for a webhook that has fallen too far behind are dropped.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// Formats of the body posted to a webhook
+const (
+	// Generic posts a Match as JSON
+	Generic = "generic"
+
+	// Slack posts a message to a Slack incoming webhook
+	Slack = "slack"
+
+	// Discord posts a message with an embedded image to a Discord
+	// webhook
+	Discord = "discord"
+)
+
+const (
+	// DefaultTolerance is the default for Rule.Tolerance
+	DefaultTolerance = 10
+
+	// DefaultRetries is the default for Notifier.Retries
+	DefaultRetries = 3
+
+	// DefaultBackoff is the default for Notifier.Backoff
+	DefaultBackoff = time.Second
+
+	// DefaultEvery is the default for Notifier.Every
+	DefaultEvery = time.Second
+
+	// queueSize is how many matches a webhook can fall behind before
+	// they're dropped
+	queueSize = 100
+)
+
+// Rule says which images to post to a webhook. An image must meet every
+// condition that's set.
+type Rule struct {
+	// Name identifies the rule in what's posted
+	Name string `json:"name"`
+
+	// Summary posts a summary of every batch of results passed to
+	// Report instead of matching images, e.g., to report on the colors
+	// of each pull cycle. Summary rules have no conditions.
+	Summary bool `json:"summary,omitempty"`
+
+	// Near is a hex color, e.g., "#ff0000". Images whose color is within
+	// Tolerance of it match.
+	Near string `json:"near,omitempty"`
+
+	// Tolerance is the largest CIE76 difference from Near that matches
+	// (see wikimg.DeltaE). Zero means DefaultTolerance.
+	Tolerance float64 `json:"tolerance,omitempty"`
+
+	// Uploader is the name of a user whose uploads match, in any case
+	Uploader string `json:"uploader,omitempty"`
+
+	// Webhook is the URL posted to
+	Webhook string `json:"webhook"`
+
+	// Format is the format of the body posted, Generic, Slack or
+	// Discord. Empty means Generic.
+	Format string `json:"format,omitempty"`
+
+	// near is Near parsed
+	near color.Color
+}
+
+// ParseRules reads rules from JSON, an array of rules, e.g.:
+//
+//	[
+//		{"name": "red", "near": "#ff0000", "tolerance": 20, "webhook": "https://hooks.slack.com/services/...", "format": "slack"},
+//		{"name": "alice", "uploader": "Alice", "webhook": "https://example.com/hook"},
+//		{"name": "daily", "summary": true, "webhook": "https://example.com/hook"}
+//	]
+//
+// Every rule needs a name, a webhook and at least one condition, unless it
+// posts summaries.
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+
+	err := json.NewDecoder(r).Decode(&rules)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rules {
+		err = rules[i].parse()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rules, nil
+}
+
+// parse checks the rule and parses its color
+func (r *Rule) parse() error {
+	if len(r.Name) < 1 {
+		return fmt.Errorf("notify: rule without a name")
+	}
+	if len(r.Webhook) < 1 {
+		return fmt.Errorf("notify: %s: no webhook", r.Name)
+	}
+	if r.Summary && (len(r.Near) > 0 || len(r.Uploader) > 0) {
+		return fmt.Errorf("notify: %s: summary rules can't have conditions", r.Name)
+	}
+	if !r.Summary && len(r.Near) < 1 && len(r.Uploader) < 1 {
+		return fmt.Errorf("notify: %s: no conditions, set near or uploader", r.Name)
+	}
+
+	switch r.Format {
+	case "", Generic, Slack, Discord:
+	default:
+		return fmt.Errorf("notify: %s: unknown format %q (must be generic, slack or discord)", r.Name, r.Format)
+	}
+
+	if len(r.Near) > 0 {
+		c, err := wikimg.ParseHex(r.Near)
+		if err != nil {
+			return fmt.Errorf("notify: %s: %v", r.Name, err)
+		}
+		r.near = c
+	}
+
+	return nil
+}
+
+// matches returns true if the image meets every condition of the rule.
+// Images never match summary rules.
+func (r Rule) matches(img wikimg.ImageInfo, info wikimg.ColorInfo) bool {
+	if r.Summary {
+		return false
+	}
+
+	if len(r.Uploader) > 0 && !strings.EqualFold(r.Uploader, img.Uploader) {
+		return false
+	}
+
+	if r.near != nil {
+		tolerance := r.Tolerance
+		if tolerance <= 0 {
+			tolerance = DefaultTolerance
+		}
+
+		c := color.NRGBA{info.R, info.G, info.B, 0xff}
+		if wikimg.DeltaE(r.near, c) > tolerance {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Match is an image that matched a rule, as posted in the Generic format
+type Match struct {
+	Rule     string    `json:"rule"`
+	URL      string    `json:"url"`
+	Page     string    `json:"page,omitempty"`
+	Title    string    `json:"title,omitempty"`
+	Uploader string    `json:"uploader,omitempty"`
+	Uploaded time.Time `json:"uploaded,omitzero"`
+	Hex      string    `json:"hex"`
+}
+
+// Report is a summary of a batch of results, as posted in the Generic
+// format. It's computed by wikimg.Summarize, so it agrees with every other
+// summary of the same results (e.g., the summary command's).
+type Report struct {
+	Rule    string         `json:"rule"`
+	Summary wikimg.Summary `json:"summary"`
+}
+
+// delivery is a match or report waiting to be posted
+type delivery struct {
+	rule   Rule
+	match  Match
+	report *Report
+}
+
+// Notifier posts to the webhooks of rules that images match. Create one
+// with New and set its fields before calling Run.
+type Notifier struct {
+	// Client is used to post to webhooks. If nil, http.DefaultClient is
+	// used.
+	Client *http.Client
+
+	// Retries is how many times a failed post is retried. Zero means
+	// DefaultRetries.
+	Retries int
+
+	// Backoff is how long to wait before the first retry, doubled for
+	// each one after it. A 429 Too Many Requests response with a
+	// Retry-After header waits that long instead. Zero means
+	// DefaultBackoff.
+	Backoff time.Duration
+
+	// Every is the shortest time between posts to one webhook. Zero
+	// means DefaultEvery.
+	Every time.Duration
+
+	// Logger is where failed and dropped posts are logged. If nil,
+	// nothing is logged.
+	Logger wikimg.Logger
+
+	rules  []Rule
+	queues map[string]chan delivery
+
+	sent, failed, dropped atomic.Int64
+}
+
+// New creates a Notifier for rules, returning an error if any are invalid
+// (see ParseRules)
+func New(rules []Rule) (*Notifier, error) {
+	n := &Notifier{queues: map[string]chan delivery{}}
+	for _, r := range rules {
+		err := r.parse()
+		if err != nil {
+			return nil, err
+		}
+		n.rules = append(n.rules, r)
+
+		if _, ok := n.queues[r.Webhook]; !ok {
+			n.queues[r.Webhook] = make(chan delivery, queueSize)
+		}
+	}
+
+	return n, nil
+}
+
+// Notify queues a post to the webhook of every rule the image matches. It
+// never blocks.
+func (n *Notifier) Notify(img wikimg.ImageInfo, info wikimg.ColorInfo) {
+	for _, r := range n.rules {
+		if !r.matches(img, info) {
+			continue
+		}
+
+		page := img.Page
+		if len(page) < 1 && len(img.Title) > 0 {
+			page = wikimg.PageURL(img.Title)
+		}
+
+		d := delivery{rule: r, match: Match{
+			Rule:     r.Name,
+			URL:      img.URL,
+			Page:     page,
+			Title:    img.Title,
+			Uploader: img.Uploader,
+			Uploaded: img.Uploaded,
+			Hex:      info.Hex,
+		}}
+
+		select {
+		case n.queues[r.Webhook] <- d:
+		default:
+			n.dropped.Add(1)
+			n.log("notify: webhook is behind, dropped match", "rule", r.Name, "url", img.URL)
+		}
+	}
+}
+
+// Report queues a post of the summary of results to the webhook of every
+// summary rule. It never blocks.
+func (n *Notifier) Report(results []wikimg.ColorResult) {
+	var summary *wikimg.Summary
+	for _, r := range n.rules {
+		if !r.Summary {
+			continue
+		}
+
+		if summary == nil {
+			s := wikimg.Summarize(results)
+			summary = &s
+		}
+
+		d := delivery{rule: r, report: &Report{Rule: r.Name, Summary: *summary}}
+
+		select {
+		case n.queues[r.Webhook] <- d:
+		default:
+			n.dropped.Add(1)
+			n.log("notify: webhook is behind, dropped report", "rule", r.Name)
+		}
+	}
+}
+
+// Stats returns the number of posts that were sent, failed after every
+// retry, and were dropped because their webhook was behind
+func (n *Notifier) Stats() (sent, failed, dropped int64) {
+	return n.sent.Load(), n.failed.Load(), n.dropped.Load()
+}
+
+// Run posts queued matches until ctx is done, then returns ctx.Err().
+// Matches still queued are dropped.
+func (n *Notifier) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for webhook, q := range n.queues {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.deliver(ctx, webhook, q)
+		}()
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// deliver posts the matches in q to webhook in order, one every n.Every
+func (n *Notifier) deliver(ctx context.Context, webhook string, q chan delivery) {
+	every := n.Every
+	if every <= 0 {
+		every = DefaultEvery
+	}
+
+	var last time.Time
+	for {
+		var d delivery
+		select {
+		case d = <-q:
+		case <-ctx.Done():
+			return
+		}
+
+		if wait := time.Until(last.Add(every)); wait > 0 && !sleep(ctx, wait) {
+			return
+		}
+		last = time.Now()
+
+		err := n.post(ctx, d)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			n.failed.Add(1)
+			n.log("notify: couldn't post to webhook", "rule", d.rule.Name, "url", d.match.URL, "err", err)
+			continue
+		}
+		n.sent.Add(1)
+	}
+}
+
+// post posts d to its webhook, retrying failures
+func (n *Notifier) post(ctx context.Context, d delivery) error {
+	body, err := encode(d)
+	if err != nil {
+		return err
+	}
+
+	retries := n.Retries
+	if retries <= 0 {
+		retries = DefaultRetries
+	}
+	backoff := n.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", d.rule.Webhook, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		wait := backoff << attempt
+		resp, err := client.Do(req)
+		if err == nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+			resp.Body.Close()
+
+			switch {
+			case resp.StatusCode/100 == 2:
+				return nil
+
+			case resp.StatusCode == http.StatusTooManyRequests:
+				if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+					wait = time.Duration(secs) * time.Second
+				}
+
+			case resp.StatusCode < 500:
+				// The request is wrong, retrying won't help
+				return fmt.Errorf("notify: webhook returned %s", resp.Status)
+			}
+
+			err = fmt.Errorf("notify: webhook returned %s", resp.Status)
+		}
+
+		if attempt >= retries {
+			return err
+		}
+		if !sleep(ctx, wait) {
+			return ctx.Err()
+		}
+	}
+}
+
+// encode returns the body posted for d in its rule's format
+func encode(d delivery) ([]byte, error) {
+	if d.report != nil {
+		return encodeReport(d)
+	}
+
+	m := d.match
+
+	name := m.Title
+	if len(name) < 1 {
+		name = m.URL
+	}
+	link := m.Page
+	if len(link) < 1 {
+		link = m.URL
+	}
+	about := fmt.Sprintf(" matched %s (%s)", m.Rule, m.Hex)
+	if len(m.Uploader) > 0 {
+		about += " uploaded by " + m.Uploader
+	}
+
+	switch d.rule.Format {
+	case Slack:
+		return json.Marshal(map[string]string{
+			"text": "<" + link + "|" + slackEscaper.Replace(name) + ">" + slackEscaper.Replace(about),
+		})
+
+	case Discord:
+		hex, _ := strconv.ParseInt(strings.TrimPrefix(m.Hex, "#"), 16, 32)
+
+		return json.Marshal(map[string]any{
+			"content": name + about,
+			"embeds": []map[string]any{{
+				"title": name,
+				"url":   link,
+				"color": hex,
+				"image": map[string]string{"url": m.URL},
+			}},
+		})
+
+	default:
+		return json.Marshal(m)
+	}
+}
+
+// encodeReport returns the body posted for the report in d in its rule's
+// format
+func encodeReport(d delivery) ([]byte, error) {
+	r := d.report
+	s := r.Summary
+
+	text := fmt.Sprintf("%s: %d images", r.Rule, s.Images)
+	if s.Errors > 0 {
+		text += fmt.Sprintf(" (%d failed)", s.Errors)
+	}
+	if top := topHue(s); top >= 0 {
+		text += ", mostly " + wikimg.HueNames[top]
+	}
+	text += fmt.Sprintf(", %.0f%% gray, mean saturation %.2f, %s", 100*s.GrayFraction, s.MeanSaturation, s.Temperature)
+
+	switch d.rule.Format {
+	case Slack:
+		return json.Marshal(map[string]string{"text": slackEscaper.Replace(text)})
+
+	case Discord:
+		return json.Marshal(map[string]string{"content": text})
+
+	default:
+		return json.Marshal(r)
+	}
+}
+
+// topHue returns the hue bin of s with the most colors, or -1 if there are
+// none
+func topHue(s wikimg.Summary) int {
+	top := -1
+	for i, n := range s.Hues {
+		if n > 0 && (top < 0 || n > s.Hues[top]) {
+			top = i
+		}
+	}
+
+	return top
+}
+
+// slackEscaper escapes the characters Slack messages use for markup
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// sleep waits for d, returning false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// log logs a warning, if there's a Logger
+func (n *Notifier) log(msg string, args ...any) {
+	if n.Logger != nil {
+		n.Logger.Warn(msg, args...)
+	}
+}
@@ -0,0 +1,282 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// red is the color of a red image
+var red = wikimg.ColorInfo{Hex: "#ff0000", R: 0xff}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(`[
+		{"name": "red", "near": "#ff0000", "tolerance": 20, "webhook": "http://example.com/a", "format": "slack"},
+		{"name": "alice", "uploader": "Alice", "webhook": "http://example.com/b"},
+		{"name": "daily", "summary": true, "webhook": "http://example.com/c"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 || rules[0].near == nil || rules[1].Format != "" || !rules[2].Summary {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+
+	for _, bad := range []string{
+		`[{"near": "#ff0000", "webhook": "http://example.com"}]`,
+		`[{"name": "a", "near": "#ff0000"}]`,
+		`[{"name": "a", "webhook": "http://example.com"}]`,
+		`[{"name": "a", "near": "red", "webhook": "http://example.com"}]`,
+		`[{"name": "a", "uploader": "Alice", "webhook": "http://example.com", "format": "irc"}]`,
+		`[{"name": "a", "summary": true, "near": "#ff0000", "webhook": "http://example.com"}]`,
+		`{}`,
+	} {
+		if _, err := ParseRules(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected an error for %s", bad)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	rules, _ := ParseRules(strings.NewReader(`[
+		{"name": "red", "near": "#ff0000", "webhook": "w"},
+		{"name": "alice's red", "near": "#ff0000", "uploader": "alice", "webhook": "w"}
+	]`))
+	nearRed, byAlice := rules[0], rules[1]
+
+	darkRed := wikimg.ColorInfo{Hex: "#f00000", R: 0xf0}
+	blue := wikimg.ColorInfo{Hex: "#0000ff", B: 0xff}
+	alice := wikimg.ImageInfo{Uploader: "Alice"}
+
+	if !nearRed.matches(wikimg.ImageInfo{}, darkRed) || nearRed.matches(wikimg.ImageInfo{}, blue) {
+		t.Error("expected only colors near red to match")
+	}
+	if !byAlice.matches(alice, red) || byAlice.matches(wikimg.ImageInfo{Uploader: "Bob"}, red) || byAlice.matches(alice, blue) {
+		t.Error("expected only red images by Alice to match")
+	}
+}
+
+// recorder is a fake webhook that fails its first failures posts with
+// status
+type recorder struct {
+	status   int
+	failures int
+
+	bodies [][]byte
+	times  []time.Time
+	mutex  sync.Mutex
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	b, _ := io.ReadAll(r.Body)
+	rec.bodies = append(rec.bodies, b)
+	rec.times = append(rec.times, time.Now())
+
+	if len(rec.bodies) <= rec.failures {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(rec.status)
+	}
+}
+
+// posts returns the bodies posted so far
+func (rec *recorder) posts() [][]byte {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	return append([][]byte(nil), rec.bodies...)
+}
+
+// run starts n and waits until fn returns true, for up to a few seconds
+func run(t *testing.T, n *Notifier, fn func() bool) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- n.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for start := time.Now(); !fn(); time.Sleep(5 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("timed out")
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	rec := &recorder{}
+	ts := httptest.NewServer(rec)
+	defer ts.Close()
+
+	n, err := New([]Rule{
+		{Name: "generic", Near: "#ff0000", Webhook: ts.URL},
+		{Name: "slack", Near: "#ff0000", Webhook: ts.URL, Format: Slack},
+		{Name: "discord", Near: "#ff0000", Webhook: ts.URL, Format: Discord},
+		{Name: "blue", Near: "#0000ff", Webhook: ts.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Every = 20 * time.Millisecond
+
+	n.Notify(wikimg.ImageInfo{URL: "http://example.com/a.png", Title: "File:A <1>.png", Uploader: "Alice"}, red)
+	run(t, n, func() bool {
+		sent, _, _ := n.Stats()
+		return sent >= 3
+	})
+
+	posts := rec.posts()
+
+	var m Match
+	err = json.Unmarshal(posts[0], &m)
+	if err != nil || m.Rule != "generic" || m.Hex != "#ff0000" || m.Page != "https://commons.wikimedia.org/wiki/File:A_%3C1%3E.png" {
+		t.Errorf("unexpected generic post %s", posts[0])
+	}
+
+	var slack struct{ Text string }
+	json.Unmarshal(posts[1], &slack)
+	if slack.Text != "<https://commons.wikimedia.org/wiki/File:A_%3C1%3E.png|File:A &lt;1&gt;.png> matched slack (#ff0000) uploaded by Alice" {
+		t.Errorf("unexpected slack post %s", posts[1])
+	}
+
+	var discord struct {
+		Content string
+		Embeds  []struct {
+			Color int
+			Image struct{ URL string }
+		}
+	}
+	json.Unmarshal(posts[2], &discord)
+	if len(discord.Embeds) != 1 || discord.Embeds[0].Color != 0xff0000 || discord.Embeds[0].Image.URL != "http://example.com/a.png" {
+		t.Errorf("unexpected discord post %s", posts[2])
+	}
+
+	// Posts to the webhook are spaced out, give or take the scheduler
+	rec.mutex.Lock()
+	gap := rec.times[2].Sub(rec.times[1])
+	rec.mutex.Unlock()
+	if gap < n.Every-10*time.Millisecond {
+		t.Errorf("expected posts about %v apart but got %v", n.Every, gap)
+	}
+
+	if sent, failed, dropped := n.Stats(); sent != 3 || failed != 0 || dropped != 0 {
+		t.Errorf("unexpected stats %d %d %d", sent, failed, dropped)
+	}
+}
+
+func TestReport(t *testing.T) {
+	rec := &recorder{}
+	ts := httptest.NewServer(rec)
+	defer ts.Close()
+
+	n, err := New([]Rule{
+		{Name: "red", Near: "#ff0000", Webhook: ts.URL},
+		{Name: "daily", Summary: true, Webhook: ts.URL},
+		{Name: "slack", Summary: true, Webhook: ts.URL, Format: Slack},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Every = time.Millisecond
+
+	blue := wikimg.ColorInfo{Hex: "#0000ff", B: 0xff, H: 240, S: 1, L: 0.5}
+	results := []wikimg.ColorResult{
+		{URL: "a", Info: red},
+		{URL: "b", Info: blue},
+		{URL: "c", Info: blue},
+		{URL: "d", Err: wikimg.Canceled},
+	}
+	n.Report(results)
+	run(t, n, func() bool {
+		sent, _, _ := n.Stats()
+		return sent >= 2
+	})
+
+	// Only summary rules get reports, computed the same way as any other
+	// summary
+	posts := rec.posts()
+	if len(posts) != 2 {
+		t.Fatalf("expected 2 reports but got %d", len(posts))
+	}
+
+	var report Report
+	err = json.Unmarshal(posts[0], &report)
+	if err != nil || report.Rule != "daily" || report.Summary != wikimg.Summarize(results) {
+		t.Errorf("unexpected report %s, %v", posts[0], err)
+	}
+
+	var slack struct{ Text string }
+	json.Unmarshal(posts[1], &slack)
+	if slack.Text != "slack: 4 images (1 failed), mostly blue, 0% gray, mean saturation 0.67, cool" {
+		t.Errorf("unexpected slack report %s", posts[1])
+	}
+}
+
+func TestNotifyRetry(t *testing.T) {
+	for _, test := range []struct {
+		status   int
+		failures int
+		posts    int
+		sent     int64
+	}{
+		// Server errors and rate limits are retried
+		{http.StatusInternalServerError, 2, 3, 1},
+		{http.StatusTooManyRequests, 1, 2, 1},
+
+		// Until there are no retries left
+		{http.StatusBadGateway, 10, 3, 0},
+
+		// Bad requests aren't
+		{http.StatusBadRequest, 1, 1, 0},
+	} {
+		rec := &recorder{status: test.status, failures: test.failures}
+		ts := httptest.NewServer(rec)
+
+		n, _ := New([]Rule{{Name: "red", Near: "#ff0000", Webhook: ts.URL}})
+		n.Retries = 2
+		n.Backoff = time.Millisecond
+
+		n.Notify(wikimg.ImageInfo{URL: "a"}, red)
+		run(t, n, func() bool {
+			sent, failed, _ := n.Stats()
+			return sent+failed > 0
+		})
+
+		if posts := len(rec.posts()); posts != test.posts {
+			t.Errorf("%d: expected %d posts but got %d", test.status, test.posts, posts)
+		}
+		if sent, _, _ := n.Stats(); sent != test.sent {
+			t.Errorf("%d: expected %d sent but got %d", test.status, test.sent, sent)
+		}
+
+		ts.Close()
+	}
+}
+
+func TestNotifyDropped(t *testing.T) {
+	n, _ := New([]Rule{{Name: "red", Near: "#ff0000", Webhook: "http://example.com"}})
+
+	// Nothing is delivering, so the queue fills up
+	for i := 0; i < queueSize+5; i++ {
+		n.Notify(wikimg.ImageInfo{URL: "a"}, red)
+	}
+
+	if _, _, dropped := n.Stats(); dropped != 5 {
+		t.Errorf("expected 5 dropped but got %d", dropped)
+	}
+}
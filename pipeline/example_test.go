@@ -0,0 +1,30 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/brnstz/routine/pipeline"
+)
+
+func Example() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Upper case words with three workers, then gather the results
+	words := pipeline.Generate(ctx, "red", "green", "blue")
+	upper := func(ctx context.Context, s string) string { return strings.ToUpper(s) }
+	results := pipeline.FanIn(ctx, pipeline.FanOut(ctx, words, 3, upper)...)
+
+	// Results arrive in whatever order the workers finish
+	var out []string
+	for s := range results {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+
+	fmt.Println(out)
+	// Output: [BLUE GREEN RED]
+}
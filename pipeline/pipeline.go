@@ -0,0 +1,182 @@
+// Package pipeline connects stages of channels, the patterns the numbered
+// examples in this repository build up by hand, as tested generic helpers.
+// Every helper stops when its context is done, closing the channels it
+// returns, so a canceled pipeline doesn't leak goroutines:
+//
+//	urls := pipeline.Generate(ctx, allURLs...)
+//	colors := pipeline.FanIn(ctx, pipeline.FanOut(ctx, urls, 10, firstColor)...)
+//	for c := range colors {
+//		fmt.Println(c)
+//	}
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// Generate returns a channel that receives each of values in order and is
+// closed after the last one
+func Generate[T any](ctx context.Context, values ...T) <-chan T {
+	out := make(chan T)
+
+	go func() {
+		defer close(out)
+
+		for _, v := range values {
+			select {
+			case out <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// OrDone returns a channel that receives everything from in until in is
+// closed or ctx is done, so a loop over it can't block forever on a
+// channel whose sender is gone
+func OrDone[T any](ctx context.Context, in <-chan T) <-chan T {
+	out := make(chan T)
+
+	go func() {
+		defer close(out)
+
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+
+				select {
+				case out <- v:
+				case <-ctx.Done():
+					return
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// FanOut starts n goroutines that each read from in, calling fn with each
+// value and sending the result on their own channel. The channels are
+// closed once in is closed (or ctx is done) and their goroutine is
+// finished. Combine them with FanIn. An n less than 1 is treated as 1.
+func FanOut[T, R any](ctx context.Context, in <-chan T, n int, fn func(ctx context.Context, v T) R) []<-chan R {
+	outs := make([]<-chan R, max(1, n))
+
+	for i := range outs {
+		out := make(chan R)
+		outs[i] = out
+
+		go func() {
+			defer close(out)
+
+			for v := range OrDone(ctx, in) {
+				select {
+				case out <- fn(ctx, v):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	return outs
+}
+
+// FanIn returns a channel that receives everything from all of ins, in
+// whatever order it arrives, and is closed once they're all closed or ctx
+// is done
+func FanIn[T any](ctx context.Context, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+
+	wg := sync.WaitGroup{}
+	for _, in := range ins {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for v := range OrDone(ctx, in) {
+				select {
+				case out <- v:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// Merge combines channels that are each sorted by less into one sorted
+// channel, e.g., to interleave feeds of images by upload time. It waits for
+// a value from every open channel before sending the least one, so one slow
+// channel holds up the rest. The returned channel is closed once all of ins
+// are closed or ctx is done.
+func Merge[T any](ctx context.Context, less func(a, b T) bool, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+
+	go func() {
+		defer close(out)
+
+		// heads holds the next value of each channel, if it has one
+		heads := make([]T, len(ins))
+		open := make([]bool, len(ins))
+
+		// next reads the next value of channel i
+		next := func(i int) bool {
+			select {
+			case heads[i], open[i] = <-ins[i]:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for i := range ins {
+			if !next(i) {
+				return
+			}
+		}
+
+		for {
+			least := -1
+			for i := range heads {
+				if open[i] && (least < 0 || less(heads[i], heads[least])) {
+					least = i
+				}
+			}
+			if least < 0 {
+				return
+			}
+
+			select {
+			case out <- heads[least]:
+			case <-ctx.Done():
+				return
+			}
+
+			if !next(least) {
+				return
+			}
+		}
+	}()
+
+	return out
+}
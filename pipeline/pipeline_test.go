@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+// collect reads everything from in
+func collect[T any](in <-chan T) []T {
+	var out []T
+	for v := range in {
+		out = append(out, v)
+	}
+
+	return out
+}
+
+func TestFanOutFanIn(t *testing.T) {
+	ctx := context.Background()
+
+	square := func(ctx context.Context, v int) int { return v * v }
+	outs := FanOut(ctx, Generate(ctx, 1, 2, 3, 4, 5), 3, square)
+	if len(outs) != 3 {
+		t.Fatalf("expected 3 channels but got %d", len(outs))
+	}
+
+	got := collect(FanIn(ctx, outs...))
+	sort.Ints(got)
+
+	expected := []int{1, 4, 9, 16, 25}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("expected %v but got %v", expected, got)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+	less := func(a, b int) bool { return a < b }
+
+	got := collect(Merge(ctx, less,
+		Generate(ctx, 1, 4, 7),
+		Generate(ctx, 2, 5),
+		Generate[int](ctx),
+		Generate(ctx, 3, 6, 8, 9),
+	))
+
+	for i, v := range got {
+		if v != i+1 {
+			t.Fatalf("expected 1 through 9 in order but got %v", got)
+		}
+	}
+	if len(got) != 9 {
+		t.Errorf("expected 9 values but got %v", got)
+	}
+}
+
+func TestOrDoneCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	before := runtime.NumGoroutine()
+
+	// Nothing is ever sent on or closes never
+	never := make(chan int)
+	out := FanIn(ctx, OrDone(ctx, never), Merge(ctx, func(a, b int) bool { return a < b }, never))
+
+	cancel()
+
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Errorf("expected no values")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected output to be closed once canceled")
+	}
+
+	// Every goroutine has finished
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("expected %d goroutines but got %d", before, n)
+	}
+}
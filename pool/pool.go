@@ -0,0 +1,96 @@
+// Package pool runs jobs on a bounded number of goroutines. It's the worker
+// pattern from the examples in this repository (a channel of jobs read by n
+// goroutines, with a WaitGroup to know when they're all done) packaged up
+// with error collection and cancellation:
+//
+//	err := pool.Run(ctx, 10, urls, func(ctx context.Context, u string) error {
+//		info, err := p.FirstColor(u)
+//		if err != nil {
+//			return err
+//		}
+//
+//		fmt.Println(u, info.Hex)
+//		return nil
+//	})
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Run calls fn with each job received on jobs, using n goroutines, until
+// jobs is closed or ctx is done. A failed job doesn't stop the others: Run
+// returns the errors of every failed job joined together (see errors.Join),
+// or nil if they all succeeded. When ctx is done, jobs that haven't started
+// are left on the channel, Run waits for the ones in progress (which should
+// watch the ctx passed to fn) and ctx.Err() is included in the result. An n
+// less than 1 is treated as 1.
+func Run[J any](ctx context.Context, n int, jobs <-chan J, fn func(ctx context.Context, job J) error) error {
+	var errs []error
+	var mutex sync.Mutex
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < max(1, n); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				// Check ctx first, so a steady supply of jobs can't
+				// starve cancellation
+				if ctx.Err() != nil {
+					return
+				}
+
+				var job J
+				var ok bool
+
+				select {
+				case job, ok = <-jobs:
+					if !ok {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+
+				err := fn(ctx, job)
+				if err != nil {
+					mutex.Lock()
+					errs = append(errs, err)
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Feed returns a channel that receives each of jobs in order and is closed
+// after the last one, or once ctx is done, suitable for Run
+func Feed[J any](ctx context.Context, jobs []J) <-chan J {
+	out := make(chan J)
+
+	go func() {
+		defer close(out)
+
+		for _, job := range jobs {
+			select {
+			case out <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
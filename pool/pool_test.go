@@ -0,0 +1,99 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	jobs := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	var sum, running, most int32
+	err := Run(ctx, 3, Feed(ctx, jobs), func(ctx context.Context, j int) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&most)
+			if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+				break
+			}
+		}
+
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&sum, int32(j))
+		atomic.AddInt32(&running, -1)
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != 55 {
+		t.Errorf("expected every job to run but got sum %d", sum)
+	}
+	if most > 3 {
+		t.Errorf("expected at most 3 jobs at once but got %d", most)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	ctx := context.Background()
+	odd := errors.New("odd")
+
+	ran := int32(0)
+	err := Run(ctx, 2, Feed(ctx, []int{1, 2, 3, 4}), func(ctx context.Context, j int) error {
+		atomic.AddInt32(&ran, 1)
+		if j%2 == 1 {
+			return odd
+		}
+
+		return nil
+	})
+
+	// Failures don't stop the other jobs, and are all reported
+	if ran != 4 {
+		t.Errorf("expected 4 jobs to run but got %d", ran)
+	}
+	if !errors.Is(err, odd) {
+		t.Fatalf("expected odd error but got %v", err)
+	}
+	if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != 2 {
+		t.Errorf("expected 2 errors but got %d", n)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// A channel that never closes
+	jobs := make(chan int)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case jobs <- i:
+			case <-time.After(time.Second):
+				return
+			}
+		}
+	}()
+
+	ran := int32(0)
+	err := Run(ctx, 2, jobs, func(ctx context.Context, j int) error {
+		if atomic.AddInt32(&ran, 1) == 5 {
+			cancel()
+		}
+
+		return nil
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled but got %v", err)
+	}
+	if ran > 7 {
+		t.Errorf("expected jobs to stop soon after cancel but %d ran", ran)
+	}
+}
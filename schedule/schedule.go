@@ -0,0 +1,136 @@
+// Package schedule runs a job periodically in the background, e.g., pulling
+// the latest images every half hour. It's the loop from the examples in
+// this repository (do the work, sleep, repeat) with the details servers
+// need:
+//
+//	s := &schedule.Scheduler{
+//		Interval:  30 * time.Minute,
+//		Jitter:    time.Minute,
+//		Immediate: true,
+//	}
+//	go s.Run(ctx, func(ctx context.Context) {
+//		// pull and analyze a batch
+//	})
+//
+// Runs never overlap. Run returns once ctx is done and the run in progress,
+// which should watch its ctx, has returned.
+package schedule
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrRunning is returned by Run if the Scheduler is already running
+var ErrRunning = errors.New("schedule: already running")
+
+// Scheduler runs a job every Interval. Set its fields before calling Run.
+type Scheduler struct {
+	// Interval is how long to wait from the start of one run to the start
+	// of the next. If a run takes longer, the next one starts as soon as
+	// it's done, but runs that were missed meanwhile are skipped rather
+	// than run back to back. It must be positive.
+	Interval time.Duration
+
+	// Jitter is the most random time added to each wait, so many
+	// instances started at once don't all run at the same time. Zero
+	// means none.
+	Jitter time.Duration
+
+	// Immediate runs the job as soon as Run is called, instead of after
+	// the first Interval
+	Immediate bool
+
+	// trigger requests a run before the next one is due
+	trigger chan struct{}
+
+	running bool
+	mutex   sync.Mutex
+}
+
+// Run calls fn every Interval until ctx is done, passing it ctx. It returns
+// ctx.Err() once fn isn't running anymore.
+func (s *Scheduler) Run(ctx context.Context, fn func(ctx context.Context)) error {
+	if s.Interval <= 0 {
+		return errors.New("schedule: Interval must be positive")
+	}
+
+	s.mutex.Lock()
+	if s.running {
+		s.mutex.Unlock()
+		return ErrRunning
+	}
+	s.running = true
+	if s.trigger == nil {
+		s.trigger = make(chan struct{}, 1)
+	}
+	trigger := s.trigger
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		s.running = false
+		s.mutex.Unlock()
+	}()
+
+	next := time.Now().Add(s.wait())
+	if s.Immediate {
+		next = time.Now()
+	}
+
+	for {
+		// Don't start another run once we're done, even if one is due
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-trigger:
+			timer.Stop()
+		case <-timer.C:
+		}
+
+		start := time.Now()
+		fn(ctx)
+
+		// Skip the runs we missed while fn was running
+		next = start.Add(s.wait())
+		if now := time.Now(); next.Before(now) {
+			next = now
+		}
+	}
+}
+
+// Trigger runs the job as soon as possible, without waiting for the next
+// Interval. If it's running, it runs again once it's done. Many triggers
+// before the job starts only run it once.
+func (s *Scheduler) Trigger() {
+	s.mutex.Lock()
+	if s.trigger == nil {
+		s.trigger = make(chan struct{}, 1)
+	}
+	trigger := s.trigger
+	s.mutex.Unlock()
+
+	select {
+	case trigger <- struct{}{}:
+	default:
+	}
+}
+
+// wait returns how long to wait between the starts of runs, with jitter
+func (s *Scheduler) wait() time.Duration {
+	if s.Jitter <= 0 {
+		return s.Interval
+	}
+
+	return s.Interval + time.Duration(rand.Int63n(int64(s.Jitter)+1))
+}
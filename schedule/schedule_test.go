@@ -0,0 +1,179 @@
+package schedule
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunImmediate(t *testing.T) {
+	s := &Scheduler{Interval: time.Hour, Immediate: true}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx, func(ctx context.Context) {
+			close(ran)
+		})
+	}()
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("expected an immediate run")
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected context.Canceled but got %v", err)
+	}
+}
+
+func TestRunInterval(t *testing.T) {
+	s := &Scheduler{Interval: 20 * time.Millisecond, Jitter: 5 * time.Millisecond}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 170*time.Millisecond)
+	defer cancel()
+
+	var runs int32
+	s.Run(ctx, func(ctx context.Context) {
+		atomic.AddInt32(&runs, 1)
+	})
+
+	// Between 170/25 and 170/20 runs, less some slack for slow machines
+	if runs < 3 || runs > 8 {
+		t.Errorf("expected about 7 runs but got %d", runs)
+	}
+}
+
+func TestRunOverlap(t *testing.T) {
+	s := &Scheduler{Interval: 5 * time.Millisecond, Immediate: true}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var running, most, runs int32
+	s.Run(ctx, func(ctx context.Context) {
+		n := atomic.AddInt32(&running, 1)
+		if n > atomic.LoadInt32(&most) {
+			atomic.StoreInt32(&most, n)
+		}
+
+		// Take 4 intervals
+		time.Sleep(20 * time.Millisecond)
+
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&runs, 1)
+	})
+
+	if most != 1 {
+		t.Errorf("expected runs not to overlap but %d ran at once", most)
+	}
+
+	// Missed runs are skipped, so at most one run every 20ms
+	if runs > 6 {
+		t.Errorf("expected at most 6 runs but got %d", runs)
+	}
+}
+
+func TestRunWaitsForJob(t *testing.T) {
+	s := &Scheduler{Interval: time.Hour, Immediate: true}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var finished int32
+	started := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx, func(ctx context.Context) {
+			close(started)
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond)
+			atomic.StoreInt32(&finished, 1)
+		})
+	}()
+
+	<-started
+	cancel()
+	<-done
+
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Error("expected Run to wait for the job to finish")
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	s := &Scheduler{Interval: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ran := make(chan struct{}, 1)
+	go s.Run(ctx, func(ctx context.Context) {
+		ran <- struct{}{}
+	})
+
+	s.Trigger()
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("expected a triggered run")
+	}
+}
+
+func TestTriggerCoalesces(t *testing.T) {
+	s := &Scheduler{Interval: time.Hour, Immediate: true}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runs := make(chan int, 10)
+	n := 0
+	go s.Run(ctx, func(ctx context.Context) {
+		n++
+		if n == 1 {
+			// Triggers while running are one more run
+			s.Trigger()
+			s.Trigger()
+			s.Trigger()
+		}
+		runs <- n
+	})
+
+	for i := 1; i <= 2; i++ {
+		select {
+		case got := <-runs:
+			if got != i {
+				t.Fatalf("expected run %d but got %d", i, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected run %d", i)
+		}
+	}
+
+	select {
+	case got := <-runs:
+		t.Errorf("unexpected run %d", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	err := (&Scheduler{}).Run(context.Background(), func(ctx context.Context) {})
+	if err == nil {
+		t.Error("expected an error without an Interval")
+	}
+
+	s := &Scheduler{Interval: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.Run(ctx, func(ctx context.Context) {})
+	time.Sleep(10 * time.Millisecond)
+
+	err = s.Run(ctx, func(ctx context.Context) {})
+	if err != ErrRunning {
+		t.Errorf("expected ErrRunning but got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// DefaultKeepCycles is the default for Server.KeepCycles
+const DefaultKeepCycles = 48
+
+// CycleStats is what happened during one background cycle
+type CycleStats struct {
+	// Start is when the cycle started and Duration is how long it took
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration_seconds"`
+
+	// Pages, Images and Bytes are from the cycle's Puller (see
+	// wikimg.Stats)
+	Pages  int64 `json:"pages"`
+	Images int64 `json:"images"`
+	Bytes  int64 `json:"bytes"`
+
+	// Processed is the number of images analyzed, whether they failed or
+	// not
+	Processed int `json:"processed"`
+
+	// Errors counts the images that failed by the kind of error: canceled,
+	// too_large, stalled, format, network or other
+	Errors map[string]int `json:"errors"`
+}
+
+// cycleLog keeps the stats of the most recent cycles
+type cycleLog struct {
+	cycles []CycleStats
+	mutex  sync.RWMutex
+}
+
+// add records the stats of a cycle, dropping the oldest if there are more
+// than max
+func (cl *cycleLog) add(cs CycleStats, max int) {
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+
+	cl.cycles = append(cl.cycles, cs)
+	if len(cl.cycles) > max {
+		cl.cycles = cl.cycles[len(cl.cycles)-max:]
+	}
+}
+
+// Cycles returns the stats of the most recent background cycles, up to
+// KeepCycles of them, most recent first
+func (s *Server) Cycles() []CycleStats {
+	s.cycles.mutex.RLock()
+	defer s.cycles.mutex.RUnlock()
+
+	cycles := make([]CycleStats, 0, len(s.cycles.cycles))
+	for i := len(s.cycles.cycles) - 1; i >= 0; i-- {
+		cycles = append(cycles, s.cycles.cycles[i])
+	}
+
+	return cycles
+}
+
+// ServeCycles writes the stats of the most recent background cycles as a
+// JSON array, most recent first
+func (s *Server) ServeCycles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Cycles())
+}
+
+// errorType classifies err for cycle stats
+func errorType(err error) string {
+	var tooLarge *wikimg.TooLargeError
+	var stalled *wikimg.StalledError
+	var netErr net.Error
+
+	switch {
+	case err == wikimg.Canceled:
+		return "canceled"
+	case errors.As(err, &tooLarge):
+		return "too_large"
+	case errors.As(err, &stalled):
+		return "stalled"
+	case errors.Is(err, image.ErrFormat):
+		return "format"
+	case errors.As(err, &netErr):
+		return "network"
+	}
+
+	return "other"
+}
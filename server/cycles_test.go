@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+func TestCycleStats(t *testing.T) {
+	s := newTestServer(t)
+	s.KeepCycles = 2
+
+	for i := 0; i < 3; i++ {
+		err := s.Cycle(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/cycles", nil))
+
+	var got []CycleStats
+	err := json.NewDecoder(w.Body).Decode(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the last two are kept, most recent first
+	if len(got) != 2 || !got[0].Start.After(got[1].Start) {
+		t.Fatalf("expected the last 2 cycles but got %+v", got)
+	}
+
+	// The missing image fails
+	if got[0].Processed != 4 || got[0].Images != 4 || got[0].Errors["other"] != 1 {
+		t.Errorf("expected 4 images with 1 error but got %+v", got[0])
+	}
+}
+
+func TestErrorType(t *testing.T) {
+	for _, test := range []struct {
+		err      error
+		expected string
+	}{
+		{wikimg.Canceled, "canceled"},
+		{&wikimg.TooLargeError{}, "too_large"},
+		{fmt.Errorf("decoding: %w", image.ErrFormat), "format"},
+		{errors.New("boom"), "other"},
+	} {
+		if got := errorType(test.err); got != test.expected {
+			t.Errorf("expected %s for %v but got %s", test.expected, test.err, got)
+		}
+	}
+}
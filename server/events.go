@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// keepAlive is how often a comment is sent to idle event streams, so
+// proxies don't close them
+var keepAlive = 30 * time.Second
+
+// ServeEvents sends each color as it's analyzed to the client as a
+// Server-Sent Event (text/event-stream), until the client disconnects.
+// Each event is called "color" and its data is a JSON object like those
+// returned by /colors. It's a lighter alternative to /ws, which browsers
+// follow with EventSource:
+//
+//	new EventSource("/events").addEventListener("color", function(e) {
+//		var c = JSON.parse(e.data);
+//	});
+//
+// Clients that can't keep up miss some colors.
+func (s *Server) ServeEvents(w http.ResponseWriter, r *http.Request) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := s.hub.subscribe()
+	defer s.hub.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+
+	tick := time.NewTicker(keepAlive)
+	defer tick.Stop()
+
+	for {
+		select {
+		case c := <-sub:
+			b, err := json.Marshal(c)
+			if err != nil {
+				return
+			}
+
+			_, err = fmt.Fprintf(w, "event: color\ndata: %s\n\n", b)
+			if err != nil {
+				return
+			}
+
+		case <-tick.C:
+			_, err := fmt.Fprint(w, ": keep-alive\n\n")
+			if err != nil {
+				return
+			}
+
+		case <-r.Context().Done():
+			return
+		}
+
+		f.Flush()
+	}
+}
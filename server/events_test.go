@@ -0,0 +1,97 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeEvents(t *testing.T) {
+	s := newTestServer(t)
+
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected an event stream but got %q", ct)
+	}
+
+	// We're subscribed once the headers arrive
+	err = s.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read the events that were sent
+	var hexes []string
+	sc := bufio.NewScanner(resp.Body)
+	for len(hexes) < 3 && sc.Scan() {
+		line := sc.Text()
+		if line == "" || line == "event: color" {
+			continue
+		}
+
+		var c map[string]any
+		err = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &c)
+		if err != nil {
+			t.Fatalf("unexpected line %q: %v", line, err)
+		}
+		hexes = append(hexes, c["hex"].(string))
+	}
+
+	if strings.Join(hexes, " ") != "#0000ff #00ff00 #ff0000" {
+		t.Errorf("expected the colors in the order analyzed but got %v", hexes)
+	}
+
+	// Disconnecting unsubscribes
+	cancel()
+	for i := 0; i < 100 && subscribers(s) > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := subscribers(s); n != 0 {
+		t.Errorf("expected no subscribers after disconnecting but got %d", n)
+	}
+}
+
+func TestServeEventsKeepAlive(t *testing.T) {
+	defer func(d time.Duration) { keepAlive = d }(keepAlive)
+	keepAlive = 10 * time.Millisecond
+
+	s := New(1, 0)
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != ": keep-alive\n" {
+		t.Errorf("expected a keep-alive but got %q, %v", line, err)
+	}
+}
+
+// subscribers returns the number of clients following s
+func subscribers(s *Server) int {
+	s.hub.mutex.Lock()
+	defer s.hub.mutex.Unlock()
+
+	return len(s.hub.subs)
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"path"
+	"time"
+)
+
+const (
+	// DefaultFeed is the number of entries /feed.xml has by default
+	DefaultFeed = 50
+
+	// swatchWidth and swatchHeight are the size of the swatch of each
+	// entry in /feed.xml
+	swatchWidth, swatchHeight = 120, 60
+)
+
+// atomFeed is an Atom feed (see RFC 4287)
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atomLink is a link from a feed or entry
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+// atomEntry is an entry of an Atom feed
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published,omitempty"`
+	Links     []atomLink  `xml:"link"`
+	Content   atomContent `xml:"content"`
+}
+
+// atomContent is the content of an entry
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// ServeFeed writes an Atom feed of the most recently analyzed colors, so
+// the stream can be followed in a feed reader. Each entry has a swatch of
+// the color, embedded as a data URI, and links to the image's page. The
+// max query parameter sets the number of entries, DefaultFeed by default.
+func (s *Server) ServeFeed(w http.ResponseWriter, r *http.Request) {
+	max := min(DefaultFeed, s.max(r))
+	if len(r.FormValue("max")) > 0 {
+		max = s.max(r)
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	self := scheme + "://" + r.Host + r.URL.Path
+
+	colors := s.Colors(max)
+
+	// The feed changed when its newest color was found
+	updated := time.Now()
+	if len(colors) > 0 {
+		updated = colors[0].Analyzed
+	}
+
+	feed := atomFeed{
+		Title:   "Latest colors",
+		ID:      self,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Links:   []atomLink{{Href: self, Rel: "self"}},
+	}
+
+	for _, c := range colors {
+		link := c.Page
+		if len(link) < 1 {
+			link = c.URL
+		}
+
+		name := c.Title
+		if len(name) < 1 {
+			name = path.Base(c.URL)
+		}
+
+		swatch, err := swatchURI(c)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		entry := atomEntry{
+			Title:   c.Hex + " " + name,
+			ID:      c.URL,
+			Updated: c.Analyzed.UTC().Format(time.RFC3339),
+			Links:   []atomLink{{Href: link, Rel: "alternate"}},
+			Content: atomContent{
+				Type: "html",
+				Body: fmt.Sprintf(`<a href="%s"><img src="%s" width="%d" height="%d" alt="%s"></a><p>%s</p>`,
+					html.EscapeString(link), swatch, swatchWidth, swatchHeight, c.Hex, c.Hex),
+			},
+		}
+		if !c.Uploaded.IsZero() {
+			entry.Published = c.Uploaded.UTC().Format(time.RFC3339)
+		}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	b, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(b)
+}
+
+// swatchURI returns a data URI of a PNG swatch of c's color
+func swatchURI(c Color) (string, error) {
+	pal := color.Palette{color.NRGBA{c.Info.R, c.Info.G, c.Info.B, 0xff}}
+	img := image.NewPaletted(image.Rect(0, 0, swatchWidth, swatchHeight), pal)
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return "", err
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
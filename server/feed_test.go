@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"image/png"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeFeed(t *testing.T) {
+	s := New(10, 0)
+
+	analyzed := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, c := range []Color{newColor("http://example.com/blue.png", "#0000ff"), newColor("http://example.com/red.png", "#ff0000")} {
+		c.Analyzed = analyzed.Add(time.Duration(i) * time.Minute)
+		if i == 1 {
+			c.Title = "File:Red.png"
+			c.Page = "https://commons.wikimedia.org/wiki/File:Red.png"
+			c.Uploaded = analyzed.Add(-time.Hour)
+		}
+
+		s.colors.Add(c.URL, c)
+	}
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "http://colors.example.com/feed.xml", nil))
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+		t.Errorf("expected an Atom feed but got %s", ct)
+	}
+
+	var feed atomFeed
+	err := xml.Unmarshal(w.Body.Bytes(), &feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if feed.ID != "http://colors.example.com/feed.xml" || feed.Updated != "2024-01-01T12:01:00Z" || len(feed.Entries) != 2 {
+		t.Fatalf("unexpected feed %+v", feed)
+	}
+
+	// Newest first, linking to the page if there is one
+	red, blue := feed.Entries[0], feed.Entries[1]
+	if red.Title != "#ff0000 File:Red.png" || red.Links[0].Href != "https://commons.wikimedia.org/wiki/File:Red.png" || red.Published != "2024-01-01T11:00:00Z" {
+		t.Errorf("unexpected entry %+v", red)
+	}
+	if blue.Title != "#0000ff blue.png" || blue.Links[0].Href != "http://example.com/blue.png" || len(blue.Published) > 0 {
+		t.Errorf("unexpected entry %+v", blue)
+	}
+
+	// The swatch is a PNG of the color
+	start := strings.Index(red.Content.Body, "base64,") + len("base64,")
+	end := strings.Index(red.Content.Body[start:], `"`) + start
+	b, err := base64.StdEncoding.DecodeString(red.Content.Body[start:end])
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, _ := img.At(0, 0).RGBA(); r != 0xffff || g != 0 || b != 0 {
+		t.Errorf("expected a red swatch but got %v", img.At(0, 0))
+	}
+
+	// max limits the entries
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/feed.xml?max=1", nil))
+	feed = atomFeed{}
+	xml.Unmarshal(w.Body.Bytes(), &feed)
+	if len(feed.Entries) != 1 {
+		t.Errorf("expected 1 entry but got %d", len(feed.Entries))
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import "sync"
+
+// subscriberBuffer is how many colors a subscriber can fall behind before
+// it misses some
+const subscriberBuffer = 64
+
+// hub sends each newly analyzed color to every subscriber, e.g., a
+// connected WebSocket. The zero value is ready to use.
+type hub struct {
+	subs  map[chan Color]bool
+	mutex sync.Mutex
+}
+
+// publish sends c to every subscriber. It never blocks: subscribers that
+// have fallen too far behind miss c, rather than holding up the workers.
+func (h *hub) publish(c Color) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for sub := range h.subs {
+		select {
+		case sub <- c:
+		default:
+		}
+	}
+}
+
+// subscribe returns a channel receiving each color published from now on
+func (h *hub) subscribe() chan Color {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.subs == nil {
+		h.subs = map[chan Color]bool{}
+	}
+
+	sub := make(chan Color, subscriberBuffer)
+	h.subs[sub] = true
+
+	return sub
+}
+
+// unsubscribe stops sending colors to sub
+func (h *hub) unsubscribe(sub chan Color) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.subs, sub)
+}
@@ -0,0 +1,48 @@
+package server
+
+import "testing"
+
+func TestHub(t *testing.T) {
+	var h hub
+
+	// Nobody is listening yet
+	h.publish(Color{Hex: "#000000"})
+
+	a := h.subscribe()
+	b := h.subscribe()
+	h.publish(Color{Hex: "#ff0000"})
+	h.unsubscribe(b)
+	h.publish(Color{Hex: "#00ff00"})
+
+	for _, hex := range []string{"#ff0000", "#00ff00"} {
+		if c := <-a; c.Hex != hex {
+			t.Errorf("expected %s but got %s", hex, c.Hex)
+		}
+	}
+
+	if c := <-b; c.Hex != "#ff0000" {
+		t.Errorf("expected #ff0000 but got %s", c.Hex)
+	}
+	select {
+	case c := <-b:
+		t.Errorf("expected nothing after unsubscribing but got %s", c.Hex)
+	default:
+	}
+}
+
+func TestHubSlowSubscriber(t *testing.T) {
+	var h hub
+
+	sub := h.subscribe()
+	for i := 0; i < subscriberBuffer*2; i++ {
+		h.publish(Color{XTerm: i})
+	}
+
+	// The oldest colors are kept, the rest are dropped
+	if len(sub) != subscriberBuffer {
+		t.Errorf("expected %d colors but got %d", subscriberBuffer, len(sub))
+	}
+	if c := <-sub; c.XTerm != 0 {
+		t.Errorf("expected the first color but got %d", c.XTerm)
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"bytes"
+	"image/jpeg"
+	"net/http"
+
+	"github.com/brnstz/routine/lru"
+	"github.com/brnstz/routine/wikimg"
+)
+
+const (
+	// DefaultThumbWidth is the default for Server.ThumbWidth
+	DefaultThumbWidth = 250
+
+	// DefaultThumbCache is the default for Server.ThumbCache
+	DefaultThumbCache = 1000
+
+	// thumbQuality is the JPEG quality of thumbnails served by /img
+	thumbQuality = 80
+)
+
+// ServeImage writes a JPEG thumbnail of the image in the url query
+// parameter, e.g., /img?url=https%3A%2F%2Fupload.wikimedia.org%2F..., so
+// the wall can show the images without every client downloading them from
+// Commons. Thumbnails are kept in a cache of their own. Only images whose
+// colors are in the cache are served, so it can't be used to proxy
+// anything else.
+func (s *Server) ServeImage(w http.ResponseWriter, r *http.Request) {
+	imgURL := r.FormValue("url")
+	if _, ok := s.colors.Peek(imgURL); !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.thumbsOnce.Do(s.initThumbs)
+
+	b, ok := s.thumbs.Get(imgURL)
+	if !ok {
+		width := orDefault(s.ThumbWidth, DefaultThumbWidth)
+
+		thumb, err := s.thumbPuller.Downscale(imgURL, width, 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		var buf bytes.Buffer
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: thumbQuality})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		b = buf.Bytes()
+		s.thumbs.Add(imgURL, b)
+	}
+
+	// Images don't change, so clients can keep them as long as they like
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(b)
+}
+
+// initThumbs creates the thumbnail cache and the Puller that downloads
+// thumbnails
+func (s *Server) initThumbs() {
+	s.thumbs = lru.New[string, []byte](orDefault(s.ThumbCache, DefaultThumbCache), 0)
+
+	if s.NewPuller != nil {
+		s.thumbPuller = s.NewPuller(0)
+	} else {
+		s.thumbPuller = wikimg.NewPuller(0)
+	}
+	s.thumbPuller.UseThumbnails(orDefault(s.ThumbWidth, DefaultThumbWidth))
+}
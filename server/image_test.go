@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"image/jpeg"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestServeImage(t *testing.T) {
+	s := newTestServer(t)
+	s.ThumbWidth = 4
+
+	err := s.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := s.Colors(1)[0].URL
+
+	get := func(imgURL string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/img?url="+url.QueryEscape(imgURL), nil))
+		return w
+	}
+
+	w := get(red)
+	if w.Code != 200 || w.Header().Get("Content-Type") != "image/jpeg" || len(w.Header().Get("Cache-Control")) < 1 {
+		t.Fatalf("expected a cacheable JPEG but got %d %v", w.Code, w.Header())
+	}
+	img, err := jpeg.Decode(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 4 {
+		t.Errorf("expected a thumbnail 4 pixels wide but got %v", img.Bounds())
+	}
+	if r, g, b, _ := img.At(0, 0).RGBA(); r < 0xf000 || g > 0x1000 || b > 0x1000 {
+		t.Errorf("expected a red thumbnail but got %v", img.At(0, 0))
+	}
+
+	// It's cached
+	if _, ok := s.thumbs.Peek(red); !ok {
+		t.Error("expected the thumbnail to be cached")
+	}
+
+	// Only images in the cache are proxied
+	if w := get("http://example.com/elsewhere.png"); w.Code != 404 {
+		t.Errorf("expected 404 for an unknown image but got %d", w.Code)
+	}
+}
+
+func TestServeWallThumbnails(t *testing.T) {
+	s := newTestServer(t)
+	s.Thumbnails = true
+
+	err := s.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := s.Colors(1)[0].URL
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/?max=1", nil))
+
+	// html/template escapes with lowercase hex digits
+	if body := w.Body.String(); !strings.Contains(strings.ToLower(body), strings.ToLower(`src="/img?url=`+url.QueryEscape(red)+`"`)) {
+		t.Errorf("expected a thumbnail of %s but got %s", red, body)
+	}
+}
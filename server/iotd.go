@@ -0,0 +1,213 @@
+package server
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// DefaultIOTDDays is the default for IOTD.Days
+const DefaultIOTDDays = 30
+
+// Strategies for picking the image of the day (see IOTD.Strategy)
+const (
+	// IOTDColorful picks the image with the most chroma, which is high
+	// for saturated colors that aren't too light or too dark
+	IOTDColorful = "colorful"
+
+	// IOTDSaturation picks the most saturated image
+	IOTDSaturation = "saturation"
+
+	// IOTDRandom picks at random, weighted towards colorful images
+	IOTDRandom = "random"
+)
+
+// ValueStore keeps values by key, each for at most a ttl. boltcache.Cache
+// and rediscache.Cache are ValueStores.
+type ValueStore interface {
+	GetValue(key string, v any) bool
+	SetValue(key string, v any, ttl time.Duration)
+}
+
+// IOTD picks an image of the day from the colors of a Server once per day
+// and keeps the picks of previous days, in its Store if it has one, so they
+// survive restarts and servers sharing a Store agree on them. Set its fields
+// before the Server runs.
+type IOTD struct {
+	// Strategy is how the image is picked: IOTDColorful, IOTDSaturation
+	// or IOTDRandom. Empty or unknown means IOTDColorful.
+	Strategy string
+
+	// Store, if set, keeps the picks
+	Store ValueStore
+
+	// Days is how many days of picks are shown and kept in the Store.
+	// Zero means DefaultIOTDDays.
+	Days int
+
+	history []Pick
+	loaded  bool
+	mutex   sync.RWMutex
+}
+
+// Pick is the image of a day
+type Pick struct {
+	// Day is the day, e.g., "2024-01-31"
+	Day string `json:"day"`
+
+	// URL is the image and Page is its page, if known
+	URL  string `json:"url"`
+	Page string `json:"page,omitempty"`
+
+	// Info is the color of the image
+	Info wikimg.ColorInfo `json:"info"`
+}
+
+// Day is the image of a day on the /iotd page
+type Day struct {
+	// Day is the day, e.g., "2024-01-31"
+	Day string
+
+	Swatch
+}
+
+// iotdKey returns the key of the image of day in the store
+func iotdKey(day string) string {
+	return "iotd:" + day
+}
+
+// days returns d.Days, or its default
+func (d *IOTD) days() int {
+	return orDefault(d.Days, DefaultIOTDDays)
+}
+
+// load reads the picks of the days before today from the store. The
+// caller must hold the lock.
+func (d *IOTD) load(today time.Time) {
+	d.loaded = true
+	if d.Store == nil {
+		return
+	}
+
+	for i := d.days() - 1; i > 0; i-- {
+		var pick Pick
+		if d.Store.GetValue(iotdKey(today.AddDate(0, 0, -i).Format(time.DateOnly)), &pick) {
+			d.history = append(d.history, pick)
+		}
+	}
+}
+
+// score rates how good a candidate for image of the day info is using the
+// strategy. Higher is better.
+func (d *IOTD) score(info wikimg.ColorInfo) float64 {
+	switch d.Strategy {
+	case IOTDSaturation:
+		return info.S
+
+	case IOTDRandom:
+		// Every image has a chance, weighted towards colorful ones
+		return rand.Float64() * (0.1 + info.S)
+	}
+
+	return info.S * (1 - math.Abs(2*info.L-1))
+}
+
+// Picks returns the images of the last Days days, most recent first
+func (d *IOTD) Picks() []Pick {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	picks := make([]Pick, 0, len(d.history))
+	for i := len(d.history) - 1; i >= 0; i-- {
+		picks = append(picks, d.history[i])
+	}
+
+	return picks
+}
+
+// pickIOTD picks an image of the day for the day of now from the colors,
+// unless one was already picked, by us or by another server sharing our
+// store
+func (s *Server) pickIOTD(now time.Time) {
+	d := s.IOTD
+	day := now.Format(time.DateOnly)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if !d.loaded {
+		d.load(now)
+	}
+
+	// Another server may have picked first, so always use the store's
+	var pick Pick
+	picked := d.Store != nil && d.Store.GetValue(iotdKey(day), &pick)
+
+	if n := len(d.history); n > 0 && d.history[n-1].Day == day {
+		if picked {
+			d.history[n-1] = pick
+		}
+		return
+	}
+
+	if !picked {
+		var best Color
+		bestScore := -1.0
+		s.colors.Each(func(key string, c Color) bool {
+			if score := d.score(c.Info); score > bestScore {
+				best, bestScore = c, score
+			}
+
+			return true
+		})
+
+		// No colors yet, try again later
+		if bestScore < 0 {
+			return
+		}
+
+		pick = Pick{Day: day, URL: best.URL, Page: best.Page, Info: best.Info}
+		if d.Store != nil {
+			d.Store.SetValue(iotdKey(day), pick, time.Duration(d.days())*24*time.Hour)
+		}
+	}
+
+	d.history = append(d.history, pick)
+	if len(d.history) > d.days() {
+		d.history = d.history[len(d.history)-d.days():]
+	}
+}
+
+// ServeIOTD shows the image of the day followed by previous days, with the
+// "iotd" template of Template
+func (s *Server) ServeIOTD(w http.ResponseWriter, r *http.Request) {
+	if s.IOTD == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Pick today's right away after midnight, rather than after the next
+	// cycle
+	picks := s.IOTD.Picks()
+	if today := time.Now(); len(picks) < 1 || picks[0].Day != today.Format(time.DateOnly) {
+		s.pickIOTD(today)
+		picks = s.IOTD.Picks()
+	}
+
+	if len(picks) < 1 {
+		http.Error(w, "no image of the day yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	page := s.page("Image of the day")
+	for _, pick := range picks {
+		c := Color{URL: pick.URL, Page: pick.Page, Hex: pick.Info.Hex, XTerm: pick.Info.Index, Info: pick.Info}
+		page.Days = append(page.Days, Day{Day: pick.Day, Swatch: Swatch{Color: c}})
+	}
+
+	s.render(w, "iotd", page)
+}
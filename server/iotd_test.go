@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memStore is a ValueStore in memory
+type memStore map[string][]byte
+
+func (m memStore) GetValue(key string, v any) bool {
+	b, ok := m[key]
+	return ok && json.Unmarshal(b, v) == nil
+}
+
+func (m memStore) SetValue(key string, v any, ttl time.Duration) {
+	m[key], _ = json.Marshal(v)
+}
+
+func TestIOTD(t *testing.T) {
+	store := memStore{}
+	s := New(10, 0)
+	s.IOTD = &IOTD{Store: store}
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/iotd", nil))
+		return w
+	}
+
+	// Nothing to pick from yet
+	if w := get(); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without colors but got %d", w.Code)
+	}
+
+	// Vivid red is more colorful than pale pink or gray
+	for _, test := range []struct {
+		hex  string
+		s, l float64
+	}{
+		{"#ffeeee", 1, 0.97},
+		{"#ff0000", 1, 0.5},
+		{"#808080", 0, 0.5},
+	} {
+		c := newColor("http://example.com/"+test.hex[1:]+".png", test.hex)
+		c.Info.S, c.Info.L = test.s, test.l
+		s.colors.Add(c.URL, c)
+	}
+
+	body := get().Body.String()
+	today := time.Now().Format(time.DateOnly)
+	if !strings.Contains(body, "<p>"+today+"</p>") || !strings.Contains(body, "background: #ff0000") {
+		t.Errorf("expected red today but got %s", body)
+	}
+
+	var pick Pick
+	if !store.GetValue(iotdKey(today), &pick) || pick.URL != "http://example.com/ff0000.png" {
+		t.Errorf("expected red in the store but got %+v", pick)
+	}
+
+	// Another server sharing the store agrees, and has yesterday's too
+	yesterday := time.Now().AddDate(0, 0, -1).Format(time.DateOnly)
+	store.SetValue(iotdKey(yesterday), Pick{Day: yesterday, URL: "http://example.com/old.png", Info: pick.Info}, 0)
+
+	other := New(10, 0)
+	other.IOTD = &IOTD{Store: store, Strategy: IOTDSaturation}
+	other.pickIOTD(time.Now())
+
+	picks := other.IOTD.Picks()
+	if len(picks) != 2 || picks[0].URL != pick.URL || picks[1].Day != yesterday {
+		t.Errorf("expected today's and yesterday's picks but got %+v", picks)
+	}
+}
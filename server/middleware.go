@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle clients are forgotten by RateLimit
+const sweepInterval = time.Minute
+
+// Middleware wraps a handler with some behavior, e.g., limiting how often
+// it may be called
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h with each middleware, so the first one sees each request
+// first:
+//
+//	h := server.Chain(s.Handler(),
+//		server.RateLimit(1, 10),
+//		server.MaxConcurrent(50),
+//		server.Timeout(20*time.Second),
+//	)
+func Chain(h http.Handler, middleware ...Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+
+	return h
+}
+
+// RateLimit allows each client IP address perSecond requests per second on
+// average, with bursts of up to burst requests at once. Other requests get
+// 429 Too Many Requests with a Retry-After header. Clients are identified by
+// the connection's remote address, so behind a proxy every client shares
+// one limit.
+func RateLimit(perSecond float64, burst int) Middleware {
+	l := &limiter{
+		rate:    perSecond,
+		burst:   float64(max(burst, 1)),
+		buckets: map[string]*bucket{},
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, wait := l.allow(clientIP(r), time.Now())
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MaxConcurrent allows at most n requests to be handled at once. Requests
+// beyond that get 503 Service Unavailable right away, rather than waiting
+// for their turn and tying up a connection.
+func MaxConcurrent(n int) Middleware {
+	sem := make(chan struct{}, max(n, 1))
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			default:
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "too busy", http.StatusServiceUnavailable)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Timeout cancels the context of each request after d. Unlike
+// http.TimeoutHandler, it doesn't buffer the response, so streaming
+// handlers keep working; they must watch r.Context() and return once
+// it's done.
+func Timeout(d time.Duration) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// clientIP returns the IP address r came from
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// bucket holds the tokens of a client. Each request takes a token and
+// tokens are added back at the limiter's rate, up to its burst.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter is a token bucket per client
+type limiter struct {
+	rate  float64
+	burst float64
+
+	buckets map[string]*bucket
+	swept   time.Time
+	mutex   sync.Mutex
+}
+
+// allow takes a token from the bucket of key at now, returning false and
+// how long until there's one if there isn't
+func (l *limiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if now.Sub(l.swept) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+
+	if b.tokens < 1 {
+		if l.rate <= 0 {
+			return false, time.Hour
+		}
+
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+
+	b.tokens--
+
+	return true, 0
+}
+
+// refill adds the tokens earned since b was last used
+func (l *limiter) refill(b *bucket, now time.Time) {
+	if now.After(b.last) {
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+	}
+}
+
+// sweep forgets clients whose buckets are full again, which is the same as
+// never having seen them, so the map doesn't grow forever
+func (l *limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.swept = now
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// okHandler is a handler that always succeeds
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+// get sends a GET from remoteAddr to h, returning its response
+func get(h http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = remoteAddr
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	get(Chain(okHandler, mark("a"), mark("b"), mark("c")), "192.0.2.1:1234")
+
+	if got := strings.Join(order, ""); got != "abc" {
+		t.Errorf("expected abc but got %s", got)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	h := RateLimit(0.001, 3)(okHandler)
+
+	for i := 0; i < 3; i++ {
+		if w := get(h, "192.0.2.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("expected request %d to be allowed but got %d", i, w.Code)
+		}
+	}
+
+	w := get(h, "192.0.2.1:5678")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected %d but got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if len(w.Header().Get("Retry-After")) < 1 {
+		t.Error("expected a Retry-After header")
+	}
+
+	// Other clients have their own limit
+	if w := get(h, "192.0.2.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("expected another client to be allowed but got %d", w.Code)
+	}
+}
+
+func TestLimiterRefill(t *testing.T) {
+	l := &limiter{rate: 2, burst: 2, buckets: map[string]*bucket{}}
+	now := time.Now()
+	l.swept = now
+
+	l.allow("a", now)
+	l.allow("a", now)
+	if ok, wait := l.allow("a", now); ok || wait != 500*time.Millisecond {
+		t.Errorf("expected to wait 500ms but got %v, %v", ok, wait)
+	}
+
+	if ok, _ := l.allow("a", now.Add(500*time.Millisecond)); !ok {
+		t.Error("expected a token after 500ms")
+	}
+
+	// Full buckets are forgotten
+	l.allow("b", now)
+	l.sweep(now.Add(time.Hour))
+	if len(l.buckets) != 0 {
+		t.Errorf("expected no buckets after sweeping but got %d", len(l.buckets))
+	}
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	entered := make(chan bool)
+	release := make(chan bool)
+	h := MaxConcurrent(1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- true
+		<-release
+	}))
+
+	done := make(chan int)
+	go func() {
+		done <- get(h, "192.0.2.1:1234").Code
+	}()
+	<-entered
+
+	if w := get(h, "192.0.2.1:1234"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected %d but got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("expected %d but got %d", http.StatusOK, code)
+	}
+
+	// The slot is free again
+	go func() { <-entered }()
+	if w := get(h, "192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("expected %d but got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	h := Timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+			t.Error("expected the context to be canceled")
+		}
+	}))
+
+	get(h, "192.0.2.1:1234")
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Formats colors can be written in (see ColorWriter)
+const (
+	// FormatHTML is a div with the background of each color
+	FormatHTML = "html"
+
+	// FormatJSON is a JSON array of hex colors
+	FormatJSON = "json"
+
+	// FormatText is a hex color on each line
+	FormatText = "text"
+)
+
+// Params are what a client can choose for each request with query
+// parameters, e.g., ?max=50&timeout=5s&format=json, so dashboards that
+// want walls of different sizes can share a server
+type Params struct {
+	// Max is the number of colors to get
+	Max int
+
+	// Timeout is how long the request may take. Zero means no limit.
+	Timeout time.Duration
+
+	// Format is how colors are written: FormatHTML, FormatJSON or
+	// FormatText
+	Format string
+}
+
+// ParseParams reads the max, timeout and format query parameters of r.
+// limits are both the defaults and the most a client can ask for: a
+// missing or invalid max or timeout, or one over the limit, is the limit.
+// A zero limit.Timeout allows any timeout. An empty format is
+// limits.Format, or FormatHTML if that's empty too. Unknown formats are
+// an error.
+func ParseParams(r *http.Request, limits Params) (Params, error) {
+	params := Params{
+		Max:     maxParam(r, limits.Max),
+		Timeout: limits.Timeout,
+		Format:  r.FormValue("format"),
+	}
+
+	if d, err := time.ParseDuration(r.FormValue("timeout")); err == nil && d > 0 && (limits.Timeout <= 0 || d < limits.Timeout) {
+		params.Timeout = d
+	}
+
+	if len(params.Format) < 1 {
+		params.Format = limits.Format
+	}
+	switch params.Format {
+	case "":
+		params.Format = FormatHTML
+	case FormatHTML, FormatJSON, FormatText:
+	default:
+		return Params{}, fmt.Errorf("server: unknown format %q (must be html, json or text)", params.Format)
+	}
+
+	return params, nil
+}
+
+// maxParam returns the number r asks for with its max query parameter, or
+// limit, which is the same, at most limit
+func maxParam(r *http.Request, limit int) int {
+	v := r.FormValue("max")
+	if len(v) < 1 {
+		v = r.FormValue("limit")
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > limit {
+		return limit
+	}
+
+	return n
+}
+
+// htmlSpec prints an HTML div with the hex background
+const htmlSpec = `<div style="background: %s; width: 100%%">&nbsp;</div>` + "\n"
+
+// ColorWriter writes hex colors to a response in a format, flushing each
+// one so clients see colors as soon as they're found. Call Close once
+// every color is written.
+type ColorWriter struct {
+	w      http.ResponseWriter
+	format string
+	n      int
+}
+
+// NewColorWriter creates a ColorWriter that writes to w in format, setting
+// its Content-Type
+func NewColorWriter(w http.ResponseWriter, format string) *ColorWriter {
+	switch format {
+	case FormatJSON:
+		w.Header().Set("Content-Type", "application/json")
+	case FormatText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	default:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+
+	return &ColorWriter{w: w, format: format}
+}
+
+// Write writes the hex color
+func (cw *ColorWriter) Write(hex string) {
+	switch cw.format {
+	case FormatJSON:
+		sep := ","
+		if cw.n == 0 {
+			sep = "["
+		}
+
+		b, _ := json.Marshal(hex)
+		fmt.Fprintf(cw.w, "%s%s", sep, b)
+
+	case FormatText:
+		fmt.Fprintln(cw.w, hex)
+
+	default:
+		fmt.Fprintf(cw.w, htmlSpec, hex)
+	}
+	cw.n++
+
+	if f, ok := cw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close finishes the response, e.g., the end of the JSON array
+func (cw *ColorWriter) Close() {
+	if cw.format != FormatJSON {
+		return
+	}
+
+	if cw.n == 0 {
+		fmt.Fprintln(cw.w, "[]")
+	} else {
+		fmt.Fprintln(cw.w, "]")
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseParams(t *testing.T) {
+	limits := Params{Max: 100, Timeout: 20 * time.Second}
+
+	for _, test := range []struct {
+		query    string
+		expected Params
+	}{
+		{"", Params{100, 20 * time.Second, FormatHTML}},
+		{"?max=10&timeout=5s&format=json", Params{10, 5 * time.Second, FormatJSON}},
+
+		// Clients can't go over the limits
+		{"?max=1000&timeout=1h&format=text", Params{100, 20 * time.Second, FormatText}},
+		{"?max=-1&timeout=soon", Params{100, 20 * time.Second, FormatHTML}},
+	} {
+		params, err := ParseParams(httptest.NewRequest("GET", "/"+test.query, nil), limits)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+		} else if params != test.expected {
+			t.Errorf("%s: expected %+v but got %+v", test.query, test.expected, params)
+		}
+	}
+
+	// Without a timeout limit, any timeout goes
+	params, _ := ParseParams(httptest.NewRequest("GET", "/?timeout=1h", nil), Params{Max: 10})
+	if params.Timeout != time.Hour {
+		t.Errorf("expected a 1h timeout but got %v", params.Timeout)
+	}
+
+	if _, err := ParseParams(httptest.NewRequest("GET", "/?format=xml", nil), limits); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestColorWriter(t *testing.T) {
+	for _, test := range []struct {
+		format, contentType string
+		check               func(body string) bool
+	}{
+		{FormatHTML, "text/html", func(body string) bool {
+			return strings.Count(body, "<div") == 2 && strings.Contains(body, "background: #ff0000")
+		}},
+		{FormatText, "text/plain", func(body string) bool {
+			return body == "#ff0000\n#0000ff\n"
+		}},
+		{FormatJSON, "application/json", func(body string) bool {
+			var hexes []string
+			return json.Unmarshal([]byte(body), &hexes) == nil && len(hexes) == 2 && hexes[1] == "#0000ff"
+		}},
+	} {
+		w := httptest.NewRecorder()
+		cw := NewColorWriter(w, test.format)
+		cw.Write("#ff0000")
+		cw.Write("#0000ff")
+		cw.Close()
+
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, test.contentType) {
+			t.Errorf("%s: expected %s but got %s", test.format, test.contentType, ct)
+		}
+		if !w.Flushed || !test.check(w.Body.String()) {
+			t.Errorf("%s: unexpected body %q", test.format, w.Body.String())
+		}
+	}
+
+	// No colors is still valid JSON
+	w := httptest.NewRecorder()
+	NewColorWriter(w, FormatJSON).Close()
+	if strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("expected an empty array but got %q", w.Body.String())
+	}
+}
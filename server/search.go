@@ -0,0 +1,156 @@
+package server
+
+import (
+	"image/color"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+const (
+	// DefaultTolerance is the tolerance of a search without one
+	DefaultTolerance = 10
+
+	// MaxTolerance is the largest tolerance a search may have
+	MaxTolerance = 100
+
+	// indexCell is the size of each cell of the color index in L*a*b*
+	// units
+	indexCell = 10
+)
+
+// cellKey is a cell of the color index
+type cellKey [3]int
+
+// cellOf returns the cell containing the L*a*b* color l, a, b
+func cellOf(l, a, b float64) cellKey {
+	return cellKey{
+		int(math.Floor(l / indexCell)),
+		int(math.Floor(a / indexCell)),
+		int(math.Floor(b / indexCell)),
+	}
+}
+
+// colorIndex finds cached colors near a color. L*a*b* space is divided into
+// cells, so a search only looks at the cells within its tolerance instead
+// of the whole cache. The zero value is ready to use.
+type colorIndex struct {
+	cells map[cellKey]map[string]bool
+
+	// urls are the cell of each URL
+	urls  map[string]cellKey
+	mutex sync.Mutex
+}
+
+// add indexes c, replacing any previous color of its URL
+func (ix *colorIndex) add(c Color) {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	if ix.cells == nil {
+		ix.cells = map[cellKey]map[string]bool{}
+		ix.urls = map[string]cellKey{}
+	}
+
+	ix.removeLocked(c.URL)
+
+	key := cellOf(wikimg.Lab(colorOf(c)))
+	if ix.cells[key] == nil {
+		ix.cells[key] = map[string]bool{}
+	}
+	ix.cells[key][c.URL] = true
+	ix.urls[c.URL] = key
+}
+
+// remove drops the color of imgURL from the index
+func (ix *colorIndex) remove(imgURL string) {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	ix.removeLocked(imgURL)
+}
+
+// removeLocked is remove for callers holding the lock
+func (ix *colorIndex) removeLocked(imgURL string) {
+	key, ok := ix.urls[imgURL]
+	if !ok {
+		return
+	}
+
+	delete(ix.urls, imgURL)
+	delete(ix.cells[key], imgURL)
+	if len(ix.cells[key]) < 1 {
+		delete(ix.cells, key)
+	}
+}
+
+// near returns the URLs in every cell that could have colors within
+// tolerance of target
+func (ix *colorIndex) near(target color.Color, tolerance float64) []string {
+	l, a, b := wikimg.Lab(target)
+	lo := cellOf(l-tolerance, a-tolerance, b-tolerance)
+	hi := cellOf(l+tolerance, a+tolerance, b+tolerance)
+
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	var urls []string
+	for i := lo[0]; i <= hi[0]; i++ {
+		for j := lo[1]; j <= hi[1]; j++ {
+			for k := lo[2]; k <= hi[2]; k++ {
+				for u := range ix.cells[cellKey{i, j, k}] {
+					urls = append(urls, u)
+				}
+			}
+		}
+	}
+
+	return urls
+}
+
+// colorOf returns the color of c
+func colorOf(c Color) color.Color {
+	return color.RGBA{c.Info.R, c.Info.G, c.Info.B, 0xff}
+}
+
+// Near returns at most max cached colors within tolerance of target, nearest
+// first. The tolerance is a CIE76 color difference (see wikimg.DeltaE), up
+// to MaxTolerance: about 2 is barely noticeable and 10 is the same hue.
+func (s *Server) Near(target color.Color, tolerance float64, max int) []Color {
+	tolerance = min(tolerance, MaxTolerance)
+
+	type match struct {
+		c Color
+		d float64
+	}
+
+	var matches []match
+	for _, u := range s.index.near(target, tolerance) {
+		// The cache has the final say, since entries may have expired
+		c, ok := s.colors.Peek(u)
+		if !ok {
+			continue
+		}
+
+		d := wikimg.DeltaE(target, colorOf(c))
+		if d <= tolerance {
+			matches = append(matches, match{c, d})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].d != matches[j].d {
+			return matches[i].d < matches[j].d
+		}
+		return matches[i].c.URL < matches[j].c.URL
+	})
+
+	colors := []Color{}
+	for i := 0; i < len(matches) && i < max; i++ {
+		colors = append(colors, matches[i].c)
+	}
+
+	return colors
+}
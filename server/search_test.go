@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// newColor returns a Color of hex for imgURL
+func newColor(imgURL, hex string) Color {
+	c, _ := wikimg.ParseHex(hex)
+
+	return Color{URL: imgURL, Hex: hex, Info: wikimg.ColorInfo{R: c.R, G: c.G, B: c.B, Hex: hex}}
+}
+
+func TestNear(t *testing.T) {
+	s := New(3, 0)
+	for _, c := range []Color{
+		newColor("red", "#ff0000"),
+		newColor("darkred", "#f00000"),
+		newColor("blue", "#0000ff"),
+	} {
+		s.index.add(c)
+		s.colors.Add(c.URL, c)
+	}
+
+	red := color.RGBA{0xff, 0, 0, 0xff}
+
+	got := s.Near(red, 10, 10)
+	if len(got) != 2 || got[0].URL != "red" || got[1].URL != "darkred" {
+		t.Errorf("expected red then darkred but got %v", got)
+	}
+
+	if got := s.Near(red, 10, 1); len(got) != 1 {
+		t.Errorf("expected 1 color but got %d", len(got))
+	}
+
+	if got := s.Near(red, 0, 10); len(got) != 1 || got[0].URL != "red" {
+		t.Errorf("expected only red but got %v", got)
+	}
+
+	// Colors dropped from the cache are dropped from the index
+	c := newColor("green", "#00ff00")
+	s.index.add(c)
+	s.colors.Add(c.URL, c)
+
+	if got := s.Near(red, 10, 10); len(got) != 1 || got[0].URL != "darkred" {
+		t.Errorf("expected only darkred after red was dropped but got %v", got)
+	}
+	if _, ok := s.index.urls["red"]; ok {
+		t.Error("expected red to be removed from the index")
+	}
+
+	// A new color replaces the old one
+	c = newColor("darkred", "#0000f0")
+	s.index.add(c)
+	s.colors.Add(c.URL, c)
+
+	if got := s.Near(red, 10, 10); len(got) != 0 {
+		t.Errorf("expected no reds but got %v", got)
+	}
+}
+
+func TestServeColorsNear(t *testing.T) {
+	s := newTestServer(t)
+
+	err := s.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		code     int
+		expected []string
+	}{
+		{"?near=%23ff0000", http.StatusOK, []string{"#ff0000"}},
+		{"?near=f00&tolerance=0", http.StatusOK, []string{"#ff0000"}},
+		{"?near=%23ffff00&tolerance=70", http.StatusOK, []string{"#00ff00"}},
+
+		// Red is 113 from yellow, beyond MaxTolerance
+		{"?near=%23ffff00&tolerance=1000", http.StatusOK, []string{"#00ff00"}},
+		{"?near=%23808080", http.StatusOK, []string{}},
+		{"?near=red", http.StatusBadRequest, nil},
+		{"?near=%23ff0000&tolerance=-1", http.StatusBadRequest, nil},
+		{"?near=%23ff0000&tolerance=NaN", http.StatusBadRequest, nil},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/colors"+test.query, nil))
+
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d but got %d", test.query, test.code, w.Code)
+			continue
+		}
+		if test.code != http.StatusOK {
+			continue
+		}
+
+		var got []Color
+		err := json.NewDecoder(w.Body).Decode(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(got) != len(test.expected) {
+			t.Errorf("%s: expected %v but got %v", test.query, test.expected, got)
+			continue
+		}
+		for i, hex := range test.expected {
+			if got[i].Hex != hex {
+				t.Errorf("%s: expected %s at %d but got %s", test.query, hex, i, got[i].Hex)
+			}
+		}
+	}
+}
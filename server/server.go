@@ -0,0 +1,660 @@
+// Package server serves the colors of the latest images on Wikimedia
+// Commons, or another wikimg.Source, over HTTP. It's the server from the
+// examples in this repository packaged up: a Server pulls images in the
+// background, analyzes them with a pool of workers and keeps their colors
+// in a cache, so every request is answered from memory without downloading
+// anything.
+//
+//	s := server.New(50000, 24*time.Hour)
+//	go s.Run(ctx)
+//
+//	http.ListenAndServe(":8000", s.Handler())
+//
+// GET /colors returns the most recently analyzed colors as a JSON array
+// and GET / shows them as a wall of HTML swatches, rendered with a template
+// that can be replaced (see ParseTemplate). Both take a max query
+// parameter, e.g., /colors?max=100. /colors can also search the cache for
+// colors near another, e.g., /colors?near=%23ff0000&tolerance=30. The
+// wall, /colors and /mosaic.png can sort the colors by hue or luminance, or
+// group them by hue, for a gradient rather than noise, e.g., /?sort=hue
+// (see wikimg.Order), and show repeated colors once with a count, e.g.,
+// /?collapse=all (see wikimg.Collapse). With an Aggregator, GET /today
+// returns the top colors of every image analyzed over the last day, or
+// less, e.g., /today?over=1h&k=5. The wall stays up to date by following
+// /ws, a WebSocket that sends each color as soon as it's analyzed. The same
+// colors are sent as Server-Sent Events by /events. GET /mosaic.png draws
+// them as a PNG grid of squares to share (see the mosaic package), and GET
+// /feed.xml is an Atom feed of them to follow in a feed reader. GET /img
+// serves cached thumbnails of the images, e.g., /img?url=..., so the wall
+// can show them without every client hitting Commons (see Thumbnails).
+//
+// The wall and /colors can page through every cached color with an offset
+// query parameter, e.g., /colors?offset=300&max=100, and say how many there
+// are in an X-Total-Count header, with a Link header to the next page. Both
+// can show colors as someone with a color vision deficiency sees them,
+// e.g., /?cvd=deuteranopia, and the wall can be just hex values, e.g.,
+// /?format=text. GET /cycles returns stats of the latest background
+// cycles, GET /mood summarizes the cached colors, e.g., whether they're
+// warm or cool, and with an IOTD, GET /iotd shows an image of the day.
+//
+// Public servers should wrap their handlers with middleware that limits
+// how much each client can ask for (see Chain).
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/brnstz/routine/lru"
+	"github.com/brnstz/routine/notify"
+	"github.com/brnstz/routine/pool"
+	"github.com/brnstz/routine/wikimg"
+	"github.com/brnstz/routine/wikimg/mosaic"
+)
+
+const (
+	// DefaultMax is the default for Server.Max
+	DefaultMax = 300
+
+	// DefaultBatch is the default for Server.Batch
+	DefaultBatch = 1000
+
+	// DefaultWorkers is the default for Server.Workers
+	DefaultWorkers = 25
+
+	// DefaultInterval is the default for Server.Interval
+	DefaultInterval = 30 * time.Minute
+
+	// DefaultCycleTimeout is the default for Server.CycleTimeout
+	DefaultCycleTimeout = 10 * time.Minute
+
+	// DefaultTop is the number of colors /today returns by default
+	DefaultTop = 10
+
+	// DefaultTitle is the default for Server.Title
+	DefaultTitle = "Latest colors on Wikimedia Commons"
+)
+
+// Color is the color of an image, as served by the API
+type Color struct {
+	// URL is the image
+	URL string `json:"url"`
+
+	// Page is the image's page on Commons, if known
+	Page string `json:"page,omitempty"`
+
+	// Title is the title of the image's page, if known
+	Title string `json:"title,omitempty"`
+
+	// Uploaded is when the image was uploaded, if known, and Analyzed is
+	// when its color was found
+	Uploaded time.Time `json:"uploaded,omitzero"`
+	Analyzed time.Time `json:"analyzed"`
+
+	// Hex is the color, e.g., "#ff0000"
+	Hex string `json:"hex"`
+
+	// XTerm is the xterm256 index of the color, or -1 if the puller
+	// doesn't map colors to a palette
+	XTerm int `json:"xterm"`
+
+	// Depicts are what the image depicts, if the puller fetches
+	// structured data
+	Depicts []wikimg.Entity `json:"depicts,omitempty"`
+
+	// Count is how many images with the same color this one stands for,
+	// if they're collapsed (see the collapse query parameter)
+	Count int `json:"count,omitempty"`
+
+	// Info is everything known about the color
+	Info wikimg.ColorInfo `json:"-"`
+}
+
+// Server pulls and analyzes images in the background and serves their
+// colors. Set its fields before calling Run. The zero value isn't usable;
+// create one with New.
+type Server struct {
+	// Max is the most colors a single request can get. Zero means
+	// DefaultMax.
+	Max int
+
+	// Batch is the number of images pulled in each background cycle.
+	// Zero means DefaultBatch.
+	Batch int
+
+	// Workers is the number of images analyzed at once. Zero means
+	// DefaultWorkers.
+	Workers int
+
+	// Interval is how long to wait between background cycles. Zero
+	// means DefaultInterval.
+	Interval time.Duration
+
+	// CycleTimeout is how long a background cycle may take before the
+	// rest of its images are abandoned. Zero means
+	// DefaultCycleTimeout.
+	CycleTimeout time.Duration
+
+	// Timeout is how long each image may take to analyze. Zero means no
+	// limit besides CycleTimeout.
+	Timeout time.Duration
+
+	// NewPuller creates the Puller for each background cycle, e.g., to
+	// set its options, licenses or a shared wikimg.Cache. Its Cancel
+	// channel is replaced with the cycle's. If nil, wikimg.NewPuller is
+	// used.
+	NewPuller func(max int) *wikimg.Puller
+
+	// NewSource creates the Source of the images of each background
+	// cycle, which are analyzed by the Puller from NewPuller. If nil, the
+	// Puller's own Source, the latest images on Commons, is used.
+	NewSource func(max int) wikimg.Source
+
+	// Aggregator, if set, adds the histogram of every analyzed image to a
+	// rolling histogram served by /today. Its Palette must be the
+	// Pullers'. Measuring histograms scans every sampled pixel of each
+	// image, rather than stopping at the first color.
+	Aggregator *wikimg.Aggregator
+
+	// Notifier, if set, is told about every analyzed image, to post to
+	// the webhooks of the rules it matches, and gets a report of every
+	// cycle's results for its summary rules. The caller runs it.
+	Notifier *notify.Notifier
+
+	// Thumbnails shows a thumbnail of each image on the wall, served by
+	// /img, next to its color
+	Thumbnails bool
+
+	// ThumbWidth is the width in pixels of the thumbnails served by /img.
+	// Zero means DefaultThumbWidth.
+	ThumbWidth int
+
+	// ThumbCache is the most thumbnails kept by /img. Zero means
+	// DefaultThumbCache.
+	ThumbCache int
+
+	// Template renders the wall, executed with a Wall. If nil, the
+	// default template is used. ParseTemplate parses one from a file.
+	Template *template.Template
+
+	// Title is the title of the wall. Empty means DefaultTitle.
+	Title string
+
+	// Refresh is how often browsers reload the wall and the image of the
+	// day. Zero means never, since the wall follows new colors anyway.
+	Refresh time.Duration
+
+	// KeepCycles is how many background cycles /cycles keeps stats for.
+	// Zero means DefaultKeepCycles.
+	KeepCycles int
+
+	// IOTD, if set, picks an image of the day from the colors after each
+	// cycle, served by /iotd
+	IOTD *IOTD
+
+	// Logger is where failed cycles and images are logged. If nil,
+	// nothing is logged.
+	Logger wikimg.Logger
+
+	colors *lru.Cache[string, Color]
+
+	// thumbs are the JPEG thumbnails served by /img, downloaded by
+	// thumbPuller. Both are created by the first request.
+	thumbs      *lru.Cache[string, []byte]
+	thumbPuller *wikimg.Puller
+	thumbsOnce  sync.Once
+
+	// index finds colors in the cache by color
+	index colorIndex
+
+	// hub sends new colors to clients following the stream
+	hub hub
+
+	// cycles are the stats of the most recent cycles
+	cycles cycleLog
+}
+
+// New creates a Server that keeps the colors of at most size images, each
+// for at most ttl (zero for no limit)
+func New(size int, ttl time.Duration) *Server {
+	s := &Server{colors: lru.New[string, Color](size, ttl)}
+	s.colors.OnEvict(func(imgURL string, c Color) {
+		s.index.remove(imgURL)
+	})
+
+	return s
+}
+
+// orDefault returns v, or def if v is zero
+func orDefault[T int | time.Duration | string](v, def T) T {
+	var zero T
+	if v == zero {
+		return def
+	}
+
+	return v
+}
+
+// Run pulls and analyzes a batch of images every Interval until ctx is
+// done, starting right away
+func (s *Server) Run(ctx context.Context) {
+	for {
+		err := s.Cycle(ctx)
+		if err != nil && ctx.Err() == nil && s.Logger != nil {
+			s.Logger.Warn("server: background cycle failed", "err", err)
+		}
+
+		select {
+		case <-time.After(orDefault(s.Interval, DefaultInterval)):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Cycle pulls a batch of images and analyzes them, adding their colors to
+// the cache. Images that fail are skipped. It returns an error if the API
+// fails or the cycle times out.
+func (s *Server) Cycle(ctx context.Context) error {
+	stats := CycleStats{Start: time.Now(), Errors: map[string]int{}}
+
+	ctx, cancel := context.WithTimeout(ctx, orDefault(s.CycleTimeout, DefaultCycleTimeout))
+	defer cancel()
+
+	batch := orDefault(s.Batch, DefaultBatch)
+
+	var p *wikimg.Puller
+	if s.NewPuller != nil {
+		p = s.NewPuller(batch)
+	} else {
+		p = wikimg.NewPuller(batch)
+	}
+	p.Cancel = ctx.Done()
+	if s.Aggregator != nil {
+		p.Options.MeasureHistogram = true
+	}
+
+	src := p.Source()
+	if s.NewSource != nil {
+		src = s.NewSource(batch)
+	}
+
+	// Pull images in the background, so they're analyzed as they arrive
+	images := make(chan wikimg.ImageInfo)
+	pullErr := make(chan error, 1)
+	go func() {
+		defer close(images)
+
+		for {
+			img, err := src.Next(ctx)
+			if err == wikimg.EndOfResults {
+				pullErr <- nil
+				return
+			} else if err != nil {
+				pullErr <- err
+				return
+			}
+
+			select {
+			case images <- img:
+			case <-ctx.Done():
+				pullErr <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	// Keep every result for the Notifier's report, and count them for
+	// the cycle's stats
+	var results []wikimg.ColorResult
+	var resultsMutex sync.Mutex
+
+	pool.Run(ctx, orDefault(s.Workers, DefaultWorkers), images, func(ctx context.Context, img wikimg.ImageInfo) error {
+		if s.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+			defer cancel()
+		}
+
+		info, err := p.FirstColorContext(ctx, img.URL)
+		resultsMutex.Lock()
+		stats.Processed++
+		if err != nil {
+			stats.Errors[errorType(err)]++
+		}
+		if s.Notifier != nil {
+			results = append(results, wikimg.ColorResult{URL: img.URL, Info: info, Err: err})
+		}
+		resultsMutex.Unlock()
+		if err != nil {
+			if s.Logger != nil {
+				s.Logger.Warn("server: couldn't analyze image", "url", img.URL, "err", err)
+			}
+			return nil
+		}
+
+		if s.Aggregator != nil {
+			s.Aggregator.Add(info.Histogram)
+		}
+		if s.Notifier != nil {
+			s.Notifier.Notify(img, info)
+		}
+
+		c := Color{
+			URL:      img.URL,
+			Title:    img.Title,
+			Uploaded: img.Uploaded,
+			Analyzed: time.Now(),
+			Hex:      info.Hex,
+			XTerm:    info.Index,
+			Depicts:  img.Depicts,
+			Info:     info,
+		}
+		if len(img.Page) > 0 {
+			c.Page = img.Page
+		} else if len(img.Title) > 0 {
+			c.Page = wikimg.PageURL(img.Title)
+		}
+
+		// Index first, so the index never has a color the cache dropped
+		s.index.add(c)
+		s.colors.Add(img.URL, c)
+		s.hub.publish(c)
+
+		return nil
+	})
+
+	if s.Notifier != nil {
+		s.Notifier.Report(results)
+	}
+
+	ps := p.Stats()
+	stats.Duration = time.Since(stats.Start).Seconds()
+	stats.Pages = ps.Pages
+	stats.Images = ps.Images
+	stats.Bytes = ps.Bytes
+	s.cycles.add(stats, orDefault(s.KeepCycles, DefaultKeepCycles))
+
+	if s.IOTD != nil {
+		s.pickIOTD(time.Now())
+	}
+
+	return <-pullErr
+}
+
+// Colors returns at most max colors, most recently analyzed first
+func (s *Server) Colors(max int) []Color {
+	colors, _ := s.Page(0, max)
+
+	return colors
+}
+
+// Page returns at most max colors, most recently analyzed first, after
+// skipping the first offset, and how many colors there are in all. Colors
+// are paged as they are at the time of the call, so new colors show up as
+// soon as they're analyzed, and expired ones are gone.
+func (s *Server) Page(offset, max int) ([]Color, int) {
+	colors := []Color{}
+	total := 0
+	s.colors.Each(func(key string, c Color) bool {
+		if total >= offset && len(colors) < max {
+			colors = append(colors, c)
+		}
+		total++
+
+		return true
+	})
+
+	return colors, total
+}
+
+// Handler returns a handler for the API and the wall
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/colors", s.ServeColors)
+	mux.HandleFunc("/ws", s.ServeWebSocket)
+	mux.HandleFunc("/events", s.ServeEvents)
+	mux.HandleFunc("/mosaic.png", s.ServeMosaic)
+	mux.HandleFunc("/today", s.ServeToday)
+	mux.HandleFunc("/feed.xml", s.ServeFeed)
+	mux.HandleFunc("/img", s.ServeImage)
+	mux.HandleFunc("/cycles", s.ServeCycles)
+	mux.HandleFunc("/mood", s.ServeMood)
+	mux.HandleFunc("/iotd", s.ServeIOTD)
+	mux.HandleFunc("/", s.ServeWall)
+
+	return mux
+}
+
+// max returns the number of colors r asks for with its max query
+// parameter, at most s.Max
+func (s *Server) max(r *http.Request) int {
+	return maxParam(r, orDefault(s.Max, DefaultMax))
+}
+
+// requested returns the most recently analyzed colors r asks for with its
+// max query parameter, skipping as many as its offset parameter, e.g.,
+// ?offset=300&max=100, and sets the X-Total-Count header of w to the number
+// of colors, and the Link header to the next page, if any. They're in the
+// order its sort query parameter names, if any, e.g., ?sort=hue (see
+// wikimg.ParseOrder). With a collapse query parameter, repeated colors are
+// merged into one with a Count, e.g., ?collapse=runs (see
+// wikimg.ParseCollapse). With a cvd parameter, they're the colors someone
+// with that color vision deficiency sees, e.g., ?cvd=deuteranopia (see
+// wikimg.ParseCVD).
+func (s *Server) requested(w http.ResponseWriter, r *http.Request) ([]Color, error) {
+	offset, err := strconv.Atoi(r.FormValue("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	max := s.max(r)
+	colors, total := s.Page(offset, max)
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if next := offset + len(colors); next < total {
+		q := r.URL.Query()
+		q.Set("offset", strconv.Itoa(next))
+		q.Set("max", strconv.Itoa(max))
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, q.Encode()))
+	}
+
+	if name := r.FormValue("cvd"); len(name) > 0 {
+		cvd, err := wikimg.ParseCVD(name)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range colors {
+			colors[i].Info = colors[i].Info.Simulate(cvd)
+			colors[i].Hex = colors[i].Info.Hex
+		}
+	}
+
+	info := func(c Color) wikimg.ColorInfo { return c.Info }
+
+	if name := r.FormValue("sort"); len(name) > 0 {
+		order, err := wikimg.ParseOrder(name)
+		if err != nil {
+			return nil, err
+		}
+
+		wikimg.SortColors(colors, order, info)
+	}
+
+	if name := r.FormValue("collapse"); len(name) > 0 {
+		collapse, err := wikimg.ParseCollapse(name)
+		if err != nil {
+			return nil, err
+		}
+
+		runs := wikimg.CollapseColors(colors, collapse, info)
+		colors = make([]Color, len(runs))
+		for i, run := range runs {
+			colors[i] = run.Item
+			colors[i].Count = run.Count
+		}
+	}
+
+	return colors, nil
+}
+
+// ServeColors writes the most recently analyzed colors as a JSON array.
+// With a near query parameter, a hex color, it writes the colors within
+// tolerance (another parameter, DefaultTolerance by default) of it instead,
+// nearest first (see Near).
+func (s *Server) ServeColors(w http.ResponseWriter, r *http.Request) {
+	near := r.FormValue("near")
+	if len(near) < 1 {
+		colors, err := s.requested(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(colors)
+		return
+	}
+
+	target, err := wikimg.ParseHex(near)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tolerance := float64(DefaultTolerance)
+	if v := r.FormValue("tolerance"); len(v) > 0 {
+		tolerance, err = strconv.ParseFloat(v, 64)
+
+		// This also rejects NaN
+		if err != nil || !(tolerance >= 0) {
+			http.Error(w, fmt.Sprintf("invalid tolerance %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Near(target, tolerance, s.max(r)))
+}
+
+// ServeToday writes the top colors of the images analyzed recently as a
+// JSON array, most first, with the share of the sampled pixels of each (see
+// wikimg.Aggregator.Top). The over query parameter is how far back to look,
+// e.g., 1h, up to the Aggregator's Window, which it is by default. The k
+// parameter is how many colors to write, DefaultTop by default.
+func (s *Server) ServeToday(w http.ResponseWriter, r *http.Request) {
+	if s.Aggregator == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	over := wikimg.DefaultAggregateWindow
+	if s.Aggregator.Window > 0 {
+		over = s.Aggregator.Window
+	}
+	if v := r.FormValue("over"); len(v) > 0 {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("invalid over %q", v), http.StatusBadRequest)
+			return
+		}
+		over = d
+	}
+
+	k, err := strconv.Atoi(r.FormValue("k"))
+	if err != nil || k < 1 {
+		k = DefaultTop
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Aggregator.Top(k, over))
+}
+
+// ServeMosaic writes a PNG with a square of each of the most recently
+// analyzed colors, in rows from the top left. The cols and cell query
+// parameters set the number of squares in each row and their size in
+// pixels, up to 100 each.
+func (s *Server) ServeMosaic(w http.ResponseWriter, r *http.Request) {
+	var m mosaic.Mosaic
+	m.Cols, _ = strconv.Atoi(r.FormValue("cols"))
+	m.Cell, _ = strconv.Atoi(r.FormValue("cell"))
+	m.Cols = min(m.Cols, 100)
+	m.Cell = min(m.Cell, 100)
+
+	colors, err := s.requested(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cells := make([]mosaic.Cell, len(colors))
+	for i, c := range colors {
+		cells[i] = mosaic.NewCell(c.URL, c.Info)
+	}
+
+	m.ServePNG(w, r, cells)
+}
+
+// ServeWall writes an HTML page with a swatch for each of the most recently
+// analyzed colors, linking to its image, with a thumbnail of the image if
+// Thumbnails is set, using Template. New colors are added to the top as
+// they're analyzed. With a format query parameter, e.g., ?format=text, it
+// writes just the hex colors instead (see ParseParams).
+func (s *Server) ServeWall(w http.ResponseWriter, r *http.Request) {
+	params, err := ParseParams(r, Params{Max: s.max(r)})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	colors, err := s.requested(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if params.Format != FormatHTML {
+		cw := NewColorWriter(w, params.Format)
+		for _, c := range colors {
+			cw.Write(c.Hex)
+		}
+		cw.Close()
+		return
+	}
+
+	// New colors would break up a sorted, collapsed, simulated or later
+	// page of the wall, so only follow them on one that's none of those
+	wall := s.page(orDefault(s.Title, DefaultTitle))
+	wall.Swatches = make([]Swatch, len(colors))
+	wall.Thumbnails = s.Thumbnails
+	wall.Live = true
+	for _, name := range []string{"sort", "collapse", "cvd", "offset"} {
+		if len(r.FormValue(name)) > 0 {
+			wall.Live = false
+		}
+	}
+	for i, c := range colors {
+		wall.Swatches[i] = Swatch{Color: c, Thumbnail: s.Thumbnails}
+	}
+
+	s.render(w, "wall", wall)
+}
+
+// ServeMood writes a summary of the colors in the cache as JSON, including
+// whether they're warm or cool (see wikimg.Summarize)
+func (s *Server) ServeMood(w http.ResponseWriter, r *http.Request) {
+	var results []wikimg.ColorResult
+	s.colors.Each(func(key string, c Color) bool {
+		results = append(results, wikimg.ColorResult{URL: c.URL, Info: c.Info})
+		return true
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wikimg.Summarize(results))
+}
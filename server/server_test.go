@@ -0,0 +1,554 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brnstz/routine/notify"
+	"github.com/brnstz/routine/wikimg"
+	"github.com/brnstz/routine/wikimg/wikimgtest"
+)
+
+// colors are served by the image server, by path
+var colors = map[string]color.RGBA{
+	"/red.png":   {0xff, 0x00, 0x00, 0xff},
+	"/green.png": {0x00, 0xff, 0x00, 0xff},
+	"/blue.png":  {0x00, 0x00, 0xff, 0xff},
+}
+
+// newTestServer creates a Server that pulls every image in colors, plus
+// one that's missing, from a fake API
+func newTestServer(t *testing.T) *Server {
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := colors[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+		for i := 0; i < 4; i++ {
+			img.Set(i%2, i/2, c)
+		}
+		png.Encode(w, img)
+	}))
+	t.Cleanup(images.Close)
+
+	src := wikimgtest.NewSource(2)
+	t.Cleanup(src.Close)
+	src.Add(images.URL+"/blue.png", images.URL+"/missing.png", images.URL+"/green.png", images.URL+"/red.png")
+
+	s := New(100, 0)
+	s.Workers = 1
+	s.NewPuller = src.Puller
+
+	return s
+}
+
+func TestCycle(t *testing.T) {
+	s := newTestServer(t)
+
+	err := s.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// With one worker, the oldest upload is analyzed last
+	got := s.Colors(10)
+	expected := []string{"#ff0000", "#00ff00", "#0000ff"}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d colors but got %v", len(expected), got)
+	}
+	for i, hex := range expected {
+		if got[i].Hex != hex {
+			t.Errorf("expected %s at %d but got %s", hex, i, got[i].Hex)
+		}
+		if !strings.HasPrefix(got[i].Page, "https://commons.wikimedia.org/wiki/File:") {
+			t.Errorf("expected a page for %s but got %q", got[i].URL, got[i].Page)
+		}
+	}
+
+	if n := len(s.Colors(2)); n != 2 {
+		t.Errorf("expected 2 colors but got %d", n)
+	}
+}
+
+func TestServeColors(t *testing.T) {
+	s := newTestServer(t)
+	s.Max = 2
+
+	err := s.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	tests := []struct {
+		query    string
+		expected int
+	}{
+		{"", 2},
+		{"?max=1", 1},
+		{"?max=100", 2},
+		{"?max=nope", 2},
+	}
+
+	for _, test := range tests {
+		resp, err := http.Get(ts.URL + "/colors" + test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []map[string]any
+		err = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(got) != test.expected {
+			t.Errorf("expected %d colors for %q but got %d", test.expected, test.query, len(got))
+			continue
+		}
+		if got[0]["hex"] != "#ff0000" || got[0]["xterm"] != float64(9) || got[0]["url"] == nil {
+			t.Errorf("expected red first but got %v", got[0])
+		}
+	}
+}
+
+func TestServeWall(t *testing.T) {
+	s := newTestServer(t)
+
+	err := s.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/?max=1", nil))
+
+	body := w.Body.String()
+	if strings.Count(body, "<a style") != 1 || !strings.Contains(body, "background: #ff0000") ||
+		!strings.Contains(body, `href="https://commons.wikimedia.org/wiki/File:red.png"`) {
+		t.Errorf("expected one red swatch but got %s", body)
+	}
+	if !strings.Contains(body, `"/ws"`) {
+		t.Errorf("expected the wall to follow /ws but got %s", body)
+	}
+}
+
+func TestServeSorted(t *testing.T) {
+	s := newTestServer(t)
+
+	err := s.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	var got []Color
+	json.NewDecoder(get("/colors?sort=luminance").Body).Decode(&got)
+	if len(got) != 3 || got[0].Hex != "#0000ff" || got[1].Hex != "#ff0000" || got[2].Hex != "#00ff00" {
+		t.Errorf("expected blue, red and green but got %+v", got)
+	}
+
+	// A sorted wall doesn't follow new colors, which would be out of
+	// order
+	body := get("/?sort=hue").Body.String()
+	if r, g, b := strings.Index(body, "#ff0000"), strings.Index(body, "#00ff00"), strings.Index(body, "#0000ff"); r > g || g > b {
+		t.Errorf("expected red, green and blue but got %s", body)
+	}
+	if strings.Contains(body, `"/ws"`) {
+		t.Errorf("expected a sorted wall not to follow /ws but got %s", body)
+	}
+
+	if w := get("/colors?sort=rainbow"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown order but got %d", w.Code)
+	}
+}
+
+func TestServeCollapsed(t *testing.T) {
+	// Most recent first, that's beige, red, beige, beige
+	s := New(10, 0)
+	for i, hex := range []string{"#f5f5dc", "#f5f5dc", "#ff0000", "#f5f5dc"} {
+		c := newColor(fmt.Sprintf("http://example.com/%d.png", i), hex)
+		s.colors.Add(c.URL, c)
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	for _, test := range []struct {
+		collapse string
+		expected []int
+	}{
+		{"runs", []int{1, 1, 2}},
+		{"all", []int{3, 1}},
+	} {
+		var got []Color
+		json.NewDecoder(get("/colors?collapse=" + test.collapse).Body).Decode(&got)
+
+		var counts []int
+		for _, c := range got {
+			counts = append(counts, c.Count)
+		}
+		if fmt.Sprint(counts) != fmt.Sprint(test.expected) {
+			t.Errorf("%s: expected counts %v but got %v", test.collapse, test.expected, counts)
+		}
+	}
+
+	if body := get("/?collapse=all").Body.String(); !strings.Contains(body, "#f5f5dc ×3") || strings.Contains(body, `"/ws"`) {
+		t.Errorf("expected beige once with a count but got %s", body)
+	}
+
+	if w := get("/colors?collapse=some"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown mode but got %d", w.Code)
+	}
+}
+
+func TestCycleCanceled(t *testing.T) {
+	s := newTestServer(t)
+	s.NewPuller = func(max int) *wikimg.Puller {
+		src := wikimgtest.NewSource(1)
+		t.Cleanup(src.Close)
+		src.Block()
+
+		return src.Puller(max)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := s.Cycle(ctx); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestCyclePublishes(t *testing.T) {
+	s := newTestServer(t)
+
+	sub := s.hub.subscribe()
+	defer s.hub.unsubscribe(sub)
+
+	err := s.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sub) != 3 {
+		t.Fatalf("expected 3 colors to be published but got %d", len(sub))
+	}
+	if c := <-sub; c.Hex != "#0000ff" {
+		t.Errorf("expected blue first but got %s", c.Hex)
+	}
+}
+
+func TestCycleNotifies(t *testing.T) {
+	s := newTestServer(t)
+
+	posted := make(chan notify.Match, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m notify.Match
+		json.NewDecoder(r.Body).Decode(&m)
+		posted <- m
+	}))
+	defer hook.Close()
+
+	var err error
+	s.Notifier, err = notify.New([]notify.Rule{{Name: "red", Near: "#ff0000", Webhook: hook.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Notifier.Run(ctx)
+
+	err = s.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the red image matches
+	if m := <-posted; m.Hex != "#ff0000" || !strings.HasSuffix(m.URL, "/red.png") {
+		t.Errorf("expected red to be posted but got %+v", m)
+	}
+	select {
+	case m := <-posted:
+		t.Errorf("expected only red to be posted but got %+v", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCycleReports(t *testing.T) {
+	s := newTestServer(t)
+
+	posted := make(chan notify.Report, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report notify.Report
+		json.NewDecoder(r.Body).Decode(&report)
+		posted <- report
+	}))
+	defer hook.Close()
+
+	var err error
+	s.Notifier, err = notify.New([]notify.Rule{{Name: "cycle", Summary: true, Webhook: hook.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Notifier.Run(ctx)
+
+	err = s.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every image is summarized, including the missing one
+	if report := <-posted; report.Rule != "cycle" || report.Summary.Images != 4 || report.Summary.Errors != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+func TestServeMosaic(t *testing.T) {
+	s := newTestServer(t)
+
+	err := s.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/mosaic.png?cols=2&cell=3", nil))
+
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Three colors, two to a row
+	if img.Bounds() != image.Rect(0, 0, 6, 6) {
+		t.Fatalf("unexpected bounds %v", img.Bounds())
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r != 0xffff {
+		t.Errorf("expected the most recent color, red, first but got %v", img.At(0, 0))
+	}
+}
+
+func TestServeToday(t *testing.T) {
+	s := newTestServer(t)
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/today", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected %d without an Aggregator but got %d", http.StatusNotFound, w.Code)
+	}
+
+	s.Aggregator = &wikimg.Aggregator{}
+	err := s.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		code     int
+		expected []string
+	}{
+		{"", http.StatusOK, []string{"#ff0000", "#00ff00", "#0000ff"}},
+		{"?k=2&over=1h", http.StatusOK, []string{"#ff0000", "#00ff00"}},
+		{"?over=yesterday", http.StatusBadRequest, nil},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/today"+test.query, nil))
+
+		if w.Code != test.code {
+			t.Errorf("%q: expected %d but got %d", test.query, test.code, w.Code)
+			continue
+		}
+		if test.code != http.StatusOK {
+			continue
+		}
+
+		var got []wikimg.ColorCount
+		err := json.NewDecoder(w.Body).Decode(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(got) != len(test.expected) {
+			t.Errorf("%q: expected %v but got %+v", test.query, test.expected, got)
+			continue
+		}
+		for i, hex := range test.expected {
+			if got[i].Hex != hex || got[i].Pixels != 4 {
+				t.Errorf("%q: expected 4 pixels of %s at %d but got %+v", test.query, hex, i, got[i])
+			}
+		}
+	}
+}
+
+func TestCycleSource(t *testing.T) {
+	s := newTestServer(t)
+
+	err := s.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var green string
+	for _, c := range s.Colors(10) {
+		if c.Hex == "#00ff00" {
+			green = c.URL
+		}
+	}
+
+	// Only the green image, from somewhere other than the API
+	other := New(100, 0)
+	other.NewPuller = s.NewPuller
+	other.NewSource = func(max int) wikimg.Source {
+		return wikimg.SliceSource([]wikimg.ImageInfo{{URL: green}})
+	}
+
+	err = other.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := other.Colors(10)
+	if len(got) != 1 || got[0].URL != green || got[0].Page != "" {
+		t.Errorf("expected only green but got %v", got)
+	}
+}
+
+func TestServePaged(t *testing.T) {
+	// Most recent first, that's 4 to 0, the oldest of which expires
+	s := New(10, time.Hour)
+	for i := 0; i < 5; i++ {
+		c := newColor(fmt.Sprintf("http://example.com/%d.png", i), "#ff0000")
+		if i == 0 {
+			s.colors.AddTTL(c.URL, c, time.Nanosecond)
+		} else {
+			s.colors.Add(c.URL, c)
+		}
+	}
+	time.Sleep(time.Millisecond)
+
+	get := func(path string) ([]string, *httptest.ResponseRecorder) {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+		var got []Color
+		json.NewDecoder(w.Body).Decode(&got)
+
+		var urls []string
+		for _, c := range got {
+			urls = append(urls, strings.TrimPrefix(c.URL, "http://example.com/"))
+		}
+		return urls, w
+	}
+
+	urls, w := get("/colors?max=2&offset=1")
+	if fmt.Sprint(urls) != "[3.png 2.png]" {
+		t.Errorf("expected 3 and 2 but got %v", urls)
+	}
+	if n := w.Header().Get("X-Total-Count"); n != "4" {
+		t.Errorf("expected 4 colors in all but got %s", n)
+	}
+	if link := w.Header().Get("Link"); link != `</colors?max=2&offset=3>; rel="next"` {
+		t.Errorf("unexpected next link %s", link)
+	}
+
+	// The last page has no next one
+	urls, w = get("/colors?max=2&offset=3")
+	if fmt.Sprint(urls) != "[1.png]" || len(w.Header().Get("Link")) > 0 {
+		t.Errorf("expected just 1 and no next page but got %v and %s", urls, w.Header().Get("Link"))
+	}
+
+	// New colors show up right away
+	c := newColor("http://example.com/5.png", "#00ff00")
+	s.colors.Add(c.URL, c)
+	if urls, _ := get("/colors?max=1"); fmt.Sprint(urls) != "[5.png]" {
+		t.Errorf("expected 5 but got %v", urls)
+	}
+}
+
+func TestServeWallFormats(t *testing.T) {
+	s := New(10, 0)
+	c := newColor("http://example.com/red.png", "#ff0000")
+	s.colors.Add(c.URL, c)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	if body := get("/?format=text").Body.String(); body != "#ff0000\n" {
+		t.Errorf("expected just red but got %q", body)
+	}
+
+	// Simulated colors are shown instead, and aren't followed
+	body := get("/?cvd=deuteranopia").Body.String()
+	if strings.Contains(body, "#ff0000") || strings.Contains(body, `"/ws"`) {
+		t.Errorf("expected red as someone with deuteranopia sees it but got %s", body)
+	}
+
+	for _, path := range []string{"/?format=xml", "/?cvd=purple"} {
+		if w := get(path); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 but got %d", path, w.Code)
+		}
+	}
+
+	// The title and refresh are set by the server
+	s.Title = "Red"
+	s.Refresh = time.Minute
+	body = get("/").Body.String()
+	if !strings.Contains(body, "<title>Red</title>") || !strings.Contains(body, `<meta http-equiv="refresh" content="60">`) {
+		t.Errorf("expected a title and refresh but got %s", body)
+	}
+}
+
+func TestServeMood(t *testing.T) {
+	s := newTestServer(t)
+
+	err := s.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/mood", nil))
+
+	var got wikimg.Summary
+	err = json.NewDecoder(w.Body).Decode(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Images != 3 {
+		t.Errorf("expected a summary of 3 colors but got %+v", got)
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"os"
+)
+
+// wallTemplates are the default templates of the wall and the image of the
+// day. "wall" is the wall itself, showing each color with the "swatch"
+// template: a div with the hex background that links to the image's page,
+// with the hex value printed on top in a contrasting color, after the
+// thumbnail, if any. "iotd" shows the images of the last days the same way.
+// Both start with "head". "live" is the script that follows new colors (see
+// liveScript).
+const wallTemplates = `{{define "swatch"}}<a style="text-decoration: none" href="{{or .Page .URL}}" title="{{.Info.Name}}"><div style="background: {{.Hex}}; color: {{.Info.Contrast}}; font-family: monospace; width: 100%">
+{{- if .Thumbnail}}<img src="/img?url={{.URL}}" loading="lazy" alt="" style="height: 3em; vertical-align: middle"> {{end -}}
+{{.Hex}}{{if gt .Count 1}} ×{{.Count}}{{end}}</div></a>
+{{end}}
+
+{{define "live"}}` + liveScript + `{{end}}
+
+{{define "head"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+{{if .Refresh}}<meta http-equiv="refresh" content="{{.Refresh}}">
+{{end -}}
+</head>
+<body style="margin: 0">
+{{end}}
+
+{{define "wall"}}{{template "head" .}}<div id="wall"{{if .Thumbnails}} data-thumbnails="true"{{end}}>
+{{range .Swatches}}{{template "swatch" .}}{{end -}}
+</div>
+{{if .Live}}{{template "live"}}{{end -}}
+</body>
+</html>
+{{end}}
+
+{{define "iotd"}}{{template "head" .}}{{range .Days}}<p>{{.Day}}</p>
+{{template "swatch" .Swatch}}{{end -}}
+</body>
+</html>
+{{end}}`
+
+// defaultTemplate renders the wall unless Server.Template is set
+var defaultTemplate = template.Must(newTemplate())
+
+// Wall is what the templates of the wall and the image of the day are
+// executed with
+type Wall struct {
+	// Title is the title of the page
+	Title string
+
+	// Refresh is how often, in seconds, browsers reload the page. Zero
+	// means never.
+	Refresh int
+
+	// Swatches are the colors on the wall, most recent first unless the
+	// request sorted them
+	Swatches []Swatch
+
+	// Thumbnails is true if the swatches show thumbnails of their images
+	Thumbnails bool
+
+	// Live is true if the wall should follow new colors over /ws, i.e.,
+	// it's neither sorted nor collapsed
+	Live bool
+
+	// Days are the images of the day, most recent first
+	Days []Day
+}
+
+// Swatch is a color on the wall
+type Swatch struct {
+	Color
+
+	// Thumbnail is true if the swatch shows a thumbnail of the image from
+	// /img
+	Thumbnail bool
+}
+
+// newTemplate parses the default templates, with the wall as the template
+// that's executed
+func newTemplate() (*template.Template, error) {
+	t, err := template.New("page").Parse(wallTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Lookup("wall"), nil
+}
+
+// ParseTemplate parses file as the template of the wall for
+// Server.Template. It's executed with a Wall, and may use the default
+// "head", "swatch" and "live" templates, or redefine them, e.g., with
+// {{define "swatch"}}...{{end}}, which the image of the day uses too. A
+// file that only redefines templates keeps the default wall.
+func ParseTemplate(file string) (*template.Template, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := newTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Parse(string(b))
+}
+
+// page returns a Wall with the title and refresh of a page
+func (s *Server) page(title string) Wall {
+	return Wall{Title: title, Refresh: int(s.Refresh.Seconds())}
+}
+
+// render executes the template name, from the same set as Template, with
+// page. It's rendered in full first, so a broken template fails the
+// request cleanly.
+func (s *Server) render(w http.ResponseWriter, name string, page Wall) {
+	t := s.Template
+	if t == nil {
+		t = defaultTemplate
+	}
+
+	// The wall is Template itself. Other pages come from its set, or the
+	// default one if it doesn't have them.
+	if name != "wall" {
+		if t.Lookup(name) != nil {
+			t = t.Lookup(name)
+		} else {
+			t = defaultTemplate.Lookup(name)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err := t.Execute(buf, page)
+	if err != nil {
+		if s.Logger != nil {
+			s.Logger.Warn("server: couldn't render page", "template", name, "err", err)
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
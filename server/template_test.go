@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	s := newTestServer(t)
+
+	err := s.Cycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func() string {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/?sort=hue", nil))
+		return w.Body.String()
+	}
+
+	file := filepath.Join(t.TempDir(), "wall.html")
+	write := func(text string) {
+		err := os.WriteFile(file, []byte(text), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A whole new wall that still uses the default swatches
+	write(`<ul>{{range .Swatches}}<li>{{.Title}}</li>{{template "swatch" .}}{{end}}</ul>`)
+	s.Template, err = ParseTemplate(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := get()
+	if !strings.HasPrefix(body, "<ul><li>File:red.png</li><a style") || strings.Count(body, "<li>") != 3 {
+		t.Errorf("expected the custom wall but got %s", body)
+	}
+
+	// Only redefining the swatch keeps the default wall
+	write(`{{define "swatch"}}<p>{{.Hex}}</p>{{end}}`)
+	s.Template, err = ParseTemplate(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body = get()
+	if !strings.Contains(body, "<!DOCTYPE html>") || !strings.Contains(body, "<p>#ff0000</p><p>#00ff00</p><p>#0000ff</p>") {
+		t.Errorf("expected custom swatches on the default wall but got %s", body)
+	}
+
+	// Values are escaped for their context
+	write(`<a href="{{"javascript:alert(1)"}}">{{"<b>"}}</a>`)
+	s.Template, err = ParseTemplate(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := get(); strings.Contains(body, "javascript:") || strings.Contains(body, "<b>") {
+		t.Errorf("expected escaped values but got %s", body)
+	}
+
+	// Templates that fail to execute fail the request
+	write(`{{.Missing}}`)
+	s.Template, err = ParseTemplate(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 500 {
+		t.Errorf("expected 500 for a broken template but got %d", w.Code)
+	}
+}
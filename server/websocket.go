@@ -0,0 +1,75 @@
+package server
+
+import (
+	"io"
+	"net/http"
+
+	"golang.org/x/net/websocket"
+)
+
+// liveScript keeps the wall up to date by adding a swatch to the top for
+// each color sent over /ws, with its thumbnail if the wall has them,
+// reconnecting if the connection drops
+const liveScript = `<script>
+function connect() {
+	var ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/ws");
+	ws.onmessage = function(e) {
+		var c = JSON.parse(e.data);
+		var a = document.createElement("a");
+		a.href = c.page || c.url;
+		a.style.textDecoration = "none";
+		var div = document.createElement("div");
+		div.style.cssText = "background: " + c.hex + "; font-family: monospace";
+		var wall = document.getElementById("wall");
+		if (wall.dataset.thumbnails) {
+			var img = document.createElement("img");
+			img.src = "/img?url=" + encodeURIComponent(c.url);
+			img.alt = "";
+			img.style.cssText = "height: 3em; vertical-align: middle";
+			div.appendChild(img);
+			div.appendChild(document.createTextNode(" "));
+		}
+		div.appendChild(document.createTextNode(c.hex));
+		a.appendChild(div);
+		wall.prepend(a);
+	};
+	ws.onclose = function() { setTimeout(connect, 2000); };
+}
+connect();
+</script>
+`
+
+// ServeWebSocket sends each color as it's analyzed to the connected
+// client, as a JSON object like those returned by /colors, until the
+// client goes away. Clients that can't keep up miss some colors.
+func (s *Server) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
+	websocket.Handler(s.stream).ServeHTTP(w, r)
+}
+
+// stream sends colors to ws
+func (s *Server) stream(ws *websocket.Conn) {
+	defer ws.Close()
+
+	sub := s.hub.subscribe()
+	defer s.hub.unsubscribe(sub)
+
+	// The client doesn't send us anything, so reading only ends when it
+	// goes away
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, ws)
+		close(gone)
+	}()
+
+	for {
+		select {
+		case c := <-sub:
+			err := websocket.JSON.Send(ws, c)
+			if err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
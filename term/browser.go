@@ -0,0 +1,31 @@
+package term
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// OpenBrowser opens u in the user's default web browser, without waiting
+// for it to start
+func OpenBrowser(u string) error {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", u)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		cmd = exec.Command("xdg-open", u)
+	}
+
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	// Don't leave a zombie behind
+	go cmd.Wait()
+
+	return nil
+}
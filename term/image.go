@@ -0,0 +1,208 @@
+package term
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+const (
+	// halfBlock is the upper half block character. Its foreground color is
+	// the top pixel and its background color is the bottom pixel, so each
+	// character shows two roughly square pixels.
+	halfBlock = "▀"
+
+	// ramp is used for previews without colors, from darkest to lightest
+	ramp = " .:-=+*#%@"
+)
+
+// Image prints a low resolution preview of img, Width characters wide, using
+// exact colors on truecolor terminals and xterm256 colors otherwise (or the
+// nearest basic colors on 16 color terminals). When
+// colors are disabled, the preview is drawn with ASCII characters by
+// lightness instead.
+func (r *Renderer) Image(img image.Image) error {
+	if r.Colors == TrueColor {
+		small, err := wikimg.ImageDownscale(img, wikimg.ColorOptions{}, r.Width, r.height(img.Bounds()))
+		if err != nil {
+			return err
+		}
+
+		return r.truecolor(small)
+	}
+
+	q, err := wikimg.ImageQuantize(img, wikimg.ColorOptions{}, r.Width, r.height(img.Bounds()))
+	if err != nil {
+		return err
+	}
+
+	return r.paletted(q)
+}
+
+// Preview retrieves the image at imgURL with p and prints it like Image.
+// Only the preview is kept in memory, not the image itself.
+func (r *Renderer) Preview(p *wikimg.Puller, imgURL string) error {
+	if r.Colors == TrueColor {
+		small, err := p.Downscale(imgURL, r.Width, 0)
+		if err != nil {
+			return err
+		}
+
+		return r.truecolor(small)
+	}
+
+	q, err := p.Quantize(imgURL, color.Palette(wikimg.XTerm256), r.Width, 0)
+	if err != nil {
+		return err
+	}
+
+	// The height is only known once the image is retrieved, so make sure
+	// it's an even number of pixels
+	if q.Bounds().Dy()%2 == 1 {
+		q = extend(q)
+	}
+
+	return r.paletted(q)
+}
+
+// height returns the height in pixels of a preview of an image with bounds
+// b. Since each character is two pixels tall, it's always even.
+func (r *Renderer) height(b image.Rectangle) int {
+	if b.Empty() {
+		return 2
+	}
+
+	h := max(1, r.Width*b.Dy()/b.Dx())
+
+	return h + h%2
+}
+
+// extend returns q with its last row repeated, giving it one more row
+func extend(q *image.Paletted) *image.Paletted {
+	b := q.Bounds()
+	out := image.NewPaletted(image.Rect(b.Min.X, b.Min.Y, b.Max.X, b.Max.Y+1), q.Palette)
+	copy(out.Pix, q.Pix)
+	copy(out.Pix[len(q.Pix):], q.Pix[len(q.Pix)-q.Stride:])
+
+	return out
+}
+
+// paletted prints q, whose indexes must be xterm256 colors, two rows of
+// pixels per line
+func (r *Renderer) paletted(q *image.Paletted) error {
+	if r.console != nil {
+		return r.consolePaletted(q)
+	}
+
+	b := q.Bounds()
+	sb := &strings.Builder{}
+
+	for y := b.Min.Y; y < b.Max.Y; y += 2 {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			top := int(q.ColorIndexAt(x, y))
+			bottom := top
+			if y+1 < b.Max.Y {
+				bottom = int(q.ColorIndexAt(x, y+1))
+			}
+
+			switch r.Colors {
+			case 0:
+				sb.WriteByte(shade(q.Palette[top], q.Palette[bottom]))
+			case 16:
+				// Foreground codes are 10 less than background codes
+				fmt.Fprintf(sb, "\x1b[%d;%dm%s", basic(top)-10, basic(bottom), halfBlock)
+			default:
+				fmt.Fprintf(sb, "\x1b[38;5;%d;48;5;%dm%s", top, bottom, halfBlock)
+			}
+		}
+
+		if r.Colors > 0 {
+			sb.WriteString("\x1b[0m")
+		}
+		sb.WriteByte('\n')
+	}
+
+	_, err := fmt.Fprint(r.w, sb.String())
+
+	return err
+}
+
+// consolePaletted prints q like paletted, but sets the colors of each
+// character through r.console
+func (r *Renderer) consolePaletted(q *image.Paletted) (err error) {
+	defer func() {
+		if rerr := r.console.reset(); err == nil {
+			err = rerr
+		}
+	}()
+
+	b := q.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y += 2 {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			top := int(q.ColorIndexAt(x, y))
+			bottom := top
+			if y+1 < b.Max.Y {
+				bottom = int(q.ColorIndexAt(x, y+1))
+			}
+
+			if err := r.console.setColors(basicIndex(top), basicIndex(bottom)); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(r.w, halfBlock); err != nil {
+				return err
+			}
+		}
+
+		if err := r.console.reset(); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(r.w, "\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// truecolor prints img with exact colors, two rows of pixels per line. An
+// odd last row is drawn over itself.
+func (r *Renderer) truecolor(img *image.NRGBA) error {
+	b := img.Bounds()
+	sb := &strings.Builder{}
+
+	for y := b.Min.Y; y < b.Max.Y; y += 2 {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			top := img.At(x, y)
+			bottom := top
+			if y+1 < b.Max.Y {
+				bottom = img.At(x, y+1)
+			}
+
+			sb.WriteString(Foreground(top))
+			sb.WriteString(Background(bottom))
+			sb.WriteString(halfBlock)
+		}
+
+		sb.WriteString(Reset)
+		sb.WriteByte('\n')
+	}
+
+	_, err := fmt.Fprint(r.w, sb.String())
+
+	return err
+}
+
+// shade returns the character of ramp for the mean lightness of two pixels
+func shade(top, bottom color.Color) byte {
+	l := 0.0
+	for _, c := range []color.Color{top, bottom} {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		l += (0.2126*float64(n.R) + 0.7152*float64(n.G) + 0.0722*float64(n.B)) / 255 / 2
+	}
+
+	return ramp[min(len(ramp)-1, int(l*float64(len(ramp))))]
+}
@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package term
+
+import "os"
+
+// makeRaw can't change the terminal mode on this platform, so keys are
+// only read once Enter is pressed
+func makeRaw(f *os.File) (func(), error) {
+	return func() {}, nil
+}
+
+// size can't find the size of the terminal on this platform
+func size(f *os.File) (int, int, bool) {
+	return 0, 0, false
+}
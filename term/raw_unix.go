@@ -0,0 +1,60 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package term
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// makeRaw puts the terminal f into raw mode, so keys are read as they're
+// pressed, without being echoed or turned into signals. The returned
+// function restores the terminal.
+func makeRaw(f *os.File) (func(), error) {
+	fd := f.Fd()
+
+	var old syscall.Termios
+	err := ioctl(fd, ioctlGetTermios, unsafe.Pointer(&old))
+	if err != nil {
+		return nil, err
+	}
+
+	raw := old
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	err = ioctl(fd, ioctlSetTermios, unsafe.Pointer(&raw))
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		ioctl(fd, ioctlSetTermios, unsafe.Pointer(&old))
+	}, nil
+}
+
+// size returns the width and height of the terminal f in characters
+func size(f *os.File) (int, int, bool) {
+	var ws struct {
+		Row, Col, X, Y uint16
+	}
+
+	err := ioctl(f.Fd(), syscall.TIOCGWINSZ, unsafe.Pointer(&ws))
+	if err != nil || ws.Col < 1 || ws.Row < 1 {
+		return 0, 0, false
+	}
+
+	return int(ws.Col), int(ws.Row), true
+}
+
+// ioctl makes an ioctl system call on fd
+func ioctl(fd, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
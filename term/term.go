@@ -0,0 +1,243 @@
+// Package term prints colors to a terminal using ANSI escape sequences,
+// adapting to the number of colors the terminal supports. When output is not
+// a terminal, or the user has set NO_COLOR (https://no-color.org), plain hex
+// values are printed instead.
+package term
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+const (
+	// defaultWidth is the default width of a bar in characters
+	defaultWidth = 80
+
+	// spec256 prints a blank bar with the given xterm256 background color
+	spec256 = "\x1b[30;48;5;%dm%-*s\x1b[0m\n"
+
+	// spec16 prints a blank bar with the given basic ANSI background color
+	spec16 = "\x1b[30;%dm%-*s\x1b[0m\n"
+
+	// specPlain prints the hex value and name of a color without any
+	// escape sequences
+	specPlain = "%s %s\n"
+)
+
+// Mode determines whether a Renderer prints colors
+type Mode int
+
+const (
+	// Auto prints colors only when writing to a terminal and NO_COLOR is
+	// not set
+	Auto Mode = iota
+
+	// Always prints colors
+	Always
+
+	// Never prints plain text
+	Never
+)
+
+// ParseMode parses "auto", "always" or "never" into a Mode, suitable for
+// a --color flag
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "auto":
+		return Auto, nil
+	case "always":
+		return Always, nil
+	case "never":
+		return Never, nil
+	}
+
+	return Auto, fmt.Errorf("term: invalid color mode %q (must be auto, always or never)", s)
+}
+
+// String returns the name of the mode
+func (m Mode) String() string {
+	switch m {
+	case Always:
+		return "always"
+	case Never:
+		return "never"
+	}
+
+	return "auto"
+}
+
+// Renderer writes color bars to a terminal
+type Renderer struct {
+	// Width is the width of each bar in characters
+	Width int
+
+	// Colors is the number of colors the terminal supports, either
+	// TrueColor, 256 or 16. Zero means colors are not printed at all.
+	Colors int
+
+	w       io.Writer
+	console console
+}
+
+// NewRenderer creates a Renderer that writes to f. In Auto mode, colors are
+// only printed when f is a terminal and the NO_COLOR environment variable is
+// empty. On platforms that require it (i.e., Windows consoles) virtual
+// terminal processing is enabled on f so ANSI escape sequences are
+// interpreted. If it can't be enabled (e.g., on consoles before Windows 10),
+// the 16 basic colors are set through the console instead. Terminals that
+// set COLORTERM to truecolor or 24bit get exact colors rather than the
+// nearest xterm256 ones, and those whose TERM only has the basic colors
+// (e.g., linux or xterm-16color) get the nearest basic ones.
+func NewRenderer(f *os.File, mode Mode) *Renderer {
+	return newRenderer(f, mode, enableVT)
+}
+
+// newRenderer is NewRenderer, enabling virtual terminal processing with vt,
+// which returns a console to fall back to when it can't be enabled
+func newRenderer(f *os.File, mode Mode, vt func(*os.File) (bool, console)) *Renderer {
+	r := &Renderer{
+		Width:  defaultWidth,
+		Colors: 256,
+		w:      f,
+	}
+
+	if mode == Never || (mode == Auto && (os.Getenv("NO_COLOR") != "" || !isTerminal(f))) {
+		r.Colors = 0
+		return r
+	}
+
+	ok, legacy := vt(f)
+
+	switch {
+	case !ok && legacy != nil:
+		r.Colors = 16
+		r.console = legacy
+	case !ok:
+		r.Colors = 0
+	case supportsTrueColor():
+		r.Colors = TrueColor
+	case basicTerm():
+		r.Colors = 16
+	}
+
+	return r
+}
+
+// Bar prints a blank line with the color as its background. On truecolor
+// terminals, the exact RGB value of the color is used. When the terminal
+// only supports 16 colors, the nearest basic color is used instead.
+// When colors are disabled, the hex value and name of the color are printed.
+func (r *Renderer) Bar(info wikimg.ColorInfo) error {
+	return r.LabeledBar(info, "")
+}
+
+// LabeledBar is like Bar, but prints label on the bar, e.g., how many
+// images have the color. When colors are disabled, it follows the name.
+func (r *Renderer) LabeledBar(info wikimg.ColorInfo, label string) error {
+	var err error
+
+	switch r.Colors {
+	case 0:
+		name := info.Name()
+		if len(label) > 0 {
+			name += " " + label
+		}
+		_, err = fmt.Fprintf(r.w, specPlain, info.Hex, name)
+	case 16:
+		if r.console != nil {
+			return r.consoleBar(basicIndex(index(info)), label)
+		}
+		_, err = fmt.Fprintf(r.w, spec16, basic(index(info)), r.Width, label)
+	case TrueColor:
+		_, err = fmt.Fprintf(r.w, specTrue, info.R, info.G, info.B, r.Width, label)
+	default:
+		_, err = fmt.Fprintf(r.w, spec256, index(info), r.Width, label)
+	}
+
+	return err
+}
+
+// isTerminal returns true if f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// basicIndex maps an xterm256 color index to the nearest of the 16 basic
+// ANSI colors, or -1 when it isn't a color
+func basicIndex(index int) int {
+	if index < 0 || index >= len(wikimg.XTerm256) {
+		return -1
+	}
+
+	if index < 16 {
+		return index
+	}
+
+	return color.Palette(wikimg.XTerm16).Index(wikimg.XTerm256[index])
+}
+
+// basic maps an xterm256 color index to the SGR background code of the
+// nearest of the 16 basic ANSI colors
+func basic(index int) int {
+	i := basicIndex(index)
+
+	// 0-7 are normal colors and 8-15 are their bright variants
+	switch {
+	case i < 0:
+		return 49
+	case i < 8:
+		return 40 + i
+	}
+
+	return 100 + i - 8
+}
+
+// basicTerm returns true if the TERM environment variable names a terminal
+// that only has the 16 basic colors, like the Linux console
+func basicTerm() bool {
+	t := os.Getenv("TERM")
+
+	return t == "linux" || t == "ansi" || strings.HasSuffix(t, "-16color") || strings.HasSuffix(t, "-8color")
+}
+
+// console sets colors on a terminal that doesn't understand escape
+// sequences, i.e., Windows consoles before Windows 10
+type console interface {
+	// setColors sets the foreground and background of what's written
+	// next to basic colors fg and bg, or leaves them as they were
+	// when -1
+	setColors(fg, bg int) error
+
+	// reset restores the colors the console had to begin with
+	reset() error
+}
+
+// consoleBar prints a bar with the basic background color bg through
+// r.console
+func (r *Renderer) consoleBar(bg int, label string) error {
+	if err := r.console.setColors(0, bg); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(r.w, "%-*s", r.Width, label)
+	if rerr := r.console.reset(); err == nil {
+		err = rerr
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(r.w, "\n")
+
+	return err
+}
@@ -0,0 +1,198 @@
+package term
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"testing"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+func TestBar(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := &Renderer{Width: 4, Colors: 256, w: buf}
+
+	red := wikimg.ColorInfo{Index: 196, Hex: "#ff0000", R: 0xff}
+
+	r.Bar(red)
+	if s := buf.String(); s != "\x1b[30;48;5;196m    \x1b[0m\n" {
+		t.Errorf("unexpected 256 color bar %q", s)
+	}
+
+	buf.Reset()
+	r.Colors = 16
+
+	// 196 is pure red, which is bright red (9) in the basic colors
+	r.Bar(red)
+	if s := buf.String(); s != "\x1b[30;101m    \x1b[0m\n" {
+		t.Errorf("unexpected 16 color bar %q", s)
+	}
+
+	buf.Reset()
+	r.Colors = TrueColor
+
+	r.Bar(red)
+	if s := buf.String(); s != "\x1b[30;48;2;255;0;0m    \x1b[0m\n" {
+		t.Errorf("unexpected truecolor bar %q", s)
+	}
+
+	// Unquantized colors are mapped to xterm256 when needed
+	buf.Reset()
+	r.Colors = 256
+	exact := wikimg.ColorInfo{Index: -1, Hex: "#fe0101", R: 0xfe, G: 1, B: 1}
+
+	r.Bar(exact)
+	if s := buf.String(); s != "\x1b[30;48;5;9m    \x1b[0m\n" {
+		t.Errorf("unexpected unquantized 256 color bar %q", s)
+	}
+
+	buf.Reset()
+	r.Colors = 0
+
+	r.Bar(red)
+	if s := buf.String(); s != "#ff0000 red\n" {
+		t.Errorf("unexpected plain bar %q", s)
+	}
+
+	// Labels are printed on the bar, or after the name
+	buf.Reset()
+	r.LabeledBar(red, "x3")
+	if s := buf.String(); s != "#ff0000 red x3\n" {
+		t.Errorf("unexpected plain labeled bar %q", s)
+	}
+
+	buf.Reset()
+	r.Colors = 256
+	r.LabeledBar(red, "x3")
+	if s := buf.String(); s != "\x1b[30;48;5;196mx3  \x1b[0m\n" {
+		t.Errorf("unexpected labeled bar %q", s)
+	}
+}
+
+// fakeConsole writes the colors it's set to into its buffer, like
+// [fg/bg] and [reset]
+type fakeConsole struct {
+	buf *bytes.Buffer
+}
+
+func (c fakeConsole) setColors(fg, bg int) error {
+	fmt.Fprintf(c.buf, "[%d/%d]", fg, bg)
+	return nil
+}
+
+func (c fakeConsole) reset() error {
+	c.buf.WriteString("[reset]")
+	return nil
+}
+
+func TestNewRendererFallback(t *testing.T) {
+	t.Setenv("COLORTERM", "")
+	t.Setenv("TERM", "xterm-256color")
+
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	vt := func(*os.File) (bool, console) { return true, nil }
+	if r := newRenderer(f, Always, vt); r.Colors != 256 {
+		t.Errorf("expected 256 colors but got %d", r.Colors)
+	}
+
+	// Terminals with only the basic colors get those
+	t.Setenv("TERM", "linux")
+	if r := newRenderer(f, Always, vt); r.Colors != 16 {
+		t.Errorf("expected 16 colors for the linux console but got %d", r.Colors)
+	}
+
+	// A console that can't interpret escape sequences has its colors set
+	// directly, or gets plain text if even that isn't possible
+	buf := &bytes.Buffer{}
+	r := newRenderer(f, Always, func(*os.File) (bool, console) { return false, fakeConsole{buf} })
+	if r.Colors != 16 {
+		t.Fatalf("expected 16 colors for a legacy console but got %d", r.Colors)
+	}
+
+	r.w = buf
+	r.Width = 4
+	r.LabeledBar(wikimg.ColorInfo{Index: 196, Hex: "#ff0000", R: 0xff}, "x3")
+	if s := buf.String(); s != "[0/9]x3  [reset]\n" {
+		t.Errorf("unexpected legacy console bar %q", s)
+	}
+
+	if r := newRenderer(f, Always, func(*os.File) (bool, console) { return false, nil }); r.Colors != 0 {
+		t.Errorf("expected plain text but got %d colors", r.Colors)
+	}
+}
+
+func TestImage(t *testing.T) {
+	// White on top, black on the bottom
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			img.Set(x, y, color.White)
+			img.Set(x, y+2, color.Black)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	r := &Renderer{Width: 2, Colors: 256, w: buf}
+
+	// Each character is a white pixel over a black one
+	err := r.Image(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cell := "\x1b[38;5;15;48;5;0m▀"
+	if s := buf.String(); s != cell+cell+"\x1b[0m\n" {
+		t.Errorf("unexpected 256 color image %q", s)
+	}
+
+	buf.Reset()
+	r.Colors = TrueColor
+
+	if err := r.Image(img); err != nil {
+		t.Fatal(err)
+	}
+	cell = "\x1b[38;2;255;255;255m\x1b[48;2;0;0;0m▀"
+	if s := buf.String(); s != cell+cell+"\x1b[0m\n" {
+		t.Errorf("unexpected truecolor image %q", s)
+	}
+
+	buf.Reset()
+	r.Colors = 0
+
+	if err := r.Image(img); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != "==\n" {
+		t.Errorf("unexpected plain image %q", s)
+	}
+
+	buf.Reset()
+	r.Colors = 16
+	r.console = fakeConsole{buf}
+
+	if err := r.Image(img); err != nil {
+		t.Fatal(err)
+	}
+	cell = "[15/0]▀"
+	if s := buf.String(); s != cell+cell+"[reset]\n[reset]" {
+		t.Errorf("unexpected legacy console image %q", s)
+	}
+}
+
+func TestTrueColorSequences(t *testing.T) {
+	c := color.NRGBA{12, 34, 56, 0xff}
+
+	if s := Background(c); s != "\x1b[48;2;12;34;56m" {
+		t.Errorf("unexpected background %q", s)
+	}
+	if s := Foreground(c); s != "\x1b[38;2;12;34;56m" {
+		t.Errorf("unexpected foreground %q", s)
+	}
+}
@@ -0,0 +1,9 @@
+package term
+
+import "syscall"
+
+// The ioctl requests that get and set terminal attributes
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
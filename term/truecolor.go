@@ -0,0 +1,55 @@
+package term
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"strings"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// TrueColor is the value of Renderer.Colors for terminals that support
+// 24-bit colors
+const TrueColor = 1 << 24
+
+// specTrue prints a blank bar with the given 24-bit background color
+const specTrue = "\x1b[30;48;2;%d;%d;%dm%-*s\x1b[0m\n"
+
+// Background returns the escape sequence that sets the background to the
+// exact 24-bit value of c
+func Background(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("\x1b[48;2;%d;%d;%dm", n.R, n.G, n.B)
+}
+
+// Foreground returns the escape sequence that sets the foreground to the
+// exact 24-bit value of c
+func Foreground(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", n.R, n.G, n.B)
+}
+
+// Reset is the escape sequence that restores the default colors
+const Reset = "\x1b[0m"
+
+// supportsTrueColor returns true if the terminal advertises 24-bit colors
+// with the COLORTERM environment variable
+func supportsTrueColor() bool {
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return true
+	}
+
+	return false
+}
+
+// index returns the xterm256 index of info, mapping it to the palette if
+// it was reported without quantization
+func index(info wikimg.ColorInfo) int {
+	if info.Index >= 0 {
+		return info.Index
+	}
+
+	return color.Palette(wikimg.XTerm256).Index(color.NRGBA{info.R, info.G, info.B, 0xff})
+}
@@ -0,0 +1,449 @@
+package term
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+const (
+	// defaultKeep is how many entries a Viewer keeps by default
+	defaultKeep = 5000
+
+	// redrawInterval is how often a Viewer redraws, at most
+	redrawInterval = 50 * time.Millisecond
+
+	// swatchWidth is the width of the color swatch in each row
+	swatchWidth = 8
+
+	// Escape sequences that switch to and from the alternate screen,
+	// hiding the cursor while we're there
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	exitScreen  = "\x1b[?25h\x1b[?1049l"
+)
+
+// Entry is a color shown by a Viewer
+type Entry struct {
+	// Info is the color
+	Info wikimg.ColorInfo
+
+	// URL is the image the color came from
+	URL string
+
+	// Title is shown instead of the URL if it's set
+	Title string
+
+	// Page is opened in the browser instead of the URL if it's set, e.g.,
+	// the image's page on Commons
+	Page string
+}
+
+// Viewer is a full screen wall of color bars, one per entry, that scrolls
+// as entries arrive. These keys control it:
+//
+//	space       pause or resume scrolling
+//	up, k       select the previous entry, pausing
+//	down, j     select the next entry, pausing
+//	h, H        show only the next or previous hue (or grays, or all)
+//	o, enter    open the selected image in the browser
+//	q, ctrl-c   quit
+//
+// Keys are read as they're pressed on Unix. Elsewhere, Enter has to be
+// pressed after each one.
+type Viewer struct {
+	// Keep is how many entries are kept for scrolling back through, the
+	// oldest being dropped first
+	Keep int
+
+	// Open opens the selected page or image. NewViewer sets it to
+	// OpenBrowser.
+	Open func(u string) error
+
+	in, out  *os.File
+	renderer *Renderer
+}
+
+// NewViewer creates a Viewer that reads keys from in and draws on out,
+// which must be terminals
+func NewViewer(in, out *os.File) *Viewer {
+	return &Viewer{
+		Keep:     defaultKeep,
+		Open:     OpenBrowser,
+		in:       in,
+		out:      out,
+		renderer: NewRenderer(out, Always),
+	}
+}
+
+// Run shows entries as they arrive on the entries channel until q is
+// pressed. It keeps running after the channel is closed, so the last
+// entries can still be looked at.
+func (v *Viewer) Run(entries <-chan Entry) error {
+	if !isTerminal(v.in) || !isTerminal(v.out) {
+		return fmt.Errorf("term: the viewer needs a terminal")
+	}
+
+	if v.renderer.console != nil {
+		return fmt.Errorf("term: the viewer needs a terminal that understands escape sequences")
+	}
+
+	restore, err := makeRaw(v.in)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	fmt.Fprint(v.out, enterScreen)
+	defer fmt.Fprint(v.out, exitScreen)
+
+	// The reader is left blocked once we quit, which is fine as long as
+	// the process exits soon after
+	keys := make(chan string)
+	go readKeys(v.in, keys)
+
+	w := &wall{keep: v.Keep, colors: v.renderer.Colors}
+	tick := time.NewTicker(redrawInterval)
+	defer tick.Stop()
+
+	dirty := true
+	width, height := 0, 0
+	for {
+		select {
+		case e, ok := <-entries:
+			if !ok {
+				entries = nil
+				continue
+			}
+			w.add(e)
+			dirty = true
+
+		case k, ok := <-keys:
+			if !ok {
+				return nil
+			}
+
+			switch w.key(k) {
+			case quit:
+				return nil
+			case open:
+				e, ok := w.selection()
+				if ok {
+					u := e.URL
+					if len(e.Page) > 0 {
+						u = e.Page
+					}
+					w.message = ""
+					if err := v.Open(u); err != nil {
+						w.message = err.Error()
+					}
+				}
+			}
+			dirty = true
+
+		case <-tick.C:
+			wd, ht, ok := size(v.out)
+			if !ok {
+				wd, ht = defaultWidth, 24
+			}
+			if !dirty && wd == width && ht == height {
+				continue
+			}
+			width, height, dirty = wd, ht, false
+
+			err := w.draw(v.out, width, height)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readKeys sends each key read from r, closing keys when r ends. Arrow
+// keys are sent as "up" and "down" and everything else as the character
+// typed.
+func readKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for _, k := range parseKeys(buf[:n]) {
+			keys <- k
+		}
+	}
+}
+
+// parseKeys splits b into keys
+func parseKeys(b []byte) []string {
+	var keys []string
+
+	for len(b) > 0 {
+		switch {
+		case strings.HasPrefix(string(b), "\x1b[A"):
+			keys = append(keys, "up")
+			b = b[3:]
+		case strings.HasPrefix(string(b), "\x1b[B"):
+			keys = append(keys, "down")
+			b = b[3:]
+		default:
+			r, n := utf8.DecodeRune(b)
+			keys = append(keys, string(r))
+			b = b[n:]
+		}
+	}
+
+	return keys
+}
+
+// action is what the Viewer should do after a key is pressed
+type action int
+
+const (
+	none action = iota
+	quit
+	open
+)
+
+// wall is the state of a Viewer: its entries and what's shown
+type wall struct {
+	entries []Entry
+	keep    int
+
+	// colors is the number of colors the terminal supports
+	colors int
+
+	// paused stops the view following new entries
+	paused bool
+
+	// filter is the hues shown: 0 for all, 1 to HueBins for a single
+	// bin and HueBins+1 for grays
+	filter int
+
+	// selected is the index in entries of the selected entry, when
+	// paused
+	selected int
+
+	// top is the position in the filtered entries of the first row shown
+	top int
+
+	// message is shown in the status line, e.g., an error opening the
+	// browser
+	message string
+}
+
+// add adds e, dropping the oldest entry if there are too many
+func (w *wall) add(e Entry) {
+	w.entries = append(w.entries, e)
+
+	if w.keep > 0 && len(w.entries) > w.keep {
+		drop := len(w.entries) - w.keep
+		w.entries = w.entries[drop:]
+		w.selected = max(w.selected-drop, 0)
+	}
+}
+
+// shows returns true if e passes the hue filter
+func (w *wall) shows(e Entry) bool {
+	switch w.filter {
+	case 0:
+		return true
+	case wikimg.HueBins + 1:
+		return e.Info.HueBin() < 0
+	}
+
+	return e.Info.HueBin() == w.filter-1
+}
+
+// filtered returns the indexes of the entries that pass the hue filter
+func (w *wall) filtered() []int {
+	var shown []int
+	for i, e := range w.entries {
+		if w.shows(e) {
+			shown = append(shown, i)
+		}
+	}
+
+	return shown
+}
+
+// position returns the position in shown of the selected entry: the
+// newest one when following, otherwise the closest shown entry at or
+// before the selected one. It's -1 if nothing is shown.
+func (w *wall) position(shown []int) int {
+	if !w.paused {
+		return len(shown) - 1
+	}
+
+	pos := 0
+	for i, index := range shown {
+		if index <= w.selected {
+			pos = i
+		}
+	}
+
+	return min(pos, len(shown)-1)
+}
+
+// selection returns the selected entry, if anything is shown
+func (w *wall) selection() (Entry, bool) {
+	shown := w.filtered()
+	pos := w.position(shown)
+	if pos < 0 {
+		return Entry{}, false
+	}
+
+	return w.entries[shown[pos]], true
+}
+
+// key handles a key press
+func (w *wall) key(k string) action {
+	shown := w.filtered()
+	pos := w.position(shown)
+
+	switch k {
+	case "q", "\x03":
+		return quit
+
+	case "o", "\r", "\n":
+		return open
+
+	case " ":
+		w.paused = !w.paused
+		if w.paused && pos >= 0 {
+			w.selected = shown[pos]
+		}
+
+	case "up", "k", "down", "j":
+		if pos < 0 {
+			break
+		}
+
+		if k == "up" || k == "k" {
+			pos = max(pos-1, 0)
+		} else {
+			pos = min(pos+1, len(shown)-1)
+		}
+		w.paused = true
+		w.selected = shown[pos]
+
+	case "h":
+		w.filter = (w.filter + 1) % (wikimg.HueBins + 2)
+
+	case "H":
+		w.filter = (w.filter + wikimg.HueBins + 1) % (wikimg.HueBins + 2)
+	}
+
+	return none
+}
+
+// filterName describes the hue filter
+func (w *wall) filterName() string {
+	switch w.filter {
+	case 0:
+		return "all"
+	case wikimg.HueBins + 1:
+		return "gray"
+	}
+
+	return wikimg.HueNames[w.filter-1]
+}
+
+// draw draws the wall on a terminal of width by height characters: a row
+// for each entry that fits, with a status line at the bottom
+func (w *wall) draw(out io.Writer, width, height int) error {
+	rows := max(height-1, 1)
+	shown := w.filtered()
+	pos := w.position(shown)
+
+	// Keep the selection on screen, following the newest entry unless
+	// we're paused
+	if !w.paused {
+		w.top = len(shown) - rows
+	}
+	if pos < w.top {
+		w.top = pos
+	}
+	if pos >= w.top+rows {
+		w.top = pos - rows + 1
+	}
+	w.top = max(min(w.top, len(shown)-rows), 0)
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+
+	for row := 0; row < rows; row++ {
+		b.WriteString("\x1b[2K")
+
+		i := w.top + row
+		if i < len(shown) {
+			e := w.entries[shown[i]]
+
+			marker := " "
+			if w.paused && i == pos {
+				marker = ">"
+			}
+
+			label := e.Title
+			if len(label) < 1 {
+				label = e.URL
+			}
+
+			fmt.Fprintf(&b, "%s%s%*s%s %s %s", marker, w.background(e.Info), swatchWidth, "", Reset, e.Info.Hex,
+				truncate(label, width-swatchWidth-len(e.Info.Hex)-3))
+		}
+
+		b.WriteString("\r\n")
+	}
+
+	// The status line
+	state := "live"
+	if w.paused {
+		state = "paused"
+	}
+	status := fmt.Sprintf(" %s | hue: %s | %d of %d | space pause, arrows select, h hue, o open, q quit",
+		state, w.filterName(), len(shown), len(w.entries))
+	if len(w.message) > 0 {
+		status = " " + w.message
+	}
+	fmt.Fprintf(&b, "\x1b[2K\x1b[7m%s%s\x1b[J", truncate(status, width), Reset)
+
+	_, err := io.WriteString(out, b.String())
+
+	return err
+}
+
+// background returns the escape sequence that sets the background to the
+// color of info, as well as the terminal supports
+func (w *wall) background(info wikimg.ColorInfo) string {
+	switch w.colors {
+	case TrueColor:
+		return Background(color.RGBA{info.R, info.G, info.B, 0xff})
+	case 16:
+		return fmt.Sprintf("\x1b[%dm", basic(index(info)))
+	}
+
+	return fmt.Sprintf("\x1b[48;5;%dm", index(info))
+}
+
+// truncate shortens s to at most n characters
+func truncate(s string, n int) string {
+	if n < 1 {
+		return ""
+	}
+
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,138 @@
+package term
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// entry returns an entry with a color of hue h, or gray if h is negative
+func entry(i int, h float64) Entry {
+	info := wikimg.ColorInfo{H: h, Hex: "#000000"}
+	if h < 0 {
+		info.Gray = true
+	}
+
+	return Entry{Info: info, URL: fmt.Sprintf("https://example.com/%d.png", i)}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys := parseKeys([]byte("q\x1b[A \x1b[Bé\r"))
+	expected := []string{"q", "up", " ", "down", "é", "\r"}
+
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %q but got %q", expected, keys)
+	}
+}
+
+func TestWallFollow(t *testing.T) {
+	w := &wall{}
+	for i := 0; i < 10; i++ {
+		w.add(entry(i, 0))
+	}
+
+	// Live, the newest entries are shown at the bottom
+	buf := &bytes.Buffer{}
+	w.draw(buf, 80, 4)
+	out := buf.String()
+	for _, i := range []int{7, 8, 9} {
+		if !strings.Contains(out, fmt.Sprintf("/%d.png", i)) {
+			t.Errorf("expected entry %d in %q", i, out)
+		}
+	}
+	if strings.Contains(out, "/6.png") || !strings.Contains(out, "live | hue: all | 10 of 10") {
+		t.Errorf("expected only the last 3 entries and a live status in %q", out)
+	}
+
+	e, _ := w.selection()
+	if e.URL != "https://example.com/9.png" {
+		t.Errorf("expected the newest entry to be selected but got %s", e.URL)
+	}
+}
+
+func TestWallPause(t *testing.T) {
+	w := &wall{}
+	for i := 0; i < 5; i++ {
+		w.add(entry(i, 0))
+	}
+
+	// Moving the selection pauses
+	w.key("up")
+	w.key("k")
+	if !w.paused {
+		t.Fatal("expected to be paused")
+	}
+
+	// New entries don't move the selection
+	w.add(entry(5, 0))
+	e, _ := w.selection()
+	if e.URL != "https://example.com/2.png" {
+		t.Errorf("expected 2 to be selected but got %s", e.URL)
+	}
+
+	buf := &bytes.Buffer{}
+	w.draw(buf, 80, 3)
+	if out := buf.String(); !strings.Contains(out, ">") || !strings.Contains(out, "/2.png") || !strings.Contains(out, "paused") {
+		t.Errorf("expected the selection on screen in %q", out)
+	}
+
+	if w.key("o") != open || w.key("q") != quit {
+		t.Error("expected o to open and q to quit")
+	}
+
+	// Resuming follows the newest entry again
+	w.key(" ")
+	e, _ = w.selection()
+	if w.paused || e.URL != "https://example.com/5.png" {
+		t.Errorf("expected to follow 5 but got %s", e.URL)
+	}
+}
+
+func TestWallFilter(t *testing.T) {
+	w := &wall{}
+	w.add(entry(0, 0))
+	w.add(entry(1, 240))
+	w.add(entry(2, -1))
+	w.add(entry(3, 10))
+
+	tests := []struct {
+		key      string
+		name     string
+		expected []int
+	}{
+		{"h", "red", []int{0, 3}},
+		{"H", "all", []int{0, 1, 2, 3}},
+		{"H", "gray", []int{2}},
+		{"H", "rose", nil},
+	}
+
+	for _, test := range tests {
+		w.key(test.key)
+		if w.filterName() != test.name {
+			t.Errorf("expected the %s filter but got %s", test.name, w.filterName())
+		}
+		if shown := w.filtered(); !reflect.DeepEqual(shown, test.expected) {
+			t.Errorf("expected %v for %s but got %v", test.expected, test.name, shown)
+		}
+	}
+
+	// Nothing to select
+	if _, ok := w.selection(); ok {
+		t.Error("expected no selection")
+	}
+}
+
+func TestWallKeep(t *testing.T) {
+	w := &wall{keep: 3}
+	for i := 0; i < 5; i++ {
+		w.add(entry(i, 0))
+	}
+
+	if len(w.entries) != 3 || w.entries[0].URL != "https://example.com/2.png" {
+		t.Errorf("expected the 3 newest entries but got %v", w.entries)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package term
+
+import "os"
+
+// enableVT is a no-op outside of Windows, where terminals interpret ANSI
+// escape sequences by default
+func enableVT(f *os.File) (bool, console) {
+	return true, nil
+}
@@ -0,0 +1,103 @@
+package term
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// enableVirtualTerminalProcessing is the console mode flag that tells
+// Windows to interpret ANSI escape sequences
+const enableVirtualTerminalProcessing = 0x0004
+
+var (
+	kernel32                       = syscall.NewLazyDLL("kernel32.dll")
+	procGetConsoleMode             = kernel32.NewProc("GetConsoleMode")
+	procSetConsoleMode             = kernel32.NewProc("SetConsoleMode")
+	procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
+	procSetConsoleTextAttribute    = kernel32.NewProc("SetConsoleTextAttribute")
+)
+
+// enableVT turns on virtual terminal processing for f, returning whether
+// the console now understands ANSI escape sequences. Older consoles (before
+// Windows 10) don't support it, so a console that sets colors with text
+// attributes is returned instead.
+func enableVT(f *os.File) (bool, console) {
+	h := syscall.Handle(f.Fd())
+
+	var mode uint32
+	ok, _, _ := procGetConsoleMode.Call(uintptr(h), uintptr(unsafe.Pointer(&mode)))
+	if ok == 0 {
+		// Not a console (e.g., output is redirected to a file), so
+		// there's nothing to enable
+		return true, nil
+	}
+
+	if mode&enableVirtualTerminalProcessing != 0 {
+		return true, nil
+	}
+
+	ok, _, _ = procSetConsoleMode.Call(uintptr(h), uintptr(mode|enableVirtualTerminalProcessing))
+	if ok != 0 {
+		return true, nil
+	}
+
+	var info consoleScreenBufferInfo
+	ok, _, _ = procGetConsoleScreenBufferInfo.Call(uintptr(h), uintptr(unsafe.Pointer(&info)))
+	if ok == 0 {
+		return false, nil
+	}
+
+	return false, &legacyConsole{h: h, original: info.attributes}
+}
+
+// consoleScreenBufferInfo is CONSOLE_SCREEN_BUFFER_INFO, of which we only
+// need the attributes
+type consoleScreenBufferInfo struct {
+	size              [2]int16
+	cursorPosition    [2]int16
+	attributes        uint16
+	window            [4]int16
+	maximumWindowSize [2]int16
+}
+
+// legacyConsole sets colors with console text attributes
+type legacyConsole struct {
+	h        syscall.Handle
+	original uint16
+}
+
+// setColors sets the text attributes to basic colors fg and bg
+func (c *legacyConsole) setColors(fg, bg int) error {
+	attr := c.original
+	if fg >= 0 {
+		attr = attr&^0x0f | attribute(fg)
+	}
+	if bg >= 0 {
+		attr = attr&^0xf0 | attribute(bg)<<4
+	}
+
+	return c.set(attr)
+}
+
+// reset restores the text attributes the console had when it was found
+func (c *legacyConsole) reset() error {
+	return c.set(c.original)
+}
+
+// set sets the console's text attributes to attr
+func (c *legacyConsole) set(attr uint16) error {
+	ok, _, err := procSetConsoleTextAttribute.Call(uintptr(c.h), uintptr(attr))
+	if ok == 0 {
+		return err
+	}
+
+	return nil
+}
+
+// attribute maps a basic ANSI color to a console foreground attribute. Both
+// use one bit each for red, green, blue and brightness, but ANSI has red
+// first and the console has blue first.
+func attribute(i int) uint16 {
+	return uint16(i&8 | i&4>>2 | i&2 | i&1<<2)
+}
@@ -0,0 +1,164 @@
+// Package tune chooses how many workers to run, either from presets or by
+// automatically adjusting to observed download latency and error rate, so
+// users don't have to guess values like 25 or 50.
+package tune
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Workers returns the number of workers for a preset: "low", "medium" or
+// "high". Presets scale with GOMAXPROCS. A plain number is also accepted.
+// For "auto" use a Tuner instead.
+func Workers(preset string) (int, error) {
+	procs := runtime.GOMAXPROCS(0)
+
+	switch preset {
+	case "low":
+		return 2 * procs, nil
+	case "medium":
+		return 8 * procs, nil
+	case "high":
+		return 32 * procs, nil
+	}
+
+	n, err := strconv.Atoi(preset)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("tune: invalid preset %q (must be low, medium, high, auto or a number)", preset)
+	}
+
+	return n, nil
+}
+
+const (
+	// maxErrorRate is the fraction of failed jobs in a window above which
+	// the Tuner backs off
+	maxErrorRate = 0.1
+
+	// maxSlowdown is how much slower than the best window a window can be
+	// before the Tuner backs off
+	maxSlowdown = 1.5
+
+	// backoff is the factor the number of workers is multiplied by when
+	// backing off
+	backoff = 0.75
+)
+
+// Tuner adjusts the number of active workers while they run. It starts
+// between min and max and, after every window of jobs, adds a worker if
+// things are going well, or backs off if the error rate rises or latency
+// degrades compared to the best it has seen (i.e., the upstream server or
+// our network is saturated).
+//
+// Start max goroutines and have each call Acquire() before a job and
+// Release() with the result after it. Only Workers() of them will run at
+// once.
+type Tuner struct {
+	min, max int
+
+	workers int
+	active  int
+
+	// Observations in the current window
+	jobs    int
+	errs    int
+	latency time.Duration
+
+	// best is the lowest mean latency of any window
+	best time.Duration
+
+	mutex sync.Mutex
+	cond  *sync.Cond
+}
+
+// NewTuner creates a Tuner that keeps the number of workers between min and
+// max, starting with the "medium" preset
+func NewTuner(min, max int) *Tuner {
+	start, _ := Workers("medium")
+
+	t := &Tuner{
+		min:     min,
+		max:     max,
+		workers: clamp(start, min, max),
+	}
+	t.cond = sync.NewCond(&t.mutex)
+
+	return t
+}
+
+// Workers returns the current number of workers allowed to run at once
+func (t *Tuner) Workers() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.workers
+}
+
+// Acquire blocks until the caller can start a job
+func (t *Tuner) Acquire() {
+	t.mutex.Lock()
+
+	for t.active >= t.workers {
+		t.cond.Wait()
+	}
+	t.active++
+
+	t.mutex.Unlock()
+}
+
+// Release records the latency and error (possibly nil) of a finished job and
+// lets another one start
+func (t *Tuner) Release(latency time.Duration, err error) {
+	t.mutex.Lock()
+
+	t.active--
+	t.jobs++
+	t.latency += latency
+	if err != nil {
+		t.errs++
+	}
+
+	// Adjust once we've seen a window's worth of jobs
+	if t.jobs >= t.workers {
+		t.adjust()
+	}
+
+	t.mutex.Unlock()
+	t.cond.Broadcast()
+}
+
+// adjust changes the number of workers based on the current window and
+// starts a new one. The mutex must be held.
+func (t *Tuner) adjust() {
+	mean := t.latency / time.Duration(t.jobs)
+	errRate := float64(t.errs) / float64(t.jobs)
+
+	if t.best == 0 || mean < t.best {
+		t.best = mean
+	}
+
+	if errRate > maxErrorRate || float64(mean) > float64(t.best)*maxSlowdown {
+		t.workers = clamp(int(float64(t.workers)*backoff), t.min, t.max)
+	} else {
+		t.workers = clamp(t.workers+1, t.min, t.max)
+	}
+
+	t.jobs, t.errs, t.latency = 0, 0, 0
+}
+
+// clamp returns n limited to be between min and max
+func clamp(n, min, max int) int {
+	if n < min {
+		return min
+	}
+
+	if n > max {
+		return max
+	}
+
+	return n
+}
@@ -0,0 +1,57 @@
+package tune
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWorkers(t *testing.T) {
+	low, _ := Workers("low")
+	high, _ := Workers("high")
+	if low < 1 || high <= low {
+		t.Errorf("unexpected presets low=%d high=%d", low, high)
+	}
+
+	if n, err := Workers("12"); n != 12 || err != nil {
+		t.Errorf("expected 12 but got %d, %v", n, err)
+	}
+
+	for _, preset := range []string{"", "auto", "0", "huge"} {
+		if _, err := Workers(preset); err == nil {
+			t.Errorf("%q: expected error", preset)
+		}
+	}
+}
+
+func TestTuner(t *testing.T) {
+	tuner := NewTuner(2, 10)
+	tuner.workers = 4
+
+	// A window of fast successful jobs adds a worker
+	for i := 0; i < 4; i++ {
+		tuner.Acquire()
+		tuner.Release(time.Millisecond, nil)
+	}
+	if n := tuner.Workers(); n != 5 {
+		t.Fatalf("expected 5 workers but got %d", n)
+	}
+
+	// A window of errors backs off
+	for i := 0; i < 5; i++ {
+		tuner.Acquire()
+		tuner.Release(time.Millisecond, errors.New("failed"))
+	}
+	if n := tuner.Workers(); n != 3 {
+		t.Fatalf("expected 3 workers but got %d", n)
+	}
+
+	// A slow window backs off, but not below min
+	for i := 0; i < 3; i++ {
+		tuner.Acquire()
+		tuner.Release(time.Second, nil)
+	}
+	if n := tuner.Workers(); n != 2 {
+		t.Fatalf("expected 2 workers but got %d", n)
+	}
+}
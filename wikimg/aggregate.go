@@ -0,0 +1,195 @@
+package wikimg
+
+import (
+	"image/color"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAggregateWindow is the default for Aggregator.Window
+	DefaultAggregateWindow = 24 * time.Hour
+
+	// DefaultAggregateResolution is the default for Aggregator.Resolution
+	DefaultAggregateResolution = 10 * time.Minute
+)
+
+// ColorCount is how much of the aggregated images is a palette color
+type ColorCount struct {
+	// Index is the color's index in the palette
+	Index int `json:"index"`
+
+	// Hex is the color, e.g., "#ff0000"
+	Hex string `json:"hex"`
+
+	// Pixels is the number of sampled pixels mapped to the color
+	Pixels int `json:"pixels"`
+
+	// Share is the fraction of all sampled pixels mapped to the color,
+	// between 0 and 1
+	Share float64 `json:"share"`
+}
+
+// Info describes the color
+func (cc ColorCount) Info() ColorInfo {
+	c, _ := ParseHex(cc.Hex)
+
+	return newColorInfo(c, cc.Index)
+}
+
+// aggregateBucket is the histogram of the images added in one Resolution
+type aggregateBucket struct {
+	start time.Time
+	hist  map[int]int
+}
+
+// Aggregator folds the histograms of many images (see Histogram and
+// ColorOptions.MeasureHistogram) into a rolling histogram, so the colors
+// of everything analyzed recently can be summed up, e.g., "what color is
+// Commons today". Set its fields before calling Add. It's safe to use from
+// many goroutines at once.
+type Aggregator struct {
+	// Window is how long histograms are kept. Zero means
+	// DefaultAggregateWindow.
+	Window time.Duration
+
+	// Resolution is how finely the window is divided. Top can only look
+	// back a whole number of Resolutions. Zero means
+	// DefaultAggregateResolution.
+	Resolution time.Duration
+
+	// Palette is the palette the histograms' indexes are in. If nil,
+	// XTerm256 is used.
+	Palette color.Palette
+
+	// buckets run from oldest to newest
+	buckets []aggregateBucket
+	mutex   sync.Mutex
+
+	// now returns the current time, so tests can control it
+	now func() time.Time
+}
+
+// resolution returns the Resolution, or its default
+func (ag *Aggregator) resolution() time.Duration {
+	if ag.Resolution <= 0 {
+		return DefaultAggregateResolution
+	}
+
+	return ag.Resolution
+}
+
+// window returns the Window, or its default
+func (ag *Aggregator) window() time.Duration {
+	if ag.Window <= 0 {
+		return DefaultAggregateWindow
+	}
+
+	return ag.Window
+}
+
+// time returns the current time
+func (ag *Aggregator) time() time.Time {
+	if ag.now != nil {
+		return ag.now()
+	}
+
+	return time.Now()
+}
+
+// Add folds hist, the histogram of an image analyzed now, into the
+// aggregate
+func (ag *Aggregator) Add(hist map[int]int) {
+	if len(hist) < 1 {
+		return
+	}
+
+	now := ag.time()
+	start := now.Truncate(ag.resolution())
+
+	ag.mutex.Lock()
+	defer ag.mutex.Unlock()
+
+	ag.expire(now)
+
+	n := len(ag.buckets)
+	if n < 1 || ag.buckets[n-1].start.Before(start) {
+		ag.buckets = append(ag.buckets, aggregateBucket{start: start, hist: map[int]int{}})
+		n++
+	}
+
+	b := ag.buckets[n-1].hist
+	for i, count := range hist {
+		b[i] += count
+	}
+}
+
+// expire drops the buckets that have left the window. The caller must hold
+// the lock.
+func (ag *Aggregator) expire(now time.Time) {
+	oldest := now.Add(-ag.window()).Truncate(ag.resolution())
+
+	i := 0
+	for i < len(ag.buckets) && ag.buckets[i].start.Before(oldest) {
+		i++
+	}
+	ag.buckets = ag.buckets[i:]
+}
+
+// Top returns the k colors with the most pixels in the images added within
+// the last over, most first. over is rounded up to a whole Resolution and
+// is at most Window. A k less than 1 returns every color.
+func (ag *Aggregator) Top(k int, over time.Duration) []ColorCount {
+	now := ag.time()
+	since := now.Add(-min(over, ag.window())).Truncate(ag.resolution())
+
+	total := 0
+	sum := map[int]int{}
+
+	ag.mutex.Lock()
+	ag.expire(now)
+	for _, b := range ag.buckets {
+		if b.start.Before(since) {
+			continue
+		}
+
+		for i, count := range b.hist {
+			sum[i] += count
+			total += count
+		}
+	}
+	ag.mutex.Unlock()
+
+	pal := ag.Palette
+	if pal == nil {
+		pal = XTerm256
+	}
+
+	top := make([]ColorCount, 0, len(sum))
+	for i, count := range sum {
+		if i < 0 || i >= len(pal) {
+			continue
+		}
+
+		top = append(top, ColorCount{
+			Index:  i,
+			Hex:    Hex(pal[i]),
+			Pixels: count,
+			Share:  float64(count) / float64(total),
+		})
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Pixels != top[j].Pixels {
+			return top[i].Pixels > top[j].Pixels
+		}
+		return top[i].Index < top[j].Index
+	})
+
+	if k > 0 && len(top) > k {
+		top = top[:k]
+	}
+
+	return top
+}
@@ -0,0 +1,73 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+	"time"
+)
+
+func TestAggregator(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ag := &Aggregator{Window: 2 * time.Hour, Resolution: time.Minute}
+	ag.now = func() time.Time { return now }
+
+	// Red then blue, an hour apart
+	ag.Add(map[int]int{9: 30, 12: 10})
+	now = now.Add(time.Hour)
+	ag.Add(map[int]int{12: 40, 15: 20})
+	ag.Add(nil)
+
+	top := ag.Top(2, 24*time.Hour)
+	if len(top) != 2 || top[0].Index != 12 || top[0].Pixels != 50 || top[1].Index != 9 {
+		t.Fatalf("expected blue then red but got %+v", top)
+	}
+	if top[0].Hex != "#0000ff" || top[0].Share != 0.5 {
+		t.Errorf("expected half of the pixels to be #0000ff but got %+v", top[0])
+	}
+	if info := top[0].Info(); info.B != 0xff || info.Index != 12 {
+		t.Errorf("unexpected info %+v", info)
+	}
+
+	// The last half hour only has the second image
+	top = ag.Top(0, 30*time.Minute)
+	if len(top) != 2 || top[0].Index != 12 || top[0].Pixels != 40 || top[1].Index != 15 {
+		t.Errorf("expected the second image but got %+v", top)
+	}
+
+	// Once the first image leaves the window, it's forgotten
+	now = now.Add(90 * time.Minute)
+	top = ag.Top(0, 24*time.Hour)
+	if len(top) != 2 || top[0].Pixels != 40 {
+		t.Errorf("expected only the second image but got %+v", top)
+	}
+	if len(ag.buckets) != 1 {
+		t.Errorf("expected 1 bucket but got %d", len(ag.buckets))
+	}
+
+	now = now.Add(time.Hour)
+	if top := ag.Top(0, 24*time.Hour); len(top) != 0 {
+		t.Errorf("expected nothing but got %+v", top)
+	}
+}
+
+func TestMeasureHistogram(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{0xff, 0, 0, 0xff}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 4, 1), image.NewUniform(color.RGBA{0, 0, 0xff, 0xff}), image.Point{}, draw.Src)
+
+	info, err := ColorOptions{MeasureHistogram: true}.firstColor(img, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(info.Histogram) != 2 || info.Histogram[9] != 12 || info.Histogram[12] != 4 {
+		t.Errorf("expected 12 red and 4 blue pixels but got %v", info.Histogram)
+	}
+
+	info, _ = ColorOptions{}.firstColor(img, nil)
+	if info.Histogram != nil {
+		t.Errorf("expected no histogram by default but got %v", info.Histogram)
+	}
+}
@@ -0,0 +1,86 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+)
+
+// background returns the color that pixels are composited over
+func (o ColorOptions) background() color.Color {
+	if o.Background == nil {
+		return color.White
+	}
+
+	return o.Background
+}
+
+// alpha handles c according to o.Alpha. It returns false if the pixel
+// should be skipped.
+func (o ColorOptions) alpha(c color.Color) (color.Color, bool) {
+	if o.Alpha == KeepAlpha {
+		return c, true
+	}
+
+	r, g, b, a := c.RGBA()
+	if a == 0xffff {
+		return c, true
+	}
+
+	if o.Alpha == SkipTransparent {
+		return c, a > 0
+	}
+
+	// RGBA values are premultiplied, so the background shows through in
+	// proportion to the transparency
+	br, bg, bb, _ := o.background().RGBA()
+	t := 0xffff - a
+
+	return color.RGBA64{
+		R: uint16(r + br*t/0xffff),
+		G: uint16(g + bg*t/0xffff),
+		B: uint16(b + bb*t/0xffff),
+		A: 0xffff,
+	}, true
+}
+
+// Transparency returns the fraction of the image's sampled pixels that are
+// fully transparent, between 0 and 1
+func (p *Puller) Transparency(imgURL string) (float64, error) {
+	a, err := p.fetch(p.context(), imgURL, false, p.Cancel)
+	if err != nil {
+		return 0, err
+	}
+	defer a.release()
+
+	return p.Options.transparency(a.img, p.Cancel)
+}
+
+// ImageTransparency returns the fraction of the sampled pixels of img that
+// are fully transparent (see Transparency)
+func ImageTransparency(img image.Image, opts ColorOptions) float64 {
+	return measureDecoded(img, opts.transparency)
+}
+
+// transparency computes the fraction of fully transparent sampled pixels
+// in img
+func (o ColorOptions) transparency(img image.Image, cancel <-chan struct{}) (float64, error) {
+	// Count every pixel, including those that would be skipped
+	o.Alpha = KeepAlpha
+
+	transparent := 0
+	count := 0
+
+	_, err := o.scan(img, cancel, func(c color.Color) bool {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			transparent++
+		}
+		count++
+
+		return false
+	})
+	if err != nil || count < 1 {
+		return 0, err
+	}
+
+	return float64(transparent) / float64(count), nil
+}
@@ -0,0 +1,58 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// firstPixel returns the hex value of the first pixel scanned in img,
+// mapped to the palette
+func firstPixel(img image.Image, opts ColorOptions) (string, error) {
+	hex := ""
+	_, err := opts.scan(img, nil, func(c color.Color) bool {
+		q, _ := opts.quantize(c)
+		hex = Hex(q)
+		return true
+	})
+
+	return hex, err
+}
+
+func TestAlpha(t *testing.T) {
+	// Left half transparent, right half red
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 1))
+	img.Set(2, 0, color.NRGBA{255, 0, 0, 255})
+	img.Set(3, 0, color.NRGBA{255, 0, 0, 255})
+
+	hex, err := firstPixel(img, ColorOptions{})
+	if err != nil || hex != "#000000" {
+		t.Errorf("expected transparent pixels to count as black but got %v, %v", hex, err)
+	}
+
+	hex, err = firstPixel(img, ColorOptions{Alpha: SkipTransparent})
+	if err != nil || hex != "#ff0000" {
+		t.Errorf("expected transparent pixels to be skipped but got %v, %v", hex, err)
+	}
+
+	opts := ColorOptions{Alpha: Composite, Background: color.NRGBA{0, 0, 255, 255}}
+	hex, err = firstPixel(img, opts)
+	if err != nil || hex != "#0000ff" {
+		t.Errorf("expected transparent pixels to be composited but got %v, %v", hex, err)
+	}
+
+	if tr := ImageTransparency(img, opts); !near(tr, 0.5) {
+		t.Errorf("expected transparency 0.5 but got %v", tr)
+	}
+}
+
+func TestComposite(t *testing.T) {
+	o := ColorOptions{Alpha: Composite}
+
+	// Half transparent black over white is middle gray
+	c, ok := o.alpha(color.NRGBA{0, 0, 0, 128})
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if !ok || n.A != 255 || n.R < 126 || n.R > 128 {
+		t.Errorf("unexpected composite %v", n)
+	}
+}
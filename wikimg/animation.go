@@ -0,0 +1,220 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"time"
+)
+
+const (
+	// minFrameDelay is the shortest delay browsers honor between GIF
+	// frames. Shorter delays (including none) are shown for defaultDelay
+	// instead.
+	minFrameDelay = 20 * time.Millisecond
+	defaultDelay  = 100 * time.Millisecond
+)
+
+// animation is a decoded image, with every frame if it's an animated GIF
+type animation struct {
+	// img is the image, or the first frame of an animated GIF as it is
+	// returned by image.Decode
+	img image.Image
+
+	// format is the name of the image's format
+	format string
+
+	// gif is the fully decoded GIF, or nil if only img was decoded
+	gif *gif.GIF
+
+	// exif is the image's EXIF metadata, if it was read
+	exif *EXIF
+
+	// memory is where reserved bytes are released to once the image is
+	// no longer needed
+	memory   *memLimiter
+	reserved int64
+}
+
+// release frees the memory reserved for a. It's safe to call more than
+// once.
+func (a *animation) release() {
+	if a.memory != nil {
+		a.memory.release(a.reserved)
+		a.memory = nil
+	}
+}
+
+// FrameColor is the first color of one frame of an animated image
+type FrameColor struct {
+	ColorInfo
+
+	// Delay is how long the frame is shown
+	Delay time.Duration `json:"delay"`
+}
+
+// FrameColors is like FirstColor, but returns the first color of every
+// frame of an animated GIF. Frames are analyzed as they are shown, drawn
+// over the frames before them. Other images have a single frame.
+func (p *Puller) FrameColors(imgURL string) ([]FrameColor, error) {
+	a, err := p.fetch(p.context(), imgURL, true, p.Cancel)
+	if err != nil {
+		return nil, err
+	}
+	defer a.release()
+
+	var colors []FrameColor
+	err = a.each(func(frame image.Image, delay time.Duration) error {
+		info, err := p.Options.firstColor(frame, p.Cancel)
+		if err != nil {
+			return err
+		}
+
+		info.setImage(frame, a.format)
+		colors = append(colors, FrameColor{ColorInfo: info, Delay: delay})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return colors, nil
+}
+
+// DominantColor returns the palette color covering the most sampled pixels
+// of the image, preferring colors that aren't gray. The frames of an
+// animated GIF are weighted by how long they are shown, so a color flashing
+// by for a moment counts less than the background of a long pause. If
+// every color is gray, the most common gray is returned with Gray set. As
+// with Histogram, colors are mapped to the palette even if
+// p.Options.Unquantized is set.
+func (p *Puller) DominantColor(imgURL string) (info ColorInfo, err error) {
+	a, err := p.fetch(p.context(), imgURL, true, p.Cancel)
+	if err != nil {
+		return
+	}
+	defer a.release()
+
+	info, err = p.Options.dominant(a, p.Cancel)
+	if err != nil {
+		return
+	}
+
+	info.setImage(a.img, a.format)
+	info.RequestID = p.RequestID
+	info.EXIF = a.exif
+	if uploaded, ok := p.uploads.Load(imgURL); ok {
+		info.Uploaded = uploaded.(time.Time)
+	}
+
+	return
+}
+
+// dominant finds the time weighted dominant color of a
+func (o ColorOptions) dominant(a *animation, cancel <-chan struct{}) (info ColorInfo, err error) {
+	o.Unquantized = false
+	pal := o.palette()
+
+	// Each frame's histogram is normalized so large and small frames count
+	// the same, then weighted by its delay
+	weights := map[int]float64{}
+	err = a.each(func(frame image.Image, delay time.Duration) error {
+		hist, err := o.histogram(frame, cancel)
+		if err != nil {
+			return err
+		}
+
+		total := 0
+		for _, n := range hist {
+			total += n
+		}
+		for i, n := range hist {
+			weights[i] += float64(n) / float64(total) * delay.Seconds()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	best, bestGray := -1, -1
+	for i, w := range weights {
+		if o.isGray(newColorInfo(pal[i], i)) {
+			if bestGray < 0 || w > weights[bestGray] || (w == weights[bestGray] && i < bestGray) {
+				bestGray = i
+			}
+			continue
+		}
+
+		if best < 0 || w > weights[best] || (w == weights[best] && i < best) {
+			best = i
+		}
+	}
+
+	switch {
+	case best >= 0:
+		info = newColorInfo(pal[best], best)
+	case bestGray >= 0:
+		info = newColorInfo(pal[bestGray], bestGray)
+		info.Gray = true
+	default:
+		// Every pixel was skipped
+		info.Gray = true
+	}
+	info.Source = PaletteColor.String()
+
+	return
+}
+
+// each calls fn with every frame of a, as it would be shown, and how long
+// it is shown. The frame is only valid until fn returns. An image that isn't
+// animated is a single frame, shown for defaultDelay.
+func (a *animation) each(fn func(frame image.Image, delay time.Duration) error) error {
+	if a.gif == nil || len(a.gif.Image) < 2 {
+		return fn(a.img, defaultDelay)
+	}
+
+	g := a.gif
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		bounds = g.Image[0].Bounds()
+	}
+	canvas := image.NewNRGBA(bounds)
+
+	for i, frame := range g.Image {
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+
+		// Keep what's under the frame if it is to be restored afterwards
+		var previous *image.NRGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewNRGBA(frame.Bounds())
+			draw.Draw(previous, previous.Bounds(), canvas, frame.Bounds().Min, draw.Src)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		delay := defaultDelay
+		if i < len(g.Delay) && time.Duration(g.Delay[i])*10*time.Millisecond >= minFrameDelay {
+			delay = time.Duration(g.Delay[i]) * 10 * time.Millisecond
+		}
+
+		if err := fn(canvas, delay); err != nil {
+			return err
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.NewUniform(color.Transparent), image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			draw.Draw(canvas, frame.Bounds(), previous, frame.Bounds().Min, draw.Src)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAnimation(t *testing.T) {
+	pal := color.Palette{color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}}
+
+	// A red frame shown briefly, then a blue square over a quarter of it
+	// shown for longer
+	red := image.NewPaletted(image.Rect(0, 0, 4, 4), pal)
+	blue := square(pal, 2, 1)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gif.EncodeAll(w, &gif.GIF{
+			Image: []*image.Paletted{red, blue},
+			Delay: []int{10, 100},
+		})
+	}))
+	defer ts.Close()
+
+	p := NewPuller(1)
+
+	frames, err := p.FrameColors(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 frames but got %d", len(frames))
+	}
+	if frames[0].Hex != "#ff0000" || frames[0].Delay != 100*time.Millisecond {
+		t.Errorf("unexpected first frame %s %v", frames[0].Hex, frames[0].Delay)
+	}
+	if frames[1].Hex != "#0000ff" || frames[1].Delay != time.Second || frames[1].Width != 4 {
+		t.Errorf("unexpected second frame %s %v %d", frames[1].Hex, frames[1].Delay, frames[1].Width)
+	}
+
+	// Red is still most of the second frame
+	info, err := p.DominantColor(ts.URL)
+	if err != nil || info.Hex != "#ff0000" {
+		t.Errorf("expected #ff0000 but got %s, %v", info.Hex, err)
+	}
+
+	// A blue second frame covering everything is shown ten times as long
+	// as the red one
+	blue = square(pal, 4, 1)
+	info, err = p.DominantColor(ts.URL)
+	if err != nil || info.Hex != "#0000ff" {
+		t.Errorf("expected #0000ff but got %s, %v", info.Hex, err)
+	}
+}
+
+// square returns a size x size image filled with the palette color at index
+func square(pal color.Palette, size int, index uint8) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, size, size), pal)
+	for i := range img.Pix {
+		img.Pix[i] = index
+	}
+
+	return img
+}
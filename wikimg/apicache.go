@@ -0,0 +1,105 @@
+package wikimg
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/brnstz/routine/lru"
+)
+
+const (
+	// apiFresh is how long an API response is reused without asking the
+	// API again, when a Puller asks for the same page twice
+	apiFresh = 10 * time.Second
+
+	// apiCacheMax is the most API responses we keep for revalidation
+	apiCacheMax = 128
+)
+
+// apiPage is a cached API response
+type apiPage struct {
+	body     []byte
+	etag     string
+	modified string
+	fetched  time.Time
+}
+
+// pageCache returns the Puller's cache of recent API responses by request
+// URL, which includes all of the request parameters, creating it on first
+// use. Each Puller has its own, so Pullers with different Clients or
+// Operators never see each other's responses.
+func (p *Puller) pageCache() *lru.Cache[string, apiPage] {
+	p.pagesOnce.Do(func() {
+		p.pages = lru.New[string, apiPage](apiCacheMax, 0)
+	})
+
+	return p.pages
+}
+
+// getPage returns the body of the API response for u. A response fetched
+// within apiFresh is reused as is. Older responses are revalidated with
+// If-None-Match and If-Modified-Since, so the API only sends the full page
+// again if it changed.
+func (p *Puller) getPage(ctx context.Context, u string) ([]byte, error) {
+	pages := p.pageCache()
+	page, cached := pages.Get(u)
+	if cached && time.Since(page.fetched) < apiFresh {
+		return page.body, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Link request to puller so it can be canceled
+	req.Cancel = p.Cancel
+
+	if cached {
+		if len(page.etag) > 0 {
+			req.Header.Set("If-None-Match", page.etag)
+		}
+		if len(page.modified) > 0 {
+			req.Header.Set("If-Modified-Since", page.modified)
+		}
+	}
+
+	p.identify(req)
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	p.stats.pages.Add(1)
+	p.observe(Event{Kind: PageFetched, URL: u})
+
+	// Our cached copy is still good
+	if cached && resp.StatusCode == http.StatusNotModified {
+		page.fetched = time.Now()
+		pages.Add(u, page)
+
+		return page.body, nil
+	}
+
+	// Read the contents of the response as bytes
+	b, err := ioutil.ReadAll(countingReader{resp.Body, &p.stats.bytes})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("wikimg: API returned %s", resp.Status)
+	}
+
+	pages.Add(u, apiPage{
+		body:     b,
+		etag:     resp.Header.Get("ETag"),
+		modified: resp.Header.Get("Last-Modified"),
+		fetched:  time.Now(),
+	})
+
+	return b, nil
+}
@@ -0,0 +1,78 @@
+package wikimg
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetPage(t *testing.T) {
+	full, revalidated := 0, 0
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidated++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		full++
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	p := NewPuller(1)
+	u := ts.URL + "/?list=allimages"
+
+	// The first request is a full one, the second is served from the cache
+	for i := 0; i < 2; i++ {
+		b, err := p.getPage(context.Background(), u)
+		if err != nil || string(b) != "{}" {
+			t.Fatalf("unexpected response %q, %v", b, err)
+		}
+	}
+	if full != 1 || revalidated != 0 {
+		t.Fatalf("expected 1 full request but got %d full, %d revalidated", full, revalidated)
+	}
+
+	// Once it's stale, it's revalidated
+	page, _ := p.pageCache().Get(u)
+	page.fetched = time.Now().Add(-2 * apiFresh)
+	p.pageCache().Add(u, page)
+
+	b, err := p.getPage(context.Background(), u)
+	if err != nil || string(b) != "{}" {
+		t.Fatalf("unexpected response %q, %v", b, err)
+	}
+	if full != 1 || revalidated != 1 {
+		t.Errorf("expected 1 revalidated request but got %d full, %d revalidated", full, revalidated)
+	}
+}
+
+func TestGetPagePerPuller(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(r.UserAgent()))
+	}))
+	defer ts.Close()
+
+	u := ts.URL + "/?list=allimages"
+
+	// Pullers don't share responses, even for the same URL
+	for _, operator := range []string{"a@example.com", "b@example.com"} {
+		p := NewPuller(1)
+		p.Operator = operator
+
+		b, err := p.getPage(context.Background(), u)
+		if err != nil || string(b) != p.UserAgent() {
+			t.Errorf("expected a response for %s but got %q, %v", operator, b, err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests but got %d", requests)
+	}
+}
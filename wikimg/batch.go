@@ -0,0 +1,143 @@
+package wikimg
+
+import (
+	"context"
+	"sync"
+)
+
+// ColorResult is the result of analyzing one image in a batch
+type ColorResult struct {
+	// Index is the position of the URL in the batch
+	Index int
+
+	// URL is the image URL
+	URL string
+
+	// Info is the image's color, if Err is nil
+	Info ColorInfo
+
+	// Err is why the image couldn't be analyzed
+	Err error
+}
+
+// FirstColors calls FirstColor for each of urls using concurrency
+// goroutines, and returns the results in the same order as urls. Each result
+// has its own error, so one bad image doesn't fail the batch. When ctx is
+// done (or p.Cancel is closed), images in progress are canceled and the
+// rest aren't started, with their errors set accordingly. If p.Budget is
+// set, images that take too long get a *TimeoutError. A concurrency less
+// than 1 is treated as 1.
+func (p *Puller) FirstColors(ctx context.Context, urls []string, concurrency int) []ColorResult {
+	in := make(chan string)
+	go func() {
+		defer close(in)
+
+		for _, u := range urls {
+			select {
+			case in <- u:
+			case <-ctx.Done():
+				return
+			case <-p.Cancel:
+				return
+			}
+		}
+	}()
+
+	results := make([]ColorResult, len(urls))
+	done := make([]bool, len(urls))
+	for res := range p.StreamColors(ctx, in, concurrency) {
+		results[res.Index] = res
+		done[res.Index] = true
+	}
+
+	// Report why the rest were never analyzed
+	for i := range results {
+		if done[i] {
+			continue
+		}
+
+		err := ctx.Err()
+		if err == nil {
+			err = Canceled
+		}
+		results[i] = ColorResult{Index: i, URL: urls[i], Err: err}
+	}
+
+	return results
+}
+
+// StreamColors is like FirstColors, but reads URLs from urls until it's
+// closed and sends each result as soon as it's ready, so results may be out
+// of order. Index is the order the URL was received in. The returned
+// channel is closed once every URL has been analyzed, or when ctx is done.
+func (p *Puller) StreamColors(ctx context.Context, urls <-chan string, concurrency int) <-chan ColorResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// cancel is closed when either ctx or the puller is canceled. stop
+	// ends the goroutine watching them once we're done.
+	cancel, stop := p.cancelWith(ctx)
+
+	type job struct {
+		index int
+		url   string
+	}
+
+	jobs := make(chan job)
+	out := make(chan ColorResult, concurrency)
+
+	// Number the URLs as they arrive
+	go func() {
+		defer close(jobs)
+
+		i := 0
+		for {
+			select {
+			case u, ok := <-urls:
+				if !ok {
+					return
+				}
+				select {
+				case jobs <- job{i, u}:
+					i++
+				case <-cancel:
+					return
+				}
+			case <-cancel:
+				return
+			}
+		}
+	}()
+
+	// Use wg to know when all workers are done and out can be closed
+	wg := sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := range jobs {
+				info, err := p.budgeted(ctx, j.url, cancel)
+				if err != nil && ctx.Err() != nil {
+					err = ctx.Err()
+				}
+
+				select {
+				case out <- ColorResult{Index: j.index, URL: j.url, Info: info, Err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		stop()
+		close(out)
+	}()
+
+	return out
+}
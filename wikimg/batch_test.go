@@ -0,0 +1,69 @@
+package wikimg
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFirstColors(t *testing.T) {
+	colors := map[string]color.Color{
+		"/red.png":  color.NRGBA{255, 0, 0, 255},
+		"/blue.png": color.NRGBA{0, 0, 255, 255},
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := colors[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+		for i := 0; i < 4; i++ {
+			img.Set(i%2, i/2, c)
+		}
+		png.Encode(w, img)
+	}))
+	defer ts.Close()
+
+	urls := []string{ts.URL + "/red.png", ts.URL + "/missing.png", ts.URL + "/blue.png", ts.URL + "/red.png"}
+	expected := []string{"#ff0000", "", "#0000ff", "#ff0000"}
+
+	p := NewPuller(0)
+	results := p.FirstColors(context.Background(), urls, 3)
+
+	if len(results) != len(urls) {
+		t.Fatalf("expected %d results but got %d", len(urls), len(results))
+	}
+
+	for i, res := range results {
+		if res.Index != i || res.URL != urls[i] {
+			t.Errorf("%d: result out of order %d %s", i, res.Index, res.URL)
+		}
+
+		if len(expected[i]) < 1 {
+			if res.Err == nil {
+				t.Errorf("%d: expected error", i)
+			}
+			continue
+		}
+
+		if res.Err != nil || res.Info.Hex != expected[i] {
+			t.Errorf("%d: expected %s but got %s, %v", i, expected[i], res.Info.Hex, res.Err)
+		}
+	}
+
+	// Nothing is analyzed once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, res := range p.FirstColors(ctx, urls, 2) {
+		if res.Err == nil {
+			t.Errorf("%s: expected error after cancel", res.URL)
+		}
+	}
+}
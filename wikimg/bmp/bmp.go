@@ -0,0 +1,10 @@
+// Package bmp adds BMP support to wikimg. Import it for its side effects:
+//
+//	import _ "github.com/brnstz/routine/wikimg/bmp"
+package bmp
+
+import (
+	// Registering the decoder with the image package is all that's needed
+	// for wikimg to decode BMP images
+	_ "golang.org/x/image/bmp"
+)
@@ -0,0 +1,249 @@
+// Package boltcache is a wikimg.Cache kept in a BoltDB file, so FirstColor
+// results survive restarts. A server that takes minutes to warm its cache
+// can start with the results of its previous run instead:
+//
+//	cache, err := boltcache.Open("colors.db", 7*24*time.Hour)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer cache.Close()
+//
+//	p := wikimg.NewPuller(100)
+//	p.Cache = cache
+//
+// It is a separate package so that programs which don't need it don't
+// depend on go.etcd.io/bbolt.
+package boltcache
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// openTimeout is how long Open waits for another process to release the
+// file
+const openTimeout = time.Second
+
+var (
+	// bucket is the bucket results are stored in
+	bucket = []byte("colors")
+
+	// values is the bucket values set with SetValue are stored in
+	values = []byte("values")
+)
+
+// entry is a stored result
+type entry struct {
+	Info    wikimg.ColorInfo `json:"info"`
+	Expires time.Time        `json:"expires,omitzero"`
+}
+
+// valueEntry is a stored value
+type valueEntry struct {
+	Value   json.RawMessage `json:"value"`
+	Expires time.Time       `json:"expires,omitzero"`
+}
+
+// Cache is a wikimg.Cache stored in a BoltDB file. Errors reading or
+// writing the file are treated as cache misses, since the result can always
+// be computed again; Err returns the most recent one.
+type Cache struct {
+	db  *bolt.DB
+	ttl time.Duration
+
+	err   error
+	mutex sync.Mutex
+}
+
+// Open opens the cache in the file at path, creating it if it doesn't
+// exist. Results are kept for ttl, or forever if ttl is zero. Only one
+// process can have the file open at once.
+func Open(path string, ttl time.Duration) (*Cache, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CreateBucketIfNotExists(values)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Cache{db: db, ttl: ttl}, nil
+}
+
+// Close closes the file
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+// Err returns the most recent error reading or writing the file, if any
+func (c *Cache) Err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.err
+}
+
+// fail records err for Err
+func (c *Cache) fail(err error) {
+	c.mutex.Lock()
+	c.err = err
+	c.mutex.Unlock()
+}
+
+// Get returns the result for key, if it's stored and hasn't expired.
+// Expired results are deleted.
+func (c *Cache) Get(key string) (wikimg.ColorInfo, bool) {
+	var e entry
+	var found bool
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket).Get([]byte(key))
+		if b == nil {
+			return nil
+		}
+
+		found = true
+		return json.Unmarshal(b, &e)
+	})
+	if err != nil {
+		c.fail(err)
+		return wikimg.ColorInfo{}, false
+	}
+	if !found {
+		return wikimg.ColorInfo{}, false
+	}
+
+	if !e.Expires.IsZero() && time.Now().After(e.Expires) {
+		c.Delete(key)
+		return wikimg.ColorInfo{}, false
+	}
+
+	return e.Info, true
+}
+
+// Set stores the result for key for ttl. A ttl of zero uses the ttl the
+// cache was opened with.
+func (c *Cache) Set(key string, info wikimg.ColorInfo, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+
+	e := entry{Info: info}
+	if ttl > 0 {
+		e.Expires = time.Now().Add(ttl)
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+
+	err = c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put([]byte(key), b)
+	})
+	if err != nil {
+		c.fail(err)
+	}
+}
+
+// Delete removes the result for key
+func (c *Cache) Delete(key string) {
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Delete([]byte(key))
+	})
+	if err != nil {
+		c.fail(err)
+	}
+}
+
+// GetValue decodes the value stored for key with SetValue into v, returning
+// false if there isn't one or it has expired. Expired values are deleted.
+func (c *Cache) GetValue(key string, v any) bool {
+	var e valueEntry
+	var found bool
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(values).Get([]byte(key))
+		if b == nil {
+			return nil
+		}
+
+		found = true
+		return json.Unmarshal(b, &e)
+	})
+	if err != nil {
+		c.fail(err)
+		return false
+	}
+	if !found {
+		return false
+	}
+
+	if !e.Expires.IsZero() && time.Now().After(e.Expires) {
+		err = c.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(values).Delete([]byte(key))
+		})
+		if err != nil {
+			c.fail(err)
+		}
+		return false
+	}
+
+	err = json.Unmarshal(e.Value, v)
+	if err != nil {
+		c.fail(err)
+		return false
+	}
+
+	return true
+}
+
+// SetValue stores v as JSON for key for ttl, e.g., state that a server
+// keeps alongside its results. Values are kept apart from results, so keys
+// can't collide. A ttl of zero uses the ttl the cache was opened with.
+func (c *Cache) SetValue(key string, v any, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+
+	e := valueEntry{Value: value}
+	if ttl > 0 {
+		e.Expires = time.Now().Add(ttl)
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+
+	err = c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(values).Put([]byte(key), b)
+	})
+	if err != nil {
+		c.fail(err)
+	}
+}
@@ -0,0 +1,112 @@
+package boltcache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+func TestCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "colors.db")
+
+	c, err := Open(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("expected an empty cache")
+	}
+
+	c.Set("a", wikimg.ColorInfo{Hex: "#aaaaaa", Index: 7}, 0)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Results survive reopening the file
+	c, err = Open(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	info, ok := c.Get("a")
+	if !ok || info.Hex != "#aaaaaa" || info.Index != 7 {
+		t.Errorf("expected a to be stored but got %+v, %v", info, ok)
+	}
+	if err := c.Err(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("expected a to be deleted")
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c, err := Open(filepath.Join(t.TempDir(), "colors.db"), time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Set("a", wikimg.ColorInfo{Hex: "#aaaaaa"}, 0)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("expected a to expire")
+	}
+}
+
+func TestValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "colors.db")
+
+	c, err := Open(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type pick struct {
+		URL string
+	}
+
+	var p pick
+	if c.GetValue("a", &p) {
+		t.Errorf("expected no value")
+	}
+
+	// Values don't collide with results
+	c.Set("a", wikimg.ColorInfo{Hex: "#aaaaaa"}, 0)
+	c.SetValue("a", pick{URL: "http://example.com/a.png"}, 0)
+	c.SetValue("b", pick{URL: "http://example.com/b.png"}, time.Millisecond)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Values survive reopening the file, until they expire
+	c, err = Open(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	time.Sleep(5 * time.Millisecond)
+
+	if !c.GetValue("a", &p) || p.URL != "http://example.com/a.png" {
+		t.Errorf("expected a to be stored but got %+v", p)
+	}
+	if info, ok := c.Get("a"); !ok || info.Hex != "#aaaaaa" {
+		t.Errorf("expected the result for a to be kept but got %+v, %v", info, ok)
+	}
+	if c.GetValue("b", &p) {
+		t.Errorf("expected b to expire")
+	}
+	if err := c.Err(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// Cache can be used as a Puller's cache
+var _ wikimg.Cache = (*Cache)(nil)
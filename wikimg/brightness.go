@@ -0,0 +1,56 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// darkLuminance is the relative luminance below which white text is more
+// readable than black text. It's where the WCAG contrast ratio against both
+// is the same: (L+0.05)/0.05 = 1.05/(L+0.05).
+var darkLuminance = math.Sqrt(1.05*0.05) - 0.05
+
+// Brightness returns the perceived brightness of the image: the mean relative
+// luminance of its sampled pixels, between 0 (black) and 1 (white). Pixels
+// are measured as they appear in the image, not as mapped to the palette.
+func (p *Puller) Brightness(imgURL string) (float64, error) {
+	a, err := p.fetch(p.context(), imgURL, false, p.Cancel)
+	if err != nil {
+		return 0, err
+	}
+	defer a.release()
+
+	return p.Options.brightness(a.img, p.Cancel)
+}
+
+// ImageBrightness returns the brightness of img, sampled according to
+// opts, for callers that decoded it themselves (see Brightness)
+func ImageBrightness(img image.Image, opts ColorOptions) float64 {
+	return measureDecoded(img, opts.brightness)
+}
+
+// Dark returns true if an image (or color) with the given brightness is dark,
+// meaning light text is more readable on top of it than dark text
+func Dark(brightness float64) bool {
+	return brightness < darkLuminance
+}
+
+// brightness computes the mean luminance of the sampled pixels of img
+func (o ColorOptions) brightness(img image.Image, cancel <-chan struct{}) (float64, error) {
+	total := 0.0
+	count := 0
+
+	_, err := o.scan(img, cancel, func(c color.Color) bool {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		total += luminance(n.R, n.G, n.B)
+		count++
+
+		return false
+	})
+	if err != nil || count < 1 {
+		return 0, err
+	}
+
+	return total / float64(count), nil
+}
@@ -0,0 +1,21 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestImageBrightness(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.White)
+	img.Set(1, 0, color.Black)
+
+	if b := ImageBrightness(img, ColorOptions{}); !near(b, 0.5) {
+		t.Errorf("expected 0.5 but got %v", b)
+	}
+
+	if Dark(0.5) || !Dark(0.1) {
+		t.Errorf("unexpected dark classification")
+	}
+}
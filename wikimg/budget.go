@@ -0,0 +1,123 @@
+package wikimg
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Budget is the most time each stage of analyzing an image may take. Zero
+// means a stage is unbounded. When a stage runs over, the image is
+// abandoned: its result is a *TimeoutError, sent right away, and the work
+// on it is canceled in the background.
+type Budget struct {
+	// Fetch bounds downloading and decoding the image
+	Fetch time.Duration
+
+	// Scan bounds finding the image's color once it's decoded
+	Scan time.Duration
+
+	// Total bounds the whole analysis, from when the image is accepted to
+	// its result
+	Total time.Duration
+}
+
+// TimeoutError is the result of an image that went over its Budget
+type TimeoutError struct {
+	URL string
+
+	// Stage is the stage that went over: "fetch", "scan" or "total"
+	Stage string
+
+	// Budget is the time the stage was allowed
+	Budget time.Duration
+}
+
+// Error describes the timeout
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("wikimg: %s: %s took longer than %v", e.URL, e.Stage, e.Budget)
+}
+
+// Timeout returns true, so a TimeoutError can be recognized like other
+// timeouts (e.g., net.Error)
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// budgetTimer returns a channel that receives after d, and a function to
+// stop it. If d isn't positive, the channel is nil and never receives.
+func budgetTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	if d <= 0 {
+		return nil, func() bool { return false }
+	}
+
+	t := time.NewTimer(d)
+
+	return t.C, t.Stop
+}
+
+// budgeted is firstColor, bounded by p.Budget
+func (p *Puller) budgeted(ctx context.Context, imgURL string, cancel <-chan struct{}) (ColorInfo, error) {
+	b := p.Budget
+	if b == (Budget{}) {
+		return p.firstColor(ctx, imgURL, cancel, nil)
+	}
+
+	// work is closed to abandon the image, either because cancel was
+	// closed or because it went over budget
+	work := make(chan struct{})
+	once := sync.Once{}
+	abandon := func() {
+		once.Do(func() { close(work) })
+	}
+	defer abandon()
+
+	go func() {
+		select {
+		case <-cancel:
+			abandon()
+		case <-work:
+		}
+	}()
+
+	type result struct {
+		info ColorInfo
+		err  error
+	}
+
+	fetched := make(chan struct{})
+	done := make(chan result, 1)
+
+	go func() {
+		info, err := p.firstColor(ctx, imgURL, work, func() { close(fetched) })
+		done <- result{info, err}
+	}()
+
+	total, stopTotal := budgetTimer(b.Total)
+	defer stopTotal()
+
+	stage, budget := "fetch", b.Fetch
+	timeout, stopStage := budgetTimer(budget)
+	defer func() { stopStage() }()
+
+	for {
+		select {
+		case res := <-done:
+			return res.info, res.err
+
+		case <-fetched:
+			// On to the next stage, with its own budget
+			fetched = nil
+			stopStage()
+			stage, budget = "scan", b.Scan
+			timeout, stopStage = budgetTimer(budget)
+
+		case <-timeout:
+			return ColorInfo{}, &TimeoutError{URL: imgURL, Stage: stage, Budget: budget}
+
+		case <-total:
+			return ColorInfo{}, &TimeoutError{URL: imgURL, Stage: "total", Budget: b.Total}
+		}
+	}
+}
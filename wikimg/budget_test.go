@@ -0,0 +1,42 @@
+package wikimg
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stall until the client gives up
+		if r.URL.Path == "/stalled.png" {
+			<-r.Context().Done()
+			return
+		}
+
+		png.Encode(w, image.NewNRGBA(image.Rect(0, 0, 2, 2)))
+	}))
+	defer ts.Close()
+
+	p := NewPuller(0)
+	p.Budget = Budget{Fetch: 50 * time.Millisecond, Total: time.Second}
+
+	start := time.Now()
+	results := p.FirstColors(context.Background(), []string{ts.URL + "/stalled.png", ts.URL + "/ok.png"}, 2)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected results within budget but took %v", elapsed)
+	}
+
+	te, ok := results[0].Err.(*TimeoutError)
+	if !ok || te.Stage != "fetch" || te.Budget != 50*time.Millisecond || !te.Timeout() {
+		t.Errorf("expected fetch *TimeoutError but got %v", results[0].Err)
+	}
+
+	if results[1].Err != nil {
+		t.Errorf("expected no error but got %v", results[1].Err)
+	}
+}
@@ -0,0 +1,103 @@
+package wikimg
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/brnstz/routine/lru"
+)
+
+// Cache memoizes FirstColor results by key (see Puller.Cache). Keys include
+// everything that can change a result, so one Cache can be shared by many
+// Pullers, or even many processes. Implementations must be safe for
+// concurrent use. Since a result can always be computed again, failures
+// are treated as misses rather than returned. ColorCache keeps results in
+// memory, the boltcache package on disk and the rediscache package in
+// Redis.
+type Cache interface {
+	// Get returns the result saved for key, if there is one
+	Get(key string) (ColorInfo, bool)
+
+	// Set saves the result for key for ttl. A ttl of zero uses the
+	// cache's default.
+	Set(key string, info ColorInfo, ttl time.Duration)
+
+	// Delete removes the result for key, if there is one
+	Delete(key string)
+}
+
+// ColorCache is a bounded, expiring cache of FirstColor results. Set it as
+// Puller.Cache to make repeated calls for the same URL return instantly. One
+// cache can be shared by many Pullers (e.g., one per request in a server),
+// since results are cached by URL and the options that affect them.
+type ColorCache struct {
+	cache *lru.Cache[string, ColorInfo]
+}
+
+// NewColorCache creates a cache that holds up to size results, each for up
+// to ttl. When it's full, the least recently used result is dropped. A ttl of
+// zero keeps results until they're dropped.
+func NewColorCache(size int, ttl time.Duration) *ColorCache {
+	return &ColorCache{cache: lru.New[string, ColorInfo](size, ttl)}
+}
+
+// Len returns the number of cached results
+func (cc *ColorCache) Len() int {
+	return cc.cache.Len()
+}
+
+// Get returns the result for key, if it's cached and hasn't expired
+func (cc *ColorCache) Get(key string) (ColorInfo, bool) {
+	return cc.cache.Get(key)
+}
+
+// Set saves the result for key, dropping the least recently used result if
+// the cache is full. A ttl of zero uses the cache's TTL.
+func (cc *ColorCache) Set(key string, info ColorInfo, ttl time.Duration) {
+	if ttl > 0 {
+		cc.cache.AddTTL(key, info, ttl)
+		return
+	}
+
+	cc.cache.Add(key, info)
+}
+
+// Delete removes the result for key
+func (cc *ColorCache) Delete(key string) {
+	cc.cache.Remove(key)
+}
+
+// Key returns a short hash identifying everything about p that can change
+// a result: its Options (see ColorOptions.Key), the width of thumbnails
+// (see UseThumbnails), SVGWidth, Routes and ReadEXIF. It can be combined
+// with an image URL as a cache key, e.g., by a server caching results of
+// many Pullers. Custom routes are identified by their AnalyzerName.
+func (p *Puller) Key() string {
+	h := sha1.New()
+
+	fmt.Fprintf(h, "%s;thumb=%d;svg=%d;exif=%t", p.Options.Key(), max(p.thumbWidth, 0), max(p.SVGWidth, 0), p.ReadEXIF)
+
+	// Routes in a fixed order
+	mimes := make([]string, 0, len(p.Routes))
+	for mime := range p.Routes {
+		mimes = append(mimes, mime)
+	}
+	sort.Strings(mimes)
+
+	fmt.Fprint(h, ";routes=")
+	for _, mime := range mimes {
+		r := p.Routes[mime]
+		fmt.Fprintf(h, "%s:%s/%d/%s,", mime, r.Strategy, r.Width, r.AnalyzerName)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// cacheKey returns the key of imgURL's result in p.Cache. Everything about
+// p that can change the result is part of it (see Key).
+func (p *Puller) cacheKey(imgURL string) string {
+	return imgURL + "|" + p.Key()
+}
@@ -0,0 +1,107 @@
+package wikimg
+
+import (
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestColorCache(t *testing.T) {
+	cc := NewColorCache(2, 0)
+
+	cc.Set("a", ColorInfo{Hex: "#aaaaaa"}, 0)
+	cc.Set("b", ColorInfo{Hex: "#bbbbbb"}, 0)
+
+	// Using a makes b the least recently used
+	if info, ok := cc.Get("a"); !ok || info.Hex != "#aaaaaa" {
+		t.Errorf("expected a to be cached")
+	}
+
+	cc.Set("c", ColorInfo{Hex: "#cccccc"}, 0)
+	if _, ok := cc.Get("b"); ok {
+		t.Errorf("expected b to be dropped")
+	}
+	if cc.Len() != 2 {
+		t.Errorf("expected 2 results but got %d", cc.Len())
+	}
+
+	// Results expire
+	cc = NewColorCache(2, time.Millisecond)
+	cc.Set("a", ColorInfo{}, 0)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cc.Get("a"); ok {
+		t.Errorf("expected a to expire")
+	}
+
+	// Unless it has its own TTL
+	cc.Set("b", ColorInfo{}, time.Hour)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cc.Get("b"); !ok {
+		t.Errorf("expected b to outlive the cache's TTL")
+	}
+
+	cc.Delete("b")
+	if _, ok := cc.Get("b"); ok {
+		t.Errorf("expected b to be deleted")
+	}
+}
+
+func TestPullerCache(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		png.Encode(w, image.NewNRGBA(image.Rect(0, 0, 1, 1)))
+	}))
+	defer ts.Close()
+
+	cache := NewColorCache(10, time.Minute)
+
+	// Pullers with the same options share results
+	for i := 0; i < 3; i++ {
+		p := NewPuller(0)
+		p.Cache = cache
+
+		if _, err := p.FirstColor(ts.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 request but got %d", requests)
+	}
+
+	// Different options are cached separately
+	p := NewPuller(0)
+	p.Cache = cache
+	p.Options.Stride = 2
+	if _, err := p.FirstColor(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests but got %d", requests)
+	}
+}
+
+func TestPullerKey(t *testing.T) {
+	def := NewPuller(1).Key()
+	if k := NewPuller(5).Key(); k != def {
+		t.Errorf("expected the same key for the same settings but got %s and %s", k, def)
+	}
+
+	for name, set := range map[string]func(p *Puller){
+		"options":    func(p *Puller) { p.Options.Stride = 2 },
+		"thumbnails": func(p *Puller) { p.UseThumbnails(320) },
+		"svg":        func(p *Puller) { p.SVGWidth = 256 },
+		"exif":       func(p *Puller) { p.ReadEXIF = true },
+		"routes":     func(p *Puller) { p.Routes["image/tiff"] = Route{Strategy: Skip} },
+		"no routes":  func(p *Puller) { p.Routes = nil },
+	} {
+		p := NewPuller(1)
+		set(p)
+		if k := p.Key(); k == def {
+			t.Errorf("%s: expected a different key", name)
+		}
+	}
+}
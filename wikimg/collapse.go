@@ -0,0 +1,82 @@
+package wikimg
+
+import "fmt"
+
+// Collapse is how repeated colors are merged, e.g., so a burst of scans of
+// the same beige paper is shown once with a count rather than as a wall of
+// beige (see CollapseColors)
+type Collapse int
+
+const (
+	// NoCollapse keeps every color
+	NoCollapse Collapse = iota
+
+	// CollapseRuns merges consecutive colors with the same hex value
+	CollapseRuns
+
+	// CollapseAll merges every color with the same hex value into the
+	// first one
+	CollapseAll
+)
+
+// collapseNames are the names of the modes, as accepted by ParseCollapse
+var collapseNames = map[Collapse]string{
+	NoCollapse:   "none",
+	CollapseRuns: "runs",
+	CollapseAll:  "all",
+}
+
+// ParseCollapse returns the mode named by s: "none", "runs" or "all"
+func ParseCollapse(s string) (Collapse, error) {
+	for k, v := range collapseNames {
+		if v == s {
+			return k, nil
+		}
+	}
+
+	return NoCollapse, fmt.Errorf("wikimg: invalid collapse %q (must be none, runs or all)", s)
+}
+
+// String returns the name of the mode
+func (c Collapse) String() string {
+	return collapseNames[c]
+}
+
+// Run is an item standing for Count items with the same color
+type Run[T any] struct {
+	Item  T
+	Count int
+}
+
+// CollapseColors merges items, whose colors are returned by info, that
+// have the same hex value, as c says. Each run is the first of its items,
+// in the order they first appear. With NoCollapse, every run has a count
+// of one.
+func CollapseColors[T any](items []T, c Collapse, info func(T) ColorInfo) []Run[T] {
+	var runs []Run[T]
+
+	// first is the index in runs of the run of each color, for
+	// CollapseAll
+	first := map[string]int{}
+
+	for _, item := range items {
+		hex := info(item).Hex
+
+		switch {
+		case c == CollapseRuns && len(runs) > 0 && info(runs[len(runs)-1].Item).Hex == hex:
+			runs[len(runs)-1].Count++
+			continue
+
+		case c == CollapseAll:
+			if i, ok := first[hex]; ok {
+				runs[i].Count++
+				continue
+			}
+			first[hex] = len(runs)
+		}
+
+		runs = append(runs, Run[T]{Item: item, Count: 1})
+	}
+
+	return runs
+}
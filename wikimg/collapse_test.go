@@ -0,0 +1,41 @@
+package wikimg
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCollapseColors(t *testing.T) {
+	hexes := []string{"#f5f5dc", "#f5f5dc", "#ff0000", "#f5f5dc", "#f5f5dc", "#f5f5dc", "#0000ff"}
+	info := func(hex string) ColorInfo { return ColorInfo{Hex: hex} }
+
+	for _, test := range []struct {
+		collapse Collapse
+		expected string
+	}{
+		{NoCollapse, "#f5f5dc×1 #f5f5dc×1 #ff0000×1 #f5f5dc×1 #f5f5dc×1 #f5f5dc×1 #0000ff×1"},
+		{CollapseRuns, "#f5f5dc×2 #ff0000×1 #f5f5dc×3 #0000ff×1"},
+		{CollapseAll, "#f5f5dc×5 #ff0000×1 #0000ff×1"},
+	} {
+		var got []string
+		for _, run := range CollapseColors(hexes, test.collapse, info) {
+			got = append(got, fmt.Sprintf("%s×%d", run.Item, run.Count))
+		}
+
+		if strings.Join(got, " ") != test.expected {
+			t.Errorf("%s: expected %s but got %s", test.collapse, test.expected, strings.Join(got, " "))
+		}
+	}
+
+	if runs := CollapseColors(nil, CollapseAll, info); len(runs) != 0 {
+		t.Errorf("expected no runs but got %v", runs)
+	}
+
+	if c, err := ParseCollapse("runs"); err != nil || c != CollapseRuns {
+		t.Errorf("expected CollapseRuns but got %v, %v", c, err)
+	}
+	if _, err := ParseCollapse("some"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
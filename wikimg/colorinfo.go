@@ -0,0 +1,157 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"time"
+)
+
+// ColorInfo describes a color found in an image
+type ColorInfo struct {
+	// Index is the position of the color in the palette it was mapped to.
+	// With the default XTerm256 palette, this is the xterm256 color id. It
+	// is -1 when quantization is disabled.
+	Index int `json:"index"`
+
+	// Hex is the color as a hex string (e.g., "#bb00cc"). Source says
+	// whether it, and the fields that follow, describe the palette color
+	// or the pixel's own color.
+	Hex string `json:"hex"`
+
+	// Source is "palette" or "pixel" (see ColorOptions.Report)
+	Source string `json:"source"`
+
+	// R, G and B are the 8-bit red, green and blue values of the color
+	R uint8 `json:"r"`
+	G uint8 `json:"g"`
+	B uint8 `json:"b"`
+
+	// H, S and L are the hue, saturation and lightness of the color. H is
+	// in degrees between 0 and 360, S and L are between 0 and 1.
+	H float64 `json:"h"`
+	S float64 `json:"s"`
+	L float64 `json:"l"`
+
+	// Luminance is the relative luminance of the color, between 0 (black)
+	// and 1 (white)
+	Luminance float64 `json:"luminance"`
+
+	// Gray is true when no non-gray color was found and the final pixel of
+	// the image was returned as a fallback
+	Gray bool `json:"gray"`
+
+	// Width and Height are the dimensions of the decoded image
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// Format is the name of the image's format (e.g., "jpeg"), as
+	// registered with the image package
+	Format string `json:"format"`
+
+	// Uploaded is when the image was uploaded to Commons. It is only known
+	// for images returned by the same Puller's Next().
+	Uploaded time.Time `json:"uploaded,omitzero"`
+
+	// RequestID is the Puller.RequestID of the run that analyzed the image
+	RequestID string `json:"request_id,omitempty"`
+
+	// EXIF is the image's EXIF metadata. It is only read from JPEGs when
+	// Puller.ReadEXIF is set.
+	EXIF *EXIF `json:"exif,omitempty"`
+
+	// DeltaE is the mean CIE76 color difference between the sampled pixels
+	// of the image and the palette colors they map to. Lower is better,
+	// differences under about 2.3 are not noticeable. It is only computed
+	// when ColorOptions.MeasureQuality is set.
+	DeltaE float64 `json:"delta_e,omitempty"`
+
+	// Histogram is the number of sampled pixels of the image that map to
+	// each palette index (see Histogram). It is only computed when
+	// ColorOptions.MeasureHistogram is set.
+	Histogram map[int]int `json:"histogram,omitempty"`
+}
+
+// newColorInfo creates a ColorInfo for c, which is found at index in its
+// palette
+func newColorInfo(c color.Color, index int) ColorInfo {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+
+	info := ColorInfo{
+		Index: index,
+		Hex:   Hex(c),
+		R:     n.R,
+		G:     n.G,
+		B:     n.B,
+	}
+
+	info.H, info.S, info.L = hsl(n.R, n.G, n.B)
+	info.Luminance = luminance(n.R, n.G, n.B)
+
+	return info
+}
+
+// setImage records the dimensions and format of the image the color came
+// from
+func (info *ColorInfo) setImage(img image.Image, format string) {
+	info.Width = img.Bounds().Dx()
+	info.Height = img.Bounds().Dy()
+	info.Format = format
+}
+
+// hsl converts 8-bit RGB values to hue (in degrees), saturation and
+// lightness
+func hsl(r8, g8, b8 uint8) (h, s, l float64) {
+	r := float64(r8) / 255
+	g := float64(g8) / 255
+	b := float64(b8) / 255
+
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	l = (max + min) / 2
+
+	// A gray color has no hue or saturation
+	if max == min {
+		return 0, 0, l
+	}
+
+	d := max - min
+	if l > 0.5 {
+		s = d / (2 - max - min)
+	} else {
+		s = d / (max + min)
+	}
+
+	switch max {
+	case r:
+		h = math.Mod((g-b)/d, 6)
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+
+	return h, s, l
+}
+
+// luminance computes the relative luminance of 8-bit sRGB values as defined
+// by WCAG https://www.w3.org/TR/WCAG20/#relativeluminancedef
+func luminance(r, g, b uint8) float64 {
+	return 0.2126*linear(r) + 0.7152*linear(g) + 0.0722*linear(b)
+}
+
+// linear converts an 8-bit sRGB channel value to linear light
+func linear(v uint8) float64 {
+	c := float64(v) / 255
+
+	if c <= 0.03928 {
+		return c / 12.92
+	}
+
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
@@ -0,0 +1,39 @@
+package wikimg
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestNewColorInfo(t *testing.T) {
+	tests := []struct {
+		in      color.Color
+		h, s, l float64
+		lum     float64
+	}{
+		{color.RGBA{0xff, 0x00, 0x00, 0xff}, 0, 1, 0.5, 0.2126},
+		{color.RGBA{0x00, 0x00, 0xff, 0xff}, 240, 1, 0.5, 0.0722},
+		{color.RGBA{0xff, 0xff, 0xff, 0xff}, 0, 0, 1, 1},
+		{color.RGBA{0x00, 0x00, 0x00, 0xff}, 0, 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		info := newColorInfo(test.in, 1)
+
+		if !near(info.H, test.h) || !near(info.S, test.s) || !near(info.L, test.l) {
+			t.Errorf("%v: expected HSL %v,%v,%v but got %v,%v,%v",
+				test.in, test.h, test.s, test.l, info.H, info.S, info.L)
+		}
+
+		if !near(info.Luminance, test.lum) {
+			t.Errorf("%v: expected luminance %v but got %v",
+				test.in, test.lum, info.Luminance)
+		}
+	}
+}
+
+// near returns true if a and b are within a small tolerance
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 0.001
+}
@@ -0,0 +1,54 @@
+package wikimg
+
+import (
+	"image/color"
+	"math"
+)
+
+// Contrast returns a foreground color, either black ("#000000") or white
+// ("#ffffff"), that is readable on top of the color, for example when
+// printing its hex value on a swatch
+func (info ColorInfo) Contrast() string {
+	if Dark(info.Luminance) {
+		return "#ffffff"
+	}
+
+	return "#000000"
+}
+
+// Complement returns the complementary color (the opposite hue with the same
+// saturation and lightness) as a hex string. It's more decorative than
+// Contrast(), but less readable for grays and mid-lightness colors.
+func (info ColorInfo) Complement() string {
+	r, g, b := rgb(math.Mod(info.H+180, 360), info.S, info.L)
+
+	return Hex(color.RGBA{r, g, b, 0xff})
+}
+
+// rgb converts hue (in degrees), saturation and lightness to 8-bit RGB
+// values
+func rgb(h, s, l float64) (r, g, b uint8) {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+
+	var rf, gf, bf float64
+	switch {
+	case h < 60:
+		rf, gf, bf = c, x, 0
+	case h < 120:
+		rf, gf, bf = x, c, 0
+	case h < 180:
+		rf, gf, bf = 0, c, x
+	case h < 240:
+		rf, gf, bf = 0, x, c
+	case h < 300:
+		rf, gf, bf = x, 0, c
+	default:
+		rf, gf, bf = c, 0, x
+	}
+
+	return uint8(math.Round((rf + m) * 255)),
+		uint8(math.Round((gf + m) * 255)),
+		uint8(math.Round((bf + m) * 255))
+}
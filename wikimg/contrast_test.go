@@ -0,0 +1,29 @@
+package wikimg
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestContrast(t *testing.T) {
+	tests := []struct {
+		in                   color.Color
+		contrast, complement string
+	}{
+		{color.RGBA{0xff, 0xff, 0x00, 0xff}, "#000000", "#0000ff"},
+		{color.RGBA{0x00, 0x00, 0x80, 0xff}, "#ffffff", "#808000"},
+		{color.RGBA{0xbb, 0x00, 0xcc, 0xff}, "#ffffff", "#11cc00"},
+	}
+
+	for _, test := range tests {
+		info := newColorInfo(test.in, -1)
+
+		if c := info.Contrast(); c != test.contrast {
+			t.Errorf("%s: expected contrast %s but got %s", info.Hex, test.contrast, c)
+		}
+
+		if c := info.Complement(); c != test.complement {
+			t.Errorf("%s: expected complement %s but got %s", info.Hex, test.complement, c)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package wikimg
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// CVD is a type of color vision deficiency (color blindness)
+type CVD int
+
+const (
+	// NormalVision leaves colors unchanged
+	NormalVision CVD = iota
+
+	// Protanopia is missing red cones
+	Protanopia
+
+	// Deuteranopia is missing green cones, the most common type
+	Deuteranopia
+
+	// Tritanopia is missing blue cones
+	Tritanopia
+)
+
+// cvdNames are the names of the deficiencies, as accepted by ParseCVD
+var cvdNames = map[CVD]string{
+	NormalVision: "none",
+	Protanopia:   "protanopia",
+	Deuteranopia: "deuteranopia",
+	Tritanopia:   "tritanopia",
+}
+
+// cvdMatrices simulate each deficiency at full severity in linear RGB, from
+// Machado, Oliveira and Fernandes (2009), "A Physiologically-based Model for
+// Simulation of Color Vision Deficiency"
+var cvdMatrices = map[CVD][3][3]float64{
+	Protanopia: {
+		{0.152286, 1.052583, -0.204868},
+		{0.114503, 0.786281, 0.099216},
+		{-0.003882, -0.048116, 1.051998},
+	},
+	Deuteranopia: {
+		{0.367322, 0.860646, -0.227968},
+		{0.280085, 0.672501, 0.047413},
+		{-0.011820, 0.042940, 0.968881},
+	},
+	Tritanopia: {
+		{1.255528, -0.076749, -0.178779},
+		{-0.078411, 0.930809, 0.147602},
+		{0.004733, 0.691367, 0.303900},
+	},
+}
+
+// ParseCVD returns the deficiency named by s: "none", "protanopia",
+// "deuteranopia" or "tritanopia"
+func ParseCVD(s string) (CVD, error) {
+	for k, v := range cvdNames {
+		if v == s {
+			return k, nil
+		}
+	}
+
+	return NormalVision, fmt.Errorf("wikimg: invalid color vision deficiency %q (must be none, protanopia, deuteranopia or tritanopia)", s)
+}
+
+// String returns the name of the deficiency
+func (cvd CVD) String() string {
+	return cvdNames[cvd]
+}
+
+// Simulate returns c as it appears to someone with cvd
+func Simulate(c color.Color, cvd CVD) color.NRGBA {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+
+	m, ok := cvdMatrices[cvd]
+	if !ok {
+		return n
+	}
+
+	in := [3]float64{linear(n.R), linear(n.G), linear(n.B)}
+	var out [3]uint8
+	for i, row := range m {
+		out[i] = encode(row[0]*in[0] + row[1]*in[1] + row[2]*in[2])
+	}
+
+	return color.NRGBA{out[0], out[1], out[2], n.A}
+}
+
+// Simulate returns the color as it appears to someone with cvd. Index and
+// the details of the image are unchanged, so a rendered wall can swap in
+// the simulated colors.
+func (info ColorInfo) Simulate(cvd CVD) ColorInfo {
+	if cvd == NormalVision {
+		return info
+	}
+
+	sim := newColorInfo(Simulate(color.NRGBA{info.R, info.G, info.B, 0xff}, cvd), info.Index)
+
+	info.Hex = sim.Hex
+	info.R, info.G, info.B = sim.R, sim.G, sim.B
+	info.H, info.S, info.L = sim.H, sim.S, sim.L
+	info.Luminance = sim.Luminance
+
+	return info
+}
+
+// encode converts linear light to an 8-bit sRGB channel value, the inverse
+// of linear
+func encode(v float64) uint8 {
+	v = math.Max(0, math.Min(1, v))
+
+	if v <= 0.03928/12.92 {
+		v *= 12.92
+	} else {
+		v = 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+
+	return uint8(math.Round(v * 255))
+}
@@ -0,0 +1,45 @@
+package wikimg
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	green := color.NRGBA{0, 255, 0, 255}
+
+	// Grays look the same to everyone
+	gray := color.NRGBA{128, 128, 128, 255}
+	for cvd := range cvdMatrices {
+		s := Simulate(gray, cvd)
+		if s.R < 126 || s.R > 130 || s.G < 126 || s.G > 130 || s.B < 126 || s.B > 130 {
+			t.Errorf("%s: expected gray to stay gray but got %v", cvd, s)
+		}
+	}
+
+	// Red and green are hard to tell apart without red or green cones
+	for _, cvd := range []CVD{Protanopia, Deuteranopia} {
+		r := toLab(Simulate(red, cvd))
+		g := toLab(Simulate(green, cvd))
+		if d := deltaE76(r, g); d >= deltaE76(toLab(red), toLab(green))/2 {
+			t.Errorf("%s: expected red and green to look similar but got difference %v", cvd, d)
+		}
+	}
+
+	if s := Simulate(red, NormalVision); s != red {
+		t.Errorf("expected normal vision to leave red unchanged but got %v", s)
+	}
+
+	info := newColorInfo(red, 9).Simulate(Deuteranopia)
+	if info.Index != 9 || info.Hex == "#ff0000" {
+		t.Errorf("unexpected simulated info %+v", info)
+	}
+
+	if cvd, err := ParseCVD("tritanopia"); err != nil || cvd != Tritanopia {
+		t.Errorf("expected Tritanopia but got %v, %v", cvd, err)
+	}
+	if _, err := ParseCVD("red"); err == nil {
+		t.Errorf("expected error for invalid name")
+	}
+}
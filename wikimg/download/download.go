@@ -0,0 +1,340 @@
+// Package download saves images to a directory, keeping a manifest so an
+// interrupted bulk download can resume where it stopped and verify the
+// files it already has.
+//
+// Images pulled from Commons are named after their page titles, so the
+// same image always gets the same file name:
+//
+//	d, err := download.NewDownloader("images")
+//	if err != nil {
+//		return err
+//	}
+//
+//	for res := range d.DownloadAll(ctx, images) {
+//		fmt.Println(res.Entry.Path, res.Err)
+//	}
+package download
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/brnstz/routine/pool"
+	"github.com/brnstz/routine/wikimg"
+)
+
+const (
+	// ManifestName is the name of the manifest file in the download
+	// directory
+	ManifestName = "manifest.json"
+
+	// DefaultWorkers is the default for Downloader.Workers
+	DefaultWorkers = 4
+)
+
+// Downloader saves files to Dir, recording each in a Manifest
+type Downloader struct {
+	// Dir is the directory files are saved to
+	Dir string
+
+	// Manifest records every download
+	Manifest *Manifest
+
+	// Client is used for requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Workers is the number of files DownloadAll downloads at once. Zero
+	// means DefaultWorkers.
+	Workers int
+}
+
+// Result is the outcome of downloading an image with DownloadAll
+type Result struct {
+	// Image is the image that was downloaded
+	Image wikimg.ImageInfo
+
+	// Entry records where it was saved, if Err is nil
+	Entry Entry
+
+	// Err is why it couldn't be downloaded
+	Err error
+}
+
+// NewDownloader creates a Downloader that saves files to dir, creating it
+// if needed. An existing manifest in dir is loaded, so downloads that are
+// already done are skipped.
+func NewDownloader(dir string) (*Downloader, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := OpenManifest(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Downloader{Dir: dir, Manifest: m}, nil
+}
+
+// Download saves the file at fileURL, named after the last element of its
+// path, unless the manifest says it's already done and the file on disk
+// still matches its checksum. The entry is recorded as pending before the
+// download starts and as done or failed when it ends, so after a crash the
+// manifest shows exactly which files need to be retried.
+//
+// If another URL is already saved with the same name, a suffix derived
+// from fileURL is added to it. If a file with the name is already in the
+// directory, e.g., because the manifest was lost, it's recorded as done
+// without downloading it again.
+func (d *Downloader) Download(fileURL string) (Entry, error) {
+	return d.download(context.Background(), fileURL, "")
+}
+
+// DownloadImage is like Download, but the file is named after the title of
+// the image's page on Commons if it's known, e.g., "File:Red fox.jpg" is
+// saved as "Red_fox.jpg". The download is abandoned when ctx is done.
+func (d *Downloader) DownloadImage(ctx context.Context, img wikimg.ImageInfo) (Entry, error) {
+	return d.download(ctx, img.URL, titleFilename(img.Title))
+}
+
+// DownloadAll downloads each image received on images with DownloadImage,
+// at most Workers at a time, until images is closed or ctx is done. The
+// result of each is sent on the returned channel, in no particular order,
+// which is closed once they're all done.
+func (d *Downloader) DownloadAll(ctx context.Context, images <-chan wikimg.ImageInfo) <-chan Result {
+	workers := d.Workers
+	if workers < 1 {
+		workers = DefaultWorkers
+	}
+
+	results := make(chan Result)
+	go func() {
+		defer close(results)
+
+		pool.Run(ctx, workers, images, func(ctx context.Context, img wikimg.ImageInfo) error {
+			e, err := d.DownloadImage(ctx, img)
+			results <- Result{Image: img, Entry: e, Err: err}
+
+			return nil
+		})
+	}()
+
+	return results
+}
+
+// download saves the file at fileURL as name, or a name derived from its
+// URL if name is empty (see Download)
+func (d *Downloader) download(ctx context.Context, fileURL, name string) (Entry, error) {
+	e, ok := d.Manifest.Get(fileURL)
+	if ok && e.Status == Done && d.verify(e) == nil {
+		return e, nil
+	}
+
+	var err error
+	if len(e.Path) < 1 {
+		if len(name) < 1 {
+			name, err = filename(fileURL)
+			if err != nil {
+				return e, err
+			}
+		}
+
+		e, err = d.Manifest.claim(fileURL, name)
+		if err != nil {
+			return e, err
+		}
+
+		// The file may be left from a run whose manifest was lost. Files
+		// are renamed into place once they're complete, so it isn't
+		// partial.
+		sum, err := d.checksum(e.Path)
+		if err == nil {
+			e.SHA1 = sum
+			e.Status = Done
+
+			return e, d.Manifest.Set(e)
+		}
+	}
+
+	e.Status = Pending
+	e.SHA1 = ""
+	e.Error = ""
+	err = d.Manifest.Set(e)
+	if err != nil {
+		return e, err
+	}
+
+	e.SHA1, err = d.save(ctx, e)
+	if err != nil {
+		e.Status = Failed
+		e.Error = err.Error()
+		d.Manifest.Set(e)
+
+		return e, err
+	}
+
+	e.Status = Done
+
+	return e, d.Manifest.Set(e)
+}
+
+// Verify checks every done entry against the file on disk, marking those
+// that are missing or changed as failed. It returns the failed entries.
+func (d *Downloader) Verify() ([]Entry, error) {
+	var failed []Entry
+
+	for _, e := range d.Manifest.Entries() {
+		if e.Status != Done {
+			continue
+		}
+
+		err := d.verify(e)
+		if err == nil {
+			continue
+		}
+
+		e.Status = Failed
+		e.Error = err.Error()
+		failed = append(failed, e)
+
+		err = d.Manifest.Set(e)
+		if err != nil {
+			return failed, err
+		}
+	}
+
+	return failed, nil
+}
+
+// verify returns an error if the file for e is missing or doesn't match its
+// checksum
+func (d *Downloader) verify(e Entry) error {
+	sum, err := d.checksum(e.Path)
+	if err != nil {
+		return err
+	}
+
+	if sum != e.SHA1 {
+		return fmt.Errorf("download: %s: checksum %s doesn't match %s", e.Path, sum, e.SHA1)
+	}
+
+	return nil
+}
+
+// checksum returns the hex encoded SHA-1 checksum of the file at name in
+// the directory
+func (d *Downloader) checksum(name string) (string, error) {
+	f, err := os.Open(filepath.Join(d.Dir, name))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha1.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// save downloads e.URL to a temporary file and renames it into place once
+// it is complete, returning its checksum
+func (d *Downloader) save(ctx context.Context, e Entry) (string, error) {
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.URL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download: %s: %s", resp.Status, e.URL)
+	}
+
+	dest := filepath.Join(d.Dir, e.Path)
+	tmp, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha1.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), resp.Body)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	err = os.Rename(tmp.Name(), dest)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// filename returns the name a file is saved as: the last element of its
+// URL path
+func filename(fileURL string) (string, error) {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return "", err
+	}
+
+	// u.Path is already unescaped, and its base never contains a slash
+	name := path.Base(u.Path)
+	if !valid(name) || strings.Contains(name, `\`) {
+		return "", fmt.Errorf("download: no file name in %s", fileURL)
+	}
+
+	return name, nil
+}
+
+// titleFilename returns the name a file with the page title is saved as,
+// or "" if there's no usable title. The namespace is removed and spaces
+// are replaced with underscores, as in the URLs of Commons, e.g.,
+// "File:Red fox.jpg" is saved as "Red_fox.jpg".
+func titleFilename(title string) string {
+	_, name, ok := strings.Cut(title, ":")
+	if !ok {
+		name = title
+	}
+
+	name = strings.NewReplacer(" ", "_", "/", "_", `\`, "_").Replace(strings.TrimSpace(name))
+	if !valid(name) {
+		return ""
+	}
+
+	return name
+}
+
+// valid returns whether name can be saved in the directory
+func valid(name string) bool {
+	return len(name) > 0 && name != "." && name != ".." && name != "/" && name != ManifestName
+}
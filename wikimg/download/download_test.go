@@ -0,0 +1,242 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+func TestDownloadResume(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("image " + r.URL.Path))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+
+	d, err := NewDownloader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := d.Download(ts.URL + "/a/Cat%20photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Status != Done || e.Path != "Cat photo.jpg" || len(e.SHA1) != 40 {
+		t.Errorf("unexpected entry %+v", e)
+	}
+
+	// Simulate a crash in the middle of a second download
+	err = d.Manifest.Set(Entry{URL: ts.URL + "/b.png", Path: "b.png", Status: Pending})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A new downloader picks up the manifest, skipping the finished file
+	// and retrying the interrupted one
+	d, err = NewDownloader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, u := range []string{ts.URL + "/a/Cat%20photo.jpg", ts.URL + "/b.png"} {
+		e, err := d.Download(u)
+		if err != nil || e.Status != Done {
+			t.Errorf("%s: unexpected entry %+v, %v", u, e, err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests but got %d", requests)
+	}
+
+	// Changed files fail verification
+	err = os.WriteFile(filepath.Join(dir, "b.png"), []byte("changed"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed, err := d.Verify()
+	if err != nil || len(failed) != 1 || failed[0].Path != "b.png" {
+		t.Errorf("expected b.png to fail verification but got %+v, %v", failed, err)
+	}
+
+	if e, _ := d.Manifest.Get(ts.URL + "/b.png"); e.Status != Failed {
+		t.Errorf("expected failed status but got %s", e.Status)
+	}
+}
+
+func TestFilename(t *testing.T) {
+	bad := []string{"http://example.com/", "http://example.com/a/..", `http://example.com/..%5Cetc`, "http://example.com/manifest.json"}
+	for _, u := range bad {
+		if name, err := filename(u); err == nil {
+			t.Errorf("%s: expected error but got %s", u, name)
+		}
+	}
+}
+
+func TestTitleFilename(t *testing.T) {
+	tests := map[string]string{
+		"File:Red fox.jpg":        "Red_fox.jpg",
+		"File:A: subtitle.png":    "A:_subtitle.png",
+		"File:AC/DC live.jpg":     "AC_DC_live.jpg",
+		"File:manifest.json":      "",
+		"File:..":                 "",
+		"":                        "",
+		"Untitled namespace.tiff": "Untitled_namespace.tiff",
+	}
+
+	for title, expected := range tests {
+		if got := titleFilename(title); got != expected {
+			t.Errorf("%q: expected %q but got %q", title, expected, got)
+		}
+	}
+}
+
+func TestDownloadCollisions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("image " + r.URL.Path))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	d, err := NewDownloader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := d.Download(ts.URL + "/a/cat.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Same name, and a name that only differs by case
+	b, err := d.Download(ts.URL + "/b/cat.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := d.Download(ts.URL + "/c/Cat.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Path != "cat.jpg" {
+		t.Errorf("expected cat.jpg but got %s", a.Path)
+	}
+	if b.Path == a.Path || c.Path == a.Path || b.Path == c.Path {
+		t.Errorf("expected different names but got %s, %s and %s", a.Path, b.Path, c.Path)
+	}
+	if filepath.Ext(b.Path) != ".jpg" {
+		t.Errorf("expected the extension to be kept but got %s", b.Path)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, b.Path))
+	if err != nil || string(got) != "image /b/cat.jpg" {
+		t.Errorf("unexpected contents %q, %v", got, err)
+	}
+
+	// The suffix depends on the URL, not on the order of downloads
+	d, err = NewDownloader(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Download(ts.URL + "/c/Cat.jpg")
+	b2, err := d.Download(ts.URL + "/b/cat.jpg")
+	if err != nil || b2.Path != b.Path {
+		t.Errorf("expected %s but got %s, %v", b.Path, b2.Path, err)
+	}
+}
+
+func TestDownloadSkipsExisting(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("image"))
+	}))
+	defer ts.Close()
+
+	// A file from a run whose manifest was lost
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "Red_fox.jpg"), []byte("fox"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewDownloader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := wikimg.ImageInfo{URL: ts.URL + "/1/1a/Red_fox.jpg", Title: "File:Red fox.jpg"}
+	e, err := d.DownloadImage(context.Background(), img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Status != Done || e.Path != "Red_fox.jpg" || len(e.SHA1) != 40 {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if requests != 0 {
+		t.Errorf("expected no requests but got %d", requests)
+	}
+}
+
+func TestDownloadAll(t *testing.T) {
+	var running, most int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&most)
+			if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("image " + r.URL.Path))
+	}))
+	defer ts.Close()
+
+	d, err := NewDownloader(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Workers = 2
+
+	images := make(chan wikimg.ImageInfo)
+	go func() {
+		defer close(images)
+		for i := 0; i < 8; i++ {
+			images <- wikimg.ImageInfo{
+				URL:   fmt.Sprintf("%s/%d.png", ts.URL, i),
+				Title: fmt.Sprintf("File:Image %d.png", i),
+			}
+		}
+	}()
+
+	paths := map[string]bool{}
+	for res := range d.DownloadAll(context.Background(), images) {
+		if res.Err != nil {
+			t.Errorf("%s: %v", res.Image.URL, res.Err)
+			continue
+		}
+		paths[res.Entry.Path] = true
+	}
+
+	if len(paths) != 8 || !paths["Image_0.png"] {
+		t.Errorf("unexpected paths %v", paths)
+	}
+	if most > 2 {
+		t.Errorf("expected at most 2 downloads at once but got %d", most)
+	}
+}
@@ -0,0 +1,192 @@
+package download
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Status is the state of a single download
+type Status string
+
+const (
+	// Pending downloads were started but haven't finished. After a crash,
+	// they are retried.
+	Pending Status = "pending"
+
+	// Done downloads were saved and their checksum recorded
+	Done Status = "done"
+
+	// Failed downloads returned an error or no longer match their
+	// checksum
+	Failed Status = "failed"
+)
+
+// Entry records the download of one URL
+type Entry struct {
+	// URL is where the file was downloaded from
+	URL string `json:"url"`
+
+	// Path is where the file was saved, relative to the download
+	// directory
+	Path string `json:"path"`
+
+	// SHA1 is the hex encoded SHA-1 checksum of the file, once it's done
+	SHA1 string `json:"sha1,omitempty"`
+
+	// Status is the state of the download
+	Status Status `json:"status"`
+
+	// Error describes why the download failed
+	Error string `json:"error,omitempty"`
+}
+
+// Manifest is a crash-safe record of downloads, stored as a JSON file. Every
+// change is written to a temporary file which is then renamed over the
+// manifest, so the file on disk is always complete: either from before or
+// after the change, never in between. It's safe to use from many
+// goroutines at once.
+type Manifest struct {
+	path    string
+	entries map[string]Entry
+
+	// paths maps the folded path of every entry to its URL, to find
+	// collisions
+	paths map[string]string
+
+	mutex sync.Mutex
+}
+
+// OpenManifest loads the manifest at path, or starts an empty one if it
+// doesn't exist yet
+func OpenManifest(path string) (*Manifest, error) {
+	m := &Manifest{path: path, entries: map[string]Entry{}, paths: map[string]string{}}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	err = json.Unmarshal(b, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		m.entries[e.URL] = e
+		m.paths[fold(e.Path)] = e.URL
+	}
+
+	return m, nil
+}
+
+// Get returns the entry for url, if there is one
+func (m *Manifest) Get(url string) (Entry, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	e, ok := m.entries[url]
+
+	return e, ok
+}
+
+// Entries returns every entry, sorted by URL
+func (m *Manifest) Entries() []Entry {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.sorted()
+}
+
+// Set records e and saves the manifest
+func (m *Manifest) Set(e Entry) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.entries[e.URL] = e
+	m.paths[fold(e.Path)] = e.URL
+
+	return m.save()
+}
+
+// claim records a pending entry for url, saved as name, and returns it. If
+// another URL is already saved as name, a name with a suffix derived from
+// url is used instead, so the same URL always gets the same name no matter
+// which is downloaded first.
+func (m *Manifest) claim(url, name string) (Entry, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if owner, ok := m.paths[fold(name)]; ok && owner != url {
+		sum := sha1.Sum([]byte(url))
+		ext := path.Ext(name)
+		name = strings.TrimSuffix(name, ext) + "-" + hex.EncodeToString(sum[:4]) + ext
+	}
+
+	e := Entry{URL: url, Path: name, Status: Pending}
+	m.entries[url] = e
+	m.paths[fold(name)] = url
+
+	return e, m.save()
+}
+
+// fold returns the key of p in Manifest.paths. Paths that only differ by
+// case are the same file on some file systems, so they collide too.
+func fold(p string) string {
+	return strings.ToLower(p)
+}
+
+// sorted returns the entries sorted by URL, so the file is stable
+func (m *Manifest) sorted() []Entry {
+	entries := make([]Entry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].URL < entries[j].URL
+	})
+
+	return entries
+}
+
+// save atomically replaces the manifest file with the current entries
+func (m *Manifest) save() error {
+	b, err := json.MarshalIndent(m.sorted(), "", "\t")
+	if err != nil {
+		return err
+	}
+
+	// The temporary file must be in the same directory for the rename to
+	// be atomic
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), filepath.Base(m.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if err == nil {
+		// Make sure the contents are on disk before they replace the old
+		// manifest
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), m.path)
+}
@@ -0,0 +1,54 @@
+package wikimg
+
+import (
+	"math"
+	"runtime"
+)
+
+// Executor bounds how many CPU-heavy tasks, like decoding and scanning
+// images, run at once. Sharing one between Pullers leaves the rest of the
+// CPU free for other work, so e.g. an HTTP server stays responsive while a
+// background cycle decodes a large batch. A nil Executor doesn't limit
+// anything.
+type Executor struct {
+	slots chan struct{}
+}
+
+// NewExecutor creates an Executor that runs at most n tasks at once. n is
+// at least 1.
+func NewExecutor(n int) *Executor {
+	return &Executor{slots: make(chan struct{}, max(1, n))}
+}
+
+// CPUFraction returns the number of tasks that use about fraction of the
+// available CPU (i.e., GOMAXPROCS), suitable for NewExecutor. It's always at
+// least 1.
+func CPUFraction(fraction float64) int {
+	return max(1, int(math.Round(fraction*float64(runtime.GOMAXPROCS(0)))))
+}
+
+// Size returns the number of tasks e runs at once, or 0 if e is nil
+func (e *Executor) Size() int {
+	if e == nil {
+		return 0
+	}
+
+	return cap(e.slots)
+}
+
+// Do runs fn once a slot is free, returning its error. If cancel is closed
+// first, fn isn't run and Canceled is returned.
+func (e *Executor) Do(cancel <-chan struct{}, fn func() error) error {
+	if e == nil {
+		return fn()
+	}
+
+	select {
+	case e.slots <- struct{}{}:
+	case <-cancel:
+		return Canceled
+	}
+	defer func() { <-e.slots }()
+
+	return fn()
+}
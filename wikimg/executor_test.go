@@ -0,0 +1,85 @@
+package wikimg
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExecutor(t *testing.T) {
+	e := NewExecutor(2)
+
+	var running, most int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			e.Do(nil, func() error {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					m := atomic.LoadInt32(&most)
+					if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+						break
+					}
+				}
+
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if most != 2 {
+		t.Errorf("expected at most 2 tasks at once but got %d", most)
+	}
+
+	// Errors are passed through
+	failed := errors.New("failed")
+	if err := e.Do(nil, func() error { return failed }); err != failed {
+		t.Errorf("expected %v but got %v", failed, err)
+	}
+
+	// Waiting for a slot can be canceled
+	full := NewExecutor(1)
+	release := make(chan struct{})
+	go full.Do(nil, func() error {
+		<-release
+		return nil
+	})
+	for len(full.slots) < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel := make(chan struct{})
+	close(cancel)
+	ran := false
+	if err := full.Do(cancel, func() error { ran = true; return nil }); err != Canceled || ran {
+		t.Errorf("expected canceled task not to run but got %v, ran %v", err, ran)
+	}
+	close(release)
+
+	// A nil executor runs everything right away
+	var none *Executor
+	if err := none.Do(nil, func() error { return nil }); err != nil || none.Size() != 0 {
+		t.Errorf("unexpected nil executor %v, size %d", err, none.Size())
+	}
+}
+
+func TestCPUFraction(t *testing.T) {
+	procs := runtime.GOMAXPROCS(0)
+
+	if n := CPUFraction(1); n != procs {
+		t.Errorf("expected all %d procs but got %d", procs, n)
+	}
+	if n := CPUFraction(0); n != 1 {
+		t.Errorf("expected at least 1 but got %d", n)
+	}
+}
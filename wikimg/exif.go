@@ -0,0 +1,245 @@
+package wikimg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"strings"
+	"time"
+)
+
+// EXIF tags we read
+const (
+	exifMake         = 0x010f
+	exifModel        = 0x0110
+	exifOrientation  = 0x0112
+	exifDateTime     = 0x0132
+	exifIFDPointer   = 0x8769
+	exifDateOriginal = 0x9003
+
+	// exifTimeLayout is the format of EXIF dates
+	exifTimeLayout = "2006:01:02 15:04:05"
+)
+
+// EXIF is metadata read from a JPEG's EXIF block
+type EXIF struct {
+	// Orientation is how the stored image must be transformed to be shown
+	// upright, from 1 (already upright) to 8
+	Orientation int `json:"orientation,omitempty"`
+
+	// Make and Model are the camera's manufacturer and model
+	Make  string `json:"make,omitempty"`
+	Model string `json:"model,omitempty"`
+
+	// Taken is when the picture was taken, according to the camera's
+	// clock. EXIF dates have no time zone, so it's in UTC.
+	Taken time.Time `json:"taken,omitzero"`
+}
+
+// parseEXIF reads the EXIF block from the start of a JPEG file. It returns
+// nil if there isn't one, or if it can't be parsed. Only the bytes before the
+// image data are needed.
+func parseEXIF(b []byte) *EXIF {
+	if len(b) < 2 || b[0] != 0xff || b[1] != 0xd8 {
+		return nil
+	}
+
+	// Walk the segments at the start of the file until we find the APP1
+	// segment with the EXIF block, or reach the image data
+	for i := 2; i+4 <= len(b); {
+		if b[i] != 0xff {
+			return nil
+		}
+
+		marker := b[i+1]
+		size := int(binary.BigEndian.Uint16(b[i+2:]))
+		if marker == 0xda || size < 2 || i+2+size > len(b) {
+			return nil
+		}
+
+		seg := b[i+4 : i+2+size]
+		if marker == 0xe1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return parseTIFF(seg[6:])
+		}
+
+		i += 2 + size
+	}
+
+	return nil
+}
+
+// parseTIFF reads the tags we're interested in from the TIFF structure
+// inside an EXIF block
+func parseTIFF(b []byte) *EXIF {
+	if len(b) < 8 {
+		return nil
+	}
+
+	var order binary.ByteOrder
+	switch string(b[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil
+	}
+
+	x := &EXIF{}
+	tags := readIFD(b, order, int(order.Uint32(b[4:])))
+
+	if v, ok := tags[exifOrientation]; ok {
+		x.Orientation = int(v.short(order))
+	}
+	x.Make = tags[exifMake].ascii(b, order)
+	x.Model = tags[exifModel].ascii(b, order)
+
+	// Prefer when the picture was taken over when the file was changed
+	taken := tags[exifDateTime].ascii(b, order)
+	if v, ok := tags[exifIFDPointer]; ok {
+		sub := readIFD(b, order, int(v.long(order)))
+		if t := sub[exifDateOriginal].ascii(b, order); len(t) > 0 {
+			taken = t
+		}
+	}
+	x.Taken, _ = time.Parse(exifTimeLayout, taken)
+
+	return x
+}
+
+// ifdEntry is the raw type, count and value (or offset to the value) of a
+// tag
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// short returns the entry's value as a SHORT
+func (e ifdEntry) short(order binary.ByteOrder) uint16 {
+	if e.typ != 3 || len(e.value) < 2 {
+		return 0
+	}
+
+	return order.Uint16(e.value)
+}
+
+// long returns the entry's value as a LONG
+func (e ifdEntry) long(order binary.ByteOrder) uint32 {
+	if e.typ != 4 || len(e.value) < 4 {
+		return 0
+	}
+
+	return order.Uint32(e.value)
+}
+
+// ascii returns the entry's value as a string. Values longer than four
+// bytes are stored elsewhere in b.
+func (e ifdEntry) ascii(b []byte, order binary.ByteOrder) string {
+	if e.typ != 2 || len(e.value) < 4 {
+		return ""
+	}
+
+	s := e.value[:min(e.count, 4)]
+	if e.count > 4 {
+		off := int(order.Uint32(e.value))
+		if off < 0 || off+int(e.count) > len(b) {
+			return ""
+		}
+		s = b[off : off+int(e.count)]
+	}
+
+	return strings.TrimSpace(strings.TrimRight(string(s), "\x00"))
+}
+
+// readIFD returns the entries of the IFD at offset in b, by tag
+func readIFD(b []byte, order binary.ByteOrder, offset int) map[uint16]ifdEntry {
+	tags := map[uint16]ifdEntry{}
+
+	if offset < 0 || offset+2 > len(b) {
+		return tags
+	}
+
+	n := int(order.Uint16(b[offset:]))
+	for i := 0; i < n; i++ {
+		start := offset + 2 + i*12
+		if start+12 > len(b) {
+			break
+		}
+
+		tags[order.Uint16(b[start:])] = ifdEntry{
+			typ:   order.Uint16(b[start+2:]),
+			count: order.Uint32(b[start+4:]),
+			value: b[start+8 : start+12],
+		}
+	}
+
+	return tags
+}
+
+// orientedImage is a view of another image transformed according to an EXIF
+// orientation, so it appears upright. Pixels are looked up on demand, so no
+// new image is allocated.
+type orientedImage struct {
+	src         image.Image
+	orientation int
+}
+
+// orient returns img transformed to be upright according to orientation
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	return &orientedImage{src: img, orientation: orientation}
+}
+
+// ColorModel returns the color model of the source image
+func (o *orientedImage) ColorModel() color.Model {
+	return o.src.ColorModel()
+}
+
+// Bounds returns the size of the upright image. Orientations 5 to 8 swap
+// the width and height.
+func (o *orientedImage) Bounds() image.Rectangle {
+	r := o.src.Bounds()
+	if o.orientation >= 5 {
+		return image.Rect(0, 0, r.Dy(), r.Dx())
+	}
+
+	return image.Rect(0, 0, r.Dx(), r.Dy())
+}
+
+// At returns the source pixel shown at x, y in the upright image
+func (o *orientedImage) At(x, y int) color.Color {
+	r := o.src.Bounds()
+	w, h := r.Dx(), r.Dy()
+
+	var sx, sy int
+	switch o.orientation {
+	case 2:
+		// Mirrored horizontally
+		sx, sy = w-1-x, y
+	case 3:
+		// Rotated 180 degrees
+		sx, sy = w-1-x, h-1-y
+	case 4:
+		// Mirrored vertically
+		sx, sy = x, h-1-y
+	case 5:
+		// Mirrored along the top left to bottom right diagonal
+		sx, sy = y, x
+	case 6:
+		// Needs rotating 90 degrees clockwise
+		sx, sy = y, h-1-x
+	case 7:
+		// Mirrored along the top right to bottom left diagonal
+		sx, sy = w-1-y, h-1-x
+	case 8:
+		// Needs rotating 90 degrees counterclockwise
+		sx, sy = w-1-y, x
+	}
+
+	return o.src.At(r.Min.X+sx, r.Min.Y+sy)
+}
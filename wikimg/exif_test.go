@@ -0,0 +1,149 @@
+package wikimg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// exifJPEG returns a JPEG of img with an EXIF block containing orientation,
+// a camera model and the date it was taken
+func exifJPEG(t *testing.T, img image.Image, orientation uint16) []byte {
+	be := binary.BigEndian
+	model := "TestCam\x00"
+	taken := "2024:05:06 07:08:09\x00"
+
+	// IFD0 at 8 has 3 entries (2+36+4 bytes), then the model, then the
+	// EXIF IFD with 1 entry (2+12+4 bytes), then the date
+	modelOff := 8 + 42
+	subOff := modelOff + len(model)
+	takenOff := subOff + 18
+
+	tiff := &bytes.Buffer{}
+	tiff.WriteString("MM")
+	binary.Write(tiff, be, []uint16{42})
+	binary.Write(tiff, be, []uint32{8})
+
+	binary.Write(tiff, be, []uint16{3})
+	binary.Write(tiff, be, []uint16{exifModel, 2})
+	binary.Write(tiff, be, []uint32{uint32(len(model)), uint32(modelOff)})
+	binary.Write(tiff, be, []uint16{exifOrientation, 3})
+	binary.Write(tiff, be, []uint32{1})
+	binary.Write(tiff, be, []uint16{orientation, 0})
+	binary.Write(tiff, be, []uint16{exifIFDPointer, 4})
+	binary.Write(tiff, be, []uint32{1, uint32(subOff)})
+	binary.Write(tiff, be, []uint32{0})
+	tiff.WriteString(model)
+
+	binary.Write(tiff, be, []uint16{1})
+	binary.Write(tiff, be, []uint16{exifDateOriginal, 2})
+	binary.Write(tiff, be, []uint32{uint32(len(taken)), uint32(takenOff)})
+	binary.Write(tiff, be, []uint32{0})
+	tiff.WriteString(taken)
+
+	enc := &bytes.Buffer{}
+	err := jpeg.Encode(enc, img, &jpeg.Options{Quality: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Insert the APP1 segment right after the start of image marker
+	out := &bytes.Buffer{}
+	out.Write(enc.Bytes()[:2])
+	out.Write([]byte{0xff, 0xe1})
+	binary.Write(out, be, []uint16{uint16(2 + 6 + tiff.Len())})
+	out.WriteString("Exif\x00\x00")
+	out.Write(tiff.Bytes())
+	out.Write(enc.Bytes()[2:])
+
+	return out.Bytes()
+}
+
+func TestReadEXIF(t *testing.T) {
+	// Red on the left, blue on the right
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 8))
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 8; y++ {
+			img.Set(x, y, color.NRGBA{255, 0, 0, 255})
+			if x >= 8 {
+				img.Set(x, y, color.NRGBA{0, 0, 255, 255})
+			}
+		}
+	}
+	b := exifJPEG(t, img, 6)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(b)
+	}))
+	defer ts.Close()
+
+	p := NewPuller(1)
+
+	// Without ReadEXIF, the image is as stored
+	info, err := p.FirstColor(ts.URL)
+	if err != nil || info.Width != 16 || info.EXIF != nil {
+		t.Errorf("unexpected %dx%d image with %+v, %v", info.Width, info.Height, info.EXIF, err)
+	}
+
+	p.ReadEXIF = true
+	info, err = p.FirstColor(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Width != 8 || info.Height != 16 {
+		t.Errorf("expected upright 8x16 image but got %dx%d", info.Width, info.Height)
+	}
+
+	expected := EXIF{
+		Orientation: 6,
+		Model:       "TestCam",
+		Taken:       time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+	}
+	if info.EXIF == nil || *info.EXIF != expected {
+		t.Errorf("expected %+v but got %+v", expected, info.EXIF)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// 3x2 image where each pixel's red value is its position
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for i := 0; i < 6; i++ {
+		img.Set(i%3, i/3, color.NRGBA{uint8(i), 0, 0, 255})
+	}
+
+	// The positions of the source pixels read left to right, top to bottom
+	// in the upright image
+	tests := map[int][]uint8{
+		1: {0, 1, 2, 3, 4, 5},
+		2: {2, 1, 0, 5, 4, 3},
+		3: {5, 4, 3, 2, 1, 0},
+		4: {3, 4, 5, 0, 1, 2},
+		5: {0, 3, 1, 4, 2, 5},
+		6: {3, 0, 4, 1, 5, 2},
+		7: {5, 2, 4, 1, 3, 0},
+		8: {2, 5, 1, 4, 0, 3},
+	}
+
+	for o, expected := range tests {
+		up := orient(img, o)
+		r := up.Bounds()
+
+		var got []uint8
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				got = append(got, color.NRGBAModel.Convert(up.At(x, y)).(color.NRGBA).R)
+			}
+		}
+
+		if !bytes.Equal(got, expected) {
+			t.Errorf("orientation %d: expected %v but got %v", o, expected, got)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package wikimg
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ExportRow is the color of an image flattened for export to spreadsheets
+// and data pipelines, which don't need everything in a Record
+type ExportRow struct {
+	// URL is the image URL
+	URL string `json:"url"`
+
+	// XTerm is the xterm256 index of the color, or -1 if it isn't mapped
+	// to a palette
+	XTerm int `json:"xterm"`
+
+	// Hex is the color, e.g., "#ff0000"
+	Hex string `json:"hex"`
+
+	// Timestamp is when the image was uploaded, if known
+	Timestamp time.Time `json:"timestamp,omitzero"`
+}
+
+// NewExportRow flattens rec. It returns false if rec hasn't been analyzed
+// or has an error.
+func NewExportRow(rec Record) (ExportRow, bool) {
+	if rec.Color == nil || len(rec.Error) > 0 {
+		return ExportRow{}, false
+	}
+
+	ts := rec.Uploaded
+	if ts.IsZero() {
+		ts = rec.Color.Uploaded
+	}
+
+	return ExportRow{URL: rec.URL, XTerm: rec.Color.Index, Hex: rec.Color.Hex, Timestamp: ts}, true
+}
+
+// ExportWriter writes ExportRows in some format. Close must be called
+// after the last row to complete the output, but it doesn't close the
+// underlying writer.
+type ExportWriter interface {
+	Write(row ExportRow) error
+	Close() error
+}
+
+// ExportFormats are the formats NewExportWriter supports
+var ExportFormats = []string{"csv", "json", "ndjson"}
+
+// NewExportWriter creates an ExportWriter for the named format (see
+// ExportFormats) that writes to w
+func NewExportWriter(format string, w io.Writer) (ExportWriter, error) {
+	switch format {
+	case "csv":
+		return NewCSVWriter(w), nil
+	case "json":
+		return NewJSONWriter(w), nil
+	case "ndjson":
+		return NewNDJSONWriter(w), nil
+	}
+
+	return nil, fmt.Errorf("wikimg: unknown export format %q", format)
+}
+
+// CSVWriter writes ExportRows as CSV with a header row. Timestamps are in
+// RFC 3339 format, or empty if unknown.
+type CSVWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+// NewCSVWriter creates a CSVWriter that writes to w
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// Write writes row, after the header if it's the first
+func (cw *CSVWriter) Write(row ExportRow) error {
+	if !cw.header {
+		cw.header = true
+
+		err := cw.w.Write([]string{"url", "xterm", "hex", "timestamp"})
+		if err != nil {
+			return err
+		}
+	}
+
+	var ts string
+	if !row.Timestamp.IsZero() {
+		ts = row.Timestamp.Format(time.RFC3339)
+	}
+
+	err := cw.w.Write([]string{row.URL, strconv.Itoa(row.XTerm), row.Hex, ts})
+	if err != nil {
+		return err
+	}
+
+	// Flush each row, so rows are seen as they're found when streaming
+	cw.w.Flush()
+
+	return cw.w.Error()
+}
+
+// Close writes the header if no rows were written
+func (cw *CSVWriter) Close() error {
+	if !cw.header {
+		cw.header = true
+
+		cw.w.Write([]string{"url", "xterm", "hex", "timestamp"})
+		cw.w.Flush()
+	}
+
+	return cw.w.Error()
+}
+
+// JSONWriter writes ExportRows as a JSON array, one element per line
+type JSONWriter struct {
+	w     io.Writer
+	count int
+}
+
+// NewJSONWriter creates a JSONWriter that writes to w
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{w: w}
+}
+
+// Write writes row as the next element of the array
+func (jw *JSONWriter) Write(row ExportRow) error {
+	b, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if jw.count == 0 {
+		sep = "[\n"
+	}
+	jw.count++
+
+	_, err = fmt.Fprintf(jw.w, "%s%s", sep, b)
+
+	return err
+}
+
+// Close ends the array
+func (jw *JSONWriter) Close() error {
+	var err error
+	if jw.count == 0 {
+		_, err = io.WriteString(jw.w, "[]\n")
+	} else {
+		_, err = io.WriteString(jw.w, "\n]\n")
+	}
+
+	return err
+}
+
+// NDJSONWriter writes ExportRows as newline-delimited JSON, one row per
+// line
+type NDJSONWriter struct {
+	enc *json.Encoder
+}
+
+// NewNDJSONWriter creates an NDJSONWriter that writes to w
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{enc: json.NewEncoder(w)}
+}
+
+// Write writes row on its own line
+func (nw *NDJSONWriter) Write(row ExportRow) error {
+	return nw.enc.Encode(row)
+}
+
+// Close does nothing, since each line is complete
+func (nw *NDJSONWriter) Close() error {
+	return nil
+}
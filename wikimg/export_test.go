@@ -0,0 +1,95 @@
+package wikimg
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// exportRows are written by the export tests
+var exportRows = []ExportRow{
+	{URL: "https://example.com/a,b.png", XTerm: 9, Hex: "#ff0000", Timestamp: time.Date(2016, 3, 1, 12, 0, 0, 0, time.UTC)},
+	{URL: "https://example.com/c.png", XTerm: -1, Hex: "#123456"},
+}
+
+func TestNewExportRow(t *testing.T) {
+	uploaded := time.Date(2016, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	row, ok := NewExportRow(Record{URL: "a", Uploaded: uploaded, Color: &ColorInfo{Index: 9, Hex: "#ff0000"}})
+	if !ok || row != (ExportRow{URL: "a", XTerm: 9, Hex: "#ff0000", Timestamp: uploaded}) {
+		t.Errorf("unexpected row %+v", row)
+	}
+
+	for _, rec := range []Record{{URL: "a"}, {URL: "b", Color: &ColorInfo{}, Error: "failed"}} {
+		if _, ok := NewExportRow(rec); ok {
+			t.Errorf("expected no row for %+v", rec)
+		}
+	}
+}
+
+func TestExportWriters(t *testing.T) {
+	expected := map[string]string{
+		"csv": "url,xterm,hex,timestamp\n" +
+			`"https://example.com/a,b.png",9,#ff0000,2016-03-01T12:00:00Z` + "\n" +
+			"https://example.com/c.png,-1,#123456,\n",
+		"json": "[\n" +
+			`{"url":"https://example.com/a,b.png","xterm":9,"hex":"#ff0000","timestamp":"2016-03-01T12:00:00Z"},` + "\n" +
+			`{"url":"https://example.com/c.png","xterm":-1,"hex":"#123456"}` + "\n]\n",
+		"ndjson": `{"url":"https://example.com/a,b.png","xterm":9,"hex":"#ff0000","timestamp":"2016-03-01T12:00:00Z"}` + "\n" +
+			`{"url":"https://example.com/c.png","xterm":-1,"hex":"#123456"}` + "\n",
+	}
+
+	for _, format := range ExportFormats {
+		buf := &bytes.Buffer{}
+		w, err := NewExportWriter(format, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, row := range exportRows {
+			err = w.Write(row)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err = w.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if buf.String() != expected[format] {
+			t.Errorf("%s: expected\n%s\nbut got\n%s", format, expected[format], buf)
+		}
+	}
+
+	var rows []ExportRow
+	err := json.Unmarshal([]byte(expected["json"]), &rows)
+	if err != nil || len(rows) != 2 {
+		t.Errorf("expected a valid JSON array but got %v, %v", rows, err)
+	}
+
+	_, err = NewExportWriter("xml", &bytes.Buffer{})
+	if err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestExportWritersEmpty(t *testing.T) {
+	expected := map[string]string{
+		"csv":    "url,xterm,hex,timestamp\n",
+		"json":   "[]\n",
+		"ndjson": "",
+	}
+
+	for _, format := range ExportFormats {
+		buf := &bytes.Buffer{}
+		w, _ := NewExportWriter(format, buf)
+		w.Close()
+
+		if buf.String() != expected[format] {
+			t.Errorf("%s: expected %q but got %q", format, expected[format], buf)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+// Package featured is a wikimg.Source of the curated images on Wikimedia
+// Commons, rather than the latest uploads, which are often noisy scans and
+// make for duller demos. It can pull the Picture of the Day, going back a
+// day at a time, from the Wikimedia feed API, or the images most recently
+// added to a category of curated images, Commons' featured pictures by
+// default:
+//
+//	src := featured.NewSource(featured.PictureOfTheDay, 30)
+//
+//	for {
+//		img, err := src.Next(ctx)
+//		if err == wikimg.EndOfResults {
+//			break
+//		}
+//		...
+//	}
+//
+// The images are the originals, which can be large, so analyze them with a
+// wikimg.Puller that uses thumbnails (see Puller.UseThumbnails).
+package featured
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// Feeds of images
+const (
+	// PictureOfTheDay is the Picture of the Day on Commons, newest first
+	PictureOfTheDay = "potd"
+
+	// Pictures are the images most recently added to Category, newest
+	// first
+	Pictures = "featured"
+)
+
+const (
+	// feedURL is the Wikimedia feed API's featured content. The Picture
+	// of the Day is the same for every wiki.
+	feedURL = "https://api.wikimedia.org/feed/v1/wikipedia/en/featured"
+
+	// apiURL is the Commons API
+	apiURL = "https://commons.wikimedia.org/w/api.php"
+
+	// DefaultCategory is the default for Source.Category
+	DefaultCategory = "Category:Featured pictures on Wikimedia Commons"
+
+	// DefaultPerPage is the default for Source.PerPage
+	DefaultPerPage = 50
+
+	// maxPerPage is the most titles the API describes at once
+	maxPerPage = 50
+
+	// maxMissing is how many days in a row may have no Picture of the Day
+	// before we decide there are no more
+	maxMissing = 7
+
+	// maxBody is the most of an API response we read
+	maxBody = 10 << 20
+)
+
+// APIError is an error returned by the Commons API
+type APIError struct {
+	Code string
+	Info string
+}
+
+// Error describes the error
+func (e *APIError) Error() string {
+	return fmt.Sprintf("featured: API error %s: %s", e.Code, e.Info)
+}
+
+// dayResp is the part of the feed API's featured content for a day that we
+// use
+type dayResp struct {
+	Image *struct {
+		Title string
+		Image struct {
+			Source string
+		}
+	}
+}
+
+// apiResp is a response of the Commons API, with formatversion=2
+type apiResp struct {
+	Error    *APIError
+	Continue map[string]json.RawMessage
+	Query    struct {
+		CategoryMembers []struct {
+			Title string
+		}
+		Pages []struct {
+			Title     string
+			ImageInfo []struct {
+				URL       string
+				Timestamp time.Time
+				User      string
+			}
+		}
+	}
+}
+
+// Source pulls curated images from Commons. Set its fields before the
+// first call to Next. Like a wikimg.Puller, it must only be used by one
+// goroutine at a time.
+type Source struct {
+	// Feed is where images come from, PictureOfTheDay or Pictures
+	Feed string
+
+	// Start is the day of the first Picture of the Day, going back from
+	// it. Zero means today, in UTC.
+	Start time.Time
+
+	// Category is the category whose images are returned by the Pictures
+	// feed, e.g., "Category:Quality images". Empty means
+	// DefaultCategory.
+	Category string
+
+	// PerPage is the number of Pictures requested at once, up to 50. Zero
+	// means DefaultPerPage.
+	PerPage int
+
+	// UserAgent identifies us to the APIs, as Wikimedia asks. Empty means
+	// wikimg.UserAgent.
+	UserAgent string
+
+	// FeedURL and APIURL are the feed API's and the Commons API's URLs,
+	// for testing. Empty means Wikimedia's.
+	FeedURL, APIURL string
+
+	// Client is the HTTP client used to call the APIs. If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	max   int
+	count int
+
+	// day is the next day to get the Picture of the Day of
+	day time.Time
+
+	// images are the images of the last page of Pictures, and cont
+	// continues the category after them. done is set once there are no
+	// more.
+	images []wikimg.ImageInfo
+	cont   map[string]json.RawMessage
+	done   bool
+}
+
+// NewSource creates a Source that returns at most max images of feed
+func NewSource(feed string, max int) *Source {
+	return &Source{Feed: feed, max: max}
+}
+
+// Next returns the next image, or wikimg.EndOfResults once max images have
+// been returned or there are no more
+func (s *Source) Next(ctx context.Context) (wikimg.ImageInfo, error) {
+	if s.count >= s.max {
+		return wikimg.ImageInfo{}, wikimg.EndOfResults
+	}
+
+	var img wikimg.ImageInfo
+	var err error
+	switch s.Feed {
+	case PictureOfTheDay:
+		img, err = s.nextDay(ctx)
+	case Pictures:
+		img, err = s.nextPicture(ctx)
+	default:
+		err = fmt.Errorf("featured: unknown feed %q (must be %s or %s)", s.Feed, PictureOfTheDay, Pictures)
+	}
+	if err != nil {
+		return wikimg.ImageInfo{}, err
+	}
+	s.count++
+
+	return img, nil
+}
+
+// nextDay returns the Picture of the Day of the next day back that has
+// one
+func (s *Source) nextDay(ctx context.Context) (wikimg.ImageInfo, error) {
+	if s.day.IsZero() {
+		start := s.Start
+		if start.IsZero() {
+			start = time.Now()
+		}
+		s.day = start.UTC().Truncate(24 * time.Hour)
+	}
+
+	for missing := 0; missing < maxMissing; missing++ {
+		day := s.day
+		s.day = s.day.AddDate(0, 0, -1)
+
+		u := s.FeedURL
+		if len(u) < 1 {
+			u = feedURL
+		}
+
+		var r dayResp
+		found, err := s.get(ctx, u+day.Format("/2006/01/02"), &r)
+		if err != nil {
+			return wikimg.ImageInfo{}, err
+		}
+		if !found || r.Image == nil || len(r.Image.Image.Source) < 1 {
+			continue
+		}
+
+		return wikimg.ImageInfo{
+			URL:   r.Image.Image.Source,
+			Title: r.Image.Title,
+		}, nil
+	}
+
+	return wikimg.ImageInfo{}, wikimg.EndOfResults
+}
+
+// nextPicture returns the next image added to the category
+func (s *Source) nextPicture(ctx context.Context) (wikimg.ImageInfo, error) {
+	for len(s.images) < 1 {
+		if s.done {
+			return wikimg.ImageInfo{}, wikimg.EndOfResults
+		}
+
+		err := s.query(ctx)
+		if err != nil {
+			return wikimg.ImageInfo{}, err
+		}
+	}
+
+	img := s.images[0]
+	s.images = s.images[1:]
+
+	return img, nil
+}
+
+// query lists the next page of the category and describes its images
+func (s *Source) query(ctx context.Context) error {
+	perPage := s.PerPage
+	if perPage < 1 {
+		perPage = DefaultPerPage
+	}
+	category := s.Category
+	if len(category) < 1 {
+		category = DefaultCategory
+	}
+
+	params := url.Values{}
+	params.Set("action", "query")
+	params.Set("format", "json")
+	params.Set("formatversion", "2")
+	params.Set("list", "categorymembers")
+	params.Set("cmtitle", category)
+	params.Set("cmtype", "file")
+	params.Set("cmsort", "timestamp")
+	params.Set("cmdir", "descending")
+	params.Set("cmlimit", strconv.Itoa(min(perPage, maxPerPage, s.max-s.count)))
+	for k, v := range s.cont {
+		var str string
+		if json.Unmarshal(v, &str) != nil {
+			str = string(v)
+		}
+		params.Set(k, str)
+	}
+
+	var members apiResp
+	err := s.call(ctx, params, &members)
+	if err != nil {
+		return err
+	}
+
+	s.cont = members.Continue
+	s.done = len(s.cont) < 1
+	if len(members.Query.CategoryMembers) < 1 {
+		s.done = true
+		return nil
+	}
+
+	// The category lists titles, their URLs need another request
+	titles := make([]string, len(members.Query.CategoryMembers))
+	for i, m := range members.Query.CategoryMembers {
+		titles[i] = m.Title
+	}
+
+	params = url.Values{}
+	params.Set("action", "query")
+	params.Set("format", "json")
+	params.Set("formatversion", "2")
+	params.Set("prop", "imageinfo")
+	params.Set("iiprop", "url|timestamp|user")
+	params.Set("titles", strings.Join(titles, "|"))
+
+	var info apiResp
+	err = s.call(ctx, params, &info)
+	if err != nil {
+		return err
+	}
+
+	byTitle := map[string]wikimg.ImageInfo{}
+	for _, page := range info.Query.Pages {
+		if len(page.ImageInfo) < 1 || len(page.ImageInfo[0].URL) < 1 {
+			continue
+		}
+
+		ii := page.ImageInfo[0]
+		byTitle[page.Title] = wikimg.ImageInfo{
+			URL:      ii.URL,
+			Title:    page.Title,
+			Uploaded: ii.Timestamp,
+			Uploader: ii.User,
+		}
+	}
+
+	// In the category's order, newest first
+	for _, title := range titles {
+		if img, ok := byTitle[title]; ok {
+			s.images = append(s.images, img)
+		}
+	}
+
+	return nil
+}
+
+// call calls the Commons API with params, decoding the response into r
+func (s *Source) call(ctx context.Context, params url.Values, r *apiResp) error {
+	u := s.APIURL
+	if len(u) < 1 {
+		u = apiURL
+	}
+
+	_, err := s.get(ctx, u+"?"+params.Encode(), r)
+	if err != nil {
+		return err
+	}
+	if r.Error != nil {
+		return r.Error
+	}
+
+	return nil
+}
+
+// get decodes the JSON at u into v. It returns false if there's nothing
+// at u.
+func (s *Source) get(ctx context.Context, u string, v any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return false, err
+	}
+
+	ua := s.UserAgent
+	if len(ua) < 1 {
+		ua = wikimg.UserAgent
+	}
+	req.Header.Set("User-Agent", ua)
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("featured: API returned %s", resp.Status)
+	}
+
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxBody)).Decode(v)
+	if err != nil {
+		return false, fmt.Errorf("featured: couldn't parse API response: %v", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,133 @@
+package featured
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// all returns every image from src
+func all(t *testing.T, src *Source) []wikimg.ImageInfo {
+	t.Helper()
+
+	var images []wikimg.ImageInfo
+	for {
+		img, err := src.Next(context.Background())
+		if err == wikimg.EndOfResults {
+			return images
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		images = append(images, img)
+	}
+}
+
+func TestPictureOfTheDay(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2024/03/10", "/2024/03/08":
+			day := strings.ReplaceAll(r.URL.Path[1:], "/", "-")
+			fmt.Fprintf(w, `{"tfa": {}, "image": {"title": "File:%s.jpg", "image": {"source": "http://example.com/%s.jpg", "width": 100}}}`, day, day)
+		case "/2024/03/09":
+			// A day without a picture
+			fmt.Fprint(w, `{"tfa": {}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	src := NewSource(PictureOfTheDay, 10)
+	src.FeedURL = ts.URL
+	src.Start = time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC)
+
+	// Days without a picture are skipped, until there are none for a week
+	images := all(t, src)
+	if len(images) != 2 || images[0].URL != "http://example.com/2024-03-10.jpg" || images[0].Title != "File:2024-03-10.jpg" ||
+		images[1].URL != "http://example.com/2024-03-08.jpg" {
+		t.Errorf("unexpected images %+v", images)
+	}
+
+	// max limits the days
+	src = NewSource(PictureOfTheDay, 1)
+	src.FeedURL = ts.URL
+	src.Start = time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	if images := all(t, src); len(images) != 1 {
+		t.Errorf("expected 1 image but got %+v", images)
+	}
+}
+
+func TestPictures(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		switch {
+		case r.FormValue("list") == "categorymembers":
+			if r.FormValue("cmtitle") != DefaultCategory || r.FormValue("cmlimit") != "2" {
+				fmt.Fprint(w, `{"error": {"code": "badrequest", "info": "unexpected request"}}`)
+				return
+			}
+
+			if r.FormValue("cmcontinue") == "" {
+				fmt.Fprint(w, `{"continue": {"cmcontinue": "next", "continue": "-||"}, "query": {"categorymembers": [{"title": "File:C.jpg"}, {"title": "File:B.jpg"}]}}`)
+			} else {
+				fmt.Fprint(w, `{"query": {"categorymembers": [{"title": "File:A.jpg"}, {"title": "File:Gone.jpg"}]}}`)
+			}
+
+		case r.FormValue("prop") == "imageinfo":
+			// Pages come back in any order, and deleted files have no
+			// image info
+			fmt.Fprint(w, `{"query": {"pages": [`)
+			for i, title := range strings.Split(r.FormValue("titles"), "|") {
+				if i > 0 {
+					fmt.Fprint(w, ",")
+				}
+				if title == "File:Gone.jpg" {
+					fmt.Fprintf(w, `{"title": %q, "missing": true}`, title)
+					continue
+				}
+
+				name := strings.TrimPrefix(title, "File:")
+				fmt.Fprintf(w, `{"title": %q, "imageinfo": [{"url": "http://example.com/%s", "timestamp": "2024-01-01T00:00:00Z", "user": "Alice"}]}`, title, name)
+			}
+			fmt.Fprint(w, `]}}`)
+		}
+	}))
+	defer ts.Close()
+
+	src := NewSource(Pictures, 10)
+	src.APIURL = ts.URL
+	src.PerPage = 2
+
+	images := all(t, src)
+	if len(images) != 3 || images[0].URL != "http://example.com/C.jpg" || images[1].Title != "File:B.jpg" || images[2].URL != "http://example.com/A.jpg" {
+		t.Fatalf("unexpected images %+v", images)
+	}
+	if images[0].Uploader != "Alice" || !images[0].Uploaded.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected image %+v", images[0])
+	}
+	if requests != 4 {
+		t.Errorf("expected 4 requests but got %d", requests)
+	}
+
+	// API errors are returned
+	src = NewSource(Pictures, 10)
+	src.APIURL = ts.URL
+	if _, err := src.Next(context.Background()); err == nil || !strings.Contains(err.Error(), "unexpected request") {
+		t.Errorf("expected an API error but got %v", err)
+	}
+}
+
+func TestUnknownFeed(t *testing.T) {
+	if _, err := NewSource("latest", 1).Next(context.Background()); err == nil {
+		t.Error("expected an error for an unknown feed")
+	}
+}
@@ -0,0 +1,211 @@
+package wikimg
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/gif"
+	"io"
+	"net/http"
+	"os"
+)
+
+// fetch retrieves and decodes the image at imgURL. If all is true and the
+// image is an animated GIF, every frame is decoded. When p is using
+// thumbnails, a thumbnail is retrieved instead, falling back to the original
+// image if the thumbnail isn't available. Types are handled according to
+// p.Routes: SVGs, for example, are retrieved as PNGs rendered by Commons.
+// The caller must release the animation once it's done with the image.
+// Closing cancel stops the download (usually it's p.Cancel). Spans are
+// children of the span in ctx.
+func (p *Puller) fetch(ctx context.Context, imgURL string, all bool, cancel <-chan struct{}) (*animation, error) {
+	mime, route := p.route(imgURL)
+
+	switch route.Strategy {
+	case Skip:
+		return nil, &SkippedError{URL: imgURL, Type: mime}
+
+	case Thumbnail:
+		// Commons can render types that can't be decoded as thumbnails
+		// which can. There's no point trying the original.
+		width := route.Width
+		if width < 1 && p.thumbWidth > 0 {
+			width = p.thumbWidth
+		} else if width < 1 {
+			width = p.SVGWidth
+		}
+
+		if thumb, ok := thumbURL(imgURL, width); ok && width > 0 {
+			return p.get(ctx, thumb, all, cancel)
+		}
+
+		return p.get(ctx, imgURL, all, cancel)
+	}
+
+	if p.thumbWidth > 0 {
+		if thumb, ok := thumbURL(imgURL, p.thumbWidth); ok {
+			a, err := p.get(ctx, thumb, all, cancel)
+			if err == nil || closed(cancel) {
+				return a, err
+			}
+		}
+	}
+
+	return p.get(ctx, imgURL, all, cancel)
+}
+
+// get retrieves and decodes the image at imgURL. The image's dimensions are
+// checked before it is fully decoded, so images with more than p.MaxPixels
+// are rejected without allocating memory for them. If all is true, every
+// frame of a GIF is decoded.
+func (p *Puller) get(ctx context.Context, imgURL string, all bool, cancel <-chan struct{}) (a *animation, err error) {
+	ctx, span := p.trace(ctx, "wikimg.fetch", imgURL)
+	defer func() { span.End(err) }()
+
+	// Create a request so we can use req.Cancel. The request carries ctx,
+	// so an instrumented Client can propagate the trace.
+	req, err := http.NewRequestWithContext(ctx, "GET", imgURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set up cancellation pipeline, link request to the caller
+	req.Cancel = cancel
+
+	// Call the image server. Local files (see FileSource) are read from
+	// disk instead, if they're allowed.
+	var rc io.ReadCloser
+	if req.URL.Scheme == "file" {
+		if !p.LocalFiles {
+			return nil, fmt.Errorf("wikimg: local files aren't allowed: %s", imgURL)
+		}
+
+		rc, err = os.Open(filePath(req.URL))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		p.identify(req)
+
+		var resp *http.Response
+		resp, err = p.client().Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		// Don't try to decode error pages
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("wikimg: %s: %s", resp.Status, imgURL)
+		}
+
+		rc = resp.Body
+	}
+	defer rc.Close()
+
+	p.stats.downloads.Add(1)
+
+	// Fail fast if the connection stalls partway through
+	body := p.watch(imgURL, rc)
+
+	// Keep a copy of the bytes read while decoding the config, so we can
+	// replay them for the full decode
+	head := getHead()
+	defer putHead(head)
+	counted := countingReader{body, &p.stats.bytes}
+	br := getReader(io.TeeReader(counted, head))
+	defer putReader(br)
+
+	// Decode only the dimensions first
+	cfg, format, err := image.DecodeConfig(br)
+	if err != nil {
+		p.decodeFailed(imgURL, err)
+		return nil, err
+	}
+	span.SetAttribute("wikimg.format", format)
+	span.SetAttribute("wikimg.width", cfg.Width)
+	span.SetAttribute("wikimg.height", cfg.Height)
+
+	if p.MaxPixels > 0 && cfg.Width*cfg.Height > p.MaxPixels {
+		return nil, &TooLargeError{
+			URL:       imgURL,
+			Width:     cfg.Width,
+			Height:    cfg.Height,
+			MaxPixels: p.MaxPixels,
+		}
+	}
+
+	// The EXIF block comes before the image data, so it has already been
+	// read
+	var exif *EXIF
+	if p.ReadEXIF && format == "jpeg" {
+		exif = parseEXIF(head.Bytes())
+	}
+
+	// Wait until there's memory for the decoded image. Animations also
+	// need a canvas to draw their frames on.
+	size := decodedSize(cfg)
+	if all && format == "gif" {
+		size += int64(cfg.Width) * int64(cfg.Height) * 4
+	}
+	err = p.memory.acquire(size, p.MaxMemory, cancel)
+	if err != nil {
+		return nil, err
+	}
+	a = &animation{format: format, exif: exif, memory: &p.memory, reserved: size}
+
+	// Decode into an object, starting over from the beginning of the body
+	br.Reset(io.MultiReader(head, counted))
+
+	_, decode := p.trace(ctx, "wikimg.decode", imgURL)
+	err = p.Decoder.Do(cancel, func() error {
+		if all && format == "gif" {
+			a.gif, err = gif.DecodeAll(br)
+			if err != nil {
+				return err
+			}
+			a.img = a.gif.Image[0]
+
+			return nil
+		}
+
+		img, _, err := image.Decode(br)
+		if err != nil {
+			return err
+		}
+
+		a.img = img
+		if exif != nil {
+			a.img = orient(img, exif.Orientation)
+		}
+
+		return nil
+	})
+	decode.End(err)
+	if err != nil {
+		a.release()
+		p.decodeFailed(imgURL, err)
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// client returns the client to make requests with
+func (p *Puller) client() *http.Client {
+	if p.Client == nil {
+		return http.DefaultClient
+	}
+
+	return p.Client
+}
+
+// closed returns true if cancel has been closed
+func closed(cancel <-chan struct{}) bool {
+	select {
+	case <-cancel:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,62 @@
+package wikimg
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchMaxPixels(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		png.Encode(w, image.NewRGBA(image.Rect(0, 0, 20, 10)))
+	}))
+	defer ts.Close()
+
+	p := NewPuller(1)
+
+	// Small enough to decode
+	p.MaxPixels = 200
+	a, err := p.fetch(context.Background(), ts.URL, false, p.Cancel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.release()
+	img, format := a.img, a.format
+
+	if format != "png" || img.Bounds().Dx() != 20 || img.Bounds().Dy() != 10 {
+		t.Errorf("unexpected %s image with bounds %v", format, img.Bounds())
+	}
+
+	// Too large
+	p.MaxPixels = 199
+	_, err = p.fetch(context.Background(), ts.URL, false, p.Cancel)
+	if tl, ok := err.(*TooLargeError); !ok || tl.Width != 20 || tl.Height != 10 {
+		t.Errorf("expected *TooLargeError but got %v", err)
+	}
+}
+
+func TestFirstColorReport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+		img.Set(0, 0, color.NRGBA{0xfa, 0x10, 0x10, 0xff})
+		png.Encode(w, img)
+	}))
+	defer ts.Close()
+
+	p := NewPuller(1)
+
+	info, err := p.FirstColor(ts.URL)
+	if err != nil || info.Hex != "#ff0000" || info.Source != "palette" {
+		t.Errorf("expected palette color #ff0000 but got %s %s, %v", info.Source, info.Hex, err)
+	}
+
+	p.Options.Report = PixelColor
+	pinfo, err := p.FirstColor(ts.URL)
+	if err != nil || pinfo.Hex != "#fa1010" || pinfo.Source != "pixel" || pinfo.Index != info.Index {
+		t.Errorf("expected pixel color #fa1010 but got %s %s, %v", pinfo.Source, pinfo.Hex, err)
+	}
+}
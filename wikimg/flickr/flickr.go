@@ -0,0 +1,300 @@
+// Package flickr is a wikimg.Source of the latest public uploads to Flickr
+// (https://www.flickr.com), so the color walls can run against Flickr
+// instead of Commons. It needs an API key (see
+// https://www.flickr.com/services/api/misc.api_keys.html):
+//
+//	src := flickr.NewSource(os.Getenv("FLICKR_API_KEY"), 500)
+//	src.Size = flickr.Medium
+//
+//	for {
+//		img, err := src.Next(ctx)
+//		if err == wikimg.EndOfResults {
+//			break
+//		}
+//		...
+//	}
+//
+// Images are analyzed by a wikimg.Puller like any others.
+package flickr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// Sizes of photos, named by the suffix Flickr uses for them (see
+// https://www.flickr.com/services/api/misc.urls.html). Not every photo has
+// every size, the largest is Original.
+const (
+	Thumbnail = "t" // 100 pixels on the longest side
+	Small     = "n" // 320
+	Medium    = "z" // 640
+	Large     = "b" // 1024
+	Original  = "o"
+)
+
+const (
+	// apiURL is the REST API
+	apiURL = "https://api.flickr.com/services/rest/"
+
+	// DefaultSize is the default for Source.Size. It's plenty for finding
+	// colors and quick to download.
+	DefaultSize = Medium
+
+	// DefaultPerPage is the default for Source.PerPage
+	DefaultPerPage = 100
+
+	// maxPerPage is the most photos the API returns at once
+	maxPerPage = 500
+
+	// maxBody is the most of an API response we read
+	maxBody = 10 << 20
+)
+
+// APIError is an error returned by the Flickr API, e.g., for an invalid
+// API key
+type APIError struct {
+	Code    int
+	Message string
+}
+
+// Error describes the error
+func (e *APIError) Error() string {
+	return fmt.Sprintf("flickr: API error %d: %s", e.Code, e.Message)
+}
+
+// photo is a photo returned by flickr.photos.getRecent, with the extras
+// we ask for
+type photo struct {
+	ID         string
+	Owner      string
+	Secret     string
+	Server     string
+	Title      string
+	DateUpload string
+	OwnerName  string
+
+	// urls are the url_* extras by size
+	urls map[string]string
+}
+
+// UnmarshalJSON decodes a photo, collecting the URL of each size
+func (ph *photo) UnmarshalJSON(b []byte) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(b, &fields)
+	if err != nil {
+		return err
+	}
+
+	str := func(name string) string {
+		var s string
+		if json.Unmarshal(fields[name], &s) == nil {
+			return s
+		}
+
+		// Some fields are numbers in some responses
+		return strings.Trim(string(fields[name]), `"`)
+	}
+
+	ph.ID, ph.Owner, ph.Secret = str("id"), str("owner"), str("secret")
+	ph.Server, ph.Title, ph.DateUpload = str("server"), str("title"), str("dateupload")
+	ph.OwnerName = str("ownername")
+
+	ph.urls = map[string]string{}
+	for name := range fields {
+		if size, ok := strings.CutPrefix(name, "url_"); ok {
+			ph.urls[size] = str(name)
+		}
+	}
+
+	return nil
+}
+
+// response is the response of flickr.photos.getRecent
+type response struct {
+	Stat    string
+	Code    int
+	Message string
+	Photos  struct {
+		Page  json.Number
+		Pages json.Number
+		Photo []photo
+	}
+}
+
+// Source pulls the latest public photos on Flickr, most recent first. Set
+// its fields before the first call to Next. Like a wikimg.Puller, it must
+// only be used by one goroutine at a time.
+type Source struct {
+	// APIKey is the key used to call the API
+	APIKey string
+
+	// Size is which size of each photo is returned, e.g., Medium. Photos
+	// that don't have it are skipped. Empty means DefaultSize.
+	Size string
+
+	// PerPage is the number of photos requested at once, up to 500. Zero
+	// means DefaultPerPage.
+	PerPage int
+
+	// APIURL is the API's URL, for testing. Empty means Flickr's.
+	APIURL string
+
+	// Client is the HTTP client used to call the API. If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	max   int
+	count int
+
+	// page is the last page requested and pages is how many there are
+	page, pages int
+	photos      []photo
+
+	// seen are the IDs returned so far. New uploads push photos onto
+	// later pages while we're paging, so some are listed twice.
+	seen map[string]bool
+}
+
+// NewSource creates a Source that returns at most max photos
+func NewSource(apiKey string, max int) *Source {
+	return &Source{APIKey: apiKey, max: max, seen: map[string]bool{}}
+}
+
+// Next returns the next most recent photo, or wikimg.EndOfResults once max
+// photos have been returned or there are no more
+func (s *Source) Next(ctx context.Context) (wikimg.ImageInfo, error) {
+	for s.count < s.max {
+		if len(s.photos) < 1 {
+			if s.page > 0 && s.page >= s.pages {
+				break
+			}
+
+			err := s.query(ctx)
+			if err != nil {
+				return wikimg.ImageInfo{}, err
+			}
+			if len(s.photos) < 1 {
+				break
+			}
+		}
+
+		ph := s.photos[0]
+		s.photos = s.photos[1:]
+
+		img, ok := s.image(ph)
+		if !ok || s.seen[ph.ID] {
+			continue
+		}
+		s.seen[ph.ID] = true
+		s.count++
+
+		return img, nil
+	}
+
+	return wikimg.ImageInfo{}, wikimg.EndOfResults
+}
+
+// size returns the size of photos to return
+func (s *Source) size() string {
+	if len(s.Size) < 1 {
+		return DefaultSize
+	}
+
+	return s.Size
+}
+
+// image describes ph, returning false if it doesn't have our size
+func (s *Source) image(ph photo) (wikimg.ImageInfo, bool) {
+	size := s.size()
+
+	u := ph.urls[size]
+	if len(u) < 1 && size != Original && len(ph.Server) > 0 && len(ph.Secret) > 0 {
+		// Every size but the original can be found from the photo's
+		// server and secret
+		u = fmt.Sprintf("https://live.staticflickr.com/%s/%s_%s_%s.jpg", ph.Server, ph.ID, ph.Secret, size)
+	}
+	if len(u) < 1 {
+		return wikimg.ImageInfo{}, false
+	}
+
+	img := wikimg.ImageInfo{
+		URL:      u,
+		Title:    ph.Title,
+		Uploader: ph.OwnerName,
+		Page:     fmt.Sprintf("https://www.flickr.com/photos/%s/%s", url.PathEscape(ph.Owner), url.PathEscape(ph.ID)),
+	}
+	if secs, err := strconv.ParseInt(ph.DateUpload, 10, 64); err == nil {
+		img.Uploaded = time.Unix(secs, 0).UTC()
+	}
+
+	return img, true
+}
+
+// query requests the next page of photos
+func (s *Source) query(ctx context.Context) error {
+	perPage := s.PerPage
+	if perPage < 1 {
+		perPage = DefaultPerPage
+	}
+
+	params := url.Values{}
+	params.Set("method", "flickr.photos.getRecent")
+	params.Set("api_key", s.APIKey)
+	params.Set("format", "json")
+	params.Set("nojsoncallback", "1")
+	params.Set("per_page", strconv.Itoa(min(perPage, maxPerPage)))
+	params.Set("page", strconv.Itoa(s.page+1))
+	params.Set("extras", "date_upload,owner_name,url_"+s.size())
+
+	u := s.APIURL
+	if len(u) < 1 {
+		u = apiURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("flickr: API returned %s", resp.Status)
+	}
+
+	var r response
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxBody)).Decode(&r)
+	if err != nil {
+		return fmt.Errorf("flickr: couldn't parse API response: %v", err)
+	}
+
+	if r.Stat != "ok" {
+		return &APIError{Code: r.Code, Message: r.Message}
+	}
+
+	page, _ := r.Photos.Page.Int64()
+	pages, _ := r.Photos.Pages.Int64()
+	s.page, s.pages = max(int(page), s.page+1), int(pages)
+	s.photos = r.Photos.Photo
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package flickr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// newAPI starts a fake API with pages of two photos each. The first
+// photo of each page after the first is the last of the page before, as
+// when photos are uploaded while paging.
+func newAPI(t *testing.T, pages int) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("api_key") != "key" {
+			fmt.Fprint(w, `{"stat": "fail", "code": 100, "message": "Invalid API Key (Key has invalid format)"}`)
+			return
+		}
+		if r.FormValue("method") != "flickr.photos.getRecent" || r.FormValue("extras") != "date_upload,owner_name,url_z" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		page, _ := strconv.Atoi(r.FormValue("page"))
+		first := page
+
+		// Photo 3 has no URL of our size and 4 has no way to make one
+		fmt.Fprintf(w, `{"photos": {"page": %d, "pages": "%d", "perpage": 2, "photo": [`, page, pages)
+		for id := first; id < first+2; id++ {
+			if id > first {
+				fmt.Fprint(w, ",")
+			}
+
+			switch id {
+			case 3:
+				fmt.Fprintf(w, `{"id": "%d", "owner": "o", "secret": "s", "server": 65535, "title": "three", "dateupload": "1700000000"}`, id)
+			case 4:
+				fmt.Fprintf(w, `{"id": "%d", "owner": "o", "title": "four"}`, id)
+			default:
+				fmt.Fprintf(w, `{"id": "%d", "owner": "o", "ownername": "Owner", "title": "p%d", "dateupload": "1700000000", "url_z": "http://example.com/%d.jpg"}`, id, id, id)
+			}
+		}
+		fmt.Fprint(w, `]}, "stat": "ok"}`)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestSource(t *testing.T) {
+	ts := newAPI(t, 3)
+
+	src := NewSource("key", 10)
+	src.APIURL = ts.URL
+	src.PerPage = 2
+
+	var got []wikimg.ImageInfo
+	for {
+		img, err := src.Next(context.Background())
+		if err == wikimg.EndOfResults {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		got = append(got, img)
+	}
+
+	// Pages are 1 2, 2 3, 3 4. Duplicates and 4 are skipped.
+	expected := []string{
+		"http://example.com/1.jpg",
+		"http://example.com/2.jpg",
+		"https://live.staticflickr.com/65535/3_s_z.jpg",
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v but got %+v", expected, got)
+	}
+	for i, u := range expected {
+		if got[i].URL != u {
+			t.Errorf("expected %s at %d but got %s", u, i, got[i].URL)
+		}
+	}
+
+	img := got[0]
+	if img.Title != "p1" || img.Uploader != "Owner" || img.Page != "https://www.flickr.com/photos/o/1" || img.Uploaded.Unix() != 1700000000 {
+		t.Errorf("unexpected image %+v", img)
+	}
+}
+
+func TestSourceMax(t *testing.T) {
+	ts := newAPI(t, 100)
+
+	src := NewSource("key", 1)
+	src.APIURL = ts.URL
+
+	if _, err := src.Next(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Next(context.Background()); err != wikimg.EndOfResults {
+		t.Errorf("expected EndOfResults but got %v", err)
+	}
+}
+
+func TestSourceAPIError(t *testing.T) {
+	ts := newAPI(t, 1)
+
+	src := NewSource("bad", 10)
+	src.APIURL = ts.URL
+
+	_, err := src.Next(context.Background())
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 100 {
+		t.Errorf("expected API error 100 but got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+)
+
+const (
+	// grayscaleSize is the longest side an image is downscaled to when
+	// checking whether it's grayscale, unless ColorOptions.MaxSize is
+	// smaller. A 64x64 sample is plenty to find color in a photo.
+	grayscaleSize = 64
+
+	// grayscaleSaturation is the HSL saturation at or below which a pixel
+	// is considered gray when ColorOptions.GraySaturation isn't set. Scans
+	// of black-and-white prints are rarely perfectly neutral.
+	grayscaleSaturation = 0.1
+
+	// grayscaleSpread is the smallest GraySpread used when checking whether
+	// an image is grayscale. Near black and near white, a tiny difference
+	// between red, green and blue is a large saturation, but isn't visible.
+	grayscaleSpread = 8
+)
+
+// IsGrayscale returns true if the image has no colored pixels. Unlike
+// FirstColor, pixels are measured as they appear in the image rather than
+// as mapped to the palette, and only a downscaled sample of the image is
+// checked, so it's a cheap way to skip black-and-white images.
+func (p *Puller) IsGrayscale(imgURL string) (bool, error) {
+	a, err := p.fetch(p.context(), imgURL, false, p.Cancel)
+	if err != nil {
+		return false, err
+	}
+	defer a.release()
+
+	return p.Options.grayscale(a.img, p.Cancel)
+}
+
+// ImageIsGrayscale returns true if none of the sampled pixels of img are
+// colored according to opts' gray thresholds (see IsGrayscale)
+func ImageIsGrayscale(img image.Image, opts ColorOptions) bool {
+	return measureDecoded(img, opts.grayscale)
+}
+
+// grayscale checks a sample of img for a pixel that isn't gray
+func (o ColorOptions) grayscale(img image.Image, cancel <-chan struct{}) (bool, error) {
+	// Gray color models can't hold anything else
+	switch img.ColorModel() {
+	case color.GrayModel, color.Gray16Model:
+		return true, nil
+	}
+
+	if o.MaxSize < 1 || o.MaxSize > grayscaleSize {
+		o.MaxSize = grayscaleSize
+	}
+
+	spread := max(o.GraySpread, grayscaleSpread)
+	saturation := o.GraySaturation
+	if saturation <= 0 {
+		saturation = grayscaleSaturation
+	}
+
+	found, err := o.scan(img, cancel, func(c color.Color) bool {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+
+		if int(max(n.R, n.G, n.B))-int(min(n.R, n.G, n.B)) <= spread {
+			return false
+		}
+
+		_, s, _ := hsl(n.R, n.G, n.B)
+
+		return s > saturation
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return !found, nil
+}
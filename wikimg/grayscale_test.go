@@ -0,0 +1,30 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestImageIsGrayscale(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 200, 100))
+	for x := 0; x < 200; x++ {
+		for y := 0; y < 100; y++ {
+			v := uint8(x)
+			img.Set(x, y, color.NRGBA{v, v, v + 1, 255})
+		}
+	}
+
+	if !ImageIsGrayscale(img, ColorOptions{}) {
+		t.Errorf("expected nearly neutral image to be grayscale")
+	}
+
+	img.Set(100, 50, color.NRGBA{200, 0, 0, 255})
+	if ImageIsGrayscale(img, ColorOptions{MaxSize: 1000}) {
+		t.Errorf("expected image with a red pixel to not be grayscale")
+	}
+
+	if !ImageIsGrayscale(image.NewGray(image.Rect(0, 0, 1, 1)), ColorOptions{}) {
+		t.Errorf("expected gray model image to be grayscale")
+	}
+}
@@ -0,0 +1,57 @@
+package wikimg
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// ParseHex parses a hex color string. The leading '#' is optional, and the
+// 3 digit ("#b0c"), 6 digit ("#bb00cc") and 8 digit ("#bb00cc80") forms are
+// accepted. The 8 digit form includes an alpha value, all other forms are
+// fully opaque.
+func ParseHex(s string) (color.NRGBA, error) {
+	c := color.NRGBA{A: 0xff}
+	h := strings.TrimPrefix(s, "#")
+
+	// Expand the short form so each digit is doubled (e.g., "b0c" becomes
+	// "bb00cc")
+	if len(h) == 3 {
+		h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+	}
+
+	if len(h) != 6 && len(h) != 8 {
+		return c, fmt.Errorf("wikimg: invalid hex color %q", s)
+	}
+
+	v, err := strconv.ParseUint(h, 16, 32)
+	if err != nil {
+		return c, fmt.Errorf("wikimg: invalid hex color %q", s)
+	}
+
+	// Shift the alpha value off the end when it's present
+	if len(h) == 8 {
+		c.A = uint8(v)
+		v >>= 8
+	}
+
+	c.R = uint8(v >> 16)
+	c.G = uint8(v >> 8)
+	c.B = uint8(v)
+
+	return c, nil
+}
+
+// Hex formats c as a lowercase hex string. Opaque colors use the 6 digit form
+// (e.g., "#bb00cc") and colors with transparency use the 8 digit form with
+// alpha as the final byte (e.g., "#bb00cc80").
+func Hex(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+
+	if n.A == 0xff {
+		return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
+}
@@ -0,0 +1,53 @@
+package wikimg
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseHex(t *testing.T) {
+	tests := []struct {
+		in  string
+		out color.NRGBA
+	}{
+		{"#bb00cc", color.NRGBA{0xbb, 0x00, 0xcc, 0xff}},
+		{"bb00cc", color.NRGBA{0xbb, 0x00, 0xcc, 0xff}},
+		{"#b0c", color.NRGBA{0xbb, 0x00, 0xcc, 0xff}},
+		{"#BB00CC80", color.NRGBA{0xbb, 0x00, 0xcc, 0x80}},
+	}
+
+	for _, test := range tests {
+		c, err := ParseHex(test.in)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.in, err)
+			continue
+		}
+
+		if c != test.out {
+			t.Errorf("%q: expected %v but got %v", test.in, test.out, c)
+		}
+	}
+
+	for _, in := range []string{"", "#", "#bb00c", "#gg00cc", "#bb00cc8"} {
+		if _, err := ParseHex(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
+
+func TestHex(t *testing.T) {
+	tests := []struct {
+		in  color.Color
+		out string
+	}{
+		{color.RGBA{0xbb, 0x00, 0xcc, 0xff}, "#bb00cc"},
+		{color.NRGBA{0xbb, 0x00, 0xcc, 0x80}, "#bb00cc80"},
+		{color.Gray{0x80}, "#808080"},
+	}
+
+	for _, test := range tests {
+		if hex := Hex(test.in); hex != test.out {
+			t.Errorf("%v: expected %q but got %q", test.in, test.out, hex)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+)
+
+// Histogram returns the number of sampled pixels in the image that map to
+// each index of the palette in p.Options. By default, the keys are xterm256
+// color ids. Since a histogram needs palette indexes, colors are mapped to
+// the palette even if p.Options.Unquantized is set.
+func (p *Puller) Histogram(imgURL string) (map[int]int, error) {
+	a, err := p.fetch(p.context(), imgURL, false, p.Cancel)
+	if err != nil {
+		return nil, err
+	}
+	defer a.release()
+
+	return p.Options.histogram(a.img, p.Cancel)
+}
+
+// ImageHistogram counts the sampled pixels of img by the index of the
+// palette color in opts they map to (see Histogram)
+func ImageHistogram(img image.Image, opts ColorOptions) map[int]int {
+	return measureDecoded(img, opts.histogram)
+}
+
+// histogram counts the sampled pixels of img that map to each palette index
+func (o ColorOptions) histogram(img image.Image, cancel <-chan struct{}) (map[int]int, error) {
+	o.Unquantized = false
+
+	hist := map[int]int{}
+
+	// Map colors the same way FirstColor does, so the histogram agrees
+	// with the color it reports
+	_, err := o.scan(img, cancel, func(c color.Color) bool {
+		_, i := o.quantize(c)
+		hist[i]++
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hist, nil
+}
@@ -0,0 +1,57 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestImageHistogram(t *testing.T) {
+	// Left half red, right half blue
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			if x < 5 {
+				img.Set(x, y, color.RGBA{0xff, 0, 0, 0xff})
+			} else {
+				img.Set(x, y, color.RGBA{0, 0, 0xff, 0xff})
+			}
+		}
+	}
+
+	hist := ImageHistogram(img, ColorOptions{})
+
+	// 9 and 12 are the first red and blue entries in XTerm256
+	if len(hist) != 2 || hist[9] != 50 || hist[12] != 50 {
+		t.Errorf("unexpected histogram %v", hist)
+	}
+
+	hist = ImageHistogram(img, ColorOptions{Unquantized: true, Stride: 5})
+	if len(hist) != 2 || hist[9] != 2 || hist[12] != 2 {
+		t.Errorf("unexpected histogram %v", hist)
+	}
+}
+
+func TestImageHistogramDistance(t *testing.T) {
+	// Dark navy is nearest gray in RGB, but blue in CIEDE2000
+	navy := color.RGBA{0, 0x20, 0x40, 0xff}
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			img.Set(x, y, navy)
+		}
+	}
+
+	pal := color.Palette{color.RGBA{0xff, 0, 0, 0xff}, color.RGBA{0, 0, 0xff, 0xff}, color.RGBA{0x80, 0x80, 0x80, 0xff}}
+
+	hist := ImageHistogram(img, ColorOptions{Palette: pal})
+	if len(hist) != 1 || hist[2] != 16 {
+		t.Errorf("expected every pixel to be gray but got %v", hist)
+	}
+
+	opts := ColorOptions{Palette: pal, Distance: CIEDE2000}
+	hist = ImageHistogram(img, opts)
+	if _, i := opts.quantize(navy); i != 1 || len(hist) != 1 || hist[1] != 16 {
+		t.Errorf("expected every pixel to be blue but got %v", hist)
+	}
+}
@@ -0,0 +1,202 @@
+package wikimg
+
+import (
+	"image/color"
+	"math"
+	"sync"
+)
+
+// lab is a color in the CIE L*a*b* color space, which is designed so that
+// distances between colors match how different they look
+type lab struct {
+	L, A, B float64
+}
+
+// D65 reference white point
+const (
+	whiteX = 0.95047
+	whiteY = 1.00000
+	whiteZ = 1.08883
+)
+
+// paletteKey identifies a palette by its backing array, so the L*a*b*
+// values and lookup tables of long-lived palettes like XTerm256 are only
+// computed once
+type paletteKey struct {
+	first *color.Color
+	n     int
+}
+
+// paletteLabs caches the L*a*b* values of palettes by paletteKey
+var paletteLabs sync.Map
+
+// labPalette returns the L*a*b* values of every color in pal
+func labPalette(pal color.Palette) []lab {
+	key := paletteKey{&pal[0], len(pal)}
+
+	if labs, ok := paletteLabs.Load(key); ok {
+		return labs.([]lab)
+	}
+
+	labs := make([]lab, len(pal))
+	for i, c := range pal {
+		labs[i] = toLab(c)
+	}
+	paletteLabs.Store(key, labs)
+
+	return labs
+}
+
+// nearestLab returns the index of the color in pal that is closest to c
+// according to the L*a*b* distance function
+func nearestLab(pal color.Palette, c color.Color, distance func(lab, lab) float64) int {
+	target := toLab(c)
+
+	best := 0
+	bestDist := math.Inf(1)
+	for i, l := range labPalette(pal) {
+		if d := distance(target, l); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+
+	return best
+}
+
+// toLab converts a color to CIE L*a*b*, treating it as sRGB with a D65 white
+// point. Transparency is ignored.
+func toLab(c color.Color) lab {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+
+	// sRGB to linear RGB to CIE XYZ
+	r, g, b := linear(n.R), linear(n.G), linear(n.B)
+	x := 0.4124564*r + 0.3575761*g + 0.1804375*b
+	y := 0.2126729*r + 0.7151522*g + 0.0721750*b
+	z := 0.0193339*r + 0.1191920*g + 0.9503041*b
+
+	// CIE XYZ to L*a*b*
+	fx, fy, fz := labF(x/whiteX), labF(y/whiteY), labF(z/whiteZ)
+
+	return lab{
+		L: 116*fy - 16,
+		A: 500 * (fx - fy),
+		B: 200 * (fy - fz),
+	}
+}
+
+// Lab returns the CIE L*a*b* values of c, treating it as sRGB with a D65
+// white point. Transparency is ignored. L is between 0 and 100, and a and b
+// are roughly between -128 and 127. The Euclidean distance between two
+// colors' values is their CIE76 difference (see DeltaE).
+func Lab(c color.Color) (l, a, b float64) {
+	v := toLab(c)
+
+	return v.L, v.A, v.B
+}
+
+// DeltaE returns the CIE76 color difference between c1 and c2. A difference
+// of about 2.3 is just noticeable.
+func DeltaE(c1, c2 color.Color) float64 {
+	return deltaE76(toLab(c1), toLab(c2))
+}
+
+// labF is the nonlinear function used when converting XYZ to L*a*b*
+func labF(t float64) float64 {
+	const delta = 6.0 / 29
+
+	if t > delta*delta*delta {
+		return math.Cbrt(t)
+	}
+
+	return t/(3*delta*delta) + 4.0/29
+}
+
+// deltaE76 is the CIE76 color difference, the Euclidean distance between
+// two L*a*b* colors. A difference of about 2.3 is just noticeable.
+func deltaE76(c1, c2 lab) float64 {
+	dl, da, db := c1.L-c2.L, c1.A-c2.A, c1.B-c2.B
+
+	return math.Sqrt(dl*dl + da*da + db*db)
+}
+
+// deltaE2000 is the CIEDE2000 color difference, which corrects CIE76 for how
+// people perceive differences in blues, saturated colors and grays.
+// See http://www2.ece.rochester.edu/~gsharma/ciede2000/ciede2000noteCRNA.pdf
+func deltaE2000(c1, c2 lab) float64 {
+	const pow25to7 = 6103515625.0 // 25^7
+
+	rad := math.Pi / 180
+
+	// Adjust a* so that chroma of near-neutral colors is handled better
+	cab := (math.Hypot(c1.A, c1.B) + math.Hypot(c2.A, c2.B)) / 2
+	cab7 := math.Pow(cab, 7)
+	g := 0.5 * (1 - math.Sqrt(cab7/(cab7+pow25to7)))
+
+	a1, a2 := c1.A*(1+g), c2.A*(1+g)
+	ch1, ch2 := math.Hypot(a1, c1.B), math.Hypot(a2, c2.B)
+
+	// Hue angles in degrees
+	hue := func(b, a float64) float64 {
+		if a == 0 && b == 0 {
+			return 0
+		}
+
+		h := math.Atan2(b, a) / rad
+		if h < 0 {
+			h += 360
+		}
+
+		return h
+	}
+	h1, h2 := hue(c1.B, a1), hue(c2.B, a2)
+
+	// Differences in lightness, chroma and hue
+	dL := c2.L - c1.L
+	dC := ch2 - ch1
+
+	dh := 0.0
+	if ch1*ch2 != 0 {
+		dh = h2 - h1
+		if dh > 180 {
+			dh -= 360
+		} else if dh < -180 {
+			dh += 360
+		}
+	}
+	dH := 2 * math.Sqrt(ch1*ch2) * math.Sin(dh*rad/2)
+
+	// Means
+	lMean := (c1.L + c2.L) / 2
+	cMean := (ch1 + ch2) / 2
+
+	hMean := h1 + h2
+	if ch1*ch2 != 0 {
+		if math.Abs(h1-h2) > 180 {
+			if hMean < 360 {
+				hMean += 360
+			} else {
+				hMean -= 360
+			}
+		}
+		hMean /= 2
+	}
+
+	// Weighting functions
+	t := 1 - 0.17*math.Cos((hMean-30)*rad) +
+		0.24*math.Cos(2*hMean*rad) +
+		0.32*math.Cos((3*hMean+6)*rad) -
+		0.20*math.Cos((4*hMean-63)*rad)
+
+	l50 := (lMean - 50) * (lMean - 50)
+	sl := 1 + 0.015*l50/math.Sqrt(20+l50)
+	sc := 1 + 0.045*cMean
+	sh := 1 + 0.015*cMean*t
+
+	cMean7 := math.Pow(cMean, 7)
+	dTheta := 30 * math.Exp(-((hMean-275)/25)*((hMean-275)/25))
+	rt := -2 * math.Sqrt(cMean7/(cMean7+pow25to7)) * math.Sin(2*dTheta*rad)
+
+	lt, ct, ht := dL/sl, dC/sc, dH/sh
+
+	return math.Sqrt(lt*lt + ct*ct + ht*ht + rt*ct*ht)
+}
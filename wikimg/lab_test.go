@@ -0,0 +1,96 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestToLab(t *testing.T) {
+	tests := []struct {
+		in      color.Color
+		l, a, b float64
+	}{
+		{color.White, 100, 0, 0},
+		{color.Black, 0, 0, 0},
+		{color.RGBA{0xff, 0x00, 0x00, 0xff}, 53.2408, 80.0925, 67.2032},
+	}
+
+	for _, test := range tests {
+		c := toLab(test.in)
+		if !nearLab(c.L, test.l) || !nearLab(c.A, test.a) || !nearLab(c.B, test.b) {
+			t.Errorf("%v: expected %v,%v,%v but got %+v", test.in, test.l, test.a, test.b, c)
+		}
+	}
+}
+
+func TestDeltaE(t *testing.T) {
+	if l, a, b := Lab(color.White); !nearLab(l, 100) || !nearLab(a, 0) || !nearLab(b, 0) {
+		t.Errorf("expected white to be 100,0,0 but got %v,%v,%v", l, a, b)
+	}
+
+	if d := DeltaE(color.White, color.Black); !nearLab(d, 100) {
+		t.Errorf("expected 100 between white and black but got %v", d)
+	}
+
+	red, darkRed := color.RGBA{0xff, 0, 0, 0xff}, color.RGBA{0xf0, 0, 0, 0xff}
+	if d := DeltaE(red, darkRed); d < 1 || d > 10 {
+		t.Errorf("expected a small difference between reds but got %v", d)
+	}
+}
+
+func TestImageDeltaE(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+
+	// Pure red is in XTerm256, so it maps perfectly
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{0xff, 0, 0, 0xff}), image.Point{}, draw.Src)
+	if d := ImageDeltaE(img, ColorOptions{}); d > 0.001 {
+		t.Errorf("expected no difference but got %v", d)
+	}
+
+	// With only black and white, red is far off
+	bw := color.Palette{color.Black, color.White}
+	if d := ImageDeltaE(img, ColorOptions{Palette: bw}); d < 50 {
+		t.Errorf("expected a large difference but got %v", d)
+	}
+}
+
+// nearLab returns true if a and b are within a tolerance suitable for L*a*b*
+// values
+func nearLab(a, b float64) bool {
+	return a-b < 0.01 && b-a < 0.01
+}
+
+func TestDeltaE2000(t *testing.T) {
+	// Test data from Sharma, Wu and Dalal's CIEDE2000 paper
+	tests := []struct {
+		c1, c2 lab
+		d      float64
+	}{
+		{lab{50, 2.6772, -79.7751}, lab{50, 0, -82.7485}, 2.0425},
+		{lab{50, -1.3802, -84.2814}, lab{50, 0, -82.7485}, 1.0000},
+		{lab{50, 2.5, 0}, lab{73, 25, -18}, 27.1492},
+		{lab{60.2574, -34.0099, 36.2677}, lab{60.4626, -34.1751, 39.4387}, 1.2644},
+		{lab{50, 0, 0}, lab{50, -1, 2}, 2.3669},
+	}
+
+	for _, test := range tests {
+		d := deltaE2000(test.c1, test.c2)
+		if d-test.d > 0.0001 || test.d-d > 0.0001 {
+			t.Errorf("%+v, %+v: expected %v but got %v", test.c1, test.c2, test.d, d)
+		}
+	}
+}
+
+func TestQuantizeDistance(t *testing.T) {
+	for _, dist := range []Distance{RGB, CIE76, CIEDE2000} {
+		opts := ColorOptions{Distance: dist}
+
+		// Colors in the palette map to themselves
+		_, i := opts.quantize(XTerm256[208])
+		if i != 208 {
+			t.Errorf("%d: expected 208 but got %d", dist, i)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package wikimg
+
+import (
+	"strings"
+	"unicode"
+)
+
+// allowed returns true if img passes the Puller's filters
+func (p *Puller) allowed(img apiImage) bool {
+	if len(p.Licenses) < 1 {
+		return true
+	}
+
+	code := strings.ToLower(img.meta("License"))
+	for _, l := range p.Licenses {
+		if licenseMatches(code, strings.ToLower(l)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// licenseMatches returns true if the license code is the same as want, or is
+// a version of want (e.g., "cc-by-4.0" matches "cc-by")
+func licenseMatches(code, want string) bool {
+	if code == want {
+		return true
+	}
+
+	v := strings.TrimPrefix(code, want+"-")
+	if len(v) == len(code) || len(v) < 1 {
+		return false
+	}
+
+	return unicode.IsDigit(rune(v[0]))
+}
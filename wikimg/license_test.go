@@ -0,0 +1,24 @@
+package wikimg
+
+import "testing"
+
+func TestLicenseMatches(t *testing.T) {
+	tests := []struct {
+		code, want string
+		match      bool
+	}{
+		{"cc0", "cc0", true},
+		{"cc-by-4.0", "cc-by", true},
+		{"cc-by-4.0", "cc-by-4.0", true},
+		{"cc-by-sa-4.0", "cc-by", false},
+		{"cc-by-sa-4.0", "cc-by-sa", true},
+		{"cc-by-3.0", "cc-by-4.0", false},
+		{"", "cc0", false},
+	}
+
+	for _, test := range tests {
+		if m := licenseMatches(test.code, test.want); m != test.match {
+			t.Errorf("%q, %q: expected %v but got %v", test.code, test.want, test.match, m)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package wikimg
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// FileURL returns the file URL of the local file at path (e.g.,
+// file:///home/me/Pictures/cat.jpg). Pullers with LocalFiles set read file
+// URLs from disk, so they can be analyzed like any other image, without a
+// network.
+func FileURL(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	// Windows paths start with a drive letter (e.g., C:\Users\me), but
+	// URL paths start with a slash (e.g., file:///C:/Users/me)
+	path = filepath.ToSlash(abs)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	u := url.URL{Scheme: "file", Path: path}
+
+	return u.String(), nil
+}
+
+// filePath returns the local path of the file URL u, undoing FileURL
+func filePath(u *url.URL) string {
+	path := u.Path
+	if len(path) > 1 && len(filepath.VolumeName(path[1:])) > 0 {
+		path = path[1:]
+	}
+
+	return filepath.FromSlash(path)
+}
+
+// FileSource returns a Source of the local images matching pattern, so a
+// photo library can be analyzed offline. pattern is a directory, which is
+// walked recursively, or a glob (see filepath.Match) of files and
+// directories, e.g., "photos/2024-*". Only files whose extension is an
+// image type (see MimeType) are returned, in lexical order, with their
+// file URL, their name as the Title and their modification time as
+// Uploaded. Everything is listed up front, so errors listing it are
+// returned here rather than by Next. The Puller analyzing the images must
+// have LocalFiles set.
+func FileSource(pattern string) (Source, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) < 1 {
+		return nil, fmt.Errorf("wikimg: no files match %s", pattern)
+	}
+
+	var images []ImageInfo
+	for _, root := range paths {
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasPrefix(MimeType(path), "image/") {
+				return nil
+			}
+
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			u, err := FileURL(path)
+			if err != nil {
+				return err
+			}
+
+			images = append(images, ImageInfo{URL: u, Title: d.Name(), Uploaded: fi.ModTime().UTC(), Page: u})
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return SliceSource(images), nil
+}
+
+// LimitSource returns a Source of at most max images from src. Wrapping a
+// long lived Source lets each call of a function like Server.NewSource
+// return the next max images, rather than the same first ones.
+func LimitSource(src Source, max int) Source {
+	n := 0
+
+	return SourceFunc(func(ctx context.Context) (ImageInfo, error) {
+		if n >= max {
+			return ImageInfo{}, EndOfResults
+		}
+
+		img, err := src.Next(ctx)
+		if err != nil {
+			return ImageInfo{}, err
+		}
+		n++
+
+		return img, nil
+	})
+}
@@ -0,0 +1,144 @@
+package wikimg
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePNG writes a 4x4 PNG of c to path
+func writePNG(t *testing.T, path string, c color.Color) {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < 16; i++ {
+		img.Set(i%4, i/4, c)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	err = png.Encode(f, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mustFileURL returns the file URL of path
+func mustFileURL(t *testing.T, path string) string {
+	t.Helper()
+
+	u, err := FileURL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+func TestFileURL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a cat #1.png")
+
+	u, err := url.Parse(mustFileURL(t, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "file" || !strings.HasPrefix(u.Path, "/") {
+		t.Errorf("unexpected file URL %s", u)
+	}
+
+	// The URL leads back to the same file
+	if p := filePath(u); p != path {
+		t.Errorf("expected %s but got %s", path, p)
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+	err := os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePNG(t, filepath.Join(dir, "a.png"), color.NRGBA{0xff, 0, 0, 0xff})
+	writePNG(t, filepath.Join(dir, "sub", "b.png"), color.NRGBA{0, 0, 0xff, 0xff})
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an image"), 0644)
+
+	src, err := FileSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// By default, local files can't be read
+	p := NewPuller(0)
+	if _, err := p.FirstColor(mustFileURL(t, filepath.Join(dir, "a.png"))); err == nil {
+		t.Error("expected an error reading a local file by default")
+	}
+
+	// Both images are found and analyzed from disk
+	p.LocalFiles = true
+	for _, expected := range []struct {
+		title string
+		hex   string
+	}{{"a.png", "#ff0000"}, {"b.png", "#0000ff"}} {
+		img, err := src.Next(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Title != expected.title || img.Page != img.URL || img.Uploaded.IsZero() {
+			t.Errorf("unexpected image %+v", img)
+		}
+
+		info, err := p.FirstColor(img.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Hex != expected.hex {
+			t.Errorf("expected %s to be %s but got %s", img.Title, expected.hex, info.Hex)
+		}
+	}
+
+	if _, err := src.Next(context.Background()); err != EndOfResults {
+		t.Errorf("expected EndOfResults but got %v", err)
+	}
+
+	// A glob only matches some
+	src, err = FileSource(filepath.Join(dir, "s*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img, err := src.Next(context.Background()); err != nil || img.Title != "b.png" {
+		t.Errorf("expected b.png but got %+v, %v", img, err)
+	}
+
+	if _, err := FileSource(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestLimitSource(t *testing.T) {
+	src := SliceSource([]ImageInfo{{URL: "a"}, {URL: "b"}, {URL: "c"}})
+
+	for _, expected := range [][]string{{"a", "b"}, {"c"}, {}} {
+		limited := LimitSource(src, 2)
+
+		for _, u := range expected {
+			if img, err := limited.Next(context.Background()); err != nil || img.URL != u {
+				t.Errorf("expected %s but got %+v, %v", u, img, err)
+			}
+		}
+
+		if _, err := limited.Next(context.Background()); err != EndOfResults {
+			t.Errorf("expected EndOfResults but got %v", err)
+		}
+	}
+}
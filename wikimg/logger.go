@@ -0,0 +1,84 @@
+package wikimg
+
+import (
+	"sync/atomic"
+)
+
+// Logger receives structured log messages about what Pullers are doing.
+// Arguments are alternating keys and values, as with log/slog, and a
+// *slog.Logger can be used directly. Routine work (pages fetched, images
+// analyzed, cache hits) is logged at the debug level and images that
+// couldn't be decoded as warnings.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// messages are what's logged for each kind of Event
+var messages = map[EventKind]string{
+	PageFetched:  "wikimg: fetched API page",
+	ImagePulled:  "wikimg: pulled image",
+	Analyzed:     "wikimg: analyzed image",
+	CacheHit:     "wikimg: cache hit",
+	CacheMiss:    "wikimg: cache miss",
+	DecodeFailed: "wikimg: couldn't decode image",
+}
+
+// discard is a Logger that logs nothing
+type discard struct{}
+
+func (discard) Debug(msg string, args ...any) {}
+func (discard) Info(msg string, args ...any)  {}
+func (discard) Warn(msg string, args ...any)  {}
+func (discard) Error(msg string, args ...any) {}
+
+// logger holds the package Logger. It's boxed so it can be swapped
+// atomically whatever its type.
+var logger atomic.Pointer[struct{ Logger }]
+
+// SetLogger sets the Logger used by Pullers without a Logger of their own.
+// Nil, the default, logs nothing.
+func SetLogger(l Logger) {
+	if l == nil {
+		logger.Store(nil)
+		return
+	}
+
+	logger.Store(&struct{ Logger }{l})
+}
+
+// log returns the Logger p should log to
+func (p *Puller) log() Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+
+	if l := logger.Load(); l != nil {
+		return l.Logger
+	}
+
+	return discard{}
+}
+
+// logEvent logs e
+func (p *Puller) logEvent(e Event) {
+	args := []any{"url", e.URL}
+	if e.Duration > 0 {
+		args = append(args, "duration", e.Duration)
+	}
+	if e.Err != nil {
+		args = append(args, "err", e.Err)
+	}
+	if len(p.RequestID) > 0 {
+		args = append(args, "request_id", p.RequestID)
+	}
+
+	if e.Kind == DecodeFailed {
+		p.log().Warn(messages[e.Kind], args...)
+		return
+	}
+
+	p.log().Debug(messages[e.Kind], args...)
+}
@@ -0,0 +1,63 @@
+package wikimg
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bad" {
+			w.Write([]byte("not an image"))
+			return
+		}
+
+		png.Encode(w, image.NewNRGBA(image.Rect(0, 0, 1, 1)))
+	}))
+	defer ts.Close()
+
+	// Only warnings from the package logger
+	global := &bytes.Buffer{}
+	SetLogger(slog.New(slog.NewTextHandler(global, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	defer SetLogger(nil)
+
+	p := NewPuller(0)
+	p.RequestID = "abc"
+	p.FirstColor(ts.URL + "/good")
+	p.FirstColor(ts.URL + "/bad")
+
+	out := global.String()
+	if strings.Count(out, "\n") != 1 || !strings.Contains(out, `level=WARN msg="wikimg: couldn't decode image"`) ||
+		!strings.Contains(out, "/bad") || !strings.Contains(out, "request_id=abc") {
+		t.Errorf("expected one decode warning but got:\n%s", out)
+	}
+
+	// Everything from the puller's own logger, and nothing from the
+	// package logger
+	global.Reset()
+	own := &bytes.Buffer{}
+	p.Logger = slog.New(slog.NewTextHandler(own, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	p.FirstColor(ts.URL + "/good")
+
+	if global.Len() > 0 {
+		t.Errorf("expected nothing in the package logger but got:\n%s", global)
+	}
+	if !strings.Contains(own.String(), `level=DEBUG msg="wikimg: analyzed image" url=`+ts.URL+"/good duration=") {
+		t.Errorf("expected a debug message but got:\n%s", own)
+	}
+}
+
+func TestLoggerDefault(t *testing.T) {
+	SetLogger(nil)
+
+	p := NewPuller(0)
+	if _, ok := p.log().(discard); !ok {
+		t.Errorf("expected no logging by default but got %T", p.log())
+	}
+}
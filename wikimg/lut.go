@@ -0,0 +1,184 @@
+package wikimg
+
+import (
+	"image/color"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// lutBits is how many of the high bits of each of red, green and blue
+	// pick a cell of a paletteLUT
+	lutBits = 5
+
+	// lutShift turns a 16-bit channel into its cell coordinate
+	lutShift = 16 - lutBits
+
+	// lutCells is the number of cells in a paletteLUT
+	lutCells = 1 << (3 * lutBits)
+)
+
+// paletteLUT finds the nearest palette color the same way as
+// color.Palette.Index, but without scanning the whole palette. RGB space
+// is divided into a grid of cells, and each cell lists the only palette
+// colors that can be nearest to a color in it, usually a handful. Cells
+// are filled in the first time they're used.
+type paletteLUT struct {
+	// colors are the palette's colors as 16-bit RGBA values
+	colors [][4]uint32
+
+	// cells are the candidates of each cell, in palette order
+	cells [lutCells]atomic.Pointer[[]uint16]
+}
+
+// paletteLUTs caches the lookup tables of palettes by paletteKey
+var paletteLUTs sync.Map
+
+// lutFor returns the lookup table of pal, or nil if pal can't have one
+func lutFor(pal color.Palette) *paletteLUT {
+	if len(pal) < 1 || len(pal) > 1<<16 {
+		return nil
+	}
+
+	key := paletteKey{&pal[0], len(pal)}
+	if lut, ok := paletteLUTs.Load(key); ok {
+		return lut.(*paletteLUT)
+	}
+
+	lut := &paletteLUT{colors: make([][4]uint32, len(pal))}
+	for i, c := range pal {
+		r, g, b, a := c.RGBA()
+		lut.colors[i] = [4]uint32{r, g, b, a}
+	}
+
+	// If another goroutine got there first, use its table
+	actual, _ := paletteLUTs.LoadOrStore(key, lut)
+
+	return actual.(*paletteLUT)
+}
+
+// paletteIndex returns the index of the palette color nearest to c, the same
+// as pal.Index(c)
+func paletteIndex(pal color.Palette, c color.Color) int {
+	lut := lutFor(pal)
+	if lut == nil {
+		return pal.Index(c)
+	}
+
+	return lut.index(c)
+}
+
+// index returns the index of the palette color nearest to c
+func (lut *paletteLUT) index(c color.Color) int {
+	r, g, b, a := c.RGBA()
+
+	// Alpha isn't part of the grid, so only opaque colors have cells
+	if a != 0xffff {
+		return lut.nearest(r, g, b, a, nil)
+	}
+
+	cell := r>>lutShift<<(2*lutBits) | g>>lutShift<<lutBits | b>>lutShift
+	candidates := lut.cells[cell].Load()
+	if candidates == nil {
+		candidates = lut.fill(cell)
+	}
+
+	return lut.nearest(r, g, b, a, *candidates)
+}
+
+// nearest returns the index of the palette color nearest to r, g, b, a,
+// considering only candidates, or the whole palette if candidates is nil.
+// Like color.Palette.Index, the first of equally near colors wins.
+func (lut *paletteLUT) nearest(r, g, b, a uint32, candidates []uint16) int {
+	best, bestSum := 0, uint32(1<<32-1)
+	try := func(i int) bool {
+		p := &lut.colors[i]
+		sum := sqDiff(r, p[0]) + sqDiff(g, p[1]) + sqDiff(b, p[2]) + sqDiff(a, p[3])
+		if sum < bestSum {
+			if sum == 0 {
+				best = i
+				return true
+			}
+			best, bestSum = i, sum
+		}
+
+		return false
+	}
+
+	if candidates == nil {
+		for i := range lut.colors {
+			if try(i) {
+				break
+			}
+		}
+	} else {
+		for _, i := range candidates {
+			if try(int(i)) {
+				break
+			}
+		}
+	}
+
+	return best
+}
+
+// fill finds and saves the candidates of cell. A palette color can't be
+// nearest to any color in the cell if even its nearest point in the cell is
+// farther than some other palette color's farthest point.
+func (lut *paletteLUT) fill(cell uint32) *[]uint16 {
+	const size = 1 << lutShift
+
+	lo := [3]uint32{
+		cell >> (2 * lutBits) << lutShift,
+		cell >> lutBits & (1<<lutBits - 1) << lutShift,
+		cell & (1<<lutBits - 1) << lutShift,
+	}
+
+	near := make([]uint64, len(lut.colors))
+	limit := uint64(1<<64 - 1)
+	for i, p := range lut.colors {
+		var nearSum, farSum uint64
+		for ch := 0; ch < 3; ch++ {
+			v, from, to := uint64(p[ch]), uint64(lo[ch]), uint64(lo[ch]+size-1)
+
+			switch {
+			case v < from:
+				nearSum += (from - v) * (from - v)
+			case v > to:
+				nearSum += (v - to) * (v - to)
+			}
+
+			far := to - v
+			if v > (from+to)/2 {
+				far = v - from
+			}
+			farSum += far * far
+		}
+
+		// Alpha is the same for every color in the cell
+		alpha := uint64(0xffff-p[3]) * uint64(0xffff-p[3])
+		near[i] = nearSum + alpha
+		limit = min(limit, farSum+alpha)
+	}
+
+	// color.Palette.Index divides each square by 4, rounding down, so
+	// allow for that rounding
+	var candidates []uint16
+	for i := range lut.colors {
+		if near[i]/4 <= limit/4+4 {
+			candidates = append(candidates, uint16(i))
+		}
+	}
+
+	lut.cells[cell].Store(&candidates)
+
+	return &candidates
+}
+
+// sqDiff returns the squared difference of x and y, shifted by 2 so that
+// adding four of them doesn't overflow a uint32, as in the image/color
+// package
+func sqDiff(x, y uint32) uint32 {
+	d := x - y
+	return (d * d) >> 2
+}
@@ -0,0 +1,110 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// randomColors returns n random colors of various types, some translucent
+func randomColors(r *rand.Rand, n int) []color.Color {
+	colors := make([]color.Color, n)
+	for i := range colors {
+		switch i % 4 {
+		case 0:
+			colors[i] = color.RGBA{uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256)), 0xff}
+		case 1:
+			colors[i] = color.NRGBA{uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256))}
+		case 2:
+			colors[i] = color.RGBA64{uint16(r.Intn(1 << 16)), uint16(r.Intn(1 << 16)), uint16(r.Intn(1 << 16)), 0xffff}
+		case 3:
+			colors[i] = color.Gray{uint8(r.Intn(256))}
+		}
+	}
+
+	return colors
+}
+
+func TestPaletteIndex(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	// A palette with duplicates and translucent colors, where ties matter
+	odd := color.Palette(randomColors(r, 40))
+	odd = append(odd, odd[:10]...)
+
+	palettes := map[string]color.Palette{
+		"xterm256": XTerm256,
+		"xterm16":  XTerm16,
+		"websafe":  WebSafe,
+		"odd":      odd,
+		"one":      {color.White},
+	}
+
+	colors := randomColors(r, 20000)
+
+	// The corners of cells, where rounding matters most
+	for _, v := range []uint16{0, 0x07ff, 0x0800, 0x7fff, 0x8000, 0xf7ff, 0xf800, 0xffff} {
+		colors = append(colors, color.RGBA64{v, 0xffff - v, v, 0xffff})
+	}
+
+	for name, pal := range palettes {
+		for _, c := range colors {
+			if expected, got := pal.Index(c), paletteIndex(pal, c); got != expected {
+				t.Fatalf("%s: %v: expected %d but got %d", name, c, expected, got)
+			}
+		}
+	}
+}
+
+func TestLUTFor(t *testing.T) {
+	if lutFor(XTerm256) != lutFor(XTerm256) {
+		t.Error("expected the same table for the same palette")
+	}
+
+	// XTerm16 shares XTerm256's array
+	if lutFor(XTerm16) == lutFor(XTerm256) {
+		t.Error("expected different tables for different palettes")
+	}
+
+	if lutFor(nil) != nil {
+		t.Error("expected no table for an empty palette")
+	}
+}
+
+func BenchmarkPaletteIndex(b *testing.B) {
+	colors := randomColors(rand.New(rand.NewSource(1)), 4096)
+	pal := color.Palette(XTerm256)
+
+	b.Run("Palette.Index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			pal.Index(colors[i%len(colors)])
+		}
+	})
+
+	b.Run("LUT", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			paletteIndex(pal, colors[i%len(colors)])
+		}
+	})
+}
+
+// BenchmarkFirstColor scans a grayscale image, which is the worst case:
+// every pixel is quantized without finding a color
+func BenchmarkFirstColor(b *testing.B) {
+	img := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	for i := 0; i < len(img.Pix); i += 4 {
+		v := uint8(i / 4 % 256)
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = v, v, v, 0xff
+	}
+
+	var opts ColorOptions
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := opts.firstColor(img, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
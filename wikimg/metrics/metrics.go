@@ -0,0 +1,140 @@
+// Package metrics exports what Pullers do as Prometheus metrics. Set a
+// Metrics as the Observer of every Puller and serve it for Prometheus to
+// scrape:
+//
+//	m := metrics.New()
+//	http.Handle("/metrics", m)
+//
+//	p := wikimg.NewPuller(100)
+//	p.Observer = m
+//
+// Metrics are written in the Prometheus text format
+// (https://prometheus.io/docs/instrumenting/exposition_formats/), so no
+// client library is needed.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// buckets are the upper bounds, in seconds, of the FirstColor latency
+// histogram. Cached results take microseconds and large images many
+// seconds.
+var buckets = []float64{0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Metrics counts the events of the Pullers it observes. It's safe to use
+// from many goroutines at once.
+type Metrics struct {
+	pages, images, errors, decodeErrors, hits, misses atomic.Int64
+
+	// counts are the cumulative counts of each bucket of the latency
+	// histogram, with sum and total its sum and count
+	counts []int64
+	sum    float64
+	total  int64
+	mutex  sync.Mutex
+}
+
+// New creates Metrics with every count at zero
+func New() *Metrics {
+	return &Metrics{counts: make([]int64, len(buckets))}
+}
+
+// Observe counts e
+func (m *Metrics) Observe(e wikimg.Event) {
+	switch e.Kind {
+	case wikimg.PageFetched:
+		m.pages.Add(1)
+
+	case wikimg.ImagePulled:
+		m.images.Add(1)
+
+	case wikimg.CacheHit:
+		m.hits.Add(1)
+
+	case wikimg.CacheMiss:
+		m.misses.Add(1)
+
+	case wikimg.DecodeFailed:
+		m.decodeErrors.Add(1)
+
+	case wikimg.Analyzed:
+		if e.Err != nil {
+			m.errors.Add(1)
+		}
+
+		s := e.Duration.Seconds()
+
+		m.mutex.Lock()
+		for i, b := range buckets {
+			if s <= b {
+				m.counts[i]++
+			}
+		}
+		m.sum += s
+		m.total++
+		m.mutex.Unlock()
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+
+	counter(cw, "wikimg_api_pages_total", "Pages of results fetched from the API.", m.pages.Load())
+	counter(cw, "wikimg_images_pulled_total", "Image URLs returned by Next.", m.images.Load())
+	counter(cw, "wikimg_first_color_errors_total", "Calls to FirstColor that failed.", m.errors.Load())
+	counter(cw, "wikimg_decode_errors_total", "Images that were downloaded but couldn't be decoded.", m.decodeErrors.Load())
+	counter(cw, "wikimg_cache_hits_total", "FirstColor results found in the cache.", m.hits.Load())
+	counter(cw, "wikimg_cache_misses_total", "FirstColor results not found in the cache.", m.misses.Load())
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := "wikimg_first_color_seconds"
+	fmt.Fprintf(cw, "# HELP %s How long calls to FirstColor took.\n# TYPE %s histogram\n", name, name)
+	for i, b := range buckets {
+		fmt.Fprintf(cw, "%s_bucket{le=\"%g\"} %d\n", name, b, m.counts[i])
+	}
+	fmt.Fprintf(cw, "%s_bucket{le=\"+Inf\"} %d\n", name, m.total)
+	fmt.Fprintf(cw, "%s_sum %g\n%s_count %d\n", name, m.sum, name, m.total)
+
+	return cw.n, cw.err
+}
+
+// counter writes a counter with its help text
+func counter(w io.Writer, name, help string, v int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+}
+
+// countingWriter counts the bytes written to w and keeps the first error
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+// Write writes to the underlying writer unless an earlier write failed
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+
+	n, err := cw.w.Write(b)
+	cw.n += int64(n)
+	cw.err = err
+
+	return n, err
+}
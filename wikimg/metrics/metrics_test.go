@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New()
+
+	m.Observe(wikimg.Event{Kind: wikimg.PageFetched})
+	m.Observe(wikimg.Event{Kind: wikimg.ImagePulled})
+	m.Observe(wikimg.Event{Kind: wikimg.ImagePulled})
+	m.Observe(wikimg.Event{Kind: wikimg.Analyzed, Duration: 20 * time.Millisecond})
+	m.Observe(wikimg.Event{Kind: wikimg.Analyzed, Duration: 2 * time.Second, Err: errors.New("failed")})
+	m.Observe(wikimg.Event{Kind: wikimg.DecodeFailed})
+
+	buf := &bytes.Buffer{}
+	if _, err := m.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	expected := []string{
+		"# TYPE wikimg_api_pages_total counter\nwikimg_api_pages_total 1\n",
+		"wikimg_images_pulled_total 2\n",
+		"wikimg_first_color_errors_total 1\n",
+		"wikimg_decode_errors_total 1\n",
+		"wikimg_cache_hits_total 0\n",
+		"# TYPE wikimg_first_color_seconds histogram\n",
+		`wikimg_first_color_seconds_bucket{le="0.01"} 0` + "\n",
+		`wikimg_first_color_seconds_bucket{le="0.05"} 1` + "\n",
+		`wikimg_first_color_seconds_bucket{le="2.5"} 2` + "\n",
+		`wikimg_first_color_seconds_bucket{le="+Inf"} 2` + "\n",
+		"wikimg_first_color_seconds_count 2\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Errorf("expected %q in:\n%s", e, out)
+		}
+	}
+}
+
+func TestPullerMetrics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bad" {
+			w.Write([]byte("not an image"))
+			return
+		}
+
+		png.Encode(w, image.NewNRGBA(image.Rect(0, 0, 1, 1)))
+	}))
+	defer ts.Close()
+
+	m := New()
+	p := wikimg.NewPuller(0)
+	p.Observer = m
+	p.Cache = wikimg.NewColorCache(10, 0)
+
+	p.FirstColor(ts.URL + "/good")
+	p.FirstColor(ts.URL + "/good")
+	p.FirstColor(ts.URL + "/bad")
+
+	if h, mi := m.hits.Load(), m.misses.Load(); h != 1 || mi != 2 {
+		t.Errorf("expected 1 hit and 2 misses but got %d, %d", h, mi)
+	}
+	if n := m.decodeErrors.Load(); n != 1 {
+		t.Errorf("expected 1 decode error but got %d", n)
+	}
+	if m.total != 3 || m.errors.Load() != 1 {
+		t.Errorf("expected 3 calls with 1 error but got %d, %d", m.total, m.errors.Load())
+	}
+}
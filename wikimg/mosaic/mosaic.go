@@ -0,0 +1,161 @@
+// Package mosaic draws a grid with a cell for each of many analyzed images,
+// filled with its color or a thumbnail of the image itself, and writes it
+// as a PNG to share:
+//
+//	m := &mosaic.Mosaic{Cols: 20}
+//	err := m.WriteFile(ctx, "wall.png", cells)
+//
+// Cells are drawn in rows from the top left, in the order given.
+package mosaic
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/brnstz/routine/pool"
+	"github.com/brnstz/routine/wikimg"
+)
+
+const (
+	// DefaultCols is the default for Mosaic.Cols
+	DefaultCols = 10
+
+	// DefaultCell is the default for Mosaic.Cell
+	DefaultCell = 32
+
+	// DefaultWorkers is the default for Mosaic.Workers
+	DefaultWorkers = 4
+)
+
+// Cell is an image in the mosaic
+type Cell struct {
+	// URL is the image, used to get its thumbnail
+	URL string
+
+	// Color is what the cell is filled with, or the background of its
+	// thumbnail
+	Color color.Color
+}
+
+// NewCell creates a Cell for the image at imgURL, filled with the color of
+// info
+func NewCell(imgURL string, info wikimg.ColorInfo) Cell {
+	return Cell{URL: imgURL, Color: color.RGBA{info.R, info.G, info.B, 0xff}}
+}
+
+// Mosaic draws cells in a grid. The zero value draws DefaultCols cells of
+// DefaultCell pixels to a row, filled with their colors.
+type Mosaic struct {
+	// Cols is the number of cells in each row. Zero means DefaultCols.
+	Cols int
+
+	// Cell is the width and height of each cell in pixels. Zero means
+	// DefaultCell.
+	Cell int
+
+	// Thumbnails optionally fills each cell with a thumbnail of its
+	// image, downloaded with this Puller, instead of its color. A
+	// thumbnail keeps its aspect ratio: it's cropped to the middle of
+	// the cell if it's too tall and its color fills the rest if it's too
+	// wide. Cells whose image fails are filled with their color.
+	Thumbnails *wikimg.Puller
+
+	// Workers is the number of thumbnails downloaded at once. Zero means
+	// DefaultWorkers.
+	Workers int
+}
+
+// orDefault returns v, or def if v is less than 1
+func orDefault(v, def int) int {
+	if v < 1 {
+		return def
+	}
+
+	return v
+}
+
+// Draw draws cells. Thumbnails that haven't been downloaded when ctx is
+// done are left as colors.
+func (m *Mosaic) Draw(ctx context.Context, cells []Cell) *image.RGBA {
+	size := orDefault(m.Cell, DefaultCell)
+	cols := min(orDefault(m.Cols, DefaultCols), max(len(cells), 1))
+	rows := max((len(cells)+cols-1)/cols, 1)
+
+	img := image.NewRGBA(image.Rect(0, 0, cols*size, rows*size))
+	for i, c := range cells {
+		draw.Draw(img, m.bounds(i, cols), image.NewUniform(c.Color), image.Point{}, draw.Src)
+	}
+
+	if m.Thumbnails == nil {
+		return img
+	}
+
+	// Each cell is only drawn by one worker, so they can share img
+	pool.Run(ctx, orDefault(m.Workers, DefaultWorkers), pool.Feed(ctx, indexes(len(cells))), func(ctx context.Context, i int) error {
+		thumb, err := m.Thumbnails.Downscale(cells[i].URL, size, 0)
+		if err != nil {
+			return nil
+		}
+
+		// Center the thumbnail in its cell, cropping what doesn't fit
+		r := m.bounds(i, cols)
+		offset := image.Pt((thumb.Rect.Dx()-size)/2, (thumb.Rect.Dy()-size)/2)
+		dest := r.Intersect(thumb.Rect.Sub(thumb.Rect.Min).Sub(offset).Add(r.Min))
+		draw.Draw(img, dest, thumb, thumb.Rect.Min.Add(offset).Add(dest.Min.Sub(r.Min)), draw.Over)
+
+		return nil
+	})
+
+	return img
+}
+
+// bounds returns the rectangle of cell i in a grid of cols
+func (m *Mosaic) bounds(i, cols int) image.Rectangle {
+	size := orDefault(m.Cell, DefaultCell)
+	x, y := i%cols*size, i/cols*size
+
+	return image.Rect(x, y, x+size, y+size)
+}
+
+// indexes returns 0 through n-1
+func indexes(n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = i
+	}
+
+	return s
+}
+
+// WritePNG draws cells and writes them to w as a PNG
+func (m *Mosaic) WritePNG(ctx context.Context, w io.Writer, cells []Cell) error {
+	return png.Encode(w, m.Draw(ctx, cells))
+}
+
+// WriteFile draws cells and writes them to a PNG file called name
+func (m *Mosaic) WriteFile(ctx context.Context, name string, cells []Cell) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	err = m.WritePNG(ctx, f, cells)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// ServePNG draws cells and writes them as the PNG response to r.
+// Thumbnails stop downloading if the client goes away.
+func (m *Mosaic) ServePNG(w http.ResponseWriter, r *http.Request, cells []Cell) {
+	w.Header().Set("Content-Type", "image/png")
+	m.WritePNG(r.Context(), w, cells)
+}
@@ -0,0 +1,122 @@
+package mosaic
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+var (
+	red   = color.RGBA{0xff, 0x00, 0x00, 0xff}
+	green = color.RGBA{0x00, 0xff, 0x00, 0xff}
+	blue  = color.RGBA{0x00, 0x00, 0xff, 0xff}
+)
+
+func TestDraw(t *testing.T) {
+	m := &Mosaic{Cols: 2, Cell: 4}
+	img := m.Draw(context.Background(), []Cell{{Color: red}, {Color: green}, {Color: blue}})
+
+	if img.Bounds() != image.Rect(0, 0, 8, 8) {
+		t.Fatalf("unexpected bounds %v", img.Bounds())
+	}
+
+	expected := map[image.Point]color.RGBA{
+		{0, 0}: red,
+		{7, 3}: green,
+		{3, 7}: blue,
+		{7, 7}: {},
+	}
+	for p, c := range expected {
+		if got := img.RGBAAt(p.X, p.Y); got != c {
+			t.Errorf("expected %v at %v but got %v", c, p, got)
+		}
+	}
+
+	// Fewer cells than columns makes a single short row
+	img = m.Draw(context.Background(), []Cell{{Color: red}})
+	if img.Bounds() != image.Rect(0, 0, 4, 4) {
+		t.Errorf("unexpected bounds %v", img.Bounds())
+	}
+}
+
+func TestDrawThumbnails(t *testing.T) {
+	// A tall image, blue at the top and bottom and red in the middle, and
+	// a wide one that's all green
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var img *image.RGBA
+		switch r.URL.Path {
+		case "/tall.png":
+			img = image.NewRGBA(image.Rect(0, 0, 4, 12))
+			for y := 0; y < 12; y++ {
+				for x := 0; x < 4; x++ {
+					c := blue
+					if y >= 4 && y < 8 {
+						c = red
+					}
+					img.SetRGBA(x, y, c)
+				}
+			}
+		case "/wide.png":
+			img = image.NewRGBA(image.Rect(0, 0, 8, 4))
+			for i := 0; i < len(img.Pix); i += 4 {
+				copy(img.Pix[i:], []uint8{0x00, 0xff, 0x00, 0xff})
+			}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		png.Encode(w, img)
+	}))
+	defer images.Close()
+
+	m := &Mosaic{Cols: 3, Cell: 4, Thumbnails: wikimg.NewPuller(0)}
+	img := m.Draw(context.Background(), []Cell{
+		{URL: images.URL + "/tall.png", Color: green},
+		{URL: images.URL + "/wide.png", Color: blue},
+		{URL: images.URL + "/missing.png", Color: red},
+	})
+
+	expected := map[image.Point]color.RGBA{
+		// The middle of the tall image fills its cell
+		{0, 0}: red,
+		{3, 3}: red,
+
+		// The wide image is centered over its color
+		{5, 0}: blue,
+		{5, 2}: green,
+		{5, 3}: blue,
+
+		// The missing image is its color
+		{9, 1}: red,
+	}
+	for p, c := range expected {
+		if got := img.RGBAAt(p.X, p.Y); got != c {
+			t.Errorf("expected %v at %v but got %v", c, p, got)
+		}
+	}
+}
+
+func TestServePNG(t *testing.T) {
+	m := &Mosaic{Cell: 2}
+	w := httptest.NewRecorder()
+	m.ServePNG(w, httptest.NewRequest("GET", "/mosaic.png", nil), []Cell{NewCell("", wikimg.ColorInfo{R: 0xff, Hex: "#ff0000"})})
+
+	if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("expected image/png but got %s", ct)
+	}
+
+	img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, _ := img.At(1, 1).RGBA(); r != 0xffff || g != 0 || b != 0 {
+		t.Errorf("expected red but got %v", img.At(1, 1))
+	}
+}
@@ -0,0 +1,38 @@
+package wikimg
+
+import (
+	"image/color"
+	"math"
+)
+
+// cssLabs are the L*a*b* values of cssColors, computed once so Name() only
+// has to convert the color being named
+var cssLabs = func() []lab {
+	labs := make([]lab, len(cssColors))
+	for i, css := range cssColors {
+		labs[i] = toLab(css.c)
+	}
+
+	return labs
+}()
+
+// Name returns the name of the CSS color (e.g., "tomato" or "steelblue")
+// that looks closest to c
+func Name(c color.Color) string {
+	target := toLab(c)
+
+	best := 0
+	bestDist := math.Inf(1)
+	for i, l := range cssLabs {
+		if d := deltaE76(target, l); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+
+	return cssColors[best].name
+}
+
+// Name returns the name of the CSS color that looks closest to the color
+func (info ColorInfo) Name() string {
+	return Name(color.RGBA{info.R, info.G, info.B, 0xff})
+}
@@ -0,0 +1,25 @@
+package wikimg
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		in   color.Color
+		name string
+	}{
+		{color.RGBA{0xff, 0x63, 0x47, 0xff}, "tomato"},
+		{color.RGBA{0xff, 0x64, 0x48, 0xff}, "tomato"},
+		{color.RGBA{0x46, 0x82, 0xb5, 0xff}, "steelblue"},
+		{color.RGBA{0x01, 0x01, 0x01, 0xff}, "black"},
+		{color.RGBA{0x00, 0xff, 0xff, 0xff}, "cyan"},
+	}
+
+	for _, test := range tests {
+		if name := Name(test.in); name != test.name {
+			t.Errorf("%v: expected %s but got %s", test.in, test.name, name)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package wikimg
+
+import (
+	"errors"
+	"time"
+)
+
+// EventKind is what happened in an Event
+type EventKind int
+
+const (
+	// PageFetched is a page of results received from the API
+	PageFetched EventKind = iota
+
+	// ImagePulled is an image returned by Next()
+	ImagePulled
+
+	// Analyzed is a call to FirstColor() finishing. Duration is how long
+	// it took and Err is set if it failed.
+	Analyzed
+
+	// CacheHit and CacheMiss are lookups in Puller.Cache
+	CacheHit
+	CacheMiss
+
+	// DecodeFailed is an image that was downloaded but couldn't be
+	// decoded, with Err saying why
+	DecodeFailed
+)
+
+// String returns the name of the kind
+func (k EventKind) String() string {
+	switch k {
+	case PageFetched:
+		return "page_fetched"
+	case ImagePulled:
+		return "image_pulled"
+	case Analyzed:
+		return "analyzed"
+	case CacheHit:
+		return "cache_hit"
+	case CacheMiss:
+		return "cache_miss"
+	case DecodeFailed:
+		return "decode_failed"
+	}
+
+	return "unknown"
+}
+
+// Event is something a Puller did
+type Event struct {
+	Kind EventKind
+
+	// URL is the API or image URL the event is about
+	URL string
+
+	// Duration is how long it took, for Analyzed events
+	Duration time.Duration
+
+	// Err is why it failed, for Analyzed and DecodeFailed events
+	Err error
+}
+
+// Observer is notified of everything a Puller does, e.g., to export
+// metrics (see the metrics package). Observe is called synchronously from
+// whichever goroutine is using the Puller, so it must be fast and safe for
+// concurrent use.
+type Observer interface {
+	Observe(e Event)
+}
+
+// observe logs e and notifies p.Observer of it, if there is one
+func (p *Puller) observe(e Event) {
+	p.logEvent(e)
+
+	if p.Observer != nil {
+		p.Observer.Observe(e)
+	}
+}
+
+// decodeFailed notifies p.Observer that decoding imgURL failed with err,
+// unless it failed because the download was canceled or stalled
+func (p *Puller) decodeFailed(imgURL string, err error) {
+	var stalled *StalledError
+	if err == Canceled || errors.As(err, &stalled) {
+		return
+	}
+
+	p.observe(Event{Kind: DecodeFailed, URL: imgURL, Err: err})
+}
@@ -0,0 +1,77 @@
+package wikimg
+
+import (
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// recorder is an Observer that keeps every event
+type recorder struct {
+	events []Event
+	mutex  sync.Mutex
+}
+
+func (r *recorder) Observe(e Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.events = append(r.events, e)
+}
+
+// kinds returns the kinds of the recorded events in order
+func (r *recorder) kinds() []EventKind {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	kinds := []EventKind{}
+	for _, e := range r.events {
+		kinds = append(kinds, e.Kind)
+	}
+
+	return kinds
+}
+
+func TestObserver(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bad" {
+			w.Write([]byte("not an image"))
+			return
+		}
+
+		png.Encode(w, image.NewNRGBA(image.Rect(0, 0, 1, 1)))
+	}))
+	defer ts.Close()
+
+	rec := &recorder{}
+	p := NewPuller(0)
+	p.Observer = rec
+	p.Cache = NewColorCache(10, 0)
+
+	p.FirstColor(ts.URL + "/good")
+	p.FirstColor(ts.URL + "/good")
+	_, err := p.FirstColor(ts.URL + "/bad")
+
+	expected := []EventKind{
+		CacheMiss, Analyzed,
+		CacheHit, Analyzed,
+		CacheMiss, DecodeFailed, Analyzed,
+	}
+	kinds := rec.kinds()
+	if len(kinds) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, kinds)
+	}
+	for i := range expected {
+		if kinds[i] != expected[i] {
+			t.Fatalf("expected %v but got %v", expected, kinds)
+		}
+	}
+
+	last := rec.events[len(rec.events)-1]
+	if err == nil || last.Err == nil {
+		t.Errorf("expected the bad image to fail but got %v, %v", err, last.Err)
+	}
+}
@@ -0,0 +1,272 @@
+package wikimg
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image/color"
+)
+
+// Distance is a way of measuring how different two colors are, used to find
+// the nearest palette color
+type Distance int
+
+const (
+	// RGB is the Euclidean distance between RGB values, as used by
+	// color.Palette. It's fast, but picks visually wrong palette colors
+	// for some hues.
+	RGB Distance = iota
+
+	// CIE76 is the Euclidean distance between CIE L*a*b* values, which
+	// approximates how different colors look
+	CIE76
+
+	// CIEDE2000 is the most accurate perceptual distance, and the
+	// slowest
+	CIEDE2000
+)
+
+// Alpha is a way of handling pixels that aren't fully opaque
+type Alpha int
+
+const (
+	// KeepAlpha uses pixels as they are. Fully transparent pixels are
+	// usually stored as transparent black, so they're counted as black.
+	KeepAlpha Alpha = iota
+
+	// SkipTransparent ignores fully transparent pixels
+	SkipTransparent
+
+	// Composite blends pixels over ColorOptions.Background, as they'd
+	// appear on a page
+	Composite
+)
+
+// ColorSource is which color is reported for a pixel: the palette color it
+// maps to, or the pixel's own color
+type ColorSource int
+
+const (
+	// PaletteColor reports the palette color a pixel maps to. This is what
+	// a terminal can show.
+	PaletteColor ColorSource = iota
+
+	// PixelColor reports the color of the pixel itself, which is usually
+	// what the web wants
+	PixelColor
+)
+
+// ParseColorSource returns the ColorSource named by s, either "palette" or
+// "pixel"
+func ParseColorSource(s string) (ColorSource, error) {
+	switch s {
+	case "palette":
+		return PaletteColor, nil
+	case "pixel":
+		return PixelColor, nil
+	}
+
+	return PaletteColor, fmt.Errorf("wikimg: invalid color source %q (must be palette or pixel)", s)
+}
+
+// String returns the name of the color source
+func (s ColorSource) String() string {
+	if s == PixelColor {
+		return "pixel"
+	}
+
+	return "palette"
+}
+
+// Method is how FirstColor picks an image's color
+type Method int
+
+const (
+	// FirstNonGray picks the first pixel that isn't gray, scanning from
+	// the top left. It's fast, since it usually stops early.
+	FirstNonGray Method = iota
+
+	// SaturationPercentile picks the pixel at ColorOptions.Percentile of
+	// the sampled pixels ordered by saturation. Unlike FirstNonGray, it
+	// isn't thrown off by a stray colored pixel in the corner, and unlike
+	// an average it's a color that's actually in the image. It scans
+	// every sampled pixel.
+	SaturationPercentile
+)
+
+// ColorOptions configures how images are scanned and how their colors are
+// mapped to a palette
+type ColorOptions struct {
+	// Palette is the palette that image colors are mapped to. If nil,
+	// XTerm256 is used.
+	Palette color.Palette
+
+	// Unquantized skips palette mapping entirely. Colors are reported
+	// exactly as they appear in the image and the returned index is -1.
+	Unquantized bool
+
+	// Report is which color FirstColor describes in the Hex, RGB and HSL
+	// fields of ColorInfo. Palette mapping still decides which pixel is
+	// the first color, and Index is always the palette index. The default
+	// is PaletteColor.
+	Report ColorSource
+
+	// Method is how the image's color is picked. The default is
+	// FirstNonGray.
+	Method Method
+
+	// Percentile is the saturation percentile, between 0 and 1, picked by
+	// the SaturationPercentile method. Zero means 0.9, i.e., a color more
+	// saturated than 90% of the image.
+	Percentile float64
+
+	// Distance is how the nearest palette color is chosen. The default is
+	// RGB.
+	Distance Distance
+
+	// Stride scans only every Nth pixel in each direction, so a Stride of
+	// 4 looks at 1/16th of the pixels. Values less than 2 scan every
+	// pixel.
+	Stride int
+
+	// MaxSize downscales the image before scanning so that neither side is
+	// longer than MaxSize pixels. Zero means no downscaling.
+	MaxSize int
+
+	// Scaler downscales images for MaxSize. If nil, pixels are sampled
+	// with nearest neighbor scaling on the fly, which needs no memory but
+	// is noisy. A Scaler (see the wikimg/scale package) allocates the
+	// downscaled image, but can average neighboring pixels.
+	Scaler Scaler
+
+	// GraySpread is the largest difference between the 8-bit red, green
+	// and blue values of a color that is still considered gray. Zero, the
+	// default, means only colors where all three are equal are gray.
+	GraySpread int
+
+	// GraySaturation is the HSL saturation (between 0 and 1) at or below
+	// which a color is considered gray. Zero means saturation isn't
+	// considered.
+	GraySaturation float64
+
+	// Alpha is how pixels that aren't fully opaque are handled. The
+	// default is KeepAlpha.
+	Alpha Alpha
+
+	// Background is the color that pixels are composited over when Alpha
+	// is Composite. If nil, white is used.
+	Background color.Color
+
+	// MeasureQuality computes how faithfully the palette represents the
+	// image, reported as DeltaE in ColorInfo. This scans every sampled
+	// pixel, even when the first color is found right away.
+	MeasureQuality bool
+
+	// MeasureHistogram counts the sampled pixels that map to each palette
+	// color, reported as Histogram in ColorInfo, e.g., to add to an
+	// Aggregator. Like MeasureQuality, it scans every sampled pixel.
+	MeasureHistogram bool
+}
+
+// palette returns the palette colors should be mapped to, or nil if
+// quantization is disabled
+func (o ColorOptions) palette() color.Palette {
+	if o.Unquantized {
+		return nil
+	}
+
+	if o.Palette == nil {
+		return color.Palette(XTerm256)
+	}
+
+	return o.Palette
+}
+
+// quantize maps c to the palette, returning the palette color and its index.
+// If quantization is disabled, c itself is returned with an index of -1.
+func (o ColorOptions) quantize(c color.Color) (color.Color, int) {
+	pal := o.palette()
+	if pal == nil {
+		return c, -1
+	}
+
+	var i int
+	switch o.Distance {
+	case CIE76:
+		i = nearestLab(pal, c, deltaE76)
+	case CIEDE2000:
+		i = nearestLab(pal, c, deltaE2000)
+	default:
+		i = paletteIndex(pal, c)
+	}
+
+	return pal[i], i
+}
+
+// source returns which color is actually reported. Without a palette, the
+// palette color is the pixel color.
+func (o ColorOptions) source() ColorSource {
+	if o.palette() == nil {
+		return PixelColor
+	}
+
+	return o.Report
+}
+
+// isGray returns true if info is considered gray
+func (o ColorOptions) isGray(info ColorInfo) bool {
+	spread := int(max(info.R, info.G, info.B)) - int(min(info.R, info.G, info.B))
+	if spread <= o.GraySpread {
+		return true
+	}
+
+	return o.GraySaturation > 0 && info.S <= o.GraySaturation
+}
+
+// Key returns a short hash identifying the options. Options that produce
+// the same results have the same key (e.g., a nil Palette and XTerm256), so
+// it can be combined with an image URL as a cache key.
+func (o ColorOptions) Key() string {
+	h := sha1.New()
+
+	// Palette, or "unquantized" when there isn't one
+	pal := o.palette()
+	if pal == nil {
+		fmt.Fprint(h, "unquantized;")
+	}
+	for _, c := range pal {
+		r, g, b, a := c.RGBA()
+		fmt.Fprintf(h, "%04x%04x%04x%04x,", r, g, b, a)
+	}
+
+	fmt.Fprintf(h, ";distance=%d;report=%s", o.Distance, o.source())
+	if o.Method == SaturationPercentile {
+		fmt.Fprintf(h, ";percentile=%g", o.percentileRank())
+	}
+
+	// Sampling strategy. A stride of 0 and 1 are the same.
+	fmt.Fprintf(h, ";stride=%d;maxsize=%d", max(o.Stride, 1), max(o.MaxSize, 0))
+	if o.Scaler != nil && o.MaxSize > 0 {
+		fmt.Fprintf(h, ";scaler=%T/%v", o.Scaler, o.Scaler)
+	}
+
+	// What counts as gray
+	fmt.Fprintf(h, ";grayspread=%d;graysat=%g", max(o.GraySpread, 0), max(o.GraySaturation, 0))
+
+	// Transparency. The background only matters when compositing.
+	fmt.Fprintf(h, ";alpha=%d", o.Alpha)
+	if o.Alpha == Composite {
+		r, g, b, a := o.background().RGBA()
+		fmt.Fprintf(h, ";background=%04x%04x%04x%04x", r, g, b, a)
+	}
+
+	// Extra measurements
+	fmt.Fprintf(h, ";quality=%t", o.MeasureQuality)
+
+	// Only when set, so keys cached before it existed stay valid
+	if o.MeasureHistogram {
+		fmt.Fprint(h, ";histogram")
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
@@ -0,0 +1,66 @@
+package wikimg
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	def := ColorOptions{}.Key()
+
+	same := []ColorOptions{
+		{Palette: XTerm256},
+		{Stride: 1},
+	}
+	for _, o := range same {
+		if k := o.Key(); k != def {
+			t.Errorf("%+v: expected key %s but got %s", o, def, k)
+		}
+	}
+
+	different := []ColorOptions{
+		{Palette: XTerm16},
+		{Palette: WebSafe},
+		{Unquantized: true},
+		{Stride: 2},
+		{MaxSize: 100},
+		{GraySpread: 3},
+		{GraySaturation: 0.1},
+		{Report: PixelColor},
+		{Alpha: SkipTransparent},
+		{Alpha: Composite},
+		{MeasureHistogram: true},
+		{Alpha: Composite, Background: color.Black},
+	}
+	for _, o := range different {
+		if k := o.Key(); k == def {
+			t.Errorf("%+v: expected key different from default", o)
+		}
+	}
+}
+
+func TestIsGray(t *testing.T) {
+	tests := []struct {
+		opts ColorOptions
+		hex  string
+		gray bool
+	}{
+		{ColorOptions{}, "#262626", true},
+		{ColorOptions{}, "#252627", false},
+		{ColorOptions{GraySpread: 2}, "#252627", true},
+		{ColorOptions{GraySpread: 2}, "#252628", false},
+		{ColorOptions{GraySaturation: 0.1}, "#252627", true},
+		{ColorOptions{GraySaturation: 0.1}, "#ff0000", false},
+	}
+
+	for _, test := range tests {
+		c, err := ParseHex(test.hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if gray := test.opts.isGray(newColorInfo(c, -1)); gray != test.gray {
+			t.Errorf("%+v %s: expected %v but got %v", test.opts, test.hex, test.gray, gray)
+		}
+	}
+}
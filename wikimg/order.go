@@ -0,0 +1,116 @@
+package wikimg
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Order is an order to show colors in, e.g., to draw a wall as a gradient
+// rather than noise
+type Order int
+
+const (
+	// Unsorted leaves colors in the order they were found
+	Unsorted Order = iota
+
+	// ByHue goes around the color wheel from red, through green and
+	// blue, back to red. Grays come last, from dark to light.
+	ByHue
+
+	// ByLuminance goes from dark to light
+	ByLuminance
+
+	// HueBuckets groups colors into HueBucketCount buckets of similar hue,
+	// in the order of ByHue, each from dark to light. Grays are the last
+	// bucket.
+	HueBuckets
+)
+
+const (
+	// HueBucketCount is the number of buckets of colored hues for
+	// HueBuckets, each 30 degrees wide, centered on red, orange, yellow,
+	// etc.
+	HueBucketCount = 12
+
+	// graySaturation is the saturation below which a color's hue is too
+	// faint to sort by
+	graySaturation = 0.1
+)
+
+// orderNames are the names of the orders, as accepted by ParseOrder
+var orderNames = map[Order]string{
+	Unsorted:    "none",
+	ByHue:       "hue",
+	ByLuminance: "luminance",
+	HueBuckets:  "buckets",
+}
+
+// ParseOrder returns the order named by s: "none", "hue", "luminance" or
+// "buckets"
+func ParseOrder(s string) (Order, error) {
+	for k, v := range orderNames {
+		if v == s {
+			return k, nil
+		}
+	}
+
+	return Unsorted, fmt.Errorf("wikimg: invalid order %q (must be none, hue, luminance or buckets)", s)
+}
+
+// String returns the name of the order
+func (o Order) String() string {
+	return orderNames[o]
+}
+
+// HueBucket returns the bucket of info for HueBuckets, from 0 (red) to
+// HueBucketCount - 1, or HueBucketCount for grays
+func HueBucket(info ColorInfo) int {
+	if isGrayish(info) {
+		return HueBucketCount
+	}
+
+	width := 360.0 / HueBucketCount
+
+	return int((info.H+width/2)/width) % HueBucketCount
+}
+
+// isGrayish returns true if info's hue is too faint to sort by
+func isGrayish(info ColorInfo) bool {
+	return info.Gray || info.S < graySaturation
+}
+
+// Less returns true if a comes before b in the order
+func (o Order) Less(a, b ColorInfo) bool {
+	switch o {
+	case ByHue:
+		if ga, gb := isGrayish(a), isGrayish(b); ga != gb {
+			return gb
+		} else if !ga && a.H != b.H {
+			return a.H < b.H
+		}
+
+	case HueBuckets:
+		if ba, bb := HueBucket(a), HueBucket(b); ba != bb {
+			return ba < bb
+		}
+
+	case ByLuminance:
+
+	default:
+		return false
+	}
+
+	return a.Luminance < b.Luminance
+}
+
+// SortColors sorts items, whose colors are returned by info, in the order
+// o. Items that are equal in the order keep their order.
+func SortColors[T any](items []T, o Order, info func(T) ColorInfo) {
+	if o == Unsorted {
+		return
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return o.Less(info(items[i]), info(items[j]))
+	})
+}
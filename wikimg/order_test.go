@@ -0,0 +1,55 @@
+package wikimg
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestSortColors(t *testing.T) {
+	infos := map[string]ColorInfo{}
+	for name, c := range map[string]color.NRGBA{
+		"red":       {255, 0, 0, 255},
+		"darkred":   {128, 0, 0, 255},
+		"orange":    {255, 128, 0, 255},
+		"green":     {0, 200, 0, 255},
+		"blue":      {0, 0, 255, 255},
+		"crimson":   {220, 20, 60, 255},
+		"white":     {255, 255, 255, 255},
+		"black":     {0, 0, 0, 255},
+		"lightgray": {200, 200, 200, 255},
+	} {
+		infos[name] = newColorInfo(c, 0)
+	}
+
+	names := []string{"white", "blue", "red", "black", "green", "crimson", "orange", "darkred", "lightgray"}
+	for _, test := range []struct {
+		order    Order
+		expected string
+	}{
+		{Unsorted, "white blue red black green crimson orange darkred lightgray"},
+		{ByHue, "darkred red orange green blue crimson black lightgray white"},
+		{ByLuminance, "black darkred blue crimson red orange green lightgray white"},
+
+		// Crimson is close enough to red to share its bucket
+		{HueBuckets, "darkred crimson red orange green blue black lightgray white"},
+	} {
+		sorted := append([]string(nil), names...)
+		SortColors(sorted, test.order, func(name string) ColorInfo { return infos[name] })
+
+		if got := strings.Join(sorted, " "); got != test.expected {
+			t.Errorf("%s: expected %s but got %s", test.order, test.expected, got)
+		}
+	}
+
+	if HueBucket(infos["red"]) != 0 || HueBucket(infos["crimson"]) != 0 || HueBucket(infos["white"]) != HueBucketCount {
+		t.Error("unexpected hue buckets")
+	}
+
+	if o, err := ParseOrder("buckets"); err != nil || o != HueBuckets {
+		t.Errorf("expected HueBuckets but got %v, %v", o, err)
+	}
+	if _, err := ParseOrder("rainbow"); err == nil {
+		t.Error("expected an error for an unknown order")
+	}
+}
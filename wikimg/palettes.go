@@ -0,0 +1,30 @@
+package wikimg
+
+import "image/color"
+
+var (
+	// XTerm16 is the 16 color palette supported by basic ANSI terminals. Like
+	// XTerm256, the index value is the color id.
+	XTerm16 = XTerm256[:16]
+
+	// WebSafe is the 216 color "web safe" palette, where each of red, green
+	// and blue is one of 0x00, 0x33, 0x66, 0x99, 0xcc or 0xff.
+	WebSafe = webSafe()
+)
+
+// webSafe generates the WebSafe palette
+func webSafe() []color.Color {
+	p := make([]color.Color, 0, 216)
+
+	for r := 0; r < 6; r++ {
+		for g := 0; g < 6; g++ {
+			for b := 0; b < 6; b++ {
+				p = append(p, color.RGBA{
+					uint8(r * 0x33), uint8(g * 0x33), uint8(b * 0x33), 0xff,
+				})
+			}
+		}
+	}
+
+	return p
+}
@@ -0,0 +1,140 @@
+package wikimg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// snippetLen is the most bytes of a payload quoted in a ParseError
+const snippetLen = 64
+
+var (
+	// ErrEmptyResponse is the Err of a ParseError for an empty body
+	ErrEmptyResponse = errors.New("empty response")
+
+	// ErrNotJSON is the Err of a ParseError for a body that isn't JSON at
+	// all, e.g., an HTML error page from a proxy
+	ErrNotJSON = errors.New("response isn't JSON")
+)
+
+// ParseError is returned when an API response can't be understood. Err is
+// what went wrong: ErrEmptyResponse, ErrNotJSON, io.ErrUnexpectedEOF for a
+// truncated body, or a *json.SyntaxError or *json.UnmarshalTypeError. Use
+// errors.Is and errors.As to check.
+type ParseError struct {
+	// URL is the API request
+	URL string
+
+	// Offset is where in the body the problem was found
+	Offset int64
+
+	// Snippet is the part of the body around Offset
+	Snippet string
+
+	Err error
+}
+
+// Error describes the problem and quotes the offending payload
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("wikimg: couldn't parse API response at byte %d (%v): %q: %s",
+		e.Offset, e.Err, e.Snippet, e.URL)
+}
+
+// Unwrap returns Err
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// rawQueryResp is queryResp with each image left undecoded, so an image
+// with unexpected fields can be skipped without losing the whole page
+type rawQueryResp struct {
+	Continue map[string]json.RawMessage
+	Query    struct {
+		AllImages []json.RawMessage
+	}
+}
+
+// parseQuery decodes the body b of the API response for u. Images that
+// can't be decoded are left out and returned as skipped.
+func parseQuery(u string, b []byte) (qr *queryResp, skipped []*ParseError, err error) {
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) < 1 {
+		return nil, nil, newParseError(u, b, 0, ErrEmptyResponse)
+	}
+	start := int64(len(b) - len(bytes.TrimLeft(b, " \t\r\n")))
+	if trimmed[0] != '{' {
+		return nil, nil, newParseError(u, b, start, ErrNotJSON)
+	}
+
+	var raw rawQueryResp
+	dec := json.NewDecoder(bytes.NewReader(b))
+	err = dec.Decode(&raw)
+	if err != nil {
+		offset := errorOffset(err)
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// The body ended early, so show its end
+			offset = int64(len(b))
+		}
+
+		return nil, nil, newParseError(u, b, offset, err)
+	}
+
+	qr = &queryResp{Continue: raw.Continue}
+	for _, rawImg := range raw.Query.AllImages {
+		var img apiImage
+		err := json.Unmarshal(rawImg, &img)
+		if err != nil {
+			skipped = append(skipped, newParseError(u, rawImg, errorOffset(err), err))
+			continue
+		}
+
+		qr.Query.AllImages = append(qr.Query.AllImages, img)
+	}
+
+	return qr, skipped, nil
+}
+
+// errorOffset returns where in the input err happened, if known
+func errorOffset(err error) int64 {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		return syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		return typeErr.Offset
+	}
+
+	return 0
+}
+
+// newParseError creates a ParseError for b with a snippet of b around
+// offset
+func newParseError(u string, b []byte, offset int64, err error) *ParseError {
+	if errors.Is(err, io.EOF) {
+		// A body that ends early isn't complete JSON
+		err = io.ErrUnexpectedEOF
+	}
+
+	offset = min(max(offset, 0), int64(len(b)))
+	start := max(offset-snippetLen/2, 0)
+	end := min(start+snippetLen, int64(len(b)))
+	if end-start < snippetLen {
+		start = max(end-snippetLen, 0)
+	}
+
+	// Don't split a UTF-8 character at either end
+	for start < end && !utf8.RuneStart(b[start]) {
+		start++
+	}
+	for end < int64(len(b)) && end > start && !utf8.RuneStart(b[end]) {
+		end--
+	}
+
+	return &ParseError{URL: u, Offset: offset, Snippet: string(b[start:end]), Err: err}
+}
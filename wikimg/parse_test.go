@@ -0,0 +1,165 @@
+package wikimg
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// validPage is a well formed page of API results
+const validPage = `{
+	"continue": {"aicontinue": "20240101|a.png", "continue": "-||"},
+	"query": {"allimages": [
+		{"url": "http://example.com/a.png", "title": "File:a.png", "timestamp": "2024-01-01T00:00:00Z"},
+		{"url": "http://example.com/b.png", "title": "File:b.png", "timestamp": "2023-12-31T23:59:00Z"}
+	]}
+}`
+
+func TestParseQuery(t *testing.T) {
+	qr, skipped, err := parseQuery("u", []byte(validPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) > 0 || len(qr.Query.AllImages) != 2 || len(qr.Continue) != 2 {
+		t.Errorf("unexpected result %+v, skipped %v", qr, skipped)
+	}
+}
+
+func TestParseQueryMalformed(t *testing.T) {
+	testCases := []struct {
+		name    string
+		body    string
+		err     error
+		snippet string
+	}{
+		{"empty", " \n", ErrEmptyResponse, ""},
+		{"html", "<!DOCTYPE html><html><body>502 Bad Gateway</body></html>", ErrNotJSON, "<!DOCTYPE html>"},
+		{"truncated", validPage[:100], io.ErrUnexpectedEOF, "allimages"},
+		{"syntax", `{"query": {"allimages": [}}`, nil, "[}"},
+		{"wrong type", `{"continue": "more", "query": {}}`, nil, `"more"`},
+	}
+
+	for _, tc := range testCases {
+		_, _, err := parseQuery("http://example.com/api", []byte(tc.body))
+
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%s: expected a *ParseError but got %v", tc.name, err)
+			continue
+		}
+		if tc.err != nil && !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected %v but got %v", tc.name, tc.err, perr.Err)
+		}
+		if !strings.Contains(perr.Snippet, tc.snippet) {
+			t.Errorf("%s: expected snippet to contain %q but got %q", tc.name, tc.snippet, perr.Snippet)
+		}
+		if !strings.Contains(err.Error(), "http://example.com/api") {
+			t.Errorf("%s: expected the URL in %q", tc.name, err)
+		}
+	}
+
+	_, _, err := parseQuery("u", []byte(`{"query": {"allimages": [}}`))
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("expected a *json.SyntaxError but got %v", err)
+	}
+}
+
+func TestParseQuerySkipsImages(t *testing.T) {
+	body := `{"query": {"allimages": [
+		{"url": "http://example.com/a.png", "timestamp": "2024-01-01T00:00:00Z"},
+		{"url": 42, "timestamp": "2024-01-01T00:00:00Z"},
+		{"url": "http://example.com/c.png", "timestamp": "yesterday"},
+		{"url": "http://example.com/d.png", "timestamp": "2024-01-01T00:00:00Z"}
+	]}}`
+
+	qr, skipped, err := parseQuery("u", []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(qr.Query.AllImages) != 2 || qr.Query.AllImages[1].URL != "http://example.com/d.png" {
+		t.Errorf("expected a.png and d.png but got %+v", qr.Query.AllImages)
+	}
+	if len(skipped) != 2 || !strings.Contains(skipped[0].Snippet, "42") {
+		t.Errorf("expected 2 skipped images but got %v", skipped)
+	}
+}
+
+func TestParseErrorSnippet(t *testing.T) {
+	// Long bodies are cut around the offset without splitting characters
+	body := []byte(strings.Repeat("é", 100))
+	perr := newParseError("u", body, 101, ErrNotJSON)
+
+	if len(perr.Snippet) > snippetLen || !utf8.ValidString(perr.Snippet) {
+		t.Errorf("expected a valid snippet of at most %d bytes but got %q", snippetLen, perr.Snippet)
+	}
+}
+
+func TestPullerMalformed(t *testing.T) {
+	html := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if html {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body>Wikimedia Error</body></html>"))
+			return
+		}
+
+		w.Write([]byte(validPage))
+	}))
+	defer ts.Close()
+
+	p := NewPuller(1)
+	p.APIURL = ts.URL
+
+	_, err := p.Next()
+	if !errors.Is(err, ErrNotJSON) {
+		t.Fatalf("expected ErrNotJSON but got %v", err)
+	}
+
+	// The bad page isn't cached, so trying again works once the API
+	// recovers
+	html = false
+	u, err := p.Next()
+	if err != nil || u != "http://example.com/a.png" {
+		t.Errorf("expected a.png but got %q, %v", u, err)
+	}
+}
+
+func FuzzParseQuery(f *testing.F) {
+	f.Add([]byte(validPage))
+	f.Add([]byte(validPage[:50]))
+	f.Add([]byte("<html></html>"))
+	f.Add([]byte(`{"query": {"allimages": [{"url": 1}]}}`))
+	f.Add([]byte(`{"continue": {"aicontinue": 5}}`))
+	f.Add([]byte(""))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		qr, skipped, err := parseQuery("u", b)
+
+		if err != nil {
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("expected a *ParseError but got %T", err)
+			}
+			if len(perr.Snippet) > snippetLen || perr.Offset < 0 || perr.Offset > int64(len(b)) {
+				t.Fatalf("bad snippet %q at %d of %d bytes", perr.Snippet, perr.Offset, len(b))
+			}
+			return
+		}
+
+		if qr == nil {
+			t.Fatal("expected a result without an error")
+		}
+		for _, perr := range skipped {
+			if len(perr.Snippet) > snippetLen {
+				t.Fatalf("snippet too long: %q", perr.Snippet)
+			}
+		}
+	})
+}
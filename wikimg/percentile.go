@@ -0,0 +1,63 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+const (
+	// percentileBins is how finely saturation is divided when finding a
+	// percentile, so we don't have to keep every pixel
+	percentileBins = 256
+
+	// defaultPercentile is the percentile used when none is set
+	defaultPercentile = 0.9
+)
+
+// percentileRank returns the percentile to pick, between 0 and 1
+func (o ColorOptions) percentileRank() float64 {
+	if o.Percentile <= 0 || o.Percentile > 1 {
+		return defaultPercentile
+	}
+
+	return o.Percentile
+}
+
+// percentile returns a sampled pixel of img at o.Percentile, ordered by
+// saturation, or nil if no pixels were sampled. Pixels are counted in bins
+// of similar saturation, and the first pixel seen in each bin represents it.
+func (o ColorOptions) percentile(img image.Image, cancel <-chan struct{}) (color.Color, error) {
+	var counts [percentileBins]int
+	var reps [percentileBins]color.Color
+	total := 0
+
+	_, err := o.scan(img, cancel, func(c color.Color) bool {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		_, s, _ := hsl(n.R, n.G, n.B)
+
+		bin := min(percentileBins-1, int(s*percentileBins))
+		counts[bin]++
+		if reps[bin] == nil {
+			reps[bin] = c
+		}
+		total++
+
+		return false
+	})
+	if err != nil || total < 1 {
+		return nil, err
+	}
+
+	// Find the bin containing the pixel at the percentile's rank
+	rank := max(1, int(math.Ceil(o.percentileRank()*float64(total))))
+	seen := 0
+	for bin, n := range counts {
+		seen += n
+		if seen >= rank {
+			return reps[bin], nil
+		}
+	}
+
+	return nil, nil
+}
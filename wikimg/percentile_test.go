@@ -0,0 +1,47 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSaturationPercentile(t *testing.T) {
+	// A single red pixel in the corner, a gray-blue image and a few
+	// saturated green pixels
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			img.Set(x, y, color.NRGBA{100, 100, 140, 255})
+		}
+	}
+	img.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+	for x := 0; x < 10; x++ {
+		img.Set(x, 9, color.NRGBA{40, 200, 40, 255})
+	}
+
+	tests := []struct {
+		opts ColorOptions
+		hex  string
+	}{
+		// The first pixel is the stray red one
+		{ColorOptions{Unquantized: true}, "#ff0000"},
+
+		// Most pixels are gray-blue
+		{ColorOptions{Unquantized: true, Method: SaturationPercentile, Percentile: 0.5}, "#64648c"},
+
+		// The most saturated tenth is mostly green
+		{ColorOptions{Unquantized: true, Method: SaturationPercentile}, "#28c828"},
+	}
+
+	for _, test := range tests {
+		info, err := test.opts.firstColor(img, nil)
+		if err != nil || info.Hex != test.hex {
+			t.Errorf("%+v: expected %s but got %s, %v", test.opts, test.hex, info.Hex, err)
+		}
+	}
+
+	if (ColorOptions{Method: SaturationPercentile}).Key() == (ColorOptions{Method: SaturationPercentile, Percentile: 0.5}).Key() {
+		t.Errorf("expected percentile to change the key")
+	}
+}
@@ -0,0 +1,133 @@
+package wikimg
+
+import (
+	"bufio"
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"sync"
+)
+
+const (
+	// maxPooledBuffer is the largest buffer returned to headPool. The
+	// headers of a few images are huge (e.g., embedded thumbnails and color
+	// profiles), and keeping those around would waste more memory than
+	// pooling saves.
+	maxPooledBuffer = 256 * 1024
+)
+
+var (
+	// headPool holds buffers for the bytes read while decoding an image's
+	// config, which are replayed for the full decode
+	headPool = sync.Pool{
+		New: func() interface{} { return &bytes.Buffer{} },
+	}
+
+	// readerPool holds buffered readers for decoding. Decoders that get a
+	// reader without ReadByte allocate their own, so we give them one.
+	readerPool = sync.Pool{
+		New: func() interface{} { return bufio.NewReader(nil) },
+	}
+)
+
+// getHead returns an empty buffer from headPool
+func getHead() *bytes.Buffer {
+	return headPool.Get().(*bytes.Buffer)
+}
+
+// putHead returns b to headPool, unless it has grown too large
+func putHead(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+
+	b.Reset()
+	headPool.Put(b)
+}
+
+// getReader returns a buffered reader from readerPool that reads from r
+func getReader(r io.Reader) *bufio.Reader {
+	br := readerPool.Get().(*bufio.Reader)
+	br.Reset(r)
+
+	return br
+}
+
+// putReader returns br to readerPool
+func putReader(br *bufio.Reader) {
+	br.Reset(nil)
+	readerPool.Put(br)
+}
+
+// memLimiter caps the memory used by decoded images at once. It's a
+// semaphore weighted by the estimated size of each image.
+type memLimiter struct {
+	used  int64
+	wait  chan struct{}
+	mutex sync.Mutex
+}
+
+// acquire blocks until n bytes are free under max, or cancel is closed. A
+// single image larger than max is allowed when nothing else is using
+// memory, so it can't block forever.
+func (m *memLimiter) acquire(n, max int64, cancel <-chan struct{}) error {
+	for {
+		m.mutex.Lock()
+		if max < 1 || m.used == 0 || m.used+n <= max {
+			m.used += n
+			m.mutex.Unlock()
+
+			return nil
+		}
+
+		if m.wait == nil {
+			m.wait = make(chan struct{})
+		}
+		wait := m.wait
+		m.mutex.Unlock()
+
+		select {
+		case <-wait:
+			// Memory was released, so try again
+		case <-cancel:
+			return Canceled
+		}
+	}
+}
+
+// release frees n bytes and wakes everyone waiting for memory
+func (m *memLimiter) release(n int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.used -= n
+	if m.wait != nil {
+		close(m.wait)
+		m.wait = nil
+	}
+}
+
+// decodedSize estimates the memory in bytes an image with cfg uses once
+// it's decoded
+func decodedSize(cfg image.Config) int64 {
+	bpp := int64(4)
+
+	switch cfg.ColorModel {
+	case color.GrayModel, color.AlphaModel:
+		bpp = 1
+	case color.Gray16Model, color.Alpha16Model:
+		bpp = 2
+	case color.RGBA64Model, color.NRGBA64Model:
+		bpp = 8
+	case color.YCbCrModel:
+		// Depends on the subsampling, 4:4:4 is the worst case
+		bpp = 3
+	default:
+		if _, ok := cfg.ColorModel.(color.Palette); ok {
+			bpp = 1
+		}
+	}
+
+	return int64(cfg.Width) * int64(cfg.Height) * bpp
+}
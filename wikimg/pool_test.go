@@ -0,0 +1,58 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestMemLimiter(t *testing.T) {
+	m := &memLimiter{}
+
+	// An oversized request is allowed when nothing else is using memory
+	if err := m.acquire(150, 100, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- m.acquire(50, 100, nil)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("expected acquire to wait for memory")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	m.release(150)
+	if err := <-acquired; err != nil {
+		t.Fatal(err)
+	}
+
+	// Waiting can be canceled
+	cancel := make(chan struct{})
+	close(cancel)
+	if err := m.acquire(60, 100, cancel); err != Canceled {
+		t.Errorf("expected Canceled but got %v", err)
+	}
+}
+
+func TestDecodedSize(t *testing.T) {
+	tests := []struct {
+		model    color.Model
+		expected int64
+	}{
+		{color.GrayModel, 200},
+		{color.RGBAModel, 800},
+		{color.Palette{color.Black}, 200},
+	}
+
+	for _, test := range tests {
+		cfg := image.Config{ColorModel: test.model, Width: 20, Height: 10}
+		if size := decodedSize(cfg); size != test.expected {
+			t.Errorf("%T: expected %d but got %d", test.model, test.expected, size)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+)
+
+// ImageDeltaE returns the mean CIE76 color difference between the sampled
+// pixels of img and the palette colors they map to. Users can compare it
+// against a threshold to decide whether palette colors are faithful enough or
+// true color output is needed. It is zero when quantization is disabled.
+func ImageDeltaE(img image.Image, opts ColorOptions) float64 {
+	return measureDecoded(img, opts.deltaE)
+}
+
+// deltaE computes the mean color difference between the sampled pixels of
+// img and their palette colors
+func (o ColorOptions) deltaE(img image.Image, cancel <-chan struct{}) (float64, error) {
+	if o.palette() == nil {
+		return 0, nil
+	}
+
+	total := 0.0
+	count := 0
+
+	_, err := o.scan(img, cancel, func(c color.Color) bool {
+		q, _ := o.quantize(c)
+		total += deltaE76(toLab(c), toLab(q))
+		count++
+
+		return false
+	})
+	if err != nil || count < 1 {
+		return 0, err
+	}
+
+	return total / float64(count), nil
+}
@@ -0,0 +1,172 @@
+package wikimg
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+// Quantize returns the image downscaled to w x h, with each pixel mapped to
+// the nearest color of pal (using p.Options.Distance). The result's Pix are
+// palette indexes, so it's a grid ready for mosaics, previews or ANSI art,
+// and it can be encoded as a PNG or GIF as is. If pal is nil, the palette in
+// p.Options is used. If w or h is zero, it's chosen to keep the image's
+// aspect ratio. Palettes are limited to 256 colors.
+func (p *Puller) Quantize(imgURL string, pal color.Palette, w, h int) (*image.Paletted, error) {
+	opts := p.Options
+	if pal != nil {
+		opts.Palette = pal
+	}
+
+	// Check what we can before downloading
+	_, _, err := opts.quantizeSize(image.Rect(0, 0, 1, 1), w, h)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := p.fetch(p.context(), imgURL, false, p.Cancel)
+	if err != nil {
+		return nil, err
+	}
+	defer a.release()
+
+	return opts.quantizeImage(a.img, w, h, p.Cancel)
+}
+
+// ImageQuantize is like Quantize, but operates on an image that has already
+// been decoded, mapping pixels to the palette in opts
+func ImageQuantize(img image.Image, opts ColorOptions, w, h int) (*image.Paletted, error) {
+	return opts.quantizeImage(img, w, h, nil)
+}
+
+// quantizeSize returns the size of the quantized image, filling in a zero
+// w or h from the aspect ratio of r
+func (o ColorOptions) quantizeSize(r image.Rectangle, w, h int) (int, int, error) {
+	o.Unquantized = false
+	if len(o.palette()) > 256 {
+		return 0, 0, errors.New("wikimg: palettes for Quantize may have at most 256 colors")
+	}
+
+	switch {
+	case w < 0 || h < 0 || (w == 0 && h == 0):
+		return 0, 0, errors.New("wikimg: Quantize needs a positive width or height")
+	case r.Empty():
+		return 0, 0, errors.New("wikimg: can't quantize an empty image")
+	case w == 0:
+		w = max(1, h*r.Dx()/r.Dy())
+	case h == 0:
+		h = max(1, w*r.Dy()/r.Dx())
+	}
+
+	return w, h, nil
+}
+
+// Downscale is like Quantize, but keeps the true colors of the image rather
+// than mapping them to a palette, e.g., for truecolor terminals
+func (p *Puller) Downscale(imgURL string, w, h int) (*image.NRGBA, error) {
+	// Check what we can before downloading
+	_, _, err := p.Options.quantizeSize(image.Rect(0, 0, 1, 1), w, h)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := p.fetch(p.context(), imgURL, false, p.Cancel)
+	if err != nil {
+		return nil, err
+	}
+	defer a.release()
+
+	return p.Options.downscale(a.img, w, h, p.Cancel)
+}
+
+// ImageDownscale is like Downscale, but operates on an image that has
+// already been decoded
+func ImageDownscale(img image.Image, opts ColorOptions, w, h int) (*image.NRGBA, error) {
+	return opts.downscale(img, w, h, nil)
+}
+
+// quantizeImage downscales img and maps it to the palette
+func (o ColorOptions) quantizeImage(img image.Image, w, h int, cancel <-chan struct{}) (*image.Paletted, error) {
+	o.Unquantized = false
+
+	small, err := o.downscale(img, w, h, cancel)
+	if err != nil {
+		return nil, err
+	}
+
+	out := image.NewPaletted(small.Bounds(), o.palette())
+	for i := 0; i < len(small.Pix); i += 4 {
+		_, idx := o.quantize(color.NRGBA{small.Pix[i], small.Pix[i+1], small.Pix[i+2], small.Pix[i+3]})
+		out.Pix[i/4] = uint8(idx)
+	}
+
+	return out, nil
+}
+
+// downscale returns img scaled down to w x h. Without a Scaler, each pixel
+// of the result is the average of the pixels it covers.
+func (o ColorOptions) downscale(img image.Image, w, h int, cancel <-chan struct{}) (*image.NRGBA, error) {
+	w, h, err := o.quantizeSize(img.Bounds(), w, h)
+	if err != nil {
+		return nil, err
+	}
+
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if o.Scaler != nil {
+		o.Scaler.Scale(out, img)
+		return out, nil
+	}
+
+	r := img.Bounds()
+	for y := 0; y < h; y++ {
+		if closed(cancel) {
+			return nil, Canceled
+		}
+
+		for x := 0; x < w; x++ {
+			// The source pixels covered by x, y
+			cell := image.Rect(
+				r.Min.X+x*r.Dx()/w, r.Min.Y+y*r.Dy()/h,
+				r.Min.X+(x+1)*r.Dx()/w, r.Min.Y+(y+1)*r.Dy()/h,
+			)
+
+			out.Set(x, y, o.average(img, cell))
+		}
+	}
+
+	return out, nil
+}
+
+// average returns the mean color of the pixels of img in cell, after alpha
+// handling. Cells smaller than a pixel use the pixel they fall in.
+func (o ColorOptions) average(img image.Image, cell image.Rectangle) color.Color {
+	if cell.Dx() < 1 {
+		cell.Max.X = cell.Min.X + 1
+	}
+	if cell.Dy() < 1 {
+		cell.Max.Y = cell.Min.Y + 1
+	}
+
+	var r, g, b, a, n uint64
+	for y := cell.Min.Y; y < cell.Max.Y; y++ {
+		for x := cell.Min.X; x < cell.Max.X; x++ {
+			c, ok := o.alpha(img.At(x, y))
+			if !ok {
+				continue
+			}
+
+			cr, cg, cb, ca := c.RGBA()
+			r += uint64(cr)
+			g += uint64(cg)
+			b += uint64(cb)
+			a += uint64(ca)
+			n++
+		}
+	}
+
+	if n == 0 {
+		return color.Transparent
+	}
+
+	return color.RGBA64{uint16(r / n), uint16(g / n), uint16(b / n), uint16(a / n)}
+}
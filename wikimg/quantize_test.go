@@ -0,0 +1,75 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestImageQuantize(t *testing.T) {
+	// Left half red, right half blue
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			c := color.NRGBA{255, 0, 0, 255}
+			if x >= 20 {
+				c = color.NRGBA{0, 0, 255, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	pal := color.Palette{color.Black, color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}}
+
+	// Height is chosen from the aspect ratio
+	q, err := ImageQuantize(img, ColorOptions{Palette: pal}, 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b := q.Bounds(); b.Dx() != 4 || b.Dy() != 2 {
+		t.Fatalf("expected 4x2 grid but got %v", b)
+	}
+
+	expected := []uint8{1, 1, 2, 2, 1, 1, 2, 2}
+	for i, idx := range q.Pix {
+		if idx != expected[i] {
+			t.Errorf("expected indexes %v but got %v", expected, q.Pix)
+			break
+		}
+	}
+
+	bad := []struct{ w, h int }{{0, 0}, {-1, 4}}
+	for _, b := range bad {
+		if _, err := ImageQuantize(img, ColorOptions{}, b.w, b.h); err == nil {
+			t.Errorf("%dx%d: expected error", b.w, b.h)
+		}
+	}
+}
+
+func TestImageDownscale(t *testing.T) {
+	// Alternating columns of two close reds average to a color that isn't
+	// in any palette
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			c := color.NRGBA{200, 10, 10, 255}
+			if x%2 == 1 {
+				c = color.NRGBA{210, 20, 30, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	small, err := ImageDownscale(img, ColorOptions{}, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := color.NRGBA{205, 15, 20, 255}
+	for x := 0; x < 2; x++ {
+		if c := small.NRGBAAt(x, 0); c != expected {
+			t.Errorf("expected %v at %d but got %v", expected, x, c)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package wikimg
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Record is the intermediate format passed between the stages of a pipeline
+// such as "wikimg pull | wikimg analyze | wikimg render". Records are encoded
+// as newline-delimited JSON (NDJSON), one record per line, so stages can be
+// connected (or filtered in between) with standard tools like grep and jq.
+//
+// The format is stable: fields may be added, but existing fields are never
+// renamed, removed or changed in meaning.
+type Record struct {
+	// URL is the image URL
+	URL string `json:"url"`
+
+	// Title is the title of the image's page on Commons, if known
+	Title string `json:"title,omitempty"`
+
+	// Page is the URL of the image's page if it isn't on Commons (see
+	// ImageInfo.Page)
+	Page string `json:"page,omitempty"`
+
+	// Depicts and Captions are the image's structured data, if it was
+	// pulled with it (see ImageInfo.Depicts)
+	Depicts  []Entity          `json:"depicts,omitempty"`
+	Captions map[string]string `json:"captions,omitempty"`
+
+	// Uploaded is when the image was uploaded, if known
+	Uploaded time.Time `json:"uploaded,omitzero"`
+
+	// Color is the image's color, once it has been analyzed
+	Color *ColorInfo `json:"color,omitempty"`
+
+	// Path is where the image was saved, once it has been downloaded
+	Path string `json:"path,omitempty"`
+
+	// RequestID identifies the run that pulled the record (see
+	// Puller.RequestID), so it can be traced through every stage
+	RequestID string `json:"request_id,omitempty"`
+
+	// Error describes why a stage failed to process the record. Later
+	// stages pass records with errors through unchanged.
+	Error string `json:"error,omitempty"`
+}
+
+// RecordWriter writes Records as NDJSON
+type RecordWriter struct {
+	enc *json.Encoder
+}
+
+// NewRecordWriter creates a RecordWriter that writes to w
+func NewRecordWriter(w io.Writer) *RecordWriter {
+	return &RecordWriter{enc: json.NewEncoder(w)}
+}
+
+// Write writes a single record on its own line
+func (rw *RecordWriter) Write(rec Record) error {
+	return rw.enc.Encode(rec)
+}
+
+// RecordReader reads Records from NDJSON
+type RecordReader struct {
+	dec *json.Decoder
+}
+
+// NewRecordReader creates a RecordReader that reads from r
+func NewRecordReader(r io.Reader) *RecordReader {
+	return &RecordReader{dec: json.NewDecoder(r)}
+}
+
+// Read returns the next record. io.EOF is returned when there are no more
+// records.
+func (rr *RecordReader) Read() (Record, error) {
+	var rec Record
+	err := rr.dec.Decode(&rec)
+
+	return rec, err
+}
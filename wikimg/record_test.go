@@ -0,0 +1,37 @@
+package wikimg
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestRecords(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewRecordWriter(buf)
+
+	w.Write(Record{URL: "a"})
+	w.Write(Record{URL: "b", Color: &ColorInfo{Index: 9, Hex: "#ff0000"}})
+	w.Write(Record{URL: "c", Error: "failed"})
+
+	if n := bytes.Count(buf.Bytes(), []byte("\n")); n != 3 {
+		t.Fatalf("expected 3 lines but got %d: %s", n, buf)
+	}
+
+	r := NewRecordReader(buf)
+	var recs []Record
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		recs = append(recs, rec)
+	}
+
+	if len(recs) != 3 || recs[0].Color != nil || recs[1].Color.Hex != "#ff0000" || recs[2].Error != "failed" {
+		t.Errorf("unexpected records %+v", recs)
+	}
+}
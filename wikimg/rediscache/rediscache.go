@@ -0,0 +1,198 @@
+// Package rediscache is a wikimg.Cache kept in Redis, so many processes
+// (e.g., several instances of a server behind a load balancer) can share
+// one set of FirstColor results:
+//
+//	cache, err := rediscache.Open("localhost:6379", 24*time.Hour)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer cache.Close()
+//
+//	p := wikimg.NewPuller(100)
+//	p.Cache = cache
+//
+// It is a separate package so that programs which don't need it don't
+// depend on github.com/redis/go-redis.
+package rediscache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+const (
+	// opTimeout is the longest a single Redis command may take. A slow
+	// cache is worse than none, since the result can be computed again.
+	opTimeout = time.Second
+
+	// prefix is prepended to keys so results don't collide with other
+	// data in the same Redis database
+	prefix = "wikimg:color:"
+
+	// valuePrefix is prepended to the keys of values set with SetValue
+	valuePrefix = "wikimg:value:"
+)
+
+// Cache is a wikimg.Cache stored in Redis. Redis expires results itself.
+// Errors talking to Redis are treated as cache misses; Err returns the most
+// recent one.
+type Cache struct {
+	client *redis.Client
+	ttl    time.Duration
+
+	err   error
+	mutex sync.Mutex
+}
+
+// Open connects to the Redis server at addr (host:port) and checks that
+// it's reachable. Results are kept for ttl, or until Redis evicts them if
+// ttl is zero.
+func Open(addr string, ttl time.Duration) (*Cache, error) {
+	return New(redis.NewClient(&redis.Options{Addr: addr}), ttl)
+}
+
+// New is like Open, but uses an existing client, e.g., one with a password
+// or TLS configured
+func New(client *redis.Client, ttl time.Duration) (*Cache, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+
+	err := client.Ping(ctx).Err()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &Cache{client: client, ttl: ttl}, nil
+}
+
+// Close closes the connection to Redis
+func (c *Cache) Close() error {
+	return c.client.Close()
+}
+
+// Err returns the most recent error talking to Redis, if any
+func (c *Cache) Err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.err
+}
+
+// fail records err for Err
+func (c *Cache) fail(err error) {
+	c.mutex.Lock()
+	c.err = err
+	c.mutex.Unlock()
+}
+
+// Get returns the result for key, if Redis has it
+func (c *Cache) Get(key string) (wikimg.ColorInfo, bool) {
+	var info wikimg.ColorInfo
+
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+
+	b, err := c.client.Get(ctx, prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return info, false
+	} else if err != nil {
+		c.fail(err)
+		return info, false
+	}
+
+	err = json.Unmarshal(b, &info)
+	if err != nil {
+		c.fail(err)
+		return info, false
+	}
+
+	return info, true
+}
+
+// Set stores the result for key for ttl. A ttl of zero uses the ttl the
+// cache was opened with.
+func (c *Cache) Set(key string, info wikimg.ColorInfo, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+
+	b, err := json.Marshal(info)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+
+	err = c.client.Set(ctx, prefix+key, b, ttl).Err()
+	if err != nil {
+		c.fail(err)
+	}
+}
+
+// Delete removes the result for key
+func (c *Cache) Delete(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+
+	err := c.client.Del(ctx, prefix+key).Err()
+	if err != nil {
+		c.fail(err)
+	}
+}
+
+// GetValue decodes the value stored for key with SetValue into v, returning
+// false if Redis doesn't have one
+func (c *Cache) GetValue(key string, v any) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+
+	b, err := c.client.Get(ctx, valuePrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return false
+	} else if err != nil {
+		c.fail(err)
+		return false
+	}
+
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		c.fail(err)
+		return false
+	}
+
+	return true
+}
+
+// SetValue stores v as JSON for key for ttl, e.g., state that servers
+// sharing the cache keep alongside their results. Values are kept apart
+// from results, so keys can't collide. A ttl of zero uses the ttl the cache
+// was opened with.
+func (c *Cache) SetValue(key string, v any, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+
+	err = c.client.Set(ctx, valuePrefix+key, b, ttl).Err()
+	if err != nil {
+		c.fail(err)
+	}
+}
@@ -0,0 +1,99 @@
+package rediscache
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// addr returns the Redis server to test against, skipping the test if
+// there isn't one
+func addr(t *testing.T) string {
+	a := os.Getenv("REDIS_ADDR")
+	if len(a) < 1 {
+		t.Skip("set REDIS_ADDR to test against a Redis server")
+	}
+
+	return a
+}
+
+func TestCache(t *testing.T) {
+	a := addr(t)
+
+	// Two caches on the same server share results
+	c1, err := Open(a, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+
+	c2, err := Open(a, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+
+	key := "test|" + wikimg.NewRequestID()
+	c1.Set(key, wikimg.ColorInfo{Hex: "#aaaaaa", Index: 7}, 0)
+
+	info, ok := c2.Get(key)
+	if !ok || info.Hex != "#aaaaaa" || info.Index != 7 {
+		t.Errorf("expected result to be shared but got %+v, %v", info, ok)
+	}
+
+	c2.Delete(key)
+	if _, ok := c1.Get(key); ok {
+		t.Errorf("expected result to be deleted")
+	}
+
+	if err := c1.Err(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c, err := Open(addr(t), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	key := "test|" + wikimg.NewRequestID()
+	c.Set(key, wikimg.ColorInfo{}, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	if _, ok := c.Get(key); ok {
+		t.Errorf("expected result to expire")
+	}
+}
+
+func TestValues(t *testing.T) {
+	c, err := Open(addr(t), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	type pick struct {
+		URL string
+	}
+
+	key := "test|" + wikimg.NewRequestID()
+	var p pick
+	if c.GetValue(key, &p) {
+		t.Errorf("expected no value")
+	}
+
+	c.SetValue(key, pick{URL: "http://example.com/a.png"}, 0)
+	if !c.GetValue(key, &p) || p.URL != "http://example.com/a.png" {
+		t.Errorf("expected value to be stored but got %+v", p)
+	}
+	if _, ok := c.Get(key); ok {
+		t.Errorf("expected values not to collide with results")
+	}
+}
+
+// Cache can be used as a Puller's cache
+var _ wikimg.Cache = (*Cache)(nil)
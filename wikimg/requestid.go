@@ -0,0 +1,54 @@
+package wikimg
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// UserAgent identifies wikimg to the servers it calls. A comment with
+	// contact information and the request ID follows it, as requested by
+	// https://meta.wikimedia.org/wiki/User-Agent_policy
+	UserAgent = "wikimg/1.0"
+
+	// RequestIDHeader is the header that carries Puller.RequestID
+	RequestIDHeader = "X-Request-Id"
+
+	// contact is where the operators of the servers we call can find us
+	contact = "https://github.com/brnstz/routine"
+)
+
+// NewRequestID returns a random ID suitable for Puller.RequestID
+func NewRequestID() string {
+	b := make([]byte, 8)
+
+	// crypto/rand never fails on supported platforms
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// UserAgent returns the User-Agent p sends. It's UserAgent followed by a
+// comment with where to find wikimg, p.Operator and p.RequestID.
+func (p *Puller) UserAgent() string {
+	comment := []string{contact}
+	if len(p.Operator) > 0 {
+		comment = append(comment, "operator "+p.Operator)
+	}
+	if len(p.RequestID) > 0 {
+		comment = append(comment, "request-id "+p.RequestID)
+	}
+
+	return fmt.Sprintf("%s (%s)", UserAgent, strings.Join(comment, "; "))
+}
+
+// identify sets the User-Agent and request ID headers of req
+func (p *Puller) identify(req *http.Request) {
+	req.Header.Set("User-Agent", p.UserAgent())
+	if len(p.RequestID) > 0 {
+		req.Header.Set(RequestIDHeader, p.RequestID)
+	}
+}
@@ -0,0 +1,61 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var ids, agents []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get(RequestIDHeader))
+		agents = append(agents, r.Header.Get("User-Agent"))
+
+		img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+		img.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+		png.Encode(w, img)
+	}))
+	defer ts.Close()
+
+	p := NewPuller(1)
+	if len(p.RequestID) != 16 || p.RequestID == NewPuller(1).RequestID {
+		t.Fatalf("expected a random request ID but got %q", p.RequestID)
+	}
+
+	p.RequestID = "run1"
+	info, err := p.FirstColor(ts.URL + "/red.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.RequestID != "run1" {
+		t.Errorf("expected result to have request ID run1 but got %q", info.RequestID)
+	}
+	if len(ids) != 1 || ids[0] != "run1" {
+		t.Errorf("expected request ID header run1 but got %v", ids)
+	}
+	if !strings.HasPrefix(agents[0], UserAgent+" (") || !strings.Contains(agents[0], "request-id run1") {
+		t.Errorf("unexpected User-Agent %q", agents[0])
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	p := NewPuller(1)
+	p.RequestID = ""
+
+	if ua := p.UserAgent(); ua != "wikimg/1.0 (https://github.com/brnstz/routine)" {
+		t.Errorf("unexpected User-Agent %q", ua)
+	}
+
+	p.Operator = "ops@example.com"
+	p.RequestID = "run1"
+	if ua := p.UserAgent(); ua != "wikimg/1.0 (https://github.com/brnstz/routine; operator ops@example.com; request-id run1)" {
+		t.Errorf("unexpected User-Agent %q", ua)
+	}
+}
@@ -0,0 +1,223 @@
+package wikimg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Strategy is how images of a given type are handled
+type Strategy int
+
+const (
+	// Decode downloads and decodes the image itself
+	Decode Strategy = iota
+
+	// Thumbnail analyzes a PNG or JPEG thumbnail that Commons renders,
+	// for types that can't be decoded directly (e.g., SVG)
+	Thumbnail
+
+	// Skip rejects the image with a *SkippedError without downloading it
+	Skip
+
+	// Custom calls the route's Analyzer instead of FirstColor's usual
+	// analysis
+	Custom
+)
+
+// strategyNames are the names of strategies in route files
+var strategyNames = map[Strategy]string{
+	Decode:    "decode",
+	Thumbnail: "thumbnail",
+	Skip:      "skip",
+	Custom:    "custom",
+}
+
+// String returns the name of the strategy
+func (s Strategy) String() string {
+	return strategyNames[s]
+}
+
+// MarshalText encodes the strategy as its name
+func (s Strategy) MarshalText() ([]byte, error) {
+	name, ok := strategyNames[s]
+	if !ok {
+		return nil, fmt.Errorf("wikimg: invalid strategy %d", s)
+	}
+
+	return []byte(name), nil
+}
+
+// UnmarshalText decodes a strategy from its name
+func (s *Strategy) UnmarshalText(b []byte) error {
+	for k, v := range strategyNames {
+		if v == string(b) {
+			*s = k
+			return nil
+		}
+	}
+
+	return fmt.Errorf("wikimg: invalid strategy %q (must be decode, thumbnail, skip or custom)", b)
+}
+
+// Analyzer computes the color of an image in place of FirstColor
+type Analyzer func(p *Puller, imgURL string) (ColorInfo, error)
+
+// analyzers are the custom analyzers that route files can refer to by name
+var analyzers sync.Map
+
+// RegisterAnalyzer makes a custom analyzer available to route files under
+// name
+func RegisterAnalyzer(name string, a Analyzer) {
+	analyzers.Store(name, a)
+}
+
+// Route says how images of one type are handled
+type Route struct {
+	// Strategy is how the images are handled
+	Strategy Strategy `json:"strategy"`
+
+	// Width is the width of the thumbnail for the Thumbnail strategy.
+	// Zero uses Puller.SVGWidth.
+	Width int `json:"width,omitempty"`
+
+	// AnalyzerName is the name the Analyzer was registered under with
+	// RegisterAnalyzer, for routes loaded from files
+	AnalyzerName string `json:"analyzer,omitempty"`
+
+	// Analyzer is called for the Custom strategy
+	Analyzer Analyzer `json:"-"`
+}
+
+// Routes maps mime types (e.g., "image/svg+xml") to how images of that type
+// are handled. A key may also be a wildcard for a whole top level type
+// (e.g., "audio/*"). Types without a route are decoded.
+type Routes map[string]Route
+
+// DefaultRoutes returns the routes NewPuller() uses: SVGs are rendered as
+// thumbnails, and audio, video and document types that can never be decoded
+// are skipped
+func DefaultRoutes() Routes {
+	return Routes{
+		"image/svg+xml":     {Strategy: Thumbnail},
+		"audio/*":           {Strategy: Skip},
+		"video/*":           {Strategy: Skip},
+		"application/ogg":   {Strategy: Skip},
+		"application/pdf":   {Strategy: Skip},
+		"image/vnd.djvu":    {Strategy: Skip},
+		"image/x-xcf":       {Strategy: Skip},
+		"application/sla":   {Strategy: Skip},
+		"application/x-tex": {Strategy: Skip},
+	}
+}
+
+// ParseRoutes reads routes from JSON, an object with mime types as keys and
+// routes as values, e.g.:
+//
+//	{
+//		"image/svg+xml": {"strategy": "thumbnail", "width": 256},
+//		"image/tiff": {"strategy": "skip"},
+//		"image/x-raw": {"strategy": "custom", "analyzer": "raw"}
+//	}
+//
+// Custom routes must name an analyzer registered with RegisterAnalyzer.
+func ParseRoutes(r io.Reader) (Routes, error) {
+	routes := Routes{}
+
+	err := json.NewDecoder(r).Decode(&routes)
+	if err != nil {
+		return nil, err
+	}
+
+	for mime, route := range routes {
+		if route.Strategy != Custom {
+			continue
+		}
+
+		a, ok := analyzers.Load(route.AnalyzerName)
+		if !ok {
+			return nil, fmt.Errorf("wikimg: %s: unknown analyzer %q", mime, route.AnalyzerName)
+		}
+
+		route.Analyzer = a.(Analyzer)
+		routes[mime] = route
+	}
+
+	return routes, nil
+}
+
+// Lookup returns the route for mime, falling back to a wildcard route for
+// its top level type, and then to decoding
+func (rs Routes) Lookup(mime string) Route {
+	if route, ok := rs[mime]; ok {
+		return route
+	}
+
+	if i := strings.Index(mime, "/"); i > 0 {
+		if route, ok := rs[mime[:i]+"/*"]; ok {
+			return route
+		}
+	}
+
+	return Route{Strategy: Decode}
+}
+
+// mimeTypes maps the extensions of files on Commons to their mime types.
+// We don't use the mime package, since its results depend on the system.
+var mimeTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".svg":  "image/svg+xml",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".xcf":  "image/x-xcf",
+	".djvu": "image/vnd.djvu",
+	".pdf":  "application/pdf",
+	".stl":  "application/sla",
+	".ogg":  "application/ogg",
+	".oga":  "audio/ogg",
+	".ogv":  "video/ogg",
+	".opus": "audio/ogg",
+	".webm": "video/webm",
+	".mpg":  "video/mpeg",
+	".mpeg": "video/mpeg",
+	".mp3":  "audio/mpeg",
+	".flac": "audio/x-flac",
+	".wav":  "audio/wav",
+	".mid":  "audio/midi",
+}
+
+// MimeType returns the mime type of a file name or URL, based on its
+// extension, or "" if it isn't known
+func MimeType(name string) string {
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+
+	return mimeTypes[strings.ToLower(path.Ext(name))]
+}
+
+// SkippedError is returned when an image's type is routed to Skip
+type SkippedError struct {
+	URL  string
+	Type string
+}
+
+// Error describes the skipped image
+func (e *SkippedError) Error() string {
+	return fmt.Sprintf("wikimg: %s: %s images are skipped", e.URL, e.Type)
+}
+
+// route returns how p handles imgURL
+func (p *Puller) route(imgURL string) (string, Route) {
+	mime := MimeType(imgURL)
+
+	return mime, p.Routes.Lookup(mime)
+}
@@ -0,0 +1,80 @@
+package wikimg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMimeType(t *testing.T) {
+	tests := map[string]string{
+		"https://upload.wikimedia.org/wikipedia/commons/a/ab/Name.SVG": "image/svg+xml",
+		"https://example.com/Name.jpg?width=100":                       "image/jpeg",
+		"Clip.webm":                                                    "video/webm",
+		"Unknown.xyz":                                                  "",
+	}
+
+	for name, expected := range tests {
+		if mime := MimeType(name); mime != expected {
+			t.Errorf("%s: expected %q but got %q", name, expected, mime)
+		}
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	routes := DefaultRoutes()
+
+	tests := map[string]Strategy{
+		"image/svg+xml": Thumbnail,
+		"audio/mpeg":    Skip,
+		"image/jpeg":    Decode,
+		"":              Decode,
+	}
+	for mime, expected := range tests {
+		if s := routes.Lookup(mime).Strategy; s != expected {
+			t.Errorf("%s: expected %s but got %s", mime, expected, s)
+		}
+	}
+
+	// Skipped types aren't downloaded
+	p := NewPuller(1)
+	_, err := p.FirstColor("http://127.0.0.1:1/Song.mp3")
+	if se, ok := err.(*SkippedError); !ok || se.Type != "audio/mpeg" {
+		t.Errorf("expected *SkippedError but got %v", err)
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	custom := errors.New("custom analyzer called")
+	RegisterAnalyzer("test", func(p *Puller, imgURL string) (ColorInfo, error) {
+		return ColorInfo{}, custom
+	})
+
+	routes, err := ParseRoutes(strings.NewReader(`{
+		"image/tiff": {"strategy": "thumbnail", "width": 64},
+		"image/png": {"strategy": "custom", "analyzer": "test"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r := routes.Lookup("image/tiff"); r.Strategy != Thumbnail || r.Width != 64 {
+		t.Errorf("unexpected route %+v", r)
+	}
+
+	p := NewPuller(1)
+	p.Routes = routes
+	if _, err := p.FirstColor("http://127.0.0.1:1/Image.png"); err != custom {
+		t.Errorf("expected custom analyzer error but got %v", err)
+	}
+
+	bad := []string{
+		`{"image/png": {"strategy": "custom", "analyzer": "missing"}}`,
+		`{"image/png": {"strategy": "unknown"}}`,
+	}
+	for _, b := range bad {
+		if _, err := ParseRoutes(strings.NewReader(b)); err == nil {
+			t.Errorf("%s: expected error", b)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// newImage converts info to an Image
+func newImage(info wikimg.ImageInfo) *Image {
+	img := &Image{Url: info.URL, Title: info.Title}
+	if !info.Uploaded.IsZero() {
+		img.Uploaded = info.Uploaded.Unix()
+	}
+
+	return img
+}
+
+// Time returns when the image was uploaded, or the zero time if unknown
+func (x *Image) Time() time.Time {
+	if x.GetUploaded() == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(x.GetUploaded(), 0).UTC()
+}
+
+// newColor converts the color of the image at u to a Color
+func newColor(u string, info wikimg.ColorInfo) *Color {
+	return &Color{
+		Url:   u,
+		Hex:   info.Hex,
+		Xterm: int32(info.Index),
+		R:     uint32(info.R),
+		G:     uint32(info.G),
+		B:     uint32(info.B),
+		H:     info.H,
+		S:     info.S,
+		L:     info.L,
+		Gray:  info.Gray,
+		Name:  info.Name(),
+	}
+}
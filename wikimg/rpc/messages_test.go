@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// TestWireFormat makes sure the field numbers in wikimg.proto don't change
+// under existing clients
+func TestWireFormat(t *testing.T) {
+	tests := []struct {
+		m        proto.Message
+		expected []byte
+	}{
+		// Field 1, length-delimited
+		{&AnalyzeColorRequest{Url: "a"}, []byte{0x0a, 0x01, 'a'}},
+
+		// Default values aren't encoded
+		{&AnalyzeColorRequest{}, nil},
+
+		// Negative int32s are sign extended to 10 bytes
+		{&Color{Xterm: -1}, []byte{0x18, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+
+		// Repeated strings, including empty ones
+		{&PullImagesRequest{Max: 2, Licenses: []string{"cc0", ""}}, []byte{0x08, 0x02, 0x12, 0x03, 'c', 'c', '0', 0x12, 0x00}},
+
+		// Doubles are fixed 64-bit little endian
+		{&Color{H: 1}, []byte{0x39, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f}},
+
+		// Error is the last field
+		{&Color{Error: "x"}, []byte{0x62, 0x01, 'x'}},
+	}
+
+	for _, test := range tests {
+		got, err := proto.Marshal(test.m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, test.expected) {
+			t.Errorf("expected %T %v to be % x but got % x", test.m, test.m, test.expected, got)
+		}
+	}
+}
+
+func TestImageTime(t *testing.T) {
+	uploaded := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+
+	img := newImage(wikimg.ImageInfo{URL: "https://example.com/a.png", Uploaded: uploaded})
+	if img.Uploaded != 1700000000 || !img.Time().Equal(uploaded) {
+		t.Errorf("expected %v but got %d (%v)", uploaded, img.Uploaded, img.Time())
+	}
+
+	// Unknown upload times stay unknown
+	img = newImage(wikimg.ImageInfo{URL: "https://example.com/b.png"})
+	if img.Uploaded != 0 || !img.Time().IsZero() {
+		t.Errorf("expected no upload time but got %d (%v)", img.Uploaded, img.Time())
+	}
+}
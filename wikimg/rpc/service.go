@@ -0,0 +1,200 @@
+// Package rpc serves wikimg over gRPC https://grpc.io, so other backend
+// services can pull images and consume their colors as they're analyzed
+// without scraping the HTML of the examples. The service is defined in
+// wikimg.proto:
+//
+//	s := grpc.NewServer()
+//	rpc.RegisterWikimgServer(s, &rpc.Server{})
+//	s.Serve(l)
+//
+// Go clients can use NewWikimgClient. Clients in other languages can be
+// generated from wikimg.proto as usual.
+//
+// The messages, client and server stubs are generated from wikimg.proto by
+// protoc-gen-go and protoc-gen-go-grpc. Run go generate after changing it.
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative wikimg.proto
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+const (
+	// DefaultMax is the default for Server.Max
+	DefaultMax = 100
+
+	// DefaultWorkers is the default for Server.Workers
+	DefaultWorkers = 10
+)
+
+// Server implements WikimgServer by wrapping wikimg. Set its fields before
+// registering it. The zero value is ready to use.
+type Server struct {
+	// Max is the most images a single call can pull. Zero means
+	// DefaultMax.
+	Max int
+
+	// Workers is the number of images StreamColors analyzes at once.
+	// Zero means DefaultWorkers.
+	Workers int
+
+	// NewPuller creates the Puller for each call, e.g., to set its
+	// options or a shared wikimg.Cache. Its Licenses are replaced with
+	// the request's, if any, and its Cancel channel with the call's. If
+	// nil, wikimg.NewPuller is used.
+	NewPuller func(max int) *wikimg.Puller
+
+	UnimplementedWikimgServer
+}
+
+// puller creates a Puller for a call that pulls at most max images (or
+// s.Max if max is out of range) under licenses
+func (s *Server) puller(ctx context.Context, max int32, licenses []string) *wikimg.Puller {
+	limit := s.Max
+	if limit < 1 {
+		limit = DefaultMax
+	}
+
+	n := int(max)
+	if n < 1 || n > limit {
+		n = limit
+	}
+
+	var p *wikimg.Puller
+	if s.NewPuller != nil {
+		p = s.NewPuller(n)
+	} else {
+		p = wikimg.NewPuller(n)
+	}
+	p.Cancel = ctx.Done()
+	if len(licenses) > 0 {
+		p.Licenses = licenses
+	}
+
+	return p
+}
+
+// PullImages returns the latest images
+func (s *Server) PullImages(ctx context.Context, req *PullImagesRequest) (*PullImagesResponse, error) {
+	p := s.puller(ctx, req.GetMax(), req.GetLicenses())
+
+	resp := &PullImagesResponse{}
+	for {
+		info, err := p.NextInfo()
+		if err == wikimg.EndOfResults {
+			return resp, nil
+		} else if err != nil {
+			return nil, toStatus(ctx, err)
+		}
+
+		resp.Images = append(resp.Images, newImage(info))
+	}
+}
+
+// AnalyzeColor returns the first color of the image in req
+func (s *Server) AnalyzeColor(ctx context.Context, req *AnalyzeColorRequest) (*Color, error) {
+	if len(req.GetUrl()) < 1 {
+		return nil, status.Error(codes.InvalidArgument, "rpc: url is required")
+	}
+
+	info, err := s.puller(ctx, 1, nil).FirstColorContext(ctx, req.GetUrl())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return newColor(req.GetUrl(), info), nil
+}
+
+// StreamColors pulls the latest images and sends the color of each one to
+// stream as soon as it's analyzed. Images that fail are sent with their
+// Error set.
+func (s *Server) StreamColors(req *StreamColorsRequest, stream grpc.ServerStreamingServer[Color]) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	p := s.puller(ctx, req.GetMax(), req.GetLicenses())
+
+	// Pull URLs in the background, so they're analyzed as they arrive
+	urls := make(chan string)
+	pullErr := make(chan error, 1)
+	go func() {
+		defer close(urls)
+
+		for {
+			u, err := p.Next()
+			if err == wikimg.EndOfResults {
+				pullErr <- nil
+				return
+			} else if err != nil {
+				pullErr <- err
+				return
+			}
+
+			select {
+			case urls <- u:
+			case <-ctx.Done():
+				pullErr <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	workers := s.Workers
+	if workers < 1 {
+		workers = DefaultWorkers
+	}
+
+	results := p.StreamColors(ctx, urls, workers)
+	for res := range results {
+		c := &Color{Url: res.URL, Error: errString(res.Err)}
+		if res.Err == nil {
+			c = newColor(res.URL, res.Info)
+		}
+
+		err := stream.Send(c)
+		if err != nil {
+			// The client is gone. Stop pulling and drain the
+			// results so StreamColors can finish.
+			cancel()
+			for range results {
+			}
+			return err
+		}
+	}
+
+	err := <-pullErr
+	if err != nil {
+		return toStatus(ctx, err)
+	}
+
+	return nil
+}
+
+// errString returns the message of err, or "" if it's nil
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
+
+// toStatus converts err to a status error, with the code of ctx's error if
+// the call was canceled or timed out
+func toStatus(ctx context.Context, err error) error {
+	switch ctx.Err() {
+	case context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	return status.Error(codes.Unknown, err.Error())
+}
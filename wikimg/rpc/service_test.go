@@ -0,0 +1,228 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/brnstz/routine/wikimg/wikimgtest"
+)
+
+// colors are served by the image server, by path
+var colors = map[string]color.RGBA{
+	"/red.png":   {0xff, 0x00, 0x00, 0xff},
+	"/green.png": {0x00, 0xff, 0x00, 0xff},
+	"/blue.png":  {0x00, 0x00, 0xff, 0xff},
+}
+
+// newTestServer creates a Server that pulls every image in colors, plus
+// one that's missing, from a fake API. It returns the image server's URL.
+func newTestServer(t *testing.T) (*Server, string) {
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := colors[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+		for i := 0; i < 4; i++ {
+			img.Set(i%2, i/2, c)
+		}
+		png.Encode(w, img)
+	}))
+	t.Cleanup(images.Close)
+
+	src := wikimgtest.NewSource(2)
+	t.Cleanup(src.Close)
+	src.Add(images.URL+"/blue.png", images.URL+"/missing.png", images.URL+"/green.png", images.URL+"/red.png")
+
+	return &Server{Workers: 2, NewPuller: src.Puller}, images.URL
+}
+
+// colorRecorder is a stream for StreamColors that keeps what's sent to it.
+// After failAfter colors, sends fail.
+type colorRecorder struct {
+	grpc.ServerStream
+
+	ctx       context.Context
+	colors    []*Color
+	failAfter int
+}
+
+func (r *colorRecorder) Send(c *Color) error {
+	if r.failAfter > 0 && len(r.colors) >= r.failAfter {
+		return errors.New("client went away")
+	}
+
+	r.colors = append(r.colors, c)
+
+	return nil
+}
+
+func (r *colorRecorder) Context() context.Context {
+	return r.ctx
+}
+
+func TestPullImages(t *testing.T) {
+	s, imgURL := newTestServer(t)
+
+	resp, err := s.PullImages(context.Background(), &PullImagesRequest{Max: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{imgURL + "/blue.png", imgURL + "/missing.png", imgURL + "/green.png"}
+	if len(resp.Images) != len(expected) {
+		t.Fatalf("expected %d images but got %d", len(expected), len(resp.Images))
+	}
+	for i, u := range expected {
+		img := resp.Images[i]
+		if img.Url != u {
+			t.Errorf("expected %s at %d but got %s", u, i, img.Url)
+		}
+		if len(img.Title) < 1 || img.Time().IsZero() {
+			t.Errorf("expected a title and upload time but got %+v", img)
+		}
+	}
+
+	// Asking for more than Max gets Max
+	s.Max = 2
+	resp, err = s.PullImages(context.Background(), &PullImagesRequest{Max: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Images) != 2 {
+		t.Errorf("expected 2 images but got %d", len(resp.Images))
+	}
+}
+
+func TestAnalyzeColor(t *testing.T) {
+	s, imgURL := newTestServer(t)
+
+	c, err := s.AnalyzeColor(context.Background(), &AnalyzeColorRequest{Url: imgURL + "/red.png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Hex != "#ff0000" || c.R != 0xff || c.Xterm != 9 || c.Name != "red" {
+		t.Errorf("expected red but got %+v", c)
+	}
+
+	_, err = s.AnalyzeColor(context.Background(), &AnalyzeColorRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument but got %v", err)
+	}
+
+	_, err = s.AnalyzeColor(context.Background(), &AnalyzeColorRequest{Url: imgURL + "/missing.png"})
+	if err == nil {
+		t.Error("expected an error for a missing image")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.AnalyzeColor(ctx, &AnalyzeColorRequest{Url: imgURL + "/red.png"})
+	if status.Code(err) != codes.Canceled {
+		t.Errorf("expected Canceled but got %v", err)
+	}
+}
+
+func TestStreamColors(t *testing.T) {
+	s, imgURL := newTestServer(t)
+
+	stream := &colorRecorder{ctx: context.Background()}
+	err := s.StreamColors(&StreamColorsRequest{}, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Colors arrive in any order
+	got := map[string]string{}
+	for _, c := range stream.colors {
+		got[c.Url] = c.Hex + c.Error
+	}
+	if len(got) != 4 {
+		t.Fatalf("expected 4 colors but got %v", got)
+	}
+
+	hexes := []string{got[imgURL+"/red.png"], got[imgURL+"/green.png"], got[imgURL+"/blue.png"]}
+	sort.Strings(hexes)
+	if hexes[0] != "#0000ff" || hexes[1] != "#00ff00" || hexes[2] != "#ff0000" {
+		t.Errorf("unexpected colors %v", got)
+	}
+	if len(got[imgURL+"/missing.png"]) < 1 {
+		t.Error("expected an error for the missing image")
+	}
+}
+
+func TestStreamColorsClientGone(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	stream := &colorRecorder{ctx: context.Background(), failAfter: 1}
+	err := s.StreamColors(&StreamColorsRequest{}, stream)
+	if err == nil {
+		t.Fatal("expected the send error")
+	}
+	if len(stream.colors) != 1 {
+		t.Errorf("expected 1 color but got %d", len(stream.colors))
+	}
+}
+
+func TestClient(t *testing.T) {
+	s, imgURL := newTestServer(t)
+
+	// Serve over an in-memory connection with gRPC's standard codec
+	l := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	RegisterWikimgServer(gs, s)
+	go gs.Serve(l)
+	t.Cleanup(gs.Stop)
+
+	cc, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	client := NewWikimgClient(cc)
+
+	c, err := client.AnalyzeColor(context.Background(), &AnalyzeColorRequest{Url: imgURL + "/red.png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.GetHex() != "#ff0000" || c.GetName() != "red" {
+		t.Errorf("expected red but got %v", c)
+	}
+
+	stream, err := client.StreamColors(context.Background(), &StreamColorsRequest{Max: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Errorf("expected 2 colors but got %d", n)
+	}
+}
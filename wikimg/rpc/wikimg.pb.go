@@ -0,0 +1,533 @@
+// The wikimg service pulls the latest images from Wikimedia Commons and
+// analyzes their colors, so backend services can consume them without
+// scraping HTML. It's implemented by github.com/brnstz/routine/wikimg/rpc,
+// whose Go code is generated from this file (see go generate), and clients
+// can be generated from it in any language.
+//
+// Field numbers must not change once released. Add fields with new numbers
+// and regenerate the Go code.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: wikimg.proto
+
+package rpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type PullImagesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// max is the most images to return. Zero means the server's default.
+	Max int32 `protobuf:"varint,1,opt,name=max,proto3" json:"max,omitempty"`
+	// licenses optionally restricts images to the given license codes (e.g.,
+	// "cc0", "cc-by")
+	Licenses      []string `protobuf:"bytes,2,rep,name=licenses,proto3" json:"licenses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PullImagesRequest) Reset() {
+	*x = PullImagesRequest{}
+	mi := &file_wikimg_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PullImagesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PullImagesRequest) ProtoMessage() {}
+
+func (x *PullImagesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_wikimg_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PullImagesRequest.ProtoReflect.Descriptor instead.
+func (*PullImagesRequest) Descriptor() ([]byte, []int) {
+	return file_wikimg_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PullImagesRequest) GetMax() int32 {
+	if x != nil {
+		return x.Max
+	}
+	return 0
+}
+
+func (x *PullImagesRequest) GetLicenses() []string {
+	if x != nil {
+		return x.Licenses
+	}
+	return nil
+}
+
+type PullImagesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Images        []*Image               `protobuf:"bytes,1,rep,name=images,proto3" json:"images,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PullImagesResponse) Reset() {
+	*x = PullImagesResponse{}
+	mi := &file_wikimg_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PullImagesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PullImagesResponse) ProtoMessage() {}
+
+func (x *PullImagesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_wikimg_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PullImagesResponse.ProtoReflect.Descriptor instead.
+func (*PullImagesResponse) Descriptor() ([]byte, []int) {
+	return file_wikimg_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PullImagesResponse) GetImages() []*Image {
+	if x != nil {
+		return x.Images
+	}
+	return nil
+}
+
+type Image struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// url is the URL of the original image
+	Url string `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	// title is the title of the image's page on Commons
+	Title string `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	// uploaded is when the image was uploaded, in seconds since the Unix
+	// epoch
+	Uploaded      int64 `protobuf:"varint,3,opt,name=uploaded,proto3" json:"uploaded,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Image) Reset() {
+	*x = Image{}
+	mi := &file_wikimg_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Image) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Image) ProtoMessage() {}
+
+func (x *Image) ProtoReflect() protoreflect.Message {
+	mi := &file_wikimg_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Image.ProtoReflect.Descriptor instead.
+func (*Image) Descriptor() ([]byte, []int) {
+	return file_wikimg_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Image) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Image) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Image) GetUploaded() int64 {
+	if x != nil {
+		return x.Uploaded
+	}
+	return 0
+}
+
+type AnalyzeColorRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyzeColorRequest) Reset() {
+	*x = AnalyzeColorRequest{}
+	mi := &file_wikimg_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeColorRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeColorRequest) ProtoMessage() {}
+
+func (x *AnalyzeColorRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_wikimg_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeColorRequest.ProtoReflect.Descriptor instead.
+func (*AnalyzeColorRequest) Descriptor() ([]byte, []int) {
+	return file_wikimg_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AnalyzeColorRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+type StreamColorsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// max is the most images to analyze. Zero means the server's default.
+	Max int32 `protobuf:"varint,1,opt,name=max,proto3" json:"max,omitempty"`
+	// licenses optionally restricts images to the given license codes
+	Licenses      []string `protobuf:"bytes,2,rep,name=licenses,proto3" json:"licenses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamColorsRequest) Reset() {
+	*x = StreamColorsRequest{}
+	mi := &file_wikimg_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamColorsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamColorsRequest) ProtoMessage() {}
+
+func (x *StreamColorsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_wikimg_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamColorsRequest.ProtoReflect.Descriptor instead.
+func (*StreamColorsRequest) Descriptor() ([]byte, []int) {
+	return file_wikimg_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *StreamColorsRequest) GetMax() int32 {
+	if x != nil {
+		return x.Max
+	}
+	return 0
+}
+
+func (x *StreamColorsRequest) GetLicenses() []string {
+	if x != nil {
+		return x.Licenses
+	}
+	return nil
+}
+
+type Color struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// url is the image
+	Url string `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	// hex is the color, e.g., "#ff0000"
+	Hex string `protobuf:"bytes,2,opt,name=hex,proto3" json:"hex,omitempty"`
+	// xterm is the xterm256 index of the color, or -1 if it isn't mapped to
+	// a palette
+	Xterm int32  `protobuf:"varint,3,opt,name=xterm,proto3" json:"xterm,omitempty"`
+	R     uint32 `protobuf:"varint,4,opt,name=r,proto3" json:"r,omitempty"`
+	G     uint32 `protobuf:"varint,5,opt,name=g,proto3" json:"g,omitempty"`
+	B     uint32 `protobuf:"varint,6,opt,name=b,proto3" json:"b,omitempty"`
+	// h is the hue in degrees, s and l are the saturation and lightness
+	// between 0 and 1
+	H float64 `protobuf:"fixed64,7,opt,name=h,proto3" json:"h,omitempty"`
+	S float64 `protobuf:"fixed64,8,opt,name=s,proto3" json:"s,omitempty"`
+	L float64 `protobuf:"fixed64,9,opt,name=l,proto3" json:"l,omitempty"`
+	// gray is true when no non-gray color was found in the image
+	Gray bool `protobuf:"varint,10,opt,name=gray,proto3" json:"gray,omitempty"`
+	// name is the name of the nearest CSS color
+	Name string `protobuf:"bytes,11,opt,name=name,proto3" json:"name,omitempty"`
+	// error is why the image couldn't be analyzed. It's only set by
+	// StreamColors, in which case the other fields but url are empty.
+	Error         string `protobuf:"bytes,12,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Color) Reset() {
+	*x = Color{}
+	mi := &file_wikimg_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Color) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Color) ProtoMessage() {}
+
+func (x *Color) ProtoReflect() protoreflect.Message {
+	mi := &file_wikimg_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Color.ProtoReflect.Descriptor instead.
+func (*Color) Descriptor() ([]byte, []int) {
+	return file_wikimg_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Color) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Color) GetHex() string {
+	if x != nil {
+		return x.Hex
+	}
+	return ""
+}
+
+func (x *Color) GetXterm() int32 {
+	if x != nil {
+		return x.Xterm
+	}
+	return 0
+}
+
+func (x *Color) GetR() uint32 {
+	if x != nil {
+		return x.R
+	}
+	return 0
+}
+
+func (x *Color) GetG() uint32 {
+	if x != nil {
+		return x.G
+	}
+	return 0
+}
+
+func (x *Color) GetB() uint32 {
+	if x != nil {
+		return x.B
+	}
+	return 0
+}
+
+func (x *Color) GetH() float64 {
+	if x != nil {
+		return x.H
+	}
+	return 0
+}
+
+func (x *Color) GetS() float64 {
+	if x != nil {
+		return x.S
+	}
+	return 0
+}
+
+func (x *Color) GetL() float64 {
+	if x != nil {
+		return x.L
+	}
+	return 0
+}
+
+func (x *Color) GetGray() bool {
+	if x != nil {
+		return x.Gray
+	}
+	return false
+}
+
+func (x *Color) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Color) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+var File_wikimg_proto protoreflect.FileDescriptor
+
+const file_wikimg_proto_rawDesc = "" +
+	"\n" +
+	"\fwikimg.proto\x12\twikimg.v1\"A\n" +
+	"\x11PullImagesRequest\x12\x10\n" +
+	"\x03max\x18\x01 \x01(\x05R\x03max\x12\x1a\n" +
+	"\blicenses\x18\x02 \x03(\tR\blicenses\">\n" +
+	"\x12PullImagesResponse\x12(\n" +
+	"\x06images\x18\x01 \x03(\v2\x10.wikimg.v1.ImageR\x06images\"K\n" +
+	"\x05Image\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x1a\n" +
+	"\buploaded\x18\x03 \x01(\x03R\buploaded\"'\n" +
+	"\x13AnalyzeColorRequest\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\"C\n" +
+	"\x13StreamColorsRequest\x12\x10\n" +
+	"\x03max\x18\x01 \x01(\x05R\x03max\x12\x1a\n" +
+	"\blicenses\x18\x02 \x03(\tR\blicenses\"\xd3\x01\n" +
+	"\x05Color\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x10\n" +
+	"\x03hex\x18\x02 \x01(\tR\x03hex\x12\x14\n" +
+	"\x05xterm\x18\x03 \x01(\x05R\x05xterm\x12\f\n" +
+	"\x01r\x18\x04 \x01(\rR\x01r\x12\f\n" +
+	"\x01g\x18\x05 \x01(\rR\x01g\x12\f\n" +
+	"\x01b\x18\x06 \x01(\rR\x01b\x12\f\n" +
+	"\x01h\x18\a \x01(\x01R\x01h\x12\f\n" +
+	"\x01s\x18\b \x01(\x01R\x01s\x12\f\n" +
+	"\x01l\x18\t \x01(\x01R\x01l\x12\x12\n" +
+	"\x04gray\x18\n" +
+	" \x01(\bR\x04gray\x12\x12\n" +
+	"\x04name\x18\v \x01(\tR\x04name\x12\x14\n" +
+	"\x05error\x18\f \x01(\tR\x05error2\xd9\x01\n" +
+	"\x06Wikimg\x12I\n" +
+	"\n" +
+	"PullImages\x12\x1c.wikimg.v1.PullImagesRequest\x1a\x1d.wikimg.v1.PullImagesResponse\x12@\n" +
+	"\fAnalyzeColor\x12\x1e.wikimg.v1.AnalyzeColorRequest\x1a\x10.wikimg.v1.Color\x12B\n" +
+	"\fStreamColors\x12\x1e.wikimg.v1.StreamColorsRequest\x1a\x10.wikimg.v1.Color0\x01B&Z$github.com/brnstz/routine/wikimg/rpcb\x06proto3"
+
+var (
+	file_wikimg_proto_rawDescOnce sync.Once
+	file_wikimg_proto_rawDescData []byte
+)
+
+func file_wikimg_proto_rawDescGZIP() []byte {
+	file_wikimg_proto_rawDescOnce.Do(func() {
+		file_wikimg_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_wikimg_proto_rawDesc), len(file_wikimg_proto_rawDesc)))
+	})
+	return file_wikimg_proto_rawDescData
+}
+
+var file_wikimg_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_wikimg_proto_goTypes = []any{
+	(*PullImagesRequest)(nil),   // 0: wikimg.v1.PullImagesRequest
+	(*PullImagesResponse)(nil),  // 1: wikimg.v1.PullImagesResponse
+	(*Image)(nil),               // 2: wikimg.v1.Image
+	(*AnalyzeColorRequest)(nil), // 3: wikimg.v1.AnalyzeColorRequest
+	(*StreamColorsRequest)(nil), // 4: wikimg.v1.StreamColorsRequest
+	(*Color)(nil),               // 5: wikimg.v1.Color
+}
+var file_wikimg_proto_depIdxs = []int32{
+	2, // 0: wikimg.v1.PullImagesResponse.images:type_name -> wikimg.v1.Image
+	0, // 1: wikimg.v1.Wikimg.PullImages:input_type -> wikimg.v1.PullImagesRequest
+	3, // 2: wikimg.v1.Wikimg.AnalyzeColor:input_type -> wikimg.v1.AnalyzeColorRequest
+	4, // 3: wikimg.v1.Wikimg.StreamColors:input_type -> wikimg.v1.StreamColorsRequest
+	1, // 4: wikimg.v1.Wikimg.PullImages:output_type -> wikimg.v1.PullImagesResponse
+	5, // 5: wikimg.v1.Wikimg.AnalyzeColor:output_type -> wikimg.v1.Color
+	5, // 6: wikimg.v1.Wikimg.StreamColors:output_type -> wikimg.v1.Color
+	4, // [4:7] is the sub-list for method output_type
+	1, // [1:4] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_wikimg_proto_init() }
+func file_wikimg_proto_init() {
+	if File_wikimg_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_wikimg_proto_rawDesc), len(file_wikimg_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_wikimg_proto_goTypes,
+		DependencyIndexes: file_wikimg_proto_depIdxs,
+		MessageInfos:      file_wikimg_proto_msgTypes,
+	}.Build()
+	File_wikimg_proto = out.File
+	file_wikimg_proto_goTypes = nil
+	file_wikimg_proto_depIdxs = nil
+}
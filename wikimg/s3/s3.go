@@ -0,0 +1,376 @@
+// Package s3 is a wikimg.Source of the images in an S3 bucket, or any
+// object storage with an S3 compatible API (e.g., MinIO, R2 or Spaces), so
+// the colors of datasets in object storage can be found without copying
+// them first:
+//
+//	src := s3.NewSource("my-dataset", 10000)
+//	src.Endpoint = "https://s3.eu-west-1.amazonaws.com"
+//	src.Region = "eu-west-1"
+//	src.Prefix = "photos/2024/"
+//	src.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+//	src.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+//
+// The URL of each image is s3://bucket/key, which stays the same from one
+// listing to the next and carries no credentials, so it's safe to cache,
+// log and show. A wikimg.Puller downloads it through a Transport, which
+// signs each request as it's sent:
+//
+//	t := http.DefaultTransport.(*http.Transport).Clone()
+//	t.RegisterProtocol("s3", src.Transport())
+//	p.Client = &http.Client{Transport: t}
+//
+// Without credentials, the bucket must be public.
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/brnstz/routine/wikimg"
+)
+
+const (
+	// DefaultRegion is the default for Config.Region
+	DefaultRegion = "us-east-1"
+
+	// DefaultPerPage is the default for Source.PerPage, the most S3
+	// lists at once
+	DefaultPerPage = 1000
+
+	// Scheme is the scheme of the URLs of objects, s3://bucket/key
+	Scheme = "s3"
+
+	// maxBody is the most of a listing we read
+	maxBody = 10 << 20
+
+	// unsignedPayload is the hash of the body of a request that isn't
+	// signed, which is fine for GETs
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// APIError is an error returned by the storage API, e.g., for a missing
+// bucket or invalid credentials
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+// Error describes the error
+func (e *APIError) Error() string {
+	return fmt.Sprintf("s3: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// object is an object in a listing
+type object struct {
+	Key          string
+	LastModified time.Time
+}
+
+// listing is the response of ListObjectsV2
+type listing struct {
+	Contents              []object
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// Config is where a bucket is and the credentials its requests are
+// signed with
+type Config struct {
+	// Endpoint is the URL of the storage API, e.g.,
+	// http://localhost:9000 for MinIO. Objects are addressed by path
+	// (Endpoint/Bucket/Key). Empty means AWS in Region.
+	Endpoint string
+
+	// Region is the region of the bucket. Empty means DefaultRegion.
+	Region string
+
+	// AccessKeyID and SecretAccessKey are the credentials requests are
+	// signed with, and SessionToken is the token of temporary
+	// credentials. Without an AccessKeyID, requests aren't signed.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// Source lists the images in a bucket, in lexical order of their keys.
+// Only objects whose extension is an image type (see wikimg.MimeType) are
+// returned. Set its fields before the first call to Next. Like a
+// wikimg.Puller, it must only be used by one goroutine at a time.
+type Source struct {
+	Config
+
+	// Bucket is the name of the bucket
+	Bucket string
+
+	// Prefix limits the images to those whose keys start with it, e.g.,
+	// "photos/"
+	Prefix string
+
+	// PerPage is the number of objects listed at once, up to 1000. Zero
+	// means DefaultPerPage.
+	PerPage int
+
+	// Client is the HTTP client used to list the bucket. If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	max   int
+	count int
+
+	// token continues the listing and done is whether it's complete
+	token   string
+	done    bool
+	objects []object
+
+	// now returns the time requests are signed at, for testing
+	now func() time.Time
+}
+
+// NewSource creates a Source that returns at most max images from bucket
+func NewSource(bucket string, max int) *Source {
+	return &Source{Bucket: bucket, max: max, now: time.Now}
+}
+
+// Next returns the next image, or wikimg.EndOfResults once max images
+// have been returned or there are no more
+func (s *Source) Next(ctx context.Context) (wikimg.ImageInfo, error) {
+	for s.count < s.max {
+		if len(s.objects) < 1 {
+			if s.done {
+				break
+			}
+
+			err := s.list(ctx)
+			if err != nil {
+				return wikimg.ImageInfo{}, err
+			}
+			continue
+		}
+
+		obj := s.objects[0]
+		s.objects = s.objects[1:]
+
+		if strings.HasSuffix(obj.Key, "/") || !strings.HasPrefix(wikimg.MimeType(obj.Key), "image/") {
+			continue
+		}
+
+		u := URL(s.Bucket, obj.Key)
+		s.count++
+
+		return wikimg.ImageInfo{URL: u, Title: obj.Key, Uploaded: obj.LastModified.UTC(), Page: u}, nil
+	}
+
+	return wikimg.ImageInfo{}, wikimg.EndOfResults
+}
+
+// list requests the next page of the listing
+func (s *Source) list(ctx context.Context) error {
+	perPage := s.PerPage
+	if perPage < 1 || perPage > DefaultPerPage {
+		perPage = DefaultPerPage
+	}
+
+	params := url.Values{}
+	params.Set("list-type", "2")
+	params.Set("max-keys", strconv.Itoa(perPage))
+	if len(s.Prefix) > 0 {
+		params.Set("prefix", s.Prefix)
+	}
+	if len(s.token) > 0 {
+		params.Set("continuation-token", s.token)
+	}
+
+	u := url.URL{Scheme: Scheme, Host: s.Bucket, Path: "/", RawQuery: params.Encode()}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	// The listing is signed like the objects are
+	t := s.Transport()
+	client := &http.Client{Transport: t}
+	if s.Client != nil {
+		t.Base = s.Client.Transport
+		client.Timeout = s.Client.Timeout
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body := io.LimitReader(resp.Body, maxBody)
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Code: resp.Status}
+		xml.NewDecoder(body).Decode(apiErr)
+
+		return apiErr
+	}
+
+	var l listing
+	err = xml.NewDecoder(body).Decode(&l)
+	if err != nil {
+		return fmt.Errorf("s3: couldn't parse listing: %v", err)
+	}
+
+	s.token = l.NextContinuationToken
+	s.done = !l.IsTruncated || len(s.token) < 1
+	s.objects = l.Contents
+
+	return nil
+}
+
+// Transport returns a Transport that signs requests with the Source's
+// Config
+func (s *Source) Transport() *Transport {
+	return &Transport{Config: s.Config, now: s.now}
+}
+
+// URL returns the URL of key in bucket, s3://bucket/key
+func URL(bucket, key string) string {
+	u := url.URL{Scheme: Scheme, Host: bucket, Path: "/" + key}
+
+	return u.String()
+}
+
+// Transport is an http.RoundTripper for the URLs of objects
+// (s3://bucket/key), which it sends to the storage API signed with the
+// credentials of its Config. Signing each request as it's sent means they
+// never expire, and the credentials are never part of the URL.
+type Transport struct {
+	Config
+
+	// Base sends the signed requests. If nil, http.DefaultTransport is
+	// used.
+	Base http.RoundTripper
+
+	// now returns the time requests are signed at, for testing
+	now func() time.Time
+}
+
+// RoundTrip sends the request for the object or bucket at req's URL
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != Scheme {
+		return nil, fmt.Errorf("s3: not an object URL: %s", req.URL)
+	}
+
+	u, err := t.url(req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	// RoundTrippers mustn't modify the request they're given
+	signed := req.Clone(req.Context())
+	signed.URL = u
+	signed.Host = u.Host
+
+	if len(t.AccessKeyID) > 0 {
+		now := time.Now
+		if t.now != nil {
+			now = t.now
+		}
+
+		// S3 wants the payload hash as a header too
+		signed.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+		creds := aws.Credentials{AccessKeyID: t.AccessKeyID, SecretAccessKey: t.SecretAccessKey, SessionToken: t.SessionToken}
+		err = signer.SignHTTP(req.Context(), creds, signed, unsignedPayload, "s3", t.region(), now())
+		if err != nil {
+			return nil, fmt.Errorf("s3: couldn't sign request: %v", err)
+		}
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base.RoundTrip(signed)
+}
+
+// signer signs requests. S3 paths are escaped once, unlike other services'.
+var signer = v4.NewSigner(func(o *v4.SignerOptions) {
+	o.DisableURIPathEscaping = true
+})
+
+// url returns the URL in the API of object, s3://bucket/key
+func (t *Transport) url(object *url.URL) (*url.URL, error) {
+	endpoint := t.Endpoint
+	if len(endpoint) < 1 {
+		endpoint = "https://s3." + t.region() + ".amazonaws.com"
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("s3: invalid endpoint: %v", err)
+	}
+	u.Path += "/" + object.Host + strings.TrimSuffix(object.Path, "/")
+	u.RawPath = encodePath(u.Path)
+	u.RawQuery = encodeQuery(object.Query())
+
+	return u, nil
+}
+
+// region returns the region of the bucket
+func (c Config) region() string {
+	if len(c.Region) < 1 {
+		return DefaultRegion
+	}
+
+	return c.Region
+}
+
+// encodeQuery encodes params sorted by key, escaped the way signatures
+// need them to be
+func encodeQuery(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range params[k] {
+			parts = append(parts, escape(k, true)+"="+escape(v, true))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// encodePath escapes a path the way signatures need it to be
+func encodePath(path string) string {
+	return escape(path, false)
+}
+
+// escape percent-encodes every byte of s except unreserved characters, and
+// slashes unless all is true
+func escape(s string, all bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !all:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
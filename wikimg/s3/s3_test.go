@@ -0,0 +1,187 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// newAPI starts a fake API with a bucket listed in pages of two objects.
+// Only some of the objects are images. The headers of each request are
+// sent to headers, if it isn't nil.
+func newAPI(t *testing.T, headers chan<- http.Header) *httptest.Server {
+	pages := [][]string{
+		{"photos/a.jpg", "photos/notes.txt"},
+		{"photos/sub/", "photos/sub/b c.png"},
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if headers != nil {
+			headers <- r.Header
+		}
+
+		if r.URL.Path == "/bucket/photos/a.jpg" {
+			fmt.Fprint(w, "not really a jpeg")
+			return
+		}
+
+		if r.URL.Path != "/bucket" || r.FormValue("list-type") != "2" || r.FormValue("prefix") != "photos/" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchBucket</Code><Message>The specified bucket does not exist</Message></Error>`)
+			return
+		}
+
+		page := 0
+		if r.FormValue("continuation-token") == "next" {
+			page = 1
+		}
+
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult>`)
+		for _, key := range pages[page] {
+			fmt.Fprintf(w, `<Contents><Key>%s</Key><LastModified>2024-01-01T12:00:00.000Z</LastModified></Contents>`, key)
+		}
+		if page == 0 {
+			fmt.Fprint(w, `<IsTruncated>true</IsTruncated><NextContinuationToken>next</NextContinuationToken>`)
+		} else {
+			fmt.Fprint(w, `<IsTruncated>false</IsTruncated>`)
+		}
+		fmt.Fprint(w, `</ListBucketResult>`)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestSource(t *testing.T) {
+	ts := newAPI(t, nil)
+
+	src := NewSource("bucket", 10)
+	src.Endpoint = ts.URL
+	src.Prefix = "photos/"
+
+	var got []wikimg.ImageInfo
+	for {
+		img, err := src.Next(context.Background())
+		if err == wikimg.EndOfResults {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		got = append(got, img)
+	}
+
+	expected := []string{"s3://bucket/photos/a.jpg", "s3://bucket/photos/sub/b%20c.png"}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v but got %+v", expected, got)
+	}
+	for i, u := range expected {
+		if got[i].URL != u {
+			t.Errorf("expected %s at %d but got %s", u, i, got[i].URL)
+		}
+	}
+
+	img := got[0]
+	if img.Title != "photos/a.jpg" || !img.Uploaded.Equal(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected image %+v", img)
+	}
+}
+
+func TestSourceSigned(t *testing.T) {
+	headers := make(chan http.Header, 2)
+	ts := newAPI(t, headers)
+
+	src := NewSource("bucket", 1)
+	src.Endpoint = ts.URL
+	src.Prefix = "photos/"
+	src.AccessKeyID = "key"
+	src.SecretAccessKey = "secret"
+	src.SessionToken = "token"
+
+	img, err := src.Next(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The URL is the same every time and has no credentials in it
+	if img.URL != "s3://bucket/photos/a.jpg" || img.Page != img.URL {
+		t.Errorf("expected an s3 URL but got %+v", img)
+	}
+
+	if _, err := src.Next(context.Background()); err != wikimg.EndOfResults {
+		t.Errorf("expected EndOfResults after max but got %v", err)
+	}
+
+	// The object is signed when it's downloaded, like the listing
+	client := &http.Client{Transport: src.Transport()}
+	resp, err := client.Get(img.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the object but got %s", resp.Status)
+	}
+
+	for _, name := range []string{"listing", "object"} {
+		h := <-headers
+		if !strings.HasPrefix(h.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") ||
+			h.Get("X-Amz-Security-Token") != "token" || h.Get("X-Amz-Content-Sha256") != unsignedPayload {
+			t.Errorf("expected a signed %s request but got %v", name, h)
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	tr := &Transport{Config: Config{Endpoint: "https://example.com/", Region: "eu-west-1"}}
+
+	for _, test := range []struct {
+		object, expected string
+	}{
+		{URL("bucket", "photos/b c.png"), "https://example.com/bucket/photos/b%20c.png"},
+		{"s3://bucket/?list-type=2&prefix=a+b", "https://example.com/bucket?list-type=2&prefix=a%20b"},
+	} {
+		object, _ := url.Parse(test.object)
+
+		u, err := tr.url(object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.String() != test.expected {
+			t.Errorf("expected %s but got %s", test.expected, u)
+		}
+	}
+
+	// Without an endpoint, the object is on AWS
+	tr = &Transport{Config: Config{Region: "eu-west-1"}}
+	object, _ := url.Parse(URL("bucket", "a.jpg"))
+	if u, _ := tr.url(object); u.String() != "https://s3.eu-west-1.amazonaws.com/bucket/a.jpg" {
+		t.Errorf("unexpected AWS URL %s", u)
+	}
+
+	// Other URLs aren't objects
+	if _, err := (&http.Client{Transport: tr}).Get("https://example.com/a.jpg"); err == nil {
+		t.Error("expected an error for an https URL")
+	}
+}
+
+func TestSourceAPIError(t *testing.T) {
+	ts := newAPI(t, nil)
+
+	src := NewSource("missing", 10)
+	src.Endpoint = ts.URL
+
+	_, err := src.Next(context.Background())
+
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "NoSuchBucket" {
+		t.Errorf("expected NoSuchBucket but got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+// Package scale provides wikimg.Scalers backed by golang.org/x/image/draw.
+// It is a separate package so that programs which don't need them don't
+// depend on golang.org/x/image. Use one to downscale images before they're
+// analyzed:
+//
+//	p.Options.MaxSize = 256
+//	p.Options.Scaler = scale.CatmullRom
+package scale
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+
+	xdraw "golang.org/x/image/draw"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// scaler adapts an x/image/draw interpolator to wikimg.Scaler
+type scaler struct {
+	name string
+	i    xdraw.Interpolator
+}
+
+// Scale draws src scaled to fill the bounds of dst
+func (s scaler) Scale(dst draw.Image, src image.Image) {
+	s.i.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+}
+
+// String returns the name of the scaler
+func (s scaler) String() string {
+	return s.name
+}
+
+var (
+	// NearestNeighbor is the fastest scaler, but the lowest quality
+	NearestNeighbor wikimg.Scaler = scaler{"nearest", xdraw.NearestNeighbor}
+
+	// ApproxBiLinear is nearly as fast as NearestNeighbor, with better
+	// quality
+	ApproxBiLinear wikimg.Scaler = scaler{"approxbilinear", xdraw.ApproxBiLinear}
+
+	// BiLinear averages neighboring pixels, a good balance of speed and
+	// quality
+	BiLinear wikimg.Scaler = scaler{"bilinear", xdraw.BiLinear}
+
+	// CatmullRom is the slowest scaler, but the highest quality
+	CatmullRom wikimg.Scaler = scaler{"catmullrom", xdraw.CatmullRom}
+)
+
+// scalers are the scalers by name
+var scalers = []wikimg.Scaler{NearestNeighbor, ApproxBiLinear, BiLinear, CatmullRom}
+
+// Parse returns the scaler named s: "nearest", "approxbilinear", "bilinear"
+// or "catmullrom"
+func Parse(s string) (wikimg.Scaler, error) {
+	for _, sc := range scalers {
+		if fmt.Sprint(sc) == s {
+			return sc, nil
+		}
+	}
+
+	return nil, fmt.Errorf("scale: invalid scaler %q (must be nearest, approxbilinear, bilinear or catmullrom)", s)
+}
@@ -0,0 +1,41 @@
+package scale
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+func TestParse(t *testing.T) {
+	for _, name := range []string{"nearest", "approxbilinear", "bilinear", "catmullrom"} {
+		if _, err := Parse(name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	if _, err := Parse("lanczos"); err == nil {
+		t.Errorf("expected error for unknown scaler")
+	}
+}
+
+func TestScaler(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	img.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+
+	opts := wikimg.ColorOptions{MaxSize: 8, Scaler: CatmullRom}
+	if opts.Key() == (wikimg.ColorOptions{MaxSize: 8}).Key() {
+		t.Errorf("expected scaler to change the key")
+	}
+	if opts.Key() == (wikimg.ColorOptions{MaxSize: 8, Scaler: BiLinear}).Key() {
+		t.Errorf("expected different scalers to have different keys")
+	}
+
+	if hist := wikimg.ImageHistogram(img, opts); len(hist) < 1 {
+		t.Errorf("expected a histogram of the scaled image")
+	}
+}
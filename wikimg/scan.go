@@ -0,0 +1,130 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+const (
+	// cancelCheckpoint is the number of pixels between checking whether the
+	// request was canceled when scanning an image
+	cancelCheckpoint = 10000
+)
+
+// Scaler resizes images. The wikimg/scale package has scalers ranging from
+// fast nearest neighbor to high quality Catmull-Rom.
+type Scaler interface {
+	// Scale draws src scaled to fill the bounds of dst
+	Scale(dst draw.Image, src image.Image)
+}
+
+// scaledImage is a nearest neighbor downscaled view of another image. Pixels
+// are looked up on demand, so no new image is allocated.
+type scaledImage struct {
+	src    image.Image
+	bounds image.Rectangle
+}
+
+// ColorModel returns the color model of the source image
+func (s *scaledImage) ColorModel() color.Model {
+	return s.src.ColorModel()
+}
+
+// Bounds returns the downscaled size of the image
+func (s *scaledImage) Bounds() image.Rectangle {
+	return s.bounds
+}
+
+// At returns the source pixel nearest to x, y in the downscaled image
+func (s *scaledImage) At(x, y int) color.Color {
+	sr := s.src.Bounds()
+
+	return s.src.At(
+		sr.Min.X+x*sr.Dx()/s.bounds.Dx(),
+		sr.Min.Y+y*sr.Dy()/s.bounds.Dy(),
+	)
+}
+
+// prepare returns img transformed according to the options before it is
+// scanned
+func (o ColorOptions) prepare(img image.Image) image.Image {
+	rect := img.Bounds()
+
+	if o.MaxSize < 1 || (rect.Dx() <= o.MaxSize && rect.Dy() <= o.MaxSize) {
+		return img
+	}
+
+	// Scale the longest side down to MaxSize, keeping the aspect ratio
+	w, h := o.MaxSize, o.MaxSize
+	if rect.Dx() > rect.Dy() {
+		h = max(1, rect.Dy()*o.MaxSize/rect.Dx())
+	} else {
+		w = max(1, rect.Dx()*o.MaxSize/rect.Dy())
+	}
+
+	if o.Scaler == nil {
+		return &scaledImage{src: img, bounds: image.Rect(0, 0, w, h)}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	o.Scaler.Scale(dst, img)
+
+	return dst
+}
+
+// measureDecoded calls measure on an image that the caller has already
+// decoded. Scans only fail when they're canceled, and there's no cancel
+// channel, so the error is always nil.
+func measureDecoded[T any](img image.Image, measure func(image.Image, <-chan struct{}) (T, error)) T {
+	v, _ := measure(img, nil)
+
+	return v
+}
+
+// scan calls fn with each sampled pixel of img, starting with 0,0 and
+// iterating through each x and y value, until fn returns true. Pixels are
+// handled according to o.Alpha first, so transparent ones may be skipped.
+// It returns whether fn stopped the scan early. If cancel is closed during
+// the scan, Canceled is returned.
+func (o ColorOptions) scan(img image.Image, cancel <-chan struct{}, fn func(c color.Color) bool) (bool, error) {
+	img = o.prepare(img)
+
+	stride := o.Stride
+	if stride < 1 {
+		stride = 1
+	}
+
+	rect := img.Bounds()
+	i := 0
+	for x := rect.Min.X; x < rect.Max.X; x += stride {
+		for y := rect.Min.Y; y < rect.Max.Y; y += stride {
+
+			// Check if cancel has been closed once every cancelCheckpoint
+			// iterations
+			if i%cancelCheckpoint == 0 {
+				select {
+
+				case <-cancel:
+					// If cancel has been closed, this will be triggered
+					return false, Canceled
+
+				default:
+					// Otherwise we'll just do nothing immediately
+				}
+			}
+			i++
+
+			c, ok := o.alpha(img.At(x, y))
+			if !ok {
+				continue
+			}
+
+			if fn(c) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
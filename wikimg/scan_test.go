@@ -0,0 +1,62 @@
+package wikimg
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+
+	tests := []struct {
+		opts  ColorOptions
+		count int
+	}{
+		{ColorOptions{}, 5000},
+		{ColorOptions{Stride: 10}, 50},
+		{ColorOptions{MaxSize: 10}, 50},
+		{ColorOptions{MaxSize: 10, Stride: 2}, 15},
+		{ColorOptions{MaxSize: 1000}, 5000},
+	}
+
+	for _, test := range tests {
+		count := 0
+		found, err := test.opts.scan(img, nil, func(c color.Color) bool {
+			count++
+			return false
+		})
+
+		if found || err != nil {
+			t.Errorf("%+v: unexpected result %v, %v", test.opts, found, err)
+		}
+
+		if count != test.count {
+			t.Errorf("%+v: expected %d pixels but scanned %d", test.opts, test.count, count)
+		}
+	}
+}
+
+// fillScaler fills dst with a single color, so we can tell it was used
+type fillScaler struct {
+	c color.Color
+}
+
+func (f fillScaler) Scale(dst draw.Image, src image.Image) {
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(f.c), image.Point{}, draw.Src)
+}
+
+func TestPrepareScaler(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+
+	opts := ColorOptions{MaxSize: 10, Scaler: fillScaler{color.NRGBA{0, 0, 255, 255}}}
+	scaled := opts.prepare(img)
+
+	if b := scaled.Bounds(); b.Dx() != 10 || b.Dy() != 5 {
+		t.Errorf("expected 10x5 image but got %v", b)
+	}
+	if h := Hex(scaled.At(3, 3)); h != "#0000ff" {
+		t.Errorf("expected scaler to be used but got %s", h)
+	}
+}
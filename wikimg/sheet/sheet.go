@@ -0,0 +1,111 @@
+// Package sheet renders the colors of many images as an SVG swatch sheet,
+// so the latest colors on Commons can be embedded in a web page as a plain
+// file, without running a server:
+//
+//	results := p.FirstColors(ctx, urls, 10)
+//	err := (&sheet.Sheet{Cols: 8}).Encode(w, results)
+//
+// Each color is a rectangle labeled with its hex value that links to its
+// image.
+package sheet
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+const (
+	// DefaultCols is the default for Sheet.Cols
+	DefaultCols = 6
+
+	// DefaultWidth is the default for Sheet.Width
+	DefaultWidth = 120
+
+	// DefaultHeight is the default for Sheet.Height
+	DefaultHeight = 40
+)
+
+// Sheet renders colors in rows of rectangles. The zero value uses the
+// defaults.
+type Sheet struct {
+	// Cols is the number of colors in each row. Zero means DefaultCols.
+	Cols int
+
+	// Width and Height are the size of each rectangle. Zero means
+	// DefaultWidth and DefaultHeight.
+	Width  int
+	Height int
+
+	// Title is an optional title of the sheet, shown by browsers as its
+	// tooltip
+	Title string
+
+	// Link optionally returns what the rectangle of the image at imgURL
+	// links to, e.g., its page on Commons. If nil, it links to the image.
+	Link func(imgURL string) string
+}
+
+// orDefault returns v, or def if v is less than 1
+func orDefault(v, def int) int {
+	if v < 1 {
+		return def
+	}
+
+	return v
+}
+
+// Encode writes an SVG document with a rectangle for each result, in rows
+// from the top left. Results with errors are skipped.
+func (s *Sheet) Encode(w io.Writer, results []wikimg.ColorResult) error {
+	var ok []wikimg.ColorResult
+	for _, res := range results {
+		if res.Err == nil {
+			ok = append(ok, res)
+		}
+	}
+
+	width, height := orDefault(s.Width, DefaultWidth), orDefault(s.Height, DefaultHeight)
+	cols := min(orDefault(s.Cols, DefaultCols), max(len(ok), 1))
+	rows := (len(ok) + cols - 1) / cols
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		cols*width, rows*height, cols*width, rows*height)
+	if len(s.Title) > 0 {
+		fmt.Fprintf(bw, "<title>%s</title>\n", escape(s.Title))
+	}
+
+	for i, res := range ok {
+		link := res.URL
+		if s.Link != nil {
+			link = s.Link(res.URL)
+		}
+
+		x, y := i%cols*width, i/cols*height
+		info := res.Info
+
+		// Both href attributes, since older renderers only know xlink
+		fmt.Fprintf(bw, `<a href="%s" xlink:href="%s" target="_blank">`, escape(link), escape(link))
+		fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`, x, y, width, height, info.Hex)
+		fmt.Fprintf(bw, `<text x="%d" y="%d" fill="%s" font-family="monospace" font-size="%d" text-anchor="middle" dominant-baseline="central">%s</text>`,
+			x+width/2, y+height/2, info.Contrast(), max(height/3, 1), escape(info.Hex))
+		fmt.Fprint(bw, "</a>\n")
+	}
+
+	fmt.Fprint(bw, "</svg>\n")
+
+	return bw.Flush()
+}
+
+// escape returns s escaped for XML text and attributes
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+
+	return b.String()
+}
@@ -0,0 +1,108 @@
+package sheet
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+func TestEncode(t *testing.T) {
+	results := []wikimg.ColorResult{
+		{URL: "https://example.com/a.png?x=1&y=2", Info: wikimg.ColorInfo{Hex: "#ff0000", Luminance: 0.2}},
+		{URL: "https://example.com/missing.png", Err: errors.New("not found")},
+		{URL: "https://example.com/b.png", Info: wikimg.ColorInfo{Hex: "#ffffff", Luminance: 1}},
+		{URL: "https://example.com/c.png", Info: wikimg.ColorInfo{Hex: "#0000ff", Luminance: 0.07}},
+	}
+
+	s := &Sheet{Cols: 2, Width: 10, Height: 6, Title: "Colors <today>"}
+	var buf bytes.Buffer
+	err := s.Encode(&buf, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// It's well formed XML with a rect for each color, in rows
+	var rects []map[string]string
+	var title string
+	d := xml.NewDecoder(bytes.NewReader(buf.Bytes()))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("invalid XML: %v\n%s", err, buf.String())
+		}
+
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch se.Name.Local {
+		case "svg":
+			attrs := attrMap(se)
+			if attrs["width"] != "20" || attrs["height"] != "12" {
+				t.Errorf("unexpected size %v", attrs)
+			}
+		case "title":
+			d.DecodeElement(&title, &se)
+		case "rect":
+			rects = append(rects, attrMap(se))
+		}
+	}
+
+	if title != "Colors <today>" {
+		t.Errorf("unexpected title %q", title)
+	}
+
+	expected := []struct{ x, y, fill string }{
+		{"0", "0", "#ff0000"},
+		{"10", "0", "#ffffff"},
+		{"0", "6", "#0000ff"},
+	}
+	if len(rects) != len(expected) {
+		t.Fatalf("expected %d rects but got %d", len(expected), len(rects))
+	}
+	for i, e := range expected {
+		r := rects[i]
+		if r["x"] != e.x || r["y"] != e.y || r["fill"] != e.fill {
+			t.Errorf("expected %+v at %d but got %v", e, i, r)
+		}
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, `href="https://example.com/a.png?x=1&amp;y=2"`) {
+		t.Errorf("expected an escaped link in %s", out)
+	}
+	if !strings.Contains(out, `fill="#000000" font-family="monospace"`) {
+		t.Errorf("expected a dark label on white in %s", out)
+	}
+}
+
+func TestEncodeLink(t *testing.T) {
+	s := &Sheet{Link: func(imgURL string) string { return "https://commons.wikimedia.org/wiki/File:A.png" }}
+	var buf bytes.Buffer
+	err := s.Encode(&buf, []wikimg.ColorResult{{URL: "https://example.com/A.png", Info: wikimg.ColorInfo{Hex: "#00ff00"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), `href="https://commons.wikimedia.org/wiki/File:A.png"`) {
+		t.Errorf("expected a link to the page in %s", buf.String())
+	}
+}
+
+// attrMap returns the attributes of se by local name
+func attrMap(se xml.StartElement) map[string]string {
+	m := map[string]string{}
+	for _, a := range se.Attr {
+		m[a.Name.Local] = a.Value
+	}
+
+	return m
+}
@@ -0,0 +1,65 @@
+package wikimg
+
+import "context"
+
+// Source is where images to analyze come from. Commons is the default
+// (see Puller.Source), but anything that can list image URLs can be one,
+// so the code analyzing, caching and serving colors doesn't depend on
+// where the images are.
+type Source interface {
+	// Next returns the next image. It returns EndOfResults once there are
+	// no more, and should give up when ctx is done. It isn't called by
+	// more than one goroutine at once.
+	Next(ctx context.Context) (ImageInfo, error)
+}
+
+// SourceFunc is a function that can be used as a Source
+type SourceFunc func(ctx context.Context) (ImageInfo, error)
+
+// Next calls f(ctx)
+func (f SourceFunc) Next(ctx context.Context) (ImageInfo, error) {
+	return f(ctx)
+}
+
+// Source returns p as a Source of the latest images on Commons. Next is
+// like NextInfo, but also stops when ctx is done, and its requests are made
+// with ctx.
+func (p *Puller) Source() Source {
+	return pullerSource{p}
+}
+
+// pullerSource is the Source returned by Puller.Source
+type pullerSource struct {
+	p *Puller
+}
+
+// Next returns the next image pulled by the Puller
+func (ps pullerSource) Next(ctx context.Context) (ImageInfo, error) {
+	if ctx.Err() != nil {
+		return ImageInfo{}, Canceled
+	}
+
+	cancel, stop := ps.p.cancelWith(ctx)
+	defer stop()
+
+	return ps.p.nextInfo(ctx, cancel)
+}
+
+// SliceSource returns a Source of images, in order. It must only be used
+// by one goroutine at a time.
+func SliceSource(images []ImageInfo) Source {
+	i := 0
+
+	return SourceFunc(func(ctx context.Context) (ImageInfo, error) {
+		if err := ctx.Err(); err != nil {
+			return ImageInfo{}, err
+		}
+
+		if i >= len(images) {
+			return ImageInfo{}, EndOfResults
+		}
+		i++
+
+		return images[i-1], nil
+	})
+}
@@ -0,0 +1,52 @@
+package wikimg
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPullerSource(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(validPage))
+	}))
+	defer ts.Close()
+
+	p := NewPuller(2)
+	p.APIURL = ts.URL + "/source"
+	src := p.Source()
+
+	for _, expected := range []string{"http://example.com/a.png", "http://example.com/b.png"} {
+		img, err := src.Next(context.Background())
+		if err != nil || img.URL != expected || img.Title == "" {
+			t.Fatalf("expected %s but got %+v, %v", expected, img, err)
+		}
+	}
+
+	if _, err := src.Next(context.Background()); err != EndOfResults {
+		t.Errorf("expected EndOfResults but got %v", err)
+	}
+
+	// A canceled ctx stops the pull
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := NewPuller(2).Source().Next(ctx); err != Canceled {
+		t.Errorf("expected Canceled but got %v", err)
+	}
+}
+
+func TestSliceSource(t *testing.T) {
+	src := SliceSource([]ImageInfo{{URL: "a"}, {URL: "b"}})
+
+	for _, expected := range []string{"a", "b"} {
+		if img, err := src.Next(context.Background()); err != nil || img.URL != expected {
+			t.Errorf("expected %s but got %+v, %v", expected, img, err)
+		}
+	}
+
+	if _, err := src.Next(context.Background()); err != EndOfResults {
+		t.Errorf("expected EndOfResults but got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package wikimg
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// rateWindow is how often the download rate is checked against
+// Puller.MinRate. It's a variable so tests can shorten it.
+var rateWindow = 5 * time.Second
+
+// StalledError is the result of an image whose download stalled, either
+// waiting longer than Puller.ReadTimeout for data or falling below
+// Puller.MinRate
+type StalledError struct {
+	URL string
+
+	// Idle is how long the download waited for data, if it timed out
+	Idle time.Duration
+
+	// Rate is the download rate in bytes per second, if it was too slow
+	Rate int64
+}
+
+// Error describes the stall
+func (e *StalledError) Error() string {
+	if e.Idle > 0 {
+		return fmt.Sprintf("wikimg: %s: no data for %v", e.URL, e.Idle)
+	}
+
+	return fmt.Sprintf("wikimg: %s: download slowed to %d bytes/s", e.URL, e.Rate)
+}
+
+// Timeout returns true, so a StalledError can be recognized like other
+// timeouts (e.g., net.Error)
+func (e *StalledError) Timeout() bool {
+	return true
+}
+
+// stallReader reads the body of a response, failing with a *StalledError
+// when a read waits too long or the download rate drops too low. Only time
+// spent waiting in Read counts, not time spent decoding or waiting for
+// memory between reads. A stalled read is interrupted by closing the body.
+type stallReader struct {
+	url     string
+	body    io.ReadCloser
+	idle    time.Duration
+	minRate int64
+
+	// timer closes the body when a read takes longer than idle, setting
+	// stalled
+	timer   *time.Timer
+	stalled atomic.Bool
+
+	// waited is the time spent reading in the current rate window and
+	// read is how many bytes were read in it
+	waited time.Duration
+	read   int64
+
+	err error
+}
+
+// watch returns a reader for body that enforces p.ReadTimeout and
+// p.MinRate. Without either, body is returned as is. When only MinRate is
+// set, reads time out after the rate window, so a connection that stalls
+// entirely is caught too.
+func (p *Puller) watch(imgURL string, body io.ReadCloser) io.Reader {
+	if p.ReadTimeout <= 0 && p.MinRate <= 0 {
+		return body
+	}
+
+	sr := &stallReader{
+		url:     imgURL,
+		body:    body,
+		idle:    p.ReadTimeout,
+		minRate: p.MinRate,
+	}
+	if sr.idle <= 0 {
+		sr.idle = rateWindow
+	}
+
+	sr.timer = time.AfterFunc(sr.idle, func() {
+		sr.stalled.Store(true)
+		body.Close()
+	})
+	sr.timer.Stop()
+
+	return sr
+}
+
+// Read reads from the body, timing out after idle and checking the rate
+func (sr *stallReader) Read(b []byte) (int, error) {
+	if sr.err != nil {
+		return 0, sr.err
+	}
+
+	start := time.Now()
+	sr.timer.Reset(sr.idle)
+	n, err := sr.body.Read(b)
+	sr.timer.Stop()
+
+	if sr.stalled.Load() {
+		sr.err = &StalledError{URL: sr.url, Idle: sr.idle}
+		return n, sr.err
+	}
+
+	sr.waited += time.Since(start)
+	sr.read += int64(n)
+	if sr.minRate > 0 && sr.waited >= rateWindow {
+		rate := int64(float64(sr.read) / sr.waited.Seconds())
+		if rate < sr.minRate {
+			sr.err = &StalledError{URL: sr.url, Rate: rate}
+			return n, sr.err
+		}
+
+		sr.waited, sr.read = 0, 0
+	}
+
+	return n, err
+}
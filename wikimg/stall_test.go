@@ -0,0 +1,92 @@
+package wikimg
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// trickle serves a PNG, writing n bytes at a time and sleeping for delay in
+// between. It stops early once done is closed.
+func trickle(n int, delay time.Duration, done chan struct{}) http.HandlerFunc {
+	buf := &bytes.Buffer{}
+	png.Encode(buf, image.NewNRGBA(image.Rect(0, 0, 64, 64)))
+	b := buf.Bytes()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < len(b); i += n {
+			w.Write(b[i:min(len(b), i+n)])
+			w.(http.Flusher).Flush()
+
+			select {
+			case <-time.After(delay):
+			case <-done:
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(trickle(100, time.Hour, done))
+	defer ts.Close()
+	defer close(done)
+
+	p := NewPuller(0)
+	p.ReadTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := p.FirstColor(ts.URL)
+
+	var stalled *StalledError
+	if !errors.As(err, &stalled) || stalled.Idle != p.ReadTimeout {
+		t.Fatalf("expected a *StalledError after %v but got %v", p.ReadTimeout, err)
+	}
+	if !stalled.Timeout() {
+		t.Errorf("expected stall to be a timeout")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("expected to fail fast but took %v", d)
+	}
+}
+
+func TestMinRate(t *testing.T) {
+	defer func(w time.Duration) { rateWindow = w }(rateWindow)
+	rateWindow = 50 * time.Millisecond
+
+	done := make(chan struct{})
+	ts := httptest.NewServer(trickle(1, 5*time.Millisecond, done))
+	defer ts.Close()
+	defer close(done)
+
+	p := NewPuller(0)
+	p.MinRate = 10000
+
+	_, err := p.FirstColor(ts.URL)
+
+	var stalled *StalledError
+	if !errors.As(err, &stalled) || stalled.Rate >= p.MinRate {
+		t.Fatalf("expected a *StalledError below %d bytes/s but got %v", p.MinRate, err)
+	}
+}
+
+func TestWatchFast(t *testing.T) {
+	ts := httptest.NewServer(trickle(1<<20, 0, nil))
+	defer ts.Close()
+
+	p := NewPuller(0)
+	p.ReadTimeout = time.Second
+	p.MinRate = 1
+
+	if _, err := p.FirstColor(ts.URL); err != nil {
+		t.Errorf("expected a fast download to succeed but got %v", err)
+	}
+}
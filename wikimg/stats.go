@@ -0,0 +1,52 @@
+package wikimg
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Stats counts the work a Puller has done
+type Stats struct {
+	// Pages is the number of pages of results requested from the API
+	Pages int64
+
+	// Images is the number of image URLs returned by Next()
+	Images int64
+
+	// Downloads is the number of images retrieved for analysis
+	Downloads int64
+
+	// Bytes is the total number of bytes downloaded from the API and image
+	// servers
+	Bytes int64
+}
+
+// pullerStats holds a Puller's counters. They are updated atomically since
+// FirstColor() may be called from many goroutines at once.
+type pullerStats struct {
+	pages, images, downloads, bytes atomic.Int64
+}
+
+// Stats returns a snapshot of the work p has done so far
+func (p *Puller) Stats() Stats {
+	return Stats{
+		Pages:     p.stats.pages.Load(),
+		Images:    p.stats.images.Load(),
+		Downloads: p.stats.downloads.Load(),
+		Bytes:     p.stats.bytes.Load(),
+	}
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+// Read reads from the underlying reader, adding to the count
+func (cr countingReader) Read(b []byte) (int, error) {
+	n, err := cr.r.Read(b)
+	cr.n.Add(int64(n))
+
+	return n, err
+}
@@ -0,0 +1,227 @@
+package wikimg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/brnstz/routine/lru"
+)
+
+const (
+	// wikidataURL is the Wikidata API, where the labels of depicted items
+	// are looked up by default
+	wikidataURL = "https://www.wikidata.org/w/api.php"
+
+	// entitiesMax is the most entities the API returns at once
+	entitiesMax = 50
+
+	// depictsProperty is the Wikidata property of what an image depicts
+	depictsProperty = "P180"
+
+	// labelLanguage is the language of the labels of depicted items,
+	// falling back to others if an item has no label in it
+	labelLanguage = "en"
+
+	// labelCacheMax is the most item labels a Puller keeps
+	labelCacheMax = 4096
+)
+
+// Entity is a Wikidata item, e.g., Q146 (house cat)
+type Entity struct {
+	// ID is the item's ID, e.g., Q146
+	ID string `json:"id"`
+
+	// Label is the item's name in English, if known
+	Label string `json:"label,omitempty"`
+}
+
+// structuredData is what we use of an image's Structured Data on Commons
+type structuredData struct {
+	depicts  []Entity
+	captions map[string]string
+}
+
+// entitiesResp mirrors the JSON returned by wbgetentities
+type entitiesResp struct {
+	Entities map[string]struct {
+		Title  string
+		Labels map[string]struct {
+			Value string
+		}
+		Statements map[string][]statement
+	}
+
+	Error *struct {
+		Code string
+		Info string
+	}
+}
+
+// statement is a single statement about an entity, e.g., one thing it
+// depicts
+type statement struct {
+	Mainsnak struct {
+		Datavalue struct {
+			Value json.RawMessage
+		}
+	}
+}
+
+// structuredData returns the Structured Data on Commons of images by title.
+// Images without any are left out. Items are labeled with the Wikidata API
+// at p.WikidataURL, or left unlabeled if it's empty.
+func (p *Puller) structuredData(ctx context.Context, images []apiImage) (map[string]structuredData, error) {
+	sd := map[string]structuredData{}
+	var unlabeled []string
+	seen := map[string]bool{}
+
+	for start := 0; start < len(images); start += entitiesMax {
+		var titles []string
+		for _, img := range images[start:min(start+entitiesMax, len(images))] {
+			titles = append(titles, img.Title)
+		}
+
+		params := url.Values{}
+		params.Set("action", "wbgetentities")
+		params.Set("format", "json")
+		params.Set("sites", "commonswiki")
+		params.Set("titles", strings.Join(titles, "|"))
+		params.Set("props", "labels|claims")
+
+		er, err := p.getEntities(ctx, p.APIURL, params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range er.Entities {
+			var data structuredData
+
+			for _, st := range e.Statements[depictsProperty] {
+				var item struct {
+					ID string
+				}
+				if json.Unmarshal(st.Mainsnak.Datavalue.Value, &item) != nil || len(item.ID) < 1 {
+					continue
+				}
+
+				data.depicts = append(data.depicts, Entity{ID: item.ID})
+				if !seen[item.ID] {
+					seen[item.ID] = true
+					unlabeled = append(unlabeled, item.ID)
+				}
+			}
+
+			for lang, label := range e.Labels {
+				if data.captions == nil {
+					data.captions = map[string]string{}
+				}
+				data.captions[lang] = label.Value
+			}
+
+			if len(e.Title) > 0 && (len(data.depicts) > 0 || len(data.captions) > 0) {
+				sd[e.Title] = data
+			}
+		}
+	}
+
+	err := p.labelItems(ctx, unlabeled)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := p.labelCache()
+	for title, data := range sd {
+		for i, item := range data.depicts {
+			if label, ok := labels.Get(p.labelKey(item.ID)); ok {
+				data.depicts[i].Label = label
+			}
+		}
+		sd[title] = data
+	}
+
+	return sd, nil
+}
+
+// labelCache returns the Puller's cache of item labels, creating it on
+// first use
+func (p *Puller) labelCache() *lru.Cache[string, string] {
+	p.labelsOnce.Do(func() {
+		p.labels = lru.New[string, string](labelCacheMax, 0)
+	})
+
+	return p.labels
+}
+
+// labelKey returns the key of the label of the item with id in the label
+// cache. Labels are only reused for the same Wikidata API.
+func (p *Puller) labelKey(id string) string {
+	return p.WikidataURL + "|" + id
+}
+
+// labelItems looks up the labels of the items with ids that aren't already
+// in the label cache. Items without a label are cached too, with an empty
+// one, so they aren't looked up again.
+func (p *Puller) labelItems(ctx context.Context, ids []string) error {
+	if len(p.WikidataURL) < 1 {
+		return nil
+	}
+
+	labels := p.labelCache()
+
+	var missing []string
+	for _, id := range ids {
+		if _, ok := labels.Peek(p.labelKey(id)); !ok {
+			missing = append(missing, id)
+		}
+	}
+
+	for start := 0; start < len(missing); start += entitiesMax {
+		batch := missing[start:min(start+entitiesMax, len(missing))]
+
+		params := url.Values{}
+		params.Set("action", "wbgetentities")
+		params.Set("format", "json")
+		params.Set("ids", strings.Join(batch, "|"))
+		params.Set("props", "labels")
+		params.Set("languages", labelLanguage)
+		params.Set("languagefallback", "1")
+
+		er, err := p.getEntities(ctx, p.WikidataURL, params)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range batch {
+			label := ""
+			for _, l := range er.Entities[id].Labels {
+				label = l.Value
+				break
+			}
+			labels.Add(p.labelKey(id), label)
+		}
+	}
+
+	return nil
+}
+
+// getEntities calls wbgetentities on the API at apiURL with params
+func (p *Puller) getEntities(ctx context.Context, apiURL string, params url.Values) (*entitiesResp, error) {
+	b, err := p.getPage(ctx, apiURL+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var er entitiesResp
+	err = json.Unmarshal(b, &er)
+	if err != nil {
+		return nil, fmt.Errorf("wikimg: couldn't parse structured data: %v", err)
+	}
+	if er.Error != nil {
+		return nil, fmt.Errorf("wikimg: structured data: %s: %s", er.Error.Code, er.Error.Info)
+	}
+
+	return &er, nil
+}
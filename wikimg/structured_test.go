@@ -0,0 +1,136 @@
+package wikimg
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// structuredPage is the structured data of the images in validPage. Only
+// a.png has any.
+const structuredPage = `{"entities": {
+	"M1": {"id": "M1", "title": "File:a.png",
+		"labels": {"en": {"language": "en", "value": "A red square"}, "de": {"language": "de", "value": "Ein rotes Quadrat"}},
+		"statements": {"P180": [
+			{"mainsnak": {"snaktype": "value", "property": "P180", "datavalue": {"type": "wikibase-entityid", "value": {"entity-type": "item", "numeric-id": 900001, "id": "Q900001"}}}},
+			{"mainsnak": {"snaktype": "somevalue", "property": "P180"}},
+			{"mainsnak": {"snaktype": "value", "property": "P180", "datavalue": {"type": "wikibase-entityid", "value": {"entity-type": "item", "numeric-id": 900002, "id": "Q900002"}}}}
+		]}},
+	"M2": {"id": "M2", "title": "File:b.png", "missing": ""}
+}}`
+
+func TestStructuredData(t *testing.T) {
+	var labeled string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/wikidata":
+			labeled = r.FormValue("ids")
+			w.Write([]byte(`{"entities": {"Q900001": {"id": "Q900001", "labels": {"en": {"language": "en", "value": "square"}}}, "Q900002": {"id": "Q900002", "labels": {}}}}`))
+
+		case r.FormValue("action") == "wbgetentities":
+			if r.FormValue("titles") != "File:a.png|File:b.png" || r.FormValue("sites") != "commonswiki" {
+				w.Write([]byte(`{"error": {"code": "no-such-entity", "info": "unexpected request"}}`))
+				return
+			}
+			w.Write([]byte(structuredPage))
+
+		default:
+			w.Write([]byte(validPage))
+		}
+	}))
+	defer ts.Close()
+
+	p := NewPuller(2)
+	p.APIURL = ts.URL + "/structured"
+	p.WikidataURL = ts.URL + "/wikidata"
+	p.StructuredData = true
+
+	img, err := p.NextInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(img.Depicts) != 2 || img.Depicts[0] != (Entity{ID: "Q900001", Label: "square"}) || img.Depicts[1] != (Entity{ID: "Q900002"}) {
+		t.Errorf("expected a square and an unlabeled item but got %+v", img.Depicts)
+	}
+	if len(img.Captions) != 2 || img.Captions["en"] != "A red square" {
+		t.Errorf("expected captions but got %v", img.Captions)
+	}
+	if labeled != "Q900001|Q900002" {
+		t.Errorf("expected both items to be labeled but got %q", labeled)
+	}
+
+	img, err = p.NextInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Depicts != nil || img.Captions != nil {
+		t.Errorf("expected no structured data for b.png but got %+v", img)
+	}
+}
+
+func TestStructuredDataFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("action") == "wbgetentities" {
+			w.Write([]byte(`{"error": {"code": "internal_api_error", "info": "oops"}}`))
+			return
+		}
+		w.Write([]byte(validPage))
+	}))
+	defer ts.Close()
+
+	p := NewPuller(2)
+	p.APIURL = ts.URL + "/failure"
+	p.StructuredData = true
+
+	// The images are still pulled
+	img, err := p.NextInfo()
+	if err != nil || img.URL != "http://example.com/a.png" || img.Depicts != nil {
+		t.Errorf("expected a.png without structured data but got %+v, %v", img, err)
+	}
+}
+
+func TestLabelItemsCache(t *testing.T) {
+	requests := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		if r.URL.Path == "/other" {
+			w.Write([]byte(`{"entities": {"Q1": {"id": "Q1", "labels": {"en": {"language": "en", "value": "other"}}}}}`))
+			return
+		}
+		w.Write([]byte(`{"entities": {"Q1": {"id": "Q1", "labels": {"en": {"language": "en", "value": "one"}}}, "Q2": {"id": "Q2", "labels": {}}}}`))
+	}))
+	defer ts.Close()
+
+	p := NewPuller(1)
+	p.WikidataURL = ts.URL + "/wikidata"
+
+	// Items without a label are cached too, so the second call is free
+	for i := 0; i < 2; i++ {
+		err := p.labelItems(context.Background(), []string{"Q1", "Q2"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests["/wikidata"] != 1 {
+		t.Errorf("expected 1 request but got %d", requests["/wikidata"])
+	}
+	if label, ok := p.labelCache().Get(p.labelKey("Q2")); !ok || label != "" {
+		t.Errorf("expected Q2 to be cached without a label but got %q, %t", label, ok)
+	}
+
+	// Another Wikidata API has its own labels
+	p.WikidataURL = ts.URL + "/other"
+	err := p.labelItems(context.Background(), []string{"Q1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if label, _ := p.labelCache().Get(p.labelKey("Q1")); requests["/other"] != 1 || label != "other" {
+		t.Errorf("expected Q1 to be labeled by the other API but got %q", label)
+	}
+
+	// And other Pullers don't share them
+	if _, ok := NewPuller(1).labelCache().Get(p.labelKey("Q1")); ok {
+		t.Error("expected a new Puller to have no labels")
+	}
+}
@@ -0,0 +1,116 @@
+package wikimg
+
+import "math"
+
+// HueBins is the number of bins in Summary.Hues, each covering 360/HueBins
+// degrees starting at red
+const HueBins = 12
+
+// HueNames label the hue bins, in order
+var HueNames = [HueBins]string{
+	"red", "orange", "yellow", "chartreuse", "green", "spring",
+	"cyan", "azure", "blue", "violet", "magenta", "rose",
+}
+
+// HueBin returns which of the HueBins the hue of info falls in, or -1 if
+// it's gray
+func (info ColorInfo) HueBin() int {
+	if info.Gray {
+		return -1
+	}
+
+	bin := int(info.H / (360 / HueBins))
+
+	return min(max(bin, 0), HueBins-1)
+}
+
+// Summary describes the colors of many images at once, e.g., a day of
+// uploads. It's computed the same way everywhere by Summarize, so a
+// dashboard and a report on the same results agree.
+type Summary struct {
+	// Images is the number of results summarized, including errors
+	Images int `json:"images"`
+
+	// Errors is the number of results that failed
+	Errors int `json:"errors"`
+
+	// Hues counts the colors, excluding grays, by hue. Bin i covers hues
+	// from i*360/HueBins up to (i+1)*360/HueBins degrees.
+	Hues [HueBins]int `json:"hues"`
+
+	// MeanSaturation is the mean saturation of every color
+	MeanSaturation float64 `json:"mean_saturation"`
+
+	// GrayFraction is the fraction of colors that are gray
+	GrayFraction float64 `json:"gray_fraction"`
+
+	// Entropy is the Shannon entropy, in bits, of the distribution of
+	// colors. It's 0 when every image has the same color and grows as
+	// colors are more varied.
+	Entropy float64 `json:"entropy"`
+
+	// Warm, Cool and Neutral count the colors by Temperature()
+	Warm    int `json:"warm"`
+	Cool    int `json:"cool"`
+	Neutral int `json:"neutral"`
+
+	// Warmth is the mean Warmth() of every color and Temperature is its
+	// classification, i.e., the overall mood
+	Warmth      float64     `json:"warmth"`
+	Temperature Temperature `json:"temperature"`
+}
+
+// Summarize computes a Summary of results. Results with errors are only
+// counted.
+func Summarize(results []ColorResult) Summary {
+	var s Summary
+	var sat, warmth float64
+	var grays int
+	counts := map[string]int{}
+
+	for _, res := range results {
+		s.Images++
+		if res.Err != nil {
+			s.Errors++
+			continue
+		}
+
+		info := res.Info
+		sat += info.S
+		warmth += info.Warmth()
+		counts[info.Hex]++
+
+		switch info.Temperature() {
+		case Warm:
+			s.Warm++
+		case Cool:
+			s.Cool++
+		default:
+			s.Neutral++
+		}
+
+		bin := info.HueBin()
+		if bin < 0 {
+			grays++
+			continue
+		}
+		s.Hues[bin]++
+	}
+
+	n := s.Images - s.Errors
+	if n < 1 {
+		return s
+	}
+
+	s.MeanSaturation = sat / float64(n)
+	s.GrayFraction = float64(grays) / float64(n)
+	s.Warmth = warmth / float64(n)
+	s.Temperature = WarmthTemperature(s.Warmth)
+
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		s.Entropy -= p * math.Log2(p)
+	}
+
+	return s
+}
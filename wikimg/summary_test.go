@@ -0,0 +1,78 @@
+package wikimg
+
+import (
+	"errors"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	red := newColorInfo(color.RGBA{255, 0, 0, 255}, -1)
+	blue := newColorInfo(color.RGBA{0, 0, 255, 255}, -1)
+	gray := newColorInfo(color.RGBA{128, 128, 128, 255}, -1)
+	gray.Gray = true
+
+	results := []ColorResult{
+		{Info: red},
+		{Info: red},
+		{Info: blue},
+		{Info: gray},
+		{Err: errors.New("failed")},
+	}
+
+	s := Summarize(results)
+
+	if s.Images != 5 || s.Errors != 1 {
+		t.Errorf("expected 5 images with 1 error but got %d, %d", s.Images, s.Errors)
+	}
+
+	// Red is in the first bin and blue (240 degrees) in the ninth
+	var hues [HueBins]int
+	hues[0], hues[8] = 2, 1
+	if s.Hues != hues {
+		t.Errorf("expected hues %v but got %v", hues, s.Hues)
+	}
+
+	if s.MeanSaturation != 0.75 {
+		t.Errorf("expected mean saturation 0.75 but got %v", s.MeanSaturation)
+	}
+	if s.GrayFraction != 0.25 {
+		t.Errorf("expected gray fraction 0.25 but got %v", s.GrayFraction)
+	}
+
+	if s.Warm != 2 || s.Cool != 1 || s.Neutral != 1 {
+		t.Errorf("expected 2 warm, 1 cool and 1 neutral but got %d, %d, %d", s.Warm, s.Cool, s.Neutral)
+	}
+
+	// Half red, a quarter each blue and gray
+	if math.Abs(s.Entropy-1.5) > 1e-9 {
+		t.Errorf("expected entropy 1.5 but got %v", s.Entropy)
+	}
+
+	// Nothing to summarize
+	if s := Summarize(nil); s.Images != 0 || s.Entropy != 0 || s.Temperature != Neutral {
+		t.Errorf("unexpected empty summary %+v", s)
+	}
+}
+
+func TestHueBin(t *testing.T) {
+	tests := []struct {
+		info     ColorInfo
+		expected int
+	}{
+		{ColorInfo{H: 0}, 0},
+		{ColorInfo{H: 29.9}, 0},
+		{ColorInfo{H: 30}, 1},
+		{ColorInfo{H: 240}, 8},
+		{ColorInfo{H: 359.9}, 11},
+		{ColorInfo{H: 360}, 11},
+		{ColorInfo{H: 120, Gray: true}, -1},
+	}
+
+	for _, test := range tests {
+		if bin := test.info.HueBin(); bin != test.expected {
+			t.Errorf("expected bin %d for %v but got %d", test.expected, test.info.H, bin)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package wikimg
+
+import (
+	"fmt"
+	"math"
+)
+
+// Temperature is whether a color looks warm, cool or neither
+type Temperature int
+
+const (
+	// Neutral colors are grays and colors too weak or too close to the
+	// boundary between warm and cool to call either way
+	Neutral Temperature = iota
+
+	// Warm colors are reds, oranges and yellows
+	Warm
+
+	// Cool colors are greens, blues and violets
+	Cool
+)
+
+const (
+	// warmestHue is the hue, in degrees, of the warmest color (orange).
+	// The coolest is opposite it, at cyan-blue.
+	warmestHue = 30.0
+
+	// neutralWarmth is how far from zero Warmth() must be for a color to
+	// be warm or cool
+	neutralWarmth = 0.1
+)
+
+// String returns the name of the temperature
+func (t Temperature) String() string {
+	switch t {
+	case Warm:
+		return "warm"
+	case Cool:
+		return "cool"
+	}
+
+	return "neutral"
+}
+
+// MarshalText encodes the temperature as its name, e.g., for JSON
+func (t Temperature) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText decodes a temperature from its name, e.g., in a Summary
+// posted by the notify package
+func (t *Temperature) UnmarshalText(b []byte) error {
+	for _, v := range []Temperature{Neutral, Warm, Cool} {
+		if v.String() == string(b) {
+			*t = v
+			return nil
+		}
+	}
+
+	return fmt.Errorf("wikimg: invalid temperature %q (must be warm, cool or neutral)", b)
+}
+
+// Warmth scores how warm the color looks, from -1 (the coolest, a vivid
+// cyan-blue) to 1 (the warmest, a vivid orange). The score depends on how
+// close the hue is to either extreme and how colorful the color is, so
+// grays, and colors near black or white, score close to zero.
+func (info ColorInfo) Warmth() float64 {
+	if info.Gray {
+		return 0
+	}
+
+	// Chroma is high for saturated colors that aren't too light or dark
+	chroma := info.S * (1 - math.Abs(2*info.L-1))
+
+	return math.Cos((info.H-warmestHue)*math.Pi/180) * chroma
+}
+
+// Temperature classifies the color as warm, cool or neutral by its Warmth()
+func (info ColorInfo) Temperature() Temperature {
+	return WarmthTemperature(info.Warmth())
+}
+
+// WarmthTemperature classifies a warmth score, such as the mean Warmth() of
+// many colors, as warm, cool or neutral
+func WarmthTemperature(w float64) Temperature {
+	switch {
+	case w >= neutralWarmth:
+		return Warm
+	case w <= -neutralWarmth:
+		return Cool
+	}
+
+	return Neutral
+}
@@ -0,0 +1,62 @@
+package wikimg
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestTemperature(t *testing.T) {
+	tests := []struct {
+		c        color.Color
+		expected Temperature
+	}{
+		{color.RGBA{255, 128, 0, 255}, Warm},
+		{color.RGBA{255, 0, 0, 255}, Warm},
+		{color.RGBA{255, 220, 0, 255}, Warm},
+		{color.RGBA{0, 128, 255, 255}, Cool},
+		{color.RGBA{0, 200, 120, 255}, Cool},
+		{color.RGBA{128, 128, 128, 255}, Neutral},
+		{color.RGBA{250, 245, 240, 255}, Neutral},
+
+		// Halfway between orange and cyan-blue
+		{color.RGBA{0, 255, 0, 255}, Neutral},
+		{color.RGBA{255, 0, 255, 255}, Neutral},
+	}
+
+	for _, test := range tests {
+		info := newColorInfo(test.c, -1)
+		if temp := info.Temperature(); temp != test.expected {
+			t.Errorf("%s: expected %v but got %v (warmth %.2f)", info.Hex, test.expected, temp, info.Warmth())
+		}
+	}
+
+	orange := newColorInfo(color.RGBA{255, 128, 0, 255}, -1)
+	blue := newColorInfo(color.RGBA{0, 128, 255, 255}, -1)
+	if w := orange.Warmth(); w < 0.99 {
+		t.Errorf("expected orange to be about 1 but got %.2f", w)
+	}
+	if w := blue.Warmth(); w > -0.99 {
+		t.Errorf("expected blue to be about -1 but got %.2f", w)
+	}
+
+	orange.Gray = true
+	if w := orange.Warmth(); w != 0 {
+		t.Errorf("expected gray to be 0 but got %.2f", w)
+	}
+}
+
+func TestWarmthTemperature(t *testing.T) {
+	tests := map[float64]Temperature{
+		0.5:   Warm,
+		0.1:   Warm,
+		0.05:  Neutral,
+		-0.05: Neutral,
+		-0.1:  Cool,
+	}
+
+	for w, expected := range tests {
+		if temp := WarmthTemperature(w); temp != expected {
+			t.Errorf("%.2f: expected %v but got %v", w, expected, temp)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package wikimg
+
+import (
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// UseThumbnails makes FirstColor() analyze a thumbnail of each image that
+// is width pixels wide, rather than the original upload. Colors are nearly
+// identical, but the download is often 10-100x smaller. Images that aren't
+// hosted on Commons, or don't have a thumbnail, are analyzed as usual. A
+// width of zero goes back to analyzing originals.
+func (p *Puller) UseThumbnails(width int) {
+	p.thumbWidth = width
+}
+
+// thumbURL rewrites a Commons upload URL (e.g.,
+// https://upload.wikimedia.org/wikipedia/commons/a/ab/Name.jpg) into the URL
+// of a thumbnail that is width pixels wide (e.g.,
+// https://upload.wikimedia.org/wikipedia/commons/thumb/a/ab/Name.jpg/320px-Name.jpg).
+// Commons renders thumbnails of vector formats like SVG as PNG, so ".png" is
+// appended for them. If imgURL isn't a Commons upload URL, false is returned.
+func thumbURL(imgURL string, width int) (string, bool) {
+	u, err := url.Parse(imgURL)
+	if err != nil || u.Host != "upload.wikimedia.org" {
+		return "", false
+	}
+
+	// The path is /<project>/<site>/<h>/<hh>/<name> where h and hh are
+	// the first one and two characters of the hash of the name
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	if len(parts) != 5 || len(parts[2]) != 1 || len(parts[3]) != 2 {
+		return "", false
+	}
+
+	name := parts[4]
+	thumb := strconv.Itoa(width) + "px-" + name
+	if isSVG(name) {
+		thumb += ".png"
+	}
+
+	u.Path = "/" + path.Join(parts[0], parts[1], "thumb", parts[2], parts[3], name, thumb)
+
+	return u.String(), true
+}
+
+// isSVG returns true if the file name or URL is an SVG
+func isSVG(name string) bool {
+	return strings.EqualFold(path.Ext(name), ".svg")
+}
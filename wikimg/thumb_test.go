@@ -0,0 +1,30 @@
+package wikimg
+
+import "testing"
+
+func TestThumbURL(t *testing.T) {
+	tests := []struct {
+		in, out string
+		ok      bool
+	}{
+		{
+			"https://upload.wikimedia.org/wikipedia/commons/a/ab/Name.jpg",
+			"https://upload.wikimedia.org/wikipedia/commons/thumb/a/ab/Name.jpg/320px-Name.jpg",
+			true,
+		},
+		{
+			"https://upload.wikimedia.org/wikipedia/commons/1/12/Flag.SVG",
+			"https://upload.wikimedia.org/wikipedia/commons/thumb/1/12/Flag.SVG/320px-Flag.SVG.png",
+			true,
+		},
+		{"https://example.com/wikipedia/commons/a/ab/Name.jpg", "", false},
+		{"https://upload.wikimedia.org/wikipedia/commons/Name.jpg", "", false},
+	}
+
+	for _, test := range tests {
+		out, ok := thumbURL(test.in, 320)
+		if out != test.out || ok != test.ok {
+			t.Errorf("%s: expected %q, %v but got %q, %v", test.in, test.out, test.ok, out, ok)
+		}
+	}
+}
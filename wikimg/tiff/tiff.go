@@ -0,0 +1,11 @@
+// Package tiff adds TIFF support to wikimg, for the scans and museum uploads
+// that make up a large share of Commons. Import it for its side effects:
+//
+//	import _ "github.com/brnstz/routine/wikimg/tiff"
+package tiff
+
+import (
+	// Registering the decoder with the image package is all that's needed
+	// for wikimg to decode TIFF images
+	_ "golang.org/x/image/tiff"
+)
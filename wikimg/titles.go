@@ -0,0 +1,134 @@
+package wikimg
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// pageURL is the base URL of pages on Commons
+const pageURL = "https://commons.wikimedia.org/wiki/"
+
+// titleEntry is a line of a TitleIndex file
+type titleEntry struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// TitleIndex maps image URLs to the titles of their pages on Commons, so
+// an image can be linked to its file page (see PageURL) when only its URL
+// was recorded. Set it as Puller.Titles to record every image returned by
+// Next(). The index can be kept in a file, one JSON object per line, which
+// is only ever appended to. It's safe to use from many goroutines at once.
+type TitleIndex struct {
+	titles map[string]string
+	f      *os.File
+	mutex  sync.RWMutex
+}
+
+// NewTitleIndex creates an index that is only kept in memory
+func NewTitleIndex() *TitleIndex {
+	return &TitleIndex{titles: map[string]string{}}
+}
+
+// OpenTitleIndex loads the index in the file at path, creating it if it
+// doesn't exist, and appends new titles to it. A partly written last line,
+// e.g., after a crash, is ignored.
+func OpenTitleIndex(path string) (*TitleIndex, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	ti := NewTitleIndex()
+	ti.f = f
+
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		var e titleEntry
+		if json.Unmarshal(s.Bytes(), &e) != nil {
+			continue
+		}
+
+		ti.titles[e.URL] = e.Title
+	}
+	if err := s.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return ti, nil
+}
+
+// Add records the title of the page of the image at imgURL. Nothing is
+// written if it's already known.
+func (ti *TitleIndex) Add(imgURL, title string) error {
+	if len(imgURL) < 1 || len(title) < 1 {
+		return nil
+	}
+
+	ti.mutex.Lock()
+	defer ti.mutex.Unlock()
+
+	if ti.titles[imgURL] == title {
+		return nil
+	}
+	ti.titles[imgURL] = title
+
+	if ti.f == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(titleEntry{URL: imgURL, Title: title})
+	if err != nil {
+		return err
+	}
+
+	_, err = ti.f.Write(append(b, '\n'))
+
+	return err
+}
+
+// Lookup returns the title of the page of the image at imgURL, if it's
+// known
+func (ti *TitleIndex) Lookup(imgURL string) (string, bool) {
+	ti.mutex.RLock()
+	defer ti.mutex.RUnlock()
+
+	title, ok := ti.titles[imgURL]
+
+	return title, ok
+}
+
+// Len returns the number of images in the index
+func (ti *TitleIndex) Len() int {
+	ti.mutex.RLock()
+	defer ti.mutex.RUnlock()
+
+	return len(ti.titles)
+}
+
+// Close closes the index's file, if it has one
+func (ti *TitleIndex) Close() error {
+	ti.mutex.Lock()
+	defer ti.mutex.Unlock()
+
+	if ti.f == nil {
+		return nil
+	}
+
+	err := ti.f.Close()
+	ti.f = nil
+
+	return err
+}
+
+// PageURL returns the URL of the Commons page with title (e.g.,
+// "File:Example.jpg")
+func PageURL(title string) string {
+	return pageURL + url.PathEscape(strings.ReplaceAll(title, " ", "_"))
+}
@@ -0,0 +1,55 @@
+package wikimg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTitleIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "titles.ndjson")
+
+	ti, err := OpenTitleIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ti.Add("http://example.com/a.png", "File:A.png")
+	ti.Add("http://example.com/b.png", "File:B b.png")
+	ti.Add("http://example.com/a.png", "File:A.png")
+	if err := ti.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash in the middle of writing a line
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"url":"http://example.com/c.png","ti`)
+	f.Close()
+
+	// Titles survive reopening the file
+	ti, err = OpenTitleIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ti.Close()
+
+	if ti.Len() != 2 {
+		t.Errorf("expected 2 titles but got %d", ti.Len())
+	}
+	if title, ok := ti.Lookup("http://example.com/b.png"); !ok || title != "File:B b.png" {
+		t.Errorf("unexpected title %q, %v", title, ok)
+	}
+	if _, ok := ti.Lookup("http://example.com/c.png"); ok {
+		t.Errorf("expected partial line to be ignored")
+	}
+}
+
+func TestPageURL(t *testing.T) {
+	u := PageURL("File:Sunset over the bay?.jpg")
+	if u != "https://commons.wikimedia.org/wiki/File:Sunset_over_the_bay%3F.jpg" {
+		t.Errorf("unexpected page URL %q", u)
+	}
+}
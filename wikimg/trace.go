@@ -0,0 +1,74 @@
+package wikimg
+
+import "context"
+
+// Tracer starts a span around each phase of pulling and analyzing images:
+// querying a page of API results (wikimg.query), analyzing an image
+// (wikimg.FirstColor), downloading it (wikimg.fetch), decoding it
+// (wikimg.decode) and finding its color (wikimg.quantize). Services
+// embedding wikimg can use it to see where the time goes in each of their
+// requests. See the wikimg/tracing package for an OpenTelemetry Tracer.
+type Tracer interface {
+	// Start starts a span called name as a child of the span in ctx, if
+	// any, and returns a context containing the new span
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a phase of work started by a Tracer
+type Span interface {
+	// SetAttribute describes the span, e.g., with the format of the
+	// image. Values are strings, ints, float64s or bools.
+	SetAttribute(key string, value any)
+
+	// End ends the span, recording err if it failed
+	End(err error)
+}
+
+// noSpan is the Span of a Puller without a Tracer
+type noSpan struct{}
+
+func (noSpan) SetAttribute(key string, value any) {}
+func (noSpan) End(err error)                      {}
+
+// trace starts a span called name for work on u, using p.Tracer if there
+// is one
+func (p *Puller) trace(ctx context.Context, name, u string) (context.Context, Span) {
+	if p.Tracer == nil {
+		return ctx, noSpan{}
+	}
+
+	ctx, span := p.Tracer.Start(ctx, name)
+	span.SetAttribute("wikimg.url", u)
+
+	return ctx, span
+}
+
+// context returns the context that p was created with by
+// NewPullerContext, or the background context
+func (p *Puller) context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+
+	return p.ctx
+}
+
+// cancelWith returns a channel that's closed when either ctx is done or
+// p.Cancel is closed, so a single channel can be passed down to the
+// download and scan. The returned function must be called once the
+// channel is no longer needed.
+func (p *Puller) cancelWith(ctx context.Context) (<-chan struct{}, func()) {
+	cancel := make(chan struct{})
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			close(cancel)
+		case <-p.Cancel:
+			close(cancel)
+		case <-stop:
+		}
+	}()
+
+	return cancel, func() { close(stop) }
+}
@@ -0,0 +1,150 @@
+package wikimg
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// spanKey is the context key of the current recorded span
+type spanKey struct{}
+
+// recordedSpan is a span kept by spanRecorder
+type recordedSpan struct {
+	name   string
+	parent string
+	attrs  map[string]any
+	ended  bool
+	err    error
+}
+
+func (s *recordedSpan) SetAttribute(key string, value any) { s.attrs[key] = value }
+func (s *recordedSpan) End(err error)                      { s.ended, s.err = true, err }
+
+// spanRecorder is a Tracer that keeps every span
+type spanRecorder struct {
+	spans []*recordedSpan
+	mutex sync.Mutex
+}
+
+func (r *spanRecorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	s := &recordedSpan{name: name, attrs: map[string]any{}}
+	if parent, ok := ctx.Value(spanKey{}).(*recordedSpan); ok {
+		s.parent = parent.name
+	}
+	r.spans = append(r.spans, s)
+
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// find returns the first span called name
+func (r *spanRecorder) find(name string) *recordedSpan {
+	for _, s := range r.spans {
+		if s.name == name {
+			return s
+		}
+	}
+
+	return nil
+}
+
+func TestTracer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bad" {
+			w.Write([]byte("not an image"))
+			return
+		}
+
+		png.Encode(w, image.NewNRGBA(image.Rect(0, 0, 3, 2)))
+	}))
+	defer ts.Close()
+
+	rec := &spanRecorder{}
+	p := NewPuller(0)
+	p.Tracer = rec
+
+	// The caller's span is the parent of ours
+	ctx, root := rec.Start(context.Background(), "request")
+	_, err := p.FirstColorContext(ctx, ts.URL+"/good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.End(nil)
+
+	expected := []struct {
+		name, parent string
+	}{
+		{"wikimg.FirstColor", "request"},
+		{"wikimg.fetch", "wikimg.FirstColor"},
+		{"wikimg.decode", "wikimg.fetch"},
+		{"wikimg.quantize", "wikimg.FirstColor"},
+	}
+	for _, e := range expected {
+		s := rec.find(e.name)
+		if s == nil {
+			t.Fatalf("expected a %s span", e.name)
+		}
+		if s.parent != e.parent {
+			t.Errorf("expected %s to be a child of %s but got %q", e.name, e.parent, s.parent)
+		}
+		if !s.ended || s.err != nil {
+			t.Errorf("expected %s to end without error but got %v, %v", e.name, s.ended, s.err)
+		}
+	}
+
+	fetch := rec.find("wikimg.fetch")
+	if fmt.Sprintf("%v %v %v", fetch.attrs["wikimg.format"], fetch.attrs["wikimg.width"], fetch.attrs["wikimg.height"]) != "png 3 2" {
+		t.Errorf("expected png 3 2 but got %v", fetch.attrs)
+	}
+
+	// Failures are recorded on the span
+	rec.spans = nil
+	_, err = p.FirstColor(ts.URL + "/bad")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, e := range expected[:2] {
+		s := rec.find(e.name)
+		if e.parent == "request" {
+			e.parent = ""
+		}
+		if s == nil || s.parent != e.parent || s.err == nil {
+			t.Errorf("expected a failed %s span under %q but got %+v", e.name, e.parent, s)
+		}
+	}
+}
+
+func TestTracerQuery(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"query":{"allimages":[{"url":"https://example.com/a.png","timestamp":"2016-01-02T03:04:05Z"}]}}`)
+	}))
+	defer ts.Close()
+
+	rec := &spanRecorder{}
+	ctx, root := rec.Start(context.Background(), "request")
+	p := NewPullerContext(ctx, 1)
+	p.APIURL = ts.URL + "/tracer"
+	p.Tracer = rec
+
+	_, err := p.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.End(nil)
+
+	s := rec.find("wikimg.query")
+	if s == nil || s.parent != "request" || !s.ended {
+		t.Fatalf("expected an ended query span under request but got %+v", s)
+	}
+	if s.attrs["wikimg.results"] != 1 {
+		t.Errorf("expected 1 result but got %v", s.attrs["wikimg.results"])
+	}
+}
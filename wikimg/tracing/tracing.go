@@ -0,0 +1,88 @@
+// Package tracing traces Pullers with OpenTelemetry
+// https://opentelemetry.io, so services embedding wikimg see how long it
+// spends querying the API and fetching, decoding and quantizing each image
+// as part of their own traces.
+//
+//	p := wikimg.NewPuller(100)
+//	p.Tracer = tracing.Default()
+//
+//	// Spans are children of the span in ctx
+//	info, err := p.FirstColorContext(ctx, imgURL)
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// Name is the instrumentation name of the tracer used by Default
+const Name = "github.com/brnstz/routine/wikimg"
+
+// Tracer is a wikimg.Tracer that starts OpenTelemetry spans
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// New creates a Tracer that starts spans with t
+func New(t trace.Tracer) *Tracer {
+	return &Tracer{tracer: t}
+}
+
+// Default creates a Tracer using the global tracer provider, so spans are
+// exported however the service has set up OpenTelemetry
+func Default() *Tracer {
+	return New(otel.Tracer(Name))
+}
+
+// Start starts an OpenTelemetry span
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, wikimg.Span) {
+	ctx, s := t.tracer.Start(ctx, name)
+
+	return ctx, span{s}
+}
+
+// span adapts an OpenTelemetry span to a wikimg.Span
+type span struct {
+	span trace.Span
+}
+
+// SetAttribute sets an attribute of the span
+func (s span) SetAttribute(key string, value any) {
+	s.span.SetAttributes(keyValue(key, value))
+}
+
+// End records err, if any, and ends the span
+func (s span) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+
+	s.span.End()
+}
+
+// keyValue converts an attribute to its OpenTelemetry type. Values that
+// aren't strings, numbers or bools are formatted as strings.
+func keyValue(key string, value any) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	}
+
+	return attribute.String(key, fmt.Sprint(value))
+}
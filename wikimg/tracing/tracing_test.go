@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// fakeSpan records what's done to it. Methods we don't use are left to the
+// nil embedded Span.
+type fakeSpan struct {
+	trace.Span
+
+	name   string
+	attrs  map[attribute.Key]any
+	errs   []error
+	status codes.Code
+	ended  bool
+}
+
+func (s *fakeSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, a := range kv {
+		s.attrs[a.Key] = a.Value.AsInterface()
+	}
+}
+
+func (s *fakeSpan) RecordError(err error, opts ...trace.EventOption) {
+	s.errs = append(s.errs, err)
+}
+
+func (s *fakeSpan) SetStatus(code codes.Code, description string) {
+	s.status = code
+}
+
+func (s *fakeSpan) End(opts ...trace.SpanEndOption) {
+	s.ended = true
+}
+
+// fakeTracer keeps every span it starts
+type fakeTracer struct {
+	trace.Tracer
+
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	s := &fakeSpan{name: name, attrs: map[attribute.Key]any{}}
+	t.spans = append(t.spans, s)
+
+	return ctx, s
+}
+
+func TestTracer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bad" {
+			w.Write([]byte("not an image"))
+			return
+		}
+
+		png.Encode(w, image.NewNRGBA(image.Rect(0, 0, 3, 2)))
+	}))
+	defer ts.Close()
+
+	ft := &fakeTracer{}
+	p := wikimg.NewPuller(0)
+	p.Tracer = New(ft)
+
+	_, err := p.FirstColorContext(context.Background(), ts.URL+"/good")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := map[string]*fakeSpan{}
+	for _, s := range ft.spans {
+		names[s.name] = s
+		if !s.ended || len(s.errs) > 0 || s.status != codes.Unset {
+			t.Errorf("expected %s to end cleanly but got %+v", s.name, s)
+		}
+	}
+	for _, name := range []string{"wikimg.FirstColor", "wikimg.fetch", "wikimg.decode", "wikimg.quantize"} {
+		if names[name] == nil {
+			t.Errorf("expected a %s span", name)
+		}
+	}
+
+	fetch := names["wikimg.fetch"]
+	if fetch.attrs["wikimg.format"] != "png" || fetch.attrs["wikimg.width"] != int64(3) {
+		t.Errorf("expected png attributes but got %v", fetch.attrs)
+	}
+
+	// Failures set the status
+	ft.spans = nil
+	_, err = p.FirstColor(ts.URL + "/bad")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	root := ft.spans[0]
+	if root.name != "wikimg.FirstColor" || root.status != codes.Error || len(root.errs) != 1 {
+		t.Errorf("expected a failed FirstColor span but got %+v", root)
+	}
+}
+
+func TestKeyValue(t *testing.T) {
+	tests := []struct {
+		value    any
+		expected any
+	}{
+		{"png", "png"},
+		{3, int64(3)},
+		{int64(4), int64(4)},
+		{0.5, 0.5},
+		{true, true},
+		{[]int{1, 2}, "[1 2]"},
+	}
+
+	for _, test := range tests {
+		kv := keyValue("k", test.value)
+		if kv.Key != "k" || kv.Value.AsInterface() != test.expected {
+			t.Errorf("expected %v for %v but got %v", test.expected, test.value, kv.Value.AsInterface())
+		}
+	}
+}
@@ -0,0 +1,282 @@
+// Package unsplash is a wikimg.Source of the latest photos on Unsplash
+// (https://unsplash.com), a stream of high quality photos for the mosaics
+// and terminal viewers. It needs an access key (see
+// https://unsplash.com/documentation#authorization):
+//
+//	src := unsplash.NewSource(os.Getenv("UNSPLASH_ACCESS_KEY"), 100)
+//	src.MaxWait = time.Hour
+//
+//	for {
+//		img, err := src.Next(ctx)
+//		if err == wikimg.EndOfResults {
+//			break
+//		}
+//		...
+//	}
+//
+// Keys are rate limited, to 50 requests an hour for new apps. Each request
+// lists up to 30 photos, so that's 1500 photos an hour.
+package unsplash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// Sizes of photos (see https://unsplash.com/documentation#example-image-use)
+const (
+	Raw     = "raw"     // the original, with no resizing
+	Full    = "full"    // the original, as a JPEG
+	Regular = "regular" // 1080 pixels wide
+	Small   = "small"   // 400
+	Thumb   = "thumb"   // 200
+)
+
+const (
+	// apiURL is the API's root
+	apiURL = "https://api.unsplash.com"
+
+	// DefaultSize is the default for Source.Size. It's plenty for finding
+	// colors and quick to download.
+	DefaultSize = Small
+
+	// DefaultPerPage is the default for Source.PerPage, the most the API
+	// returns at once, since we're limited by requests rather than photos
+	DefaultPerPage = 30
+
+	// maxPerPage is the most photos the API returns at once
+	maxPerPage = 30
+
+	// DefaultRetry is how long Next waits to retry when it's rate limited
+	// and the API doesn't say when to
+	DefaultRetry = time.Minute
+
+	// maxBody is the most of an API response we read
+	maxBody = 10 << 20
+)
+
+// RateLimitError is returned by Next when the access key has made too many
+// requests and it won't wait for more
+type RateLimitError struct {
+	// Limit is the number of requests allowed an hour, if known
+	Limit int
+
+	// RetryAfter is how long until requests may be allowed again
+	RetryAfter time.Duration
+}
+
+// Error describes the error
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("unsplash: rate limit of %d requests an hour exceeded, retry after %v", e.Limit, e.RetryAfter)
+}
+
+// photo is a photo listed by the API, with only the fields we use
+type photo struct {
+	ID             string
+	CreatedAt      time.Time `json:"created_at"`
+	Description    string
+	AltDescription string            `json:"alt_description"`
+	URLs           map[string]string `json:"urls"`
+	Links          struct {
+		HTML string
+	}
+	User struct {
+		Name string
+	}
+}
+
+// Source pulls the latest photos on Unsplash, most recent first. Set its
+// fields before the first call to Next. Like a wikimg.Puller, it must only
+// be used by one goroutine at a time.
+type Source struct {
+	// AccessKey is the key used to call the API
+	AccessKey string
+
+	// Size is which size of each photo is returned, e.g., Small. Empty
+	// means DefaultSize.
+	Size string
+
+	// PerPage is the number of photos requested at once, up to 30. Zero
+	// means DefaultPerPage.
+	PerPage int
+
+	// MaxWait is the longest Next waits for requests to be allowed again
+	// once it's rate limited, in total. Zero means it returns a
+	// *RateLimitError right away.
+	MaxWait time.Duration
+
+	// APIURL is the API's root URL, for testing. Empty means Unsplash's.
+	APIURL string
+
+	// Client is the HTTP client used to call the API. If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	// Remaining is the number of requests the access key has left this
+	// hour, as of the last response, or -1 before the first
+	Remaining int
+
+	max   int
+	count int
+
+	// page is the last page requested and done is whether it was the last
+	page   int
+	done   bool
+	photos []photo
+
+	// waited is how long Next has waited for the rate limit so far
+	waited time.Duration
+
+	// seen are the IDs returned so far. New photos push others onto later
+	// pages while we're paging, so some are listed twice.
+	seen map[string]bool
+}
+
+// NewSource creates a Source that returns at most max photos
+func NewSource(accessKey string, max int) *Source {
+	return &Source{AccessKey: accessKey, max: max, Remaining: -1, seen: map[string]bool{}}
+}
+
+// Next returns the next most recent photo, or wikimg.EndOfResults once max
+// photos have been returned or there are no more
+func (s *Source) Next(ctx context.Context) (wikimg.ImageInfo, error) {
+	for s.count < s.max {
+		if len(s.photos) < 1 {
+			if s.done {
+				break
+			}
+
+			err := s.query(ctx)
+			if err != nil {
+				return wikimg.ImageInfo{}, err
+			}
+			if len(s.photos) < 1 {
+				break
+			}
+		}
+
+		ph := s.photos[0]
+		s.photos = s.photos[1:]
+
+		u := ph.URLs[s.size()]
+		if len(u) < 1 || s.seen[ph.ID] {
+			continue
+		}
+		s.seen[ph.ID] = true
+		s.count++
+
+		title := ph.Description
+		if len(title) < 1 {
+			title = ph.AltDescription
+		}
+
+		return wikimg.ImageInfo{URL: u, Title: title, Uploaded: ph.CreatedAt.UTC(), Uploader: ph.User.Name, Page: ph.Links.HTML}, nil
+	}
+
+	return wikimg.ImageInfo{}, wikimg.EndOfResults
+}
+
+// size returns the size of photos to return
+func (s *Source) size() string {
+	if len(s.Size) < 1 {
+		return DefaultSize
+	}
+
+	return s.Size
+}
+
+// query requests the next page of photos, waiting while it's rate limited
+// for up to MaxWait in total
+func (s *Source) query(ctx context.Context) error {
+	for {
+		err := s.get(ctx)
+
+		rle, ok := err.(*RateLimitError)
+		if !ok || s.waited+rle.RetryAfter > s.MaxWait {
+			return err
+		}
+
+		t := time.NewTimer(rle.RetryAfter)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+		s.waited += rle.RetryAfter
+	}
+}
+
+// get requests the next page of photos once
+func (s *Source) get(ctx context.Context) error {
+	perPage := s.PerPage
+	if perPage < 1 {
+		perPage = DefaultPerPage
+	}
+	perPage = min(perPage, maxPerPage)
+
+	params := url.Values{}
+	params.Set("order_by", "latest")
+	params.Set("per_page", strconv.Itoa(perPage))
+	params.Set("page", strconv.Itoa(s.page+1))
+
+	u := s.APIURL
+	if len(u) < 1 {
+		u = apiURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u+"/photos?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Client-ID "+s.AccessKey)
+	req.Header.Set("Accept-Version", "v1")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	limit, _ := strconv.Atoi(resp.Header.Get("X-Ratelimit-Limit"))
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-Ratelimit-Remaining")); err == nil {
+		s.Remaining = remaining
+	}
+
+	// Unsplash says 403 when it's rate limited, but others say 429
+	if resp.StatusCode == http.StatusTooManyRequests || (resp.StatusCode == http.StatusForbidden && s.Remaining == 0) {
+		retry := DefaultRetry
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			retry = time.Duration(secs) * time.Second
+		}
+
+		return &RateLimitError{Limit: limit, RetryAfter: retry}
+	} else if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unsplash: API returned %s", resp.Status)
+	}
+
+	var photos []photo
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxBody)).Decode(&photos)
+	if err != nil {
+		return fmt.Errorf("unsplash: couldn't parse API response: %v", err)
+	}
+
+	s.page++
+	s.done = len(photos) < perPage
+	s.photos = photos
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package unsplash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// newAPI starts a fake API with pages of two photos each, 5 photos in all.
+// The first photo of each page after the first is the last of the page
+// before, as when photos are added while paging. The first limited
+// requests are rate limited.
+func newAPI(t *testing.T, limited int, retryAfter string) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+
+		if r.Header.Get("Authorization") != "Client-ID key" || r.URL.Path != "/photos" || r.FormValue("order_by") != "latest" {
+			http.Error(w, "unexpected request", http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("X-Ratelimit-Limit", "50")
+		if n <= limited {
+			w.Header().Set("X-Ratelimit-Remaining", "0")
+			if len(retryAfter) > 0 {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			http.Error(w, "Rate Limit Exceeded", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("X-Ratelimit-Remaining", strconv.Itoa(50-n))
+
+		page, _ := strconv.Atoi(r.FormValue("page"))
+
+		// Photo 2 doesn't have our size
+		fmt.Fprint(w, "[")
+		for id := page; id < page+2 && id <= 5; id++ {
+			if id > page {
+				fmt.Fprint(w, ",")
+			}
+
+			small := fmt.Sprintf(`"small": "http://example.com/%d.jpg"`, id)
+			if id == 2 {
+				small = `"thumb": "http://example.com/2-thumb.jpg"`
+			}
+			fmt.Fprintf(w, `{"id": "p%d", "created_at": "2024-01-01T12:00:00-05:00", "description": null, "alt_description": "photo %d", "urls": {%s}, "links": {"html": "https://unsplash.com/photos/p%d"}, "user": {"name": "Photographer"}}`, id, id, small, id)
+		}
+		fmt.Fprint(w, "]")
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts, &requests
+}
+
+func TestSource(t *testing.T) {
+	ts, requests := newAPI(t, 0, "")
+
+	src := NewSource("key", 10)
+	src.APIURL = ts.URL
+	src.PerPage = 2
+
+	var got []wikimg.ImageInfo
+	for {
+		img, err := src.Next(context.Background())
+		if err == wikimg.EndOfResults {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		got = append(got, img)
+	}
+
+	// Pages are 1 2, 2 3, 3 4, 4 5, 5. Duplicates and 2 are skipped.
+	expected := []string{"1", "3", "4", "5"}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v but got %+v", expected, got)
+	}
+	for i, id := range expected {
+		if u := "http://example.com/" + id + ".jpg"; got[i].URL != u {
+			t.Errorf("expected %s at %d but got %s", u, i, got[i].URL)
+		}
+	}
+
+	img := got[0]
+	if img.Title != "photo 1" || img.Uploader != "Photographer" || img.Page != "https://unsplash.com/photos/p1" || !img.Uploaded.Equal(time.Date(2024, 1, 1, 17, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected image %+v", img)
+	}
+	if n := requests.Load(); n != 5 {
+		t.Errorf("expected 5 requests but got %d", n)
+	}
+	if src.Remaining != 45 {
+		t.Errorf("expected 45 requests remaining but got %d", src.Remaining)
+	}
+}
+
+func TestSourceRateLimit(t *testing.T) {
+	ts, _ := newAPI(t, 1, "3600")
+
+	src := NewSource("key", 10)
+	src.APIURL = ts.URL
+	src.MaxWait = time.Minute
+
+	// Waiting an hour is too long
+	_, err := src.Next(context.Background())
+
+	var rle *RateLimitError
+	if !errors.As(err, &rle) || rle.Limit != 50 || rle.RetryAfter != time.Hour {
+		t.Fatalf("expected a rate limit error but got %v", err)
+	}
+
+	// The next request isn't limited
+	if _, err = src.Next(context.Background()); err != nil {
+		t.Errorf("expected a photo but got %v", err)
+	}
+}
+
+func TestSourceRateLimitWait(t *testing.T) {
+	ts, requests := newAPI(t, 2, "0")
+
+	src := NewSource("key", 1)
+	src.APIURL = ts.URL
+	src.MaxWait = time.Minute
+
+	img, err := src.Next(context.Background())
+	if err != nil || img.URL != "http://example.com/1.jpg" {
+		t.Fatalf("expected the first photo but got %+v, %v", img, err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("expected 3 requests but got %d", n)
+	}
+
+	// Without a Retry-After, it waits DefaultRetry, unless it's canceled
+	ts, _ = newAPI(t, 1, "")
+	src = NewSource("key", 1)
+	src.APIURL = ts.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	src.MaxWait = time.Hour
+
+	_, err = src.Next(ctx)
+	if err == nil {
+		t.Error("expected an error when canceled")
+	}
+}
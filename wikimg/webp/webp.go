@@ -0,0 +1,13 @@
+// Package webp adds WebP support to wikimg. It is a separate package so that
+// programs which don't need WebP don't depend on golang.org/x/image. Import
+// it for its side effects, the same way the standard image decoders are
+// imported:
+//
+//	import _ "github.com/brnstz/routine/wikimg/webp"
+package webp
+
+import (
+	// Registering the decoder with the image package is all that's needed
+	// for wikimg to decode WebP images
+	_ "golang.org/x/image/webp"
+)
@@ -4,17 +4,23 @@
 package wikimg
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"image"
 	"image/color"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
+	"time"
 
-	// We define which image formats we support by importing decoder packages
+	"github.com/brnstz/routine/lru"
+
+	// We define which image formats we support by importing decoder
+	// packages. Other formats can be added the same way (see the webp,
+	// tiff and bmp sub-packages).
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
@@ -29,37 +35,80 @@ var (
 	Canceled = errors.New("wikimg: canceled image processing")
 )
 
+// TooLargeError is returned by FirstColor() when an image has more pixels
+// than the Puller's MaxPixels
+type TooLargeError struct {
+	// URL is the image that was rejected
+	URL string
+
+	// Width and Height are the dimensions of the image
+	Width, Height int
+
+	// MaxPixels is the limit the image exceeded
+	MaxPixels int
+}
+
+// Error describes the image that was too large
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("wikimg: %dx%d image exceeds %d pixels: %s",
+		e.Width, e.Height, e.MaxPixels, e.URL)
+}
+
 const (
-	// queryURL is the API we are querying
+	// queryURL is the API we query by default
 	queryURL = "https://commons.wikimedia.org/w/api.php"
 
 	// apiMax is the max results we can request from the API at one time
 	apiMax = 500
 
-	// cancelCheckpoint is the number of pixels between checking whether the
-	// request was canceled when running FirstColor()
-	cancelCheckpoint = 10000
+	// DefaultMaxPixels is the default limit on the number of pixels in an
+	// image that FirstColor() will decode (25 megapixels)
+	DefaultMaxPixels = 25000000
+
+	// DefaultSVGWidth is the default width of the PNG that SVG images are
+	// rendered as
+	DefaultSVGWidth = 512
+
+	// DefaultReadTimeout is the default limit on how long a download
+	// may wait for data
+	DefaultReadTimeout = 30 * time.Second
 )
 
-// queryResp mirrors the JSON structure returned by queryURL, specifying only
+// queryResp mirrors the JSON structure returned by the API, specifying only
 // the info we're interested in.
 type queryResp struct {
 
-	// Continue contains strings we need to pass back into the API to
-	// continue where we left off
-	Continue struct {
-		Continue   string
-		AIContinue string
-	}
+	// Continue contains the values we need to pass back into the API to
+	// continue where we left off. Which keys are present depends on the
+	// API, so we keep them all and echo every one back.
+	Continue map[string]json.RawMessage
 
 	// Query contains the actual results
 	Query struct {
-		AllImages []struct {
-			URL string
-		}
+		AllImages []apiImage
 	}
 }
 
+// apiImage is a single image returned by the API
+type apiImage struct {
+	URL       string
+	Title     string
+	Timestamp time.Time
+	User      string
+
+	// ExtMetadata contains extended metadata about the image, such as its
+	// license. It is only requested when needed.
+	ExtMetadata map[string]struct {
+		Value interface{}
+	}
+}
+
+// meta returns the extended metadata value for key as a string
+func (img apiImage) meta(key string) string {
+	s, _ := img.ExtMetadata[key].Value.(string)
+	return s
+}
+
 // Puller is an image puller that retrieves the most recent image URLs that
 // have been uploaded to Wikimedia Commons https://commons.wikimedia.org
 type Puller struct {
@@ -69,12 +118,44 @@ type Puller struct {
 	// i is the current index into qr.Query.AllImages
 	i int
 
+	// structured is the structured data of the images in qr by title, if
+	// StructuredData is set
+	structured map[string]structuredData
+
+	// pages caches recent API responses. See pageCache().
+	pages     *lru.Cache[string, apiPage]
+	pagesOnce sync.Once
+
+	// labels caches the labels of Wikidata items by WikidataURL and ID,
+	// which rarely change. See labelCache().
+	labels     *lru.Cache[string, string]
+	labelsOnce sync.Once
+
 	// count is the total number of images we've collected
 	count int
 
 	// max is the maximum number of images we want to collect
 	max int
 
+	// stats counts the work we've done
+	stats pullerStats
+
+	// uploads maps the URLs we've returned to when they were uploaded
+	uploads sync.Map
+
+	// memory tracks how much memory decoded images are using, for
+	// MaxMemory
+	memory memLimiter
+
+	// thumbWidth is the width of thumbnails to analyze instead of the
+	// original images, if any. See UseThumbnails().
+	thumbWidth int
+
+	// ctx is the context the Puller was created with, if any. Spans
+	// that aren't started by a call with its own context are its
+	// children.
+	ctx context.Context
+
 	// Cancel is an optional channel. Setting this value on Puller
 	// and closing the channel signals to the Puller that any
 	// in process operations (i.e, retrieving an image or computing
@@ -82,172 +163,543 @@ type Puller struct {
 	// calls to Next() or FirstColor() will return a Canceled
 	// error.
 	Cancel <-chan struct{}
+
+	// Client is used for every request to the API and image servers. If
+	// nil, http.DefaultClient is used. Tests can set one with a custom
+	// Transport, e.g., to inject faults (see the wikimgtest package).
+	Client *http.Client
+
+	// LocalFiles lets the Puller read file URLs (see FileURL) from disk,
+	// e.g., for the images of a FileSource. It's off by default, so a
+	// Puller given URLs by someone else (e.g., a client of the rpc
+	// package) can't be made to read local files.
+	LocalFiles bool
+
+	// APIURL is the Commons API endpoint that image URLs are pulled from.
+	// NewPuller() sets it to the public Commons API. Tests can point it at
+	// a fake server (see the wikimgtest package).
+	APIURL string
+
+	// WikidataURL is the Wikidata API endpoint that the labels of
+	// depicted items are looked up at (see StructuredData). NewPuller()
+	// sets it to the public Wikidata API. If empty, items aren't labeled.
+	WikidataURL string
+
+	// StructuredData fetches the Structured Data on Commons of each image
+	// returned by NextInfo(): what it depicts and its captions (see
+	// ImageInfo). It costs a request to the API, and to Wikidata for new
+	// items, for every 50 images. Images are returned without it if it
+	// can't be fetched.
+	StructuredData bool
+
+	// Licenses optionally restricts results to images under the given
+	// licenses, using the license codes reported by Commons (e.g., "cc0",
+	// "cc-by-4.0", "pd"). A code without a version (e.g., "cc-by") matches
+	// every version of that license, but not its variants (e.g.,
+	// "cc-by-sa-4.0"). If empty, all images are returned.
+	Licenses []string
+
+	// MaxPixels is the largest image, in total pixels, that FirstColor()
+	// will decode. Larger images are rejected with a *TooLargeError before
+	// they are decoded. Zero means no limit. NewPuller() sets this to
+	// DefaultMaxPixels.
+	MaxPixels int
+
+	// SVGWidth is the width of the PNG that Commons renders SVG images as
+	// for FirstColor(), since SVGs can't be decoded directly. Zero
+	// disables rendering, so SVGs fail to decode. NewPuller() sets this
+	// to DefaultSVGWidth.
+	SVGWidth int
+
+	// MaxMemory caps the estimated memory, in bytes, of the images being
+	// decoded and analyzed at once by all goroutines using the Puller.
+	// Images wait until enough memory is free. An image larger than
+	// MaxMemory is still analyzed, but only on its own. Zero means no
+	// limit.
+	MaxMemory int64
+
+	// ReadTimeout is the longest an image download may wait for more
+	// data, including in the middle of decoding, before it fails with a
+	// *StalledError. Zero means no limit. NewPuller() sets this to
+	// DefaultReadTimeout.
+	ReadTimeout time.Duration
+
+	// MinRate is the slowest an image may download, in bytes per second,
+	// measured every few seconds. Slower downloads fail with a
+	// *StalledError. Zero means no limit.
+	MinRate int64
+
+	// Cache optionally memoizes FirstColor() results, so repeated calls
+	// for the same URL (e.g., across pull cycles) return instantly. It
+	// may be shared by many Pullers. Only successful results are cached.
+	Cache Cache
+
+	// Budget bounds how long FirstColors() and StreamColors() spend on
+	// each image, so one pathological file can't stall a live display.
+	// The zero value has no bounds.
+	Budget Budget
+
+	// Routes says how images are handled based on their mime type, e.g.,
+	// which are skipped and which are analyzed as thumbnails. NewPuller()
+	// sets this to DefaultRoutes(). If nil, every image is decoded.
+	Routes Routes
+
+	// Titles optionally records the page title of every image returned
+	// by Next(), so callers can look it up by URL later. It may be shared
+	// by many Pullers.
+	Titles *TitleIndex
+
+	// Observer is optionally notified of everything the Puller does, e.g.,
+	// to export metrics. It may be shared by many Pullers.
+	Observer Observer
+
+	// Logger is where the Puller logs what it's doing. If nil, the
+	// Logger set with SetLogger() is used, and by default nothing is
+	// logged.
+	Logger Logger
+
+	// Tracer optionally traces the phases of pulling and analyzing each
+	// image. Spans are children of the span in the context passed to
+	// FirstColorContext(), FirstColors() or StreamColors(), or otherwise
+	// of the context passed to NewPullerContext().
+	Tracer Tracer
+
+	// Decoder optionally bounds how many images are decoded and scanned
+	// at once, to leave CPU for other work. It may be shared by many
+	// Pullers. If nil, decoding is only bounded by the caller's
+	// concurrency.
+	Decoder *Executor
+
+	// Operator is how to contact whoever runs this Puller (e.g., an email
+	// address or a URL). It's included in the User-Agent of every request,
+	// as the Wikimedia bot policy asks, so API admins can reach the
+	// operator of a misbehaving deployment instead of blocking it.
+	Operator string
+
+	// RequestID identifies this Puller's run to the operators of the
+	// servers it calls. It's sent in the X-Request-Id header and the
+	// User-Agent of every request, and included in each ColorInfo.
+	// NewPuller() sets it to a random ID.
+	RequestID string
+
+	// ReadEXIF reads EXIF metadata from JPEGs. Images are turned upright
+	// according to their orientation before they're analyzed, and the
+	// camera and capture date are reported in ColorInfo. It's off by
+	// default, so the fast path stays metadata free.
+	ReadEXIF bool
+
+	// Options controls how FirstColor() scans images and maps their
+	// colors. The zero value scans every pixel and maps colors to the
+	// XTerm256 palette.
+	Options ColorOptions
 }
 
 // NewPuller creates a puller that can return at most max images when calls to
 // Next() are made
 func NewPuller(max int) *Puller {
 	return &Puller{
-		max: max,
+		max:         max,
+		APIURL:      queryURL,
+		WikidataURL: wikidataURL,
+		MaxPixels:   DefaultMaxPixels,
+		SVGWidth:    DefaultSVGWidth,
+		ReadTimeout: DefaultReadTimeout,
+		Routes:      DefaultRoutes(),
+		RequestID:   NewRequestID(),
 	}
 }
 
+// NewPullerContext is like NewPuller, but the Puller is canceled when ctx
+// is done, e.g., when a lifecycle group shuts down. Its spans are children
+// of the span in ctx, if any (see Tracer).
+func NewPullerContext(ctx context.Context, max int) *Puller {
+	p := NewPuller(max)
+	p.Cancel = ctx.Done()
+	p.ctx = ctx
+
+	return p
+}
+
+// ImageInfo describes an image returned by the API
+type ImageInfo struct {
+	// URL is the URL of the original image
+	URL string `json:"url"`
+
+	// Title is the title of the image's page on Commons (e.g.,
+	// "File:Example.jpg"), or of the image itself if it's from another
+	// Source
+	Title string `json:"title"`
+
+	// Uploaded is when the image was uploaded
+	Uploaded time.Time `json:"uploaded"`
+
+	// Uploader is the name of the user who uploaded the image, if known
+	Uploader string `json:"uploader,omitempty"`
+
+	// Page is the URL of the image's page if it isn't on Commons, whose
+	// pages are found with PageURL(Title)
+	Page string `json:"page,omitempty"`
+
+	// Depicts are the Wikidata items the image depicts, e.g., to group
+	// colors by subject, and Captions are its captions by language code.
+	// They're only set with Puller.StructuredData.
+	Depicts  []Entity          `json:"depicts,omitempty"`
+	Captions map[string]string `json:"captions,omitempty"`
+}
+
 // Next returns the next most recent image URL. If no more results are
 // available EndOfResults is returned as an error.
 func (p *Puller) Next() (string, error) {
+	info, err := p.NextInfo()
+
+	return info.URL, err
+}
+
+// NextInfo is like Next, but returns more information about the image,
+// including when it was uploaded
+func (p *Puller) NextInfo() (ImageInfo, error) {
+	return p.nextInfo(p.context(), p.Cancel)
+}
+
+// nextInfo is NextInfo, stopping when cancel is closed and making requests
+// with ctx
+func (p *Puller) nextInfo(ctx context.Context, cancel <-chan struct{}) (ImageInfo, error) {
 	// If we've exceeded that max we want to get, then stop
 	if p.count >= p.max {
-		return "", EndOfResults
+		return ImageInfo{}, EndOfResults
 	}
 
-	// Ensure we haven't been canceled yet
-	select {
-	case <-p.Cancel:
-		// If p.Cancel has been closed, this will be triggered
-		return "", Canceled
+	for {
+		// Ensure we haven't been canceled yet
+		select {
+		case <-cancel:
+			// If cancel has been closed, this will be triggered
+			return ImageInfo{}, Canceled
 
-	default:
-		// Otherwise we'll just do nothing immediately
+		default:
+			// Otherwise we'll just do nothing immediately
+		}
+
+		// If we're within the length of our current request, return the
+		// next image that passes our filters and increment our counters
+		for p.qr != nil && p.i < len(p.qr.Query.AllImages) {
+			img := p.qr.Query.AllImages[p.i]
+			p.i++
+
+			if !p.allowed(img) {
+				continue
+			}
+
+			info := ImageInfo{
+				URL:      img.URL,
+				Title:    img.Title,
+				Uploaded: img.Timestamp,
+				Uploader: img.User,
+			}
+			if sd, ok := p.structured[img.Title]; ok {
+				info.Depicts, info.Captions = sd.depicts, sd.captions
+			}
+
+			// Remember when the image was uploaded, so it can be included
+			// in results
+			p.uploads.Store(img.URL, img.Timestamp)
+
+			// The index is best effort, it can't fail the pull
+			if p.Titles != nil {
+				p.Titles.Add(img.URL, img.Title)
+			}
+
+			p.count++
+			p.stats.images.Add(1)
+			p.observe(Event{Kind: ImagePulled, URL: img.URL})
+			return info, nil
+		}
+
+		// If the previous request had no continue values, there's
+		// nothing more to get
+		if p.qr != nil && len(p.qr.Continue) < 1 {
+			return ImageInfo{}, EndOfResults
+		}
+
+		// Otherwise, we need to create a new request
+		err := p.query(ctx)
+		if err != nil {
+			return ImageInfo{}, err
+		}
+
+		// If there's no more images, then return
+		if len(p.qr.Query.AllImages) < 1 {
+			return ImageInfo{}, EndOfResults
+		}
 	}
+}
 
-	// If we're within the length of our current request,
-	// return right away and increment our counters
-	if p.qr != nil && p.i < len(p.qr.Query.AllImages) {
-		img := p.qr.Query.AllImages[p.i].URL
-		p.i++
-		p.count++
-		return img, nil
+// continueValue converts a raw continue value into a query parameter. Values
+// are normally strings, but anything else (e.g., a number) is passed back
+// exactly as the API sent it.
+func continueValue(v json.RawMessage) string {
+	var s string
+	if json.Unmarshal(v, &s) == nil {
+		return s
 	}
 
-	// Otherwise, we need to create a new request. Recreate our request params
-	// and reset per-request counter.
-	p.i = 0
+	return string(v)
+}
+
+// query requests the next page of results from the API with ctx, replacing
+// p.qr
+func (p *Puller) query(ctx context.Context) (err error) {
+	// Recreate our request params
 	params := url.Values{}
 	params.Set("action", "query")
 	params.Set("format", "json")
 	params.Set("list", "allimages")
 	params.Set("aidir", "descending")
 	params.Set("aisort", "timestamp")
+	params.Set("aiprop", "url|timestamp|user")
 
 	// 500 is the most allowed by the API per request, but we may want less.
-	if p.count+apiMax > p.max {
+	// When filtering we can't know how many results we'll skip, so
+	// always get the most.
+	if p.count+apiMax > p.max && len(p.Licenses) < 1 {
 		params.Set("ailimit", strconv.Itoa(p.max-p.count))
 	} else {
-		params.Set("ailimit", strconv.Itoa(p.max))
+		params.Set("ailimit", strconv.Itoa(apiMax))
 	}
 
-	// If we have a previous request with continue values, use them
-	if p.qr != nil &&
-		len(p.qr.Continue.Continue) > 0 &&
-		len(p.qr.Continue.AIContinue) > 0 {
-		params.Set("continue", p.qr.Continue.Continue)
-		params.Set("aicontinue", p.qr.Continue.AIContinue)
+	// Licenses are in the extended metadata
+	if len(p.Licenses) > 0 {
+		params.Set("aiprop", "url|timestamp|user|extmetadata")
 	}
 
-	// Call the wikimedia API
-	resp, err := http.Get(queryURL + "?" + params.Encode())
-	if err != nil {
-		return "", err
+	// If we have a previous request with continue values, use them
+	if p.qr != nil {
+		for k, v := range p.qr.Continue {
+			params.Set(k, continueValue(v))
+		}
 	}
-	defer resp.Body.Close()
 
-	// Read the contents of the response as bytes
-	b, err := ioutil.ReadAll(resp.Body)
+	// Call the wikimedia API, or reuse a recent identical call
+	u := p.APIURL + "?" + params.Encode()
+	ctx, span := p.trace(ctx, "wikimg.query", u)
+	defer func() { span.End(err) }()
+
+	b, err := p.getPage(ctx, u)
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	// Parse the bytes into a struct
-	p.qr = &queryResp{}
-	err = json.Unmarshal(b, p.qr)
+	qr, skipped, err := parseQuery(u, b)
 	if err != nil {
-		return "", err
+		// Don't reuse a bad response, so a retry asks the API again
+		p.pageCache().Remove(u)
+		return err
 	}
 
-	// If there's no more images, then return
-	if len(p.qr.Query.AllImages) < 1 {
-		return "", EndOfResults
+	// One odd image shouldn't lose the whole page
+	for _, perr := range skipped {
+		p.log().Warn("wikimg: skipped malformed image", "err", perr)
+	}
+
+	// Start at the beginning of the new page
+	p.qr = qr
+	p.i = 0
+	span.SetAttribute("wikimg.results", len(p.qr.Query.AllImages))
+
+	// Structured data is extra, it can't fail the pull
+	p.structured = nil
+	if p.StructuredData {
+		var images []apiImage
+		for _, img := range qr.Query.AllImages {
+			if p.allowed(img) {
+				images = append(images, img)
+			}
+		}
+
+		p.structured, err = p.structuredData(ctx, images)
+		if err != nil {
+			p.log().Warn("wikimg: couldn't fetch structured data", "err", err)
+			err = nil
+		}
 	}
 
-	// Return first value of the new request
-	p.count++
-	return p.qr.Query.AllImages[p.i].URL, nil
+	return nil
 }
 
-// FirstColor tries to return the first non-gray color in the image. A gray
-// color is one that, when mapped to an xterm256 palette, has the same value
-// for red, green and blue. We iterate through pixels starting with 0,0 and
-// through each x and y value. In the worst case (a grayscale image), we
-// iterate through every pixel, give up, and return the final pixel color even
-// though it's gray. Both the xtermColor (an integer between 0-255) and a hex
-// string (e.g., "#bb00cc") is returned.
-func (p *Puller) FirstColor(imgURL string) (xtermColor int, hex string, err error) {
-	// Create a request so we can use req.Cancel
-	req, err := http.NewRequest("GET", imgURL, nil)
-	if err != nil {
-		return
+// FirstColor tries to return the first non-gray color in the image. By
+// default, a gray color is one that, when mapped to the palette in
+// p.Options, has the same value for red, green and blue (see GraySpread and
+// GraySaturation in ColorOptions to loosen this). We iterate through pixels
+// starting with 0,0 and through each x and y value (sampled according to
+// p.Options). In the worst case (a grayscale image), we iterate through
+// every pixel, give up, and return the final pixel color even though it's
+// gray, setting Gray on the result. The returned ColorInfo includes the
+// index of the color in the palette (by default an xterm256 value between
+// 0-255) and a hex string (e.g., "#bb00cc"). If p.Options.Unquantized is
+// set, the index is always -1 and the color is that of the pixel itself.
+// With p.Options.Method set to SaturationPercentile, a representative color
+// is picked by saturation instead (see Method). If p.Options.Report is
+// PixelColor, the returned color is that of the pixel, but the index is
+// still that of the palette color. Images whose type is routed to a custom
+// Analyzer in p.Routes are analyzed by it instead.
+func (p *Puller) FirstColor(imgURL string) (info ColorInfo, err error) {
+	return p.firstColor(p.context(), imgURL, p.Cancel, nil)
+}
+
+// FirstColorContext is like FirstColor, but also stops when ctx is done,
+// and its spans are children of the span in ctx (see Tracer)
+func (p *Puller) FirstColorContext(ctx context.Context, imgURL string) (ColorInfo, error) {
+	cancel, stop := p.cancelWith(ctx)
+	defer stop()
+
+	return p.firstColor(ctx, imgURL, cancel, nil)
+}
+
+// firstColor is FirstColor, stopping when cancel is closed and tracing
+// within ctx. If fetched isn't nil, it's called once the image has been
+// retrieved and decoded, before it's scanned.
+func (p *Puller) firstColor(ctx context.Context, imgURL string, cancel <-chan struct{}, fetched func()) (info ColorInfo, err error) {
+	// Results, cached or not, belong to this run. Every call is observed,
+	// including how long it took.
+	ctx, span := p.trace(ctx, "wikimg.FirstColor", imgURL)
+	start := time.Now()
+	defer func() {
+		info.RequestID = p.RequestID
+		p.observe(Event{Kind: Analyzed, URL: imgURL, Duration: time.Since(start), Err: err})
+		span.End(err)
+	}()
+
+	// Use a cached result if we have one
+	if p.Cache != nil {
+		key := p.cacheKey(imgURL)
+
+		var ok bool
+		info, ok = p.Cache.Get(key)
+		span.SetAttribute("wikimg.cached", ok)
+		if ok {
+			p.observe(Event{Kind: CacheHit, URL: imgURL})
+
+			if uploaded, ok := p.uploads.Load(imgURL); ok {
+				info.Uploaded = uploaded.(time.Time)
+			}
+
+			return
+		}
+
+		p.observe(Event{Kind: CacheMiss, URL: imgURL})
+
+		defer func() {
+			if err == nil {
+				p.Cache.Set(key, info, 0)
+			}
+		}()
 	}
 
-	// Set up cancellation pipeline, link request to puller
-	req.Cancel = p.Cancel
+	// Some types have their own analysis
+	if _, route := p.route(imgURL); route.Strategy == Custom && route.Analyzer != nil {
+		return route.Analyzer(p, imgURL)
+	}
 
-	// Call the image server
-	resp, err := http.DefaultClient.Do(req)
+	// Retrieve and decode the image
+	a, err := p.fetch(ctx, imgURL, false, cancel)
+	if fetched != nil {
+		fetched()
+	}
 	if err != nil {
 		return
 	}
-	defer resp.Body.Close()
-
-	// Decode into an object
-	img, _, err := image.Decode(resp.Body)
+	defer a.release()
+
+	_, quantize := p.trace(ctx, "wikimg.quantize", imgURL)
+	err = p.Decoder.Do(cancel, func() error {
+		info, err = p.Options.firstColor(a.img, cancel)
+		return err
+	})
+	quantize.End(err)
 	if err != nil {
 		return
 	}
 
-	// Use our XTerm256 as a color.Palette so we can map the colors of the
-	// image to our palette.
-	pal := color.Palette(XTerm256)
-
-	// Iterate through every pixel and try to find a color. If we don't find a
-	// color (i.e., the image is grayscale) we'll default to the last pixel in
-	// the image.
-	rect := img.Bounds()
-	i := 0
-	for x := 0; x < rect.Dx(); x++ {
-		for y := 0; y < rect.Dy(); y++ {
+	// Describe the image the color came from
+	info.setImage(a.img, a.format)
+	info.EXIF = a.exif
+	if uploaded, ok := p.uploads.Load(imgURL); ok {
+		info.Uploaded = uploaded.(time.Time)
+	}
 
-			// Check if p.Cancel has been closed once every cancelCheckpoint
-			// iterations
-			if i%cancelCheckpoint == 0 {
-				select {
+	return
+}
 
-				case <-p.Cancel:
-					// If p.Cancel has been closed, this will be triggered
-					err = Canceled
-					return
+// firstColor finds the first non-gray color in img, as described in
+// FirstColor
+func (o ColorOptions) firstColor(img image.Image, cancel <-chan struct{}) (info ColorInfo, err error) {
+	var pixel color.Color
+	var found bool
+
+	switch o.Method {
+	case SaturationPercentile:
+		pixel, err = o.percentile(img, cancel)
+		if err != nil || pixel == nil {
+			break
+		}
 
-				default:
-					// Otherwise we'll just do nothing immediately
-				}
-			}
-			i++
+		c, index := o.quantize(pixel)
+		info = newColorInfo(c, index)
+		found = !o.isGray(info)
 
-			// xtermColor is the index in the palette which this
-			// actual color maps to. It is also (by design) the
+	default:
+		// Scan the pixels and try to find a color. If we don't find a
+		// color (i.e., the image is grayscale) we'll default to the last
+		// pixel scanned.
+		found, err = o.scan(img, cancel, func(c color.Color) bool {
+			pixel = c
+
+			// index is the position in the palette which this actual
+			// color maps to. For XTerm256 it is also (by design) the
 			// xterm256 value that maps to this color.
-			xtermColor = pal.Index(img.At(x, y))
-			c := pal[xtermColor]
-			r, g, b, _ := c.RGBA()
+			c, index := o.quantize(c)
 
-			// Compute the hex value of the color
-			hex = fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+			// Compute the details of the color
+			info = newColorInfo(c, index)
 
-			// If any of the RGB values differ, it's a color, so we can stop.
-			if !(r == g && g == b) {
-				return
-			}
+			// If the RGB values differ enough, it's a color, so we can
+			// stop.
+			return !o.isGray(info)
+		})
+	}
+	if err != nil {
+		return
+	}
+
+	// Report the pixel's own color if asked
+	if o.Report == PixelColor && pixel != nil {
+		info = newColorInfo(pixel, info.Index)
+	}
+	info.Source = o.source().String()
+
+	// Measure how well the palette fits the image
+	if o.MeasureQuality {
+		info.DeltaE, err = o.deltaE(img, cancel)
+		if err != nil {
+			return
 		}
 	}
 
+	// Count the palette colors of the whole image
+	if o.MeasureHistogram {
+		info.Histogram, err = o.histogram(img, cancel)
+		if err != nil {
+			return
+		}
+	}
+
+	if found {
+		return
+	}
+
+	// We didn't find a color, so we're returning the final gray pixel
+	info.Gray = true
+
 	return
 }
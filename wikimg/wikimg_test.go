@@ -1,20 +1,30 @@
 package wikimg_test
 
 import (
+	"context"
 	"fmt"
-	"io"
+	"image/color"
 	"io/ioutil"
-	"net/http"
 	"os"
 
 	"github.com/brnstz/routine/wikimg"
+	"github.com/brnstz/routine/wikimg/download"
+	"github.com/brnstz/routine/wikimg/wikimgtest"
 )
 
 func Example() {
-	// Save 10 random images to a directory
+	// Save 10 images to a directory
+
+	// Fake the Commons API, so the example runs without the network.
+	// Use wikimg.NewPuller(10) to pull the latest images on Commons.
+	src := wikimgtest.NewSource(4)
+	defer src.Close()
+	for i := 0; i < 12; i++ {
+		src.AddImage(fmt.Sprintf("gray%d", i), color.Gray{uint8(i * 20)})
+	}
 
 	// Create a pull with max 10 results
-	p := wikimg.NewPuller(10)
+	p := src.Puller(10)
 
 	// Create temp dir for storing the images
 	dir, err := ioutil.TempDir("", "")
@@ -25,43 +35,43 @@ func Example() {
 	// Remove dir after test is complete
 	defer os.RemoveAll(dir)
 
-	for {
-		// Get the next URL
-		imgURL, err := p.Next()
+	// Create a downloader that saves images to dir, named after their
+	// pages on Commons
+	d, err := download.NewDownloader(dir)
+	if err != nil {
+		panic(err)
+	}
+
+	// Pull images in the background, downloading them as they arrive
+	images := make(chan wikimg.ImageInfo)
+	go func() {
+		defer close(images)
 
-		if err == wikimg.EndOfResults {
-			// We've reached the end
-			break
-		} else if err != nil {
-			// There's an unexpected error
-			panic(err)
-		}
+		for {
+			// Get the next image
+			img, err := p.NextInfo()
 
-		// Call GET on the image URL
-		resp, err := http.Get(imgURL)
-		if err != nil {
-			panic(err)
-		}
+			if err == wikimg.EndOfResults {
+				// We've reached the end
+				return
+			} else if err != nil {
+				// There's an unexpected error
+				panic(err)
+			}
 
-		// Open a temporary file
-		fh, err := ioutil.TempFile(dir, "")
-		if err != nil {
-			// We need to close our HTTP response here too
-			resp.Body.Close()
-			panic(err)
+			images <- img
 		}
+	}()
 
-		// Copy GET results to file and close stuff
-		_, err = io.Copy(fh, resp.Body)
-		fh.Close()
-		resp.Body.Close()
-		if err != nil {
-			panic(err)
+	saved := 0
+	for res := range d.DownloadAll(context.Background(), images) {
+		if res.Err != nil {
+			panic(res.Err)
 		}
-	}
 
-	files, _ := ioutil.ReadDir(dir)
-	fmt.Println(len(files))
+		saved++
+	}
+	fmt.Println(saved)
 
 	// Output: 10
 }
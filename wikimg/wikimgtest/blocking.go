@@ -0,0 +1,71 @@
+package wikimgtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// BlockingServer is an image server whose responses hang until Release() is
+// called, simulating a stalled download. Requests end early if the client
+// gives up, which lets tests check that cancellation reaches the network.
+type BlockingServer struct {
+	*httptest.Server
+
+	started chan string
+	release chan struct{}
+	once    sync.Once
+}
+
+// NewBlockingServer starts a BlockingServer. Call Close() when finished.
+func NewBlockingServer() *BlockingServer {
+	s := &BlockingServer{
+		started: make(chan string, 100),
+		release: make(chan struct{}),
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	return s
+}
+
+// ImageURL returns the URL of an image on the server
+func (s *BlockingServer) ImageURL(name string) string {
+	return s.URL + "/" + name + ".png"
+}
+
+// Started receives the path of each request once it is blocked
+func (s *BlockingServer) Started() <-chan string {
+	return s.started
+}
+
+// Release unblocks all current and future requests, which then receive a
+// small PNG
+func (s *BlockingServer) Release() {
+	s.once.Do(func() {
+		close(s.release)
+	})
+}
+
+// Close releases any blocked requests and shuts down the server
+func (s *BlockingServer) Close() {
+	s.Release()
+	s.Server.Close()
+}
+
+// serve blocks until the server is released or the client goes away
+func (s *BlockingServer) serve(w http.ResponseWriter, r *http.Request) {
+	select {
+	case s.started <- r.URL.Path:
+	default:
+	}
+
+	select {
+	case <-s.release:
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngImage)
+
+	case <-r.Context().Done():
+		// The client canceled
+	}
+}
@@ -0,0 +1,187 @@
+package wikimgtest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// ErrDropped is the error of requests dropped by a Chaos transport
+var ErrDropped = errors.New("wikimgtest: request dropped by chaos")
+
+// corruptEvery is roughly how many bytes of a corrupted response there are
+// per flipped byte
+const corruptEvery = 64
+
+// Chaos injects faults into HTTP requests and responses, so code can be
+// tested against a misbehaving network: slow responses, dropped
+// connections and corrupted bodies. Each fault happens to a request at
+// random with its rate, between 0 (never) and 1 (always). Use Transport()
+// on the client side (e.g., as wikimg.Puller.Client's transport) or
+// Handler() on the server side (e.g., with Source.SetChaos). It's safe to
+// use from many goroutines at once.
+type Chaos struct {
+	// DelayRate is how often responses are delayed by Delay
+	DelayRate float64
+	Delay     time.Duration
+
+	// DropRate is how often requests fail without a response
+	DropRate float64
+
+	// CorruptRate is how often response bodies have random bytes flipped
+	CorruptRate float64
+
+	rand  *rand.Rand
+	mutex sync.Mutex
+}
+
+// NewChaos creates a Chaos that injects no faults until its rates are set.
+// The same seed injects the same faults into the same sequence of
+// requests.
+func NewChaos(seed int64) *Chaos {
+	return &Chaos{rand: rand.New(rand.NewSource(seed))}
+}
+
+// roll returns true with probability rate
+func (c *Chaos) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.rand.Float64() < rate
+}
+
+// corrupt flips random bytes of b, at least one unless b is empty
+func (c *Chaos) corrupt(b []byte) {
+	if len(b) < 1 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	flipped := false
+	for i := range b {
+		if c.rand.Intn(corruptEvery) == 0 {
+			b[i] ^= byte(1 + c.rand.Intn(255))
+			flipped = true
+		}
+	}
+
+	if !flipped {
+		b[c.rand.Intn(len(b))] ^= byte(1 + c.rand.Intn(255))
+	}
+}
+
+// delay waits for Delay, if the roll says so, or until done is closed. It
+// returns false if done was closed first.
+func (c *Chaos) delay(done <-chan struct{}) bool {
+	if !c.roll(c.DelayRate) {
+		return true
+	}
+
+	select {
+	case <-time.After(c.Delay):
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// Transport returns a RoundTripper that injects faults into the requests
+// made with next. If next is nil, http.DefaultTransport is used.
+func (c *Chaos) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return chaosTransport{c, next}
+}
+
+// chaosTransport is the RoundTripper returned by Chaos.Transport
+type chaosTransport struct {
+	chaos *Chaos
+	next  http.RoundTripper
+}
+
+// RoundTrip makes the request, injecting faults
+func (ct chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !ct.chaos.delay(req.Context().Done()) {
+		return nil, req.Context().Err()
+	}
+
+	if ct.chaos.roll(ct.chaos.DropRate) {
+		return nil, ErrDropped
+	}
+
+	resp, err := ct.next.RoundTrip(req)
+	if err != nil || !ct.chaos.roll(ct.chaos.CorruptRate) {
+		return resp, err
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	ct.chaos.corrupt(b)
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+
+	return resp, nil
+}
+
+// Handler returns a handler that injects faults into the responses of
+// next. Dropped requests have their connection closed without a response.
+func (c *Chaos) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.delay(r.Context().Done()) {
+			return
+		}
+
+		if c.roll(c.DropRate) {
+			if hj, ok := w.(http.Hijacker); ok {
+				conn, _, err := hj.Hijack()
+				if err == nil {
+					conn.Close()
+					return
+				}
+			}
+
+			http.Error(w, ErrDropped.Error(), http.StatusBadGateway)
+			return
+		}
+
+		if !c.roll(c.CorruptRate) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Record the whole response so its body can be corrupted
+		rec := httptest.NewRecorder()
+		next.ServeHTTP(rec, r)
+
+		b := rec.Body.Bytes()
+		c.corrupt(b)
+
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(b)
+	})
+}
+
+// Client returns an HTTP client that injects faults into every request,
+// suitable for wikimg.Puller.Client
+func (c *Chaos) Client() *http.Client {
+	return &http.Client{Transport: c.Transport(nil)}
+}
@@ -0,0 +1,101 @@
+package wikimgtest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+func TestChaosTransport(t *testing.T) {
+	body := bytes.Repeat([]byte("color"), 1000)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	c := NewChaos(1)
+	client := c.Client()
+
+	// No faults until rates are set
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !bytes.Equal(b, body) {
+		t.Errorf("expected body to be intact")
+	}
+
+	c.CorruptRate = 1
+	resp, err = client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if len(b) != len(body) || bytes.Equal(b, body) {
+		t.Errorf("expected body to be corrupted")
+	}
+
+	c.CorruptRate = 0
+	c.DropRate = 1
+	if _, err := client.Get(ts.URL); !errors.Is(err, ErrDropped) {
+		t.Errorf("expected dropped request but got %v", err)
+	}
+
+	c.DropRate = 0
+	c.DelayRate = 1
+	c.Delay = 20 * time.Millisecond
+	start := time.Now()
+	if _, err := client.Get(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < c.Delay {
+		t.Errorf("expected a delay of %v but took %v", c.Delay, d)
+	}
+}
+
+func TestSourceChaos(t *testing.T) {
+	s := NewSource(10)
+	defer s.Close()
+	s.Add("http://example.com/a.png")
+
+	c := NewChaos(1)
+	c.DropRate = 1
+	s.SetChaos(c)
+
+	// The puller sees the dropped connection as an error
+	if _, err := s.Puller(1).Next(); err == nil {
+		t.Errorf("expected dropped response to fail")
+	}
+
+	s.SetChaos(nil)
+	if u, err := s.Puller(1).Next(); err != nil || u != "http://example.com/a.png" {
+		t.Errorf("expected a.png once chaos is off but got %q, %v", u, err)
+	}
+}
+
+func TestChaosFirstColor(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pngImage)
+	}))
+	defer ts.Close()
+
+	c := NewChaos(1)
+	c.CorruptRate = 1
+
+	p := wikimg.NewPuller(0)
+	p.Client = c.Client()
+
+	// Corrupted images fail to decode rather than returning a color
+	if _, err := p.FirstColor(ts.URL); err == nil {
+		t.Errorf("expected corrupted image to fail")
+	}
+}
@@ -0,0 +1,224 @@
+package wikimgtest
+
+import (
+	"encoding/json"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+// imagePath is the path fixture images are served under
+const imagePath = "/images/"
+
+// Source is a fake Commons API. It returns the image URLs added to it, a page
+// at a time, using continue values like the real API. The first URL added is
+// the most recent upload. While blocked, its
+// responses hang until it is unblocked or the client gives up.
+//
+// It also serves fixture images added with AddImage, so tests that pull
+// and analyze images don't need the network:
+//
+//	s := wikimgtest.NewSource(10)
+//	defer s.Close()
+//	s.AddImage("red", color.RGBA{0xff, 0x00, 0x00, 0xff})
+//
+//	info, err := p.FirstColor(s.ImageURL("red"))
+type Source struct {
+	*httptest.Server
+
+	urls     []string
+	images   map[string][]byte
+	epoch    time.Time
+	pageSize int
+	requests int
+	blocked  chan struct{}
+	chaos    *Chaos
+	mutex    sync.Mutex
+}
+
+// NewSource starts a Source that returns at most pageSize URLs per request.
+// Call Close() when finished.
+func NewSource(pageSize int) *Source {
+	s := &Source{
+		pageSize: pageSize,
+		epoch:    time.Now().UTC().Truncate(time.Second),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
+
+// Add queues image URLs to be returned
+func (s *Source) Add(urls ...string) {
+	s.mutex.Lock()
+	s.urls = append(s.urls, urls...)
+	s.mutex.Unlock()
+}
+
+// AddImage queues the URL of a fixture image called name, a small PNG
+// that is entirely c, which the Source serves itself. It returns the URL.
+func (s *Source) AddImage(name string, c color.Color) string {
+	u := s.ImageURL(name)
+
+	s.mutex.Lock()
+	if s.images == nil {
+		s.images = map[string][]byte{}
+	}
+	s.images[name] = encodePNG(c)
+	s.urls = append(s.urls, u)
+	s.mutex.Unlock()
+
+	return u
+}
+
+// ImageURL returns the URL of the fixture image called name
+func (s *Source) ImageURL(name string) string {
+	return s.URL + imagePath + name + ".png"
+}
+
+// Block makes requests hang until Unblock() is called
+func (s *Source) Block() {
+	s.mutex.Lock()
+	if s.blocked == nil {
+		s.blocked = make(chan struct{})
+	}
+	s.mutex.Unlock()
+}
+
+// Unblock lets blocked and future requests complete
+func (s *Source) Unblock() {
+	s.mutex.Lock()
+	if s.blocked != nil {
+		close(s.blocked)
+		s.blocked = nil
+	}
+	s.mutex.Unlock()
+}
+
+// SetChaos injects the faults of c into API responses. A nil c turns
+// fault injection off.
+func (s *Source) SetChaos(c *Chaos) {
+	s.mutex.Lock()
+	s.chaos = c
+	s.mutex.Unlock()
+}
+
+// Requests returns the number of API requests received so far
+func (s *Source) Requests() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.requests
+}
+
+// Close unblocks any requests and shuts down the server
+func (s *Source) Close() {
+	s.Unblock()
+	s.Server.Close()
+}
+
+// Puller creates a wikimg.Puller that pulls at most max images from the
+// Source
+func (s *Source) Puller(max int) *wikimg.Puller {
+	p := wikimg.NewPuller(max)
+	p.APIURL = s.URL
+
+	return p
+}
+
+// handle serves a request, injecting faults into API responses if chaos
+// is set
+func (s *Source) handle(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, imagePath) {
+		s.serveImage(w, r)
+		return
+	}
+
+	s.mutex.Lock()
+	chaos := s.chaos
+	s.mutex.Unlock()
+
+	if chaos != nil {
+		chaos.Handler(http.HandlerFunc(s.serve)).ServeHTTP(w, r)
+		return
+	}
+
+	s.serve(w, r)
+}
+
+// serveImage serves a fixture image
+func (s *Source) serveImage(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, imagePath), ".png")
+
+	s.mutex.Lock()
+	b, ok := s.images[name]
+	s.mutex.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(b)
+}
+
+// serve returns a page of results in the same shape as the allimages API
+func (s *Source) serve(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.requests++
+	blocked := s.blocked
+	s.mutex.Unlock()
+
+	if blocked != nil {
+		select {
+		case <-blocked:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	// aicontinue is our offset into the queue
+	offset, _ := strconv.Atoi(r.FormValue("aicontinue"))
+	limit, err := strconv.Atoi(r.FormValue("ailimit"))
+	if err != nil || limit > s.pageSize {
+		limit = s.pageSize
+	}
+
+	s.mutex.Lock()
+	end := min(offset+limit, len(s.urls))
+	page := s.urls[min(offset, end):end]
+	more := end < len(s.urls)
+	s.mutex.Unlock()
+
+	resp := map[string]interface{}{}
+
+	// Like the real API, the most recent upload is first. Each image is
+	// uploaded a minute before the previous one.
+	images := make([]map[string]string, len(page))
+	for i, u := range page {
+		images[i] = map[string]string{
+			"url":       u,
+			"title":     "File:" + path.Base(u),
+			"timestamp": s.epoch.Add(-time.Duration(offset+i) * time.Minute).Format(time.RFC3339),
+		}
+	}
+	resp["query"] = map[string]interface{}{"allimages": images}
+
+	if more {
+		resp["continue"] = map[string]string{
+			"continue":   "-||",
+			"aicontinue": strconv.Itoa(end),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
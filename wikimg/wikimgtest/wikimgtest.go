@@ -0,0 +1,31 @@
+// Package wikimgtest provides fake servers for testing code that uses
+// wikimg, in particular how it handles cancellation and timeouts. A
+// BlockingServer simulates stalled image downloads and a Source simulates the
+// Commons API, under the test's control, along with the images it lists. Chaos injects random faults into
+// either side of a connection.
+package wikimgtest
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// pngImage is a small red PNG served as the image for every URL
+var pngImage = encodePNG(color.RGBA{0xff, 0x00, 0x00, 0xff})
+
+// encodePNG encodes a 4x4 PNG that is entirely c
+func encodePNG(c color.Color) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			img.Set(x, y, c)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	png.Encode(buf, img)
+
+	return buf.Bytes()
+}
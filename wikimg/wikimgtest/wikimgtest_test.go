@@ -0,0 +1,149 @@
+package wikimgtest
+
+import (
+	"image/color"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/brnstz/routine/wikimg"
+)
+
+func TestSource(t *testing.T) {
+	s := NewSource(3)
+	defer s.Close()
+
+	for i := 0; i < 10; i++ {
+		s.Add("http://example.com/" + strconv.Itoa(i) + ".png")
+	}
+
+	p := s.Puller(8)
+
+	var urls []string
+	var last time.Time
+	for {
+		img, err := p.NextInfo()
+		if err == wikimg.EndOfResults {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		if !last.IsZero() && !img.Uploaded.Before(last) {
+			t.Errorf("%s: expected upload before %v but got %v", img.URL, last, img.Uploaded)
+		}
+		last = img.Uploaded
+
+		urls = append(urls, img.URL)
+	}
+
+	if len(urls) != 8 || urls[0] != "http://example.com/0.png" || urls[7] != "http://example.com/7.png" {
+		t.Errorf("unexpected urls %v", urls)
+	}
+
+	if n := s.Requests(); n != 3 {
+		t.Errorf("expected 3 requests but got %d", n)
+	}
+}
+
+func TestBlockingServerCancel(t *testing.T) {
+	s := NewBlockingServer()
+	defer s.Close()
+
+	cancel := make(chan struct{})
+	p := wikimg.NewPuller(1)
+	p.Cancel = cancel
+
+	errs := make(chan error)
+	go func() {
+		_, err := p.FirstColor(s.ImageURL("stalled"))
+		errs <- err
+	}()
+
+	// Wait until the request is stuck, then cancel it
+	<-s.Started()
+	close(cancel)
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("expected an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("FirstColor was not canceled")
+	}
+}
+
+func TestBlockingServerRelease(t *testing.T) {
+	s := NewBlockingServer()
+	defer s.Close()
+
+	s.Release()
+
+	info, err := wikimg.NewPuller(1).FirstColor(s.ImageURL("red"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Hex != "#ff0000" {
+		t.Errorf("expected #ff0000 but got %s", info.Hex)
+	}
+}
+
+func TestSourceTitles(t *testing.T) {
+	s := NewSource(10)
+	defer s.Close()
+
+	s.Add("http://example.com/a.png")
+
+	p := s.Puller(1)
+	p.Titles = wikimg.NewTitleIndex()
+
+	img, err := p.NextInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if title, ok := p.Titles.Lookup(img.URL); !ok || title != "File:a.png" {
+		t.Errorf("expected title to be recorded but got %q, %v", title, ok)
+	}
+}
+
+func TestSourceImages(t *testing.T) {
+	s := NewSource(1)
+	defer s.Close()
+
+	s.AddImage("red", color.RGBA{0xff, 0x00, 0x00, 0xff})
+	s.AddImage("blue", color.RGBA{0x00, 0x00, 0xff, 0xff})
+
+	p := s.Puller(10)
+
+	var hexes []string
+	for {
+		u, err := p.Next()
+		if err == wikimg.EndOfResults {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := p.FirstColor(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hexes = append(hexes, info.Hex)
+	}
+
+	if len(hexes) != 2 || hexes[0] != "#ff0000" || hexes[1] != "#0000ff" {
+		t.Errorf("expected red then blue but got %v", hexes)
+	}
+
+	// Only API requests are counted
+	if n := s.Requests(); n != 2 {
+		t.Errorf("expected 2 requests but got %d", n)
+	}
+
+	if _, err := p.FirstColor(s.ImageURL("missing")); err == nil {
+		t.Error("expected a missing image to fail")
+	}
+}